@@ -30,6 +30,14 @@ func main() {
 		panic(err)
 	}
 
+	if len(os.Args) > 1 {
+		if err := runCommand(cfg, os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	gob.Register([]models.Message{})
 	gob.Register(&models.ErrorMessage{})
 	gob.Register(&models.InfoMessage{})
@@ -86,3 +94,14 @@ func main() {
 	<-shutdownComplete
 	slog.Info("Shutdown complete")
 }
+
+func runCommand(cfg *config.Config, args []string) error {
+	switch args[0] {
+	case "maintenance":
+		return runMaintenance(cfg, args[1:])
+	case "webhook-sink":
+		return runWebhookSink(cfg, args[1:])
+	default:
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/mysql"
+	"github.com/RealistikOsu/soumetsu/internal/config"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/repositories"
+	"github.com/RealistikOsu/soumetsu/internal/services/settings"
+)
+
+const maintenanceUsage = `usage: soumetsu maintenance <status|on|off> [flags]
+
+  on flags:
+    -start   RFC3339 time the window begins (default: now)
+    -end     RFC3339 time the window ends (default: open-ended)
+    -message message shown on the maintenance page and banner`
+
+// runMaintenance toggles website maintenance without the web process running,
+// for use around database migrations.
+func runMaintenance(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(maintenanceUsage)
+	}
+
+	db, err := mysql.New(cfg.Database)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	svc := settings.NewService(repositories.NewSystemRepository(db))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	switch args[0] {
+	case "status":
+		m, err := svc.GetMaintenance(ctx)
+		if err != nil {
+			return err
+		}
+		printMaintenance(m)
+		return nil
+	case "on":
+		m, err := parseMaintenanceFlags(args[1:])
+		if err != nil {
+			return err
+		}
+		if err := svc.SetMaintenance(ctx, m); err != nil {
+			return err
+		}
+		printMaintenance(m)
+		return nil
+	case "off":
+		if err := svc.SetMaintenance(ctx, models.Maintenance{}); err != nil {
+			return err
+		}
+		fmt.Println("maintenance disabled")
+		return nil
+	default:
+		return errors.New(maintenanceUsage)
+	}
+}
+
+func parseMaintenanceFlags(args []string) (models.Maintenance, error) {
+	fs := flag.NewFlagSet("maintenance on", flag.ContinueOnError)
+	start := fs.String("start", "", "")
+	end := fs.String("end", "", "")
+	message := fs.String("message", "", "")
+	if err := fs.Parse(args); err != nil {
+		return models.Maintenance{}, err
+	}
+
+	var m models.Maintenance
+	m.Message = *message
+
+	if *start == "" {
+		m.Enabled = true
+	} else {
+		t, err := time.Parse(time.RFC3339, *start)
+		if err != nil {
+			return m, fmt.Errorf("invalid -start: %w", err)
+		}
+		m.StartsAt = t
+	}
+
+	if *end != "" {
+		t, err := time.Parse(time.RFC3339, *end)
+		if err != nil {
+			return m, fmt.Errorf("invalid -end: %w", err)
+		}
+		m.EndsAt = t
+	}
+
+	return m, nil
+}
+
+func printMaintenance(m models.Maintenance) {
+	now := time.Now()
+	state := "off"
+	switch {
+	case m.ActiveAt(now):
+		state = "active"
+	case m.UpcomingAt(now):
+		state = "scheduled"
+	}
+
+	fmt.Println("state:  ", state)
+	if !m.StartsAt.IsZero() {
+		fmt.Println("start:  ", m.StartsAt.Format(time.RFC3339))
+	}
+	if !m.EndsAt.IsZero() {
+		fmt.Println("end:    ", m.EndsAt.Format(time.RFC3339))
+	}
+	if m.Message != "" {
+		fmt.Println("message:", m.Message)
+	}
+}
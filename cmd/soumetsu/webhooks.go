@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/RealistikOsu/soumetsu/internal/config"
+	"github.com/RealistikOsu/soumetsu/internal/services/webhooks"
+)
+
+const webhookSinkUsage = `usage: soumetsu webhook-sink [addr]
+
+  Listens on addr (default :9010) and prints every webhook delivered to it,
+  checking signatures against SOUMETSU_WEBHOOK_SECRET. Route an event to
+  http://localhost:9010/ on /admin/webhooks to try it out.
+  Append ?fail=503 to the route to see retries.`
+
+// runWebhookSink is a stand-in receiver for testing webhooks locally.
+func runWebhookSink(cfg *config.Config, args []string) error {
+	addr := ":9010"
+	switch len(args) {
+	case 0:
+	case 1:
+		addr = args[0]
+	default:
+		return errors.New(webhookSinkUsage)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		fmt.Printf("--- %s %s\n", r.Method, r.URL)
+		for _, h := range []string{"Content-Type", webhooks.HeaderEvent, webhooks.HeaderDelivery, webhooks.HeaderTimestamp, webhooks.HeaderSignature} {
+			if v := r.Header.Get(h); v != "" {
+				fmt.Printf("%s: %s\n", h, v)
+			}
+		}
+		if signature := r.Header.Get(webhooks.HeaderSignature); signature != "" {
+			timestamp, _ := strconv.ParseInt(r.Header.Get(webhooks.HeaderTimestamp), 10, 64)
+			fmt.Printf("signature valid: %t\n", webhooks.Verify(cfg.Security.WebhookSecret, timestamp, body, signature))
+		}
+
+		var pretty bytes.Buffer
+		if json.Indent(&pretty, body, "", "  ") == nil {
+			body = pretty.Bytes()
+		}
+		fmt.Printf("%s\n\n", body)
+
+		if code, err := strconv.Atoi(r.URL.Query().Get("fail")); err == nil && code >= 400 && code < 600 {
+			w.WriteHeader(code)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	fmt.Fprintf(os.Stderr, "listening for webhooks on %s\n", addr)
+	return http.ListenAndServe(addr, handler)
+}
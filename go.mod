@@ -5,6 +5,7 @@ go 1.21
 toolchain go1.22.5
 
 require (
+	github.com/alicebob/miniredis/v2 v2.33.0
 	github.com/dustin/go-humanize v1.0.1
 	github.com/frustra/bbcode v0.0.0-20150429195712-e3d2906cb269
 	github.com/go-chi/chi/v5 v5.1.0
@@ -20,6 +21,7 @@ require (
 )
 
 require (
+	github.com/alicebob/gopher-json v0.0.0-20200520072559-a9ecdc9d1d3a // indirect
 	github.com/boj/redistore v0.0.0-20160128113310-fc113767cd6b
 	github.com/garyburd/redigo v1.6.4 // indirect
 	github.com/gorilla/context v0.0.0-20160226214623-1ea25387ff6f // indirect
@@ -30,5 +32,6 @@ require (
 	github.com/onsi/ginkgo v1.16.5 // indirect
 	github.com/onsi/gomega v1.34.1 // indirect
 	github.com/shurcooL/sanitized_anchor_name v1.0.0 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
 	golang.org/x/net v0.27.0 // indirect
 )
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"net/url"
 	"strconv"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/beatmapcache"
+	"github.com/RealistikOsu/soumetsu/internal/models"
 )
 
 type Beatmap struct {
@@ -26,31 +29,45 @@ type Beatmap struct {
 	MapperID      int     `json:"mapper_id"`
 }
 
+func (b *Beatmap) cacheEntry() beatmapcache.Entry {
+	return beatmapcache.Entry{Status: models.StatusFromServer(b.Ranked), MD5s: []string{b.BeatmapMD5}, BeatmapID: b.BeatmapID}
+}
+
 func (c *Client) GetBeatmap(ctx context.Context, beatmapID int) (*Beatmap, error) {
-	path := fmt.Sprintf("/api/v2/beatmaps/%d", beatmapID)
-	resp, err := c.Get(ctx, path, "")
-	if err != nil {
-		return nil, err
-	}
-	return decodeResponse[Beatmap](resp)
+	return beatmapcache.Get(ctx, c.beatmaps, beatmapcache.APIBeatmapKey(beatmapID), func(ctx context.Context) (*Beatmap, error) {
+		path := fmt.Sprintf("/api/v2/beatmaps/%d", beatmapID)
+		resp, err := c.Get(ctx, path, "")
+		if err != nil {
+			return nil, err
+		}
+		return decodeResponse[Beatmap](resp)
+	}, (*Beatmap).cacheEntry)
 }
 
 func (c *Client) GetBeatmapByMD5(ctx context.Context, md5 string) (*Beatmap, error) {
-	params := url.Values{}
-	params.Set("md5", md5)
-	path := "/api/v2/beatmaps/lookup?" + params.Encode()
-	resp, err := c.Get(ctx, path, "")
-	if err != nil {
-		return nil, err
-	}
-	return decodeResponse[Beatmap](resp)
+	return beatmapcache.Get(ctx, c.beatmaps, beatmapcache.APIBeatmapMD5Key(md5), func(ctx context.Context) (*Beatmap, error) {
+		params := url.Values{}
+		params.Set("md5", md5)
+		path := "/api/v2/beatmaps/lookup?" + params.Encode()
+		resp, err := c.Get(ctx, path, "")
+		if err != nil {
+			return nil, err
+		}
+		return decodeResponse[Beatmap](resp)
+	}, (*Beatmap).cacheEntry)
 }
 
+// SearchBeatmaps searches the server's beatmaps. A negative mode or ranked
+// status matches any.
 func (c *Client) SearchBeatmaps(ctx context.Context, query string, mode, ranked, page, limit int) ([]Beatmap, error) {
 	params := url.Values{}
 	params.Set("q", query)
-	params.Set("mode", strconv.Itoa(mode))
-	params.Set("ranked", strconv.Itoa(ranked))
+	if mode >= 0 {
+		params.Set("mode", strconv.Itoa(mode))
+	}
+	if ranked >= 0 {
+		params.Set("ranked", strconv.Itoa(ranked))
+	}
 	params.Set("page", strconv.Itoa(page))
 	params.Set("limit", strconv.Itoa(limit))
 	path := "/api/v2/beatmaps?" + params.Encode()
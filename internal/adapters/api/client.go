@@ -10,16 +10,21 @@ import (
 	"net/http"
 	"net/url"
 	"time"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/beatmapcache"
 )
 
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	// beatmaps caches beatmap lookups. It may be nil.
+	beatmaps *beatmapcache.Cache
 }
 
-func New(baseURL string) *Client {
+func New(baseURL string, beatmaps *beatmapcache.Cache) *Client {
 	return &Client{
-		baseURL: baseURL,
+		baseURL:  baseURL,
+		beatmaps: beatmaps,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
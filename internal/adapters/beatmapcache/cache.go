@@ -0,0 +1,255 @@
+package beatmapcache
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/redis"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/pkg/flight"
+)
+
+const (
+	keyPrefix = "soumetsu:beatmaps:"
+
+	// Ranked, approved and loved maps practically never change. Graveyarded
+	// ones rarely do; anything else may be updated or re-ranked at any time.
+	settledTTL   = 24 * time.Hour
+	graveyardTTL = time.Hour
+	defaultTTL   = 10 * time.Minute
+
+	// loadTimeout bounds a shared load, which outlives any one caller.
+	loadTimeout = 30 * time.Second
+
+	// refreshChannel is where the score server is told a beatmap changed,
+	// as ranking.Service does after a status change.
+	refreshChannel = "ussr:refresh_bmap"
+)
+
+// Entry describes a loaded value: its ranked status decides how long it's
+// kept, and a refresh of any of its MD5s evicts it.
+type Entry struct {
+	Status int
+	MD5s   []string
+	// BeatmapID is set for values about one difficulty. A refresh of its
+	// MD5s then also evicts everything else cached for the difficulty, such
+	// as attributes calculated from an older version of its file.
+	BeatmapID int
+	// TTL, if set, is how long the value is kept regardless of Status.
+	TTL time.Duration
+}
+
+// Cache is a read-through cache of beatmap lookups in Redis, shared by every
+// instance. Entries are evicted when a beatmap refresh is published.
+type Cache struct {
+	redis *redis.Client
+	group flight.Group[[]byte]
+
+	stopOnce sync.Once
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func New(redisClient *redis.Client) *Cache {
+	return &Cache{
+		redis: redisClient,
+		done:  make(chan struct{}),
+	}
+}
+
+func SetKey(setID int) string {
+	return keyPrefix + "set:" + strconv.Itoa(setID)
+}
+
+func BeatmapKey(beatmapID int) string {
+	return keyPrefix + "map:" + strconv.Itoa(beatmapID)
+}
+
+// APIBeatmapKey and APIBeatmapMD5Key hold the API's view of a beatmap, which
+// carries this server's status rather than the mirror's.
+func APIBeatmapKey(beatmapID int) string {
+	return keyPrefix + "api:" + strconv.Itoa(beatmapID)
+}
+
+func APIBeatmapMD5Key(md5 string) string {
+	return keyPrefix + "api:md5:" + md5
+}
+
+// SearchKey holds the results of a search, identified by a hash of its
+// parameters.
+func SearchKey(hash string) string {
+	return keyPrefix + "search:" + hash
+}
+
+// DifficultyKey holds a difficulty's calculated attributes with the given
+// mods, written as acronyms.
+func DifficultyKey(beatmapID int, mods string) string {
+	return keyPrefix + "difficulty:" + strconv.Itoa(beatmapID) + ":" + mods
+}
+
+func md5Key(md5 string) string {
+	return keyPrefix + "md5:" + md5
+}
+
+const idKeyPrefix = keyPrefix + "id:"
+
+func idKey(beatmapID int) string {
+	return idKeyPrefix + strconv.Itoa(beatmapID)
+}
+
+// ttl is how long a value with the given ranked status is cached for.
+func ttl(status int) time.Duration {
+	switch status {
+	case models.StatusRanked, models.StatusApproved, models.StatusLoved:
+		return settledTTL
+	case models.StatusGraveyard:
+		return graveyardTTL
+	}
+	return defaultTTL
+}
+
+// Get returns the value cached under key, or loads and caches it. Concurrent
+// misses on one key share a single load. Errors aren't cached, and Redis
+// being down only means every call loads. A nil Cache always loads.
+func Get[T any](ctx context.Context, c *Cache, key string, load func(context.Context) (*T, error), describe func(*T) Entry) (*T, error) {
+	if c == nil {
+		return load(ctx)
+	}
+	if v, ok := read[T](ctx, c, key); ok {
+		return v, nil
+	}
+
+	data, err := c.group.Do(ctx, key, func(ctx context.Context) ([]byte, error) {
+		ctx, cancel := context.WithTimeout(ctx, loadTimeout)
+		defer cancel()
+
+		v, err := load(ctx)
+		if err != nil || v == nil {
+			return nil, err
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		c.store(ctx, key, data, describe(v))
+		return data, nil
+	})
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	// Every caller decodes its own copy, so none can change what another
+	// sees.
+	v := new(T)
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Peek returns the value cached under key without loading it on a miss.
+func Peek[T any](ctx context.Context, c *Cache, key string) (*T, bool) {
+	if c == nil {
+		return nil, false
+	}
+	return read[T](ctx, c, key)
+}
+
+func read[T any](ctx context.Context, c *Cache, key string) (*T, bool) {
+	data, err := c.redis.Get(ctx, key)
+	if err != nil {
+		if err != redis.Nil {
+			slog.Warn("failed to read beatmap cache", "error", err, "key", key)
+		}
+		return nil, false
+	}
+	v := new(T)
+	if err := json.Unmarshal([]byte(data), v); err != nil {
+		slog.Warn("discarding unreadable beatmap cache entry", "error", err, "key", key)
+		return nil, false
+	}
+	return v, true
+}
+
+func (c *Cache) store(ctx context.Context, key string, data []byte, entry Entry) {
+	expiration := entry.TTL
+	if expiration == 0 {
+		expiration = ttl(entry.Status)
+	}
+	if err := c.redis.Set(ctx, key, data, expiration); err != nil {
+		slog.Warn("failed to write beatmap cache", "error", err, "key", key)
+		return
+	}
+	// Each MD5 remembers the keys holding it, and the difficulty it belongs
+	// to, whose own index remembers every key holding that.
+	if entry.BeatmapID != 0 {
+		c.index(ctx, idKey(entry.BeatmapID), key)
+	}
+	for _, md5 := range entry.MD5s {
+		if md5 == "" {
+			continue
+		}
+		c.index(ctx, md5Key(md5), key)
+		if entry.BeatmapID != 0 {
+			c.index(ctx, md5Key(md5), idKey(entry.BeatmapID))
+		}
+	}
+}
+
+// index adds member to the set at indexKey. The index outlives every entry
+// so it's never gone before they are.
+func (c *Cache) index(ctx context.Context, indexKey, member string) {
+	if err := c.redis.SAdd(ctx, indexKey, member); err != nil {
+		slog.Warn("failed to index beatmap cache entry", "error", err, "key", member)
+		return
+	}
+	if err := c.redis.Expire(ctx, indexKey, settledTTL); err != nil {
+		slog.Warn("failed to index beatmap cache entry", "error", err, "key", member)
+	}
+}
+
+// Invalidate evicts every entry holding the beatmap with the given MD5, and
+// everything cached for the difficulties it belongs to.
+func (c *Cache) Invalidate(ctx context.Context, md5 string) error {
+	keys, err := c.redis.SMembers(ctx, md5Key(md5))
+	if err != nil {
+		return err
+	}
+	evict := append(keys, md5Key(md5))
+	for _, key := range keys {
+		if !strings.HasPrefix(key, idKeyPrefix) {
+			continue
+		}
+		held, err := c.redis.SMembers(ctx, key)
+		if err != nil {
+			return err
+		}
+		evict = append(evict, held...)
+	}
+	return c.redis.Del(ctx, evict...)
+}
+
+// Start listens for beatmap refreshes until Stop is called.
+func (c *Cache) Start() {
+	c.wg.Add(1)
+	go c.listen()
+}
+
+func (c *Cache) Stop() {
+	c.stopOnce.Do(func() { close(c.done) })
+	c.wg.Wait()
+}
+
+func (c *Cache) listen() {
+	defer c.wg.Done()
+	c.redis.Listen(c.done, "beatmap refresh", func(msg *redis.Message) {
+		if err := c.Invalidate(context.Background(), msg.Payload); err != nil {
+			slog.Error("failed to invalidate beatmap cache", "error", err, "beatmap_md5", msg.Payload)
+		}
+	}, refreshChannel)
+}
@@ -0,0 +1,90 @@
+package beatmapcache
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/redis"
+	"github.com/RealistikOsu/soumetsu/internal/config"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+)
+
+func newTestCache(t *testing.T) (*Cache, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	port, err := strconv.Atoi(mr.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := redis.New(config.RedisConfig{Host: mr.Host(), Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return New(client), mr
+}
+
+type value struct {
+	N int
+}
+
+// put caches n under key, described by entry.
+func put(t *testing.T, c *Cache, key string, n int, entry Entry) {
+	t.Helper()
+	_, err := Get(context.Background(), c, key, func(context.Context) (*value, error) {
+		return &value{N: n}, nil
+	}, func(*value) Entry { return entry })
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	c, mr := newTestCache(t)
+	ctx := context.Background()
+
+	// Attributes calculated from the old file, then the difficulty as it is
+	// now, and another difficulty of the same set.
+	put(t, c, DifficultyKey(1, "NM"), 1, Entry{Status: models.StatusRanked, MD5s: []string{"old"}, BeatmapID: 1})
+	put(t, c, BeatmapKey(1), 2, Entry{MD5s: []string{"new"}, BeatmapID: 1})
+	put(t, c, SetKey(10), 3, Entry{Status: models.StatusRanked, MD5s: []string{"new", "other"}})
+	put(t, c, BeatmapKey(2), 4, Entry{MD5s: []string{"other"}, BeatmapID: 2})
+	put(t, c, DifficultyKey(2, "NM"), 5, Entry{Status: models.StatusRanked, MD5s: []string{"other"}, BeatmapID: 2})
+
+	if err := c.Invalidate(ctx, "new"); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]bool{
+		DifficultyKey(1, "NM"): false,
+		BeatmapKey(1):          false,
+		SetKey(10):             false,
+		BeatmapKey(2):          true,
+		DifficultyKey(2, "NM"): true,
+	} {
+		if got := mr.Exists(key); got != want {
+			t.Errorf("%s cached = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestEntryLifetime(t *testing.T) {
+	c, mr := newTestCache(t)
+
+	put(t, c, SetKey(1), 1, Entry{Status: models.StatusRanked})
+	put(t, c, SetKey(2), 2, Entry{Status: models.StatusPending})
+	put(t, c, SearchKey("abc"), 3, Entry{Status: models.StatusRanked, TTL: time.Minute})
+
+	for key, want := range map[string]time.Duration{
+		SetKey(1):        settledTTL,
+		SetKey(2):        defaultTTL,
+		SearchKey("abc"): time.Minute,
+	} {
+		if got := mr.TTL(key); got != want {
+			t.Errorf("%s TTL = %v, want %v", key, got, want)
+		}
+	}
+}
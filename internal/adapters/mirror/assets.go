@@ -0,0 +1,33 @@
+package mirror
+
+import (
+	"context"
+	"strconv"
+)
+
+// maxAssetSize is well above any set's artwork or preview.
+const maxAssetSize = 8 << 20
+
+// Assets fetches beatmap set artwork from servers laid out like
+// assets.ppy.sh/beatmaps, as <base>/<set ID>/covers/<name>, and audio
+// previews from ones like b.ppy.sh/preview, as <base>/<set ID>.mp3.
+type Assets struct {
+	covers   *fileSource
+	previews *fileSource
+}
+
+func NewAssets(coverURLs, previewURLs []string) *Assets {
+	return &Assets{
+		covers:   newFileSource("beatmap cover", coverURLs, maxAssetSize),
+		previews: newFileSource("beatmap preview", previewURLs, maxAssetSize),
+	}
+}
+
+// Cover fetches one of the set's cover images, such as "cover@2x.jpg".
+func (a *Assets) Cover(ctx context.Context, setID int, name string) ([]byte, error) {
+	return a.covers.get(ctx, "/"+strconv.Itoa(setID)+"/covers/"+name)
+}
+
+func (a *Assets) Preview(ctx context.Context, setID int) ([]byte, error) {
+	return a.previews.get(ctx, "/"+strconv.Itoa(setID)+".mp3")
+}
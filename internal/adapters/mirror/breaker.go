@@ -0,0 +1,73 @@
+package mirror
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// breakerThreshold consecutive failures open a mirror's breaker, which
+	// skips it for breakerCooldown. After that one trial request is let
+	// through; if it fails the breaker opens again.
+	breakerThreshold = 5
+	breakerCooldown  = 30 * time.Second
+)
+
+type mirror struct {
+	baseURL string
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	// probing is set while the trial request after a cooldown is in flight.
+	probing bool
+}
+
+// allow reports whether a request may go to the mirror.
+func (m *mirror) allow() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.failures < breakerThreshold {
+		return true
+	}
+	if time.Now().Before(m.openUntil) || m.probing {
+		return false
+	}
+	m.probing = true
+	return true
+}
+
+func (m *mirror) succeeded() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.failures >= breakerThreshold {
+		slog.Info("beatmap mirror recovered", "mirror", m.baseURL)
+	}
+	m.failures = 0
+	m.probing = false
+}
+
+func (m *mirror) failed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.failures++
+	m.probing = false
+	if m.failures >= breakerThreshold {
+		if m.failures == breakerThreshold {
+			slog.Warn("beatmap mirror breaker opened", "mirror", m.baseURL)
+		}
+		m.openUntil = time.Now().Add(breakerCooldown)
+	}
+}
+
+// abandoned is for a request cut short by its caller, which counts as
+// neither a success nor a failure.
+func (m *mirror) abandoned() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.probing = false
+}
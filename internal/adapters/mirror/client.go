@@ -0,0 +1,166 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RealistikOsu/soumetsu/internal/models"
+)
+
+const (
+	// requestTimeout bounds one request to one mirror; the caller's context
+	// bounds the whole lookup.
+	requestTimeout = 5 * time.Second
+	// rounds is how many times every mirror is tried before giving up.
+	rounds      = 2
+	baseBackoff = 200 * time.Millisecond
+
+	maxBodySize = 4 << 20
+)
+
+// NotFoundError means a mirror answered that the set or beatmap doesn't
+// exist.
+type NotFoundError struct {
+	Path string
+}
+
+func (e *NotFoundError) Error() string {
+	return "mirror: " + e.Path + " not found"
+}
+
+// UnavailableError means no mirror gave a usable answer. Err is the last
+// failure.
+type UnavailableError struct {
+	Err error
+}
+
+func (e *UnavailableError) Error() string {
+	if e.Err == nil {
+		return "mirror: every mirror is unavailable"
+	}
+	return "mirror: unavailable: " + e.Err.Error()
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// statusError is a response that isn't worth decoding.
+type statusError struct {
+	mirror string
+	status int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s answered %d", e.mirror, e.status)
+}
+
+// Client looks beatmaps up on a list of cheesegull-style mirrors, in order,
+// moving on to the next when one fails or its breaker is open.
+type Client struct {
+	mirrors    []*mirror
+	httpClient *http.Client
+}
+
+func New(baseURLs []string) *Client {
+	return &Client{
+		mirrors:    newMirrors(baseURLs),
+		httpClient: &http.Client{},
+	}
+}
+
+func (c *Client) GetBeatmapSet(ctx context.Context, setID int) (*models.BeatmapSet, error) {
+	path := "/s/" + strconv.Itoa(setID)
+	set, err := get[models.BeatmapSet](ctx, c, path)
+	if err != nil {
+		return nil, err
+	}
+	if set.ID == 0 {
+		return nil, &NotFoundError{Path: path}
+	}
+	return set, nil
+}
+
+func (c *Client) GetBeatmap(ctx context.Context, beatmapID int) (*models.Beatmap, error) {
+	path := "/b/" + strconv.Itoa(beatmapID)
+	beatmap, err := get[models.Beatmap](ctx, c, path)
+	if err != nil {
+		return nil, err
+	}
+	if beatmap.ID == 0 {
+		return nil, &NotFoundError{Path: path}
+	}
+	return beatmap, nil
+}
+
+// get decodes path from the first mirror that answers it. Not-found answers
+// are final; anything else moves on to the next mirror, and the whole list is
+// retried.
+func get[T any](ctx context.Context, c *Client, path string) (*T, error) {
+	f := failover{what: "beatmap mirror", path: path, rounds: rounds, notFoundFinal: true}
+	return tryMirrors(ctx, c.mirrors, f, func(m *mirror) (*T, error) {
+		// Decode into a fresh value each time so a half-decoded answer
+		// from a broken mirror can't leak into the next one's.
+		v := new(T)
+		if err := c.fetch(ctx, m, path, v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	})
+}
+
+func (c *Client) fetch(ctx context.Context, m *mirror, path string, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return &NotFoundError{Path: path}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &statusError{mirror: m.baseURL, status: resp.StatusCode}
+	}
+
+	body, err := readLimited(resp.Body, maxBodySize)
+	if err != nil {
+		return fmt.Errorf("reading %s%s: %w", m.baseURL, path, err)
+	}
+	// Some mirrors answer a missing ID with 200 and null.
+	if trimmed := bytes.TrimSpace(body); len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return &NotFoundError{Path: path}
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("decoding %s%s: %w", m.baseURL, path, err)
+	}
+	return nil
+}
+
+// readLimited reads all of r, failing rather than cutting it short when it
+// holds more than limit bytes.
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("response is over %d bytes", limit)
+	}
+	return body, nil
+}
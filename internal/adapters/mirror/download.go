@@ -0,0 +1,92 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// downloadHeaderTimeout bounds the wait for a download mirror to start
+// answering. The transfer itself can take as long as the caller allows.
+const downloadHeaderTimeout = 15 * time.Second
+
+// Downloader fetches .osz files from a list of download mirrors, in order,
+// moving on to the next when one fails or its breaker is open.
+type Downloader struct {
+	mirrors    []*mirror
+	httpClient *http.Client
+}
+
+func NewDownloader(baseURLs []string) *Downloader {
+	return &Downloader{
+		mirrors: newMirrors(baseURLs),
+		httpClient: &http.Client{
+			Transport: &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				DialContext:           (&net.Dialer{Timeout: 10 * time.Second}).DialContext,
+				TLSHandshakeTimeout:   10 * time.Second,
+				ResponseHeaderTimeout: downloadHeaderTimeout,
+				MaxIdleConnsPerHost:   4,
+			},
+		},
+	}
+}
+
+// Download is a mirror's answer to a download request: the whole file, the
+// requested range of it, or 416 for a range past its end.
+type Download struct {
+	Status int
+	Header http.Header
+	Body   io.ReadCloser
+}
+
+// Download requests a set's .osz. rangeHeader, if set, is passed on so a
+// download can be resumed.
+func (d *Downloader) Download(ctx context.Context, setID int, noVideo bool, rangeHeader string) (*Download, error) {
+	path := "/" + strconv.Itoa(setID)
+	if noVideo {
+		path += "?noVideo=1"
+	}
+
+	f := failover{what: "beatmap download mirror", path: path}
+	return tryMirrors(ctx, d.mirrors, f, func(m *mirror) (*Download, error) {
+		return d.fetch(ctx, m, path, rangeHeader)
+	})
+}
+
+func (d *Downloader) fetch(ctx context.Context, m *mirror, path, rangeHeader string) (*Download, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent:
+		// Some mirrors answer errors with a page or a JSON body and a 200.
+		contentType := resp.Header.Get("Content-Type")
+		if strings.HasPrefix(contentType, "text/html") || strings.HasPrefix(contentType, "application/json") {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s answered %s instead of a beatmap", m.baseURL, contentType)
+		}
+		fallthrough
+	case http.StatusRequestedRangeNotSatisfiable:
+		return &Download{Status: resp.StatusCode, Header: resp.Header, Body: resp.Body}, nil
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &NotFoundError{Path: path}
+	}
+	return nil, &statusError{mirror: m.baseURL, status: resp.StatusCode}
+}
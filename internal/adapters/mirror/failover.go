@@ -0,0 +1,103 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// failover describes how a request is spread over a list of mirrors.
+type failover struct {
+	// what names the mirrors in logs, such as "beatmap mirror".
+	what string
+	path string
+	// rounds is how many times every mirror is tried before giving up. Zero
+	// means once.
+	rounds int
+	// notFoundFinal ends the search at the first not-found answer. Without
+	// it the other mirrors are asked too, since they don't all carry the
+	// same files, and NotFoundError is returned if none has it.
+	notFoundFinal bool
+}
+
+func newMirrors(baseURLs []string) []*mirror {
+	mirrors := make([]*mirror, 0, len(baseURLs))
+	for _, u := range baseURLs {
+		mirrors = append(mirrors, &mirror{baseURL: strings.TrimRight(u, "/")})
+	}
+	return mirrors
+}
+
+// tryMirrors calls attempt on each mirror whose breaker lets it through, in
+// order, until one answers. Between rounds it waits a jittered backoff. A
+// request cut short by ctx counts against no mirror.
+func tryMirrors[T any](ctx context.Context, mirrors []*mirror, f failover, attempt func(m *mirror) (T, error)) (T, error) {
+	var zero T
+	var lastErr error
+	notFound := false
+	for round := 0; round < max(f.rounds, 1); round++ {
+		if round > 0 {
+			if err := sleep(ctx, backoff(round)); err != nil {
+				return zero, &UnavailableError{Err: lastErr}
+			}
+		}
+
+		tried := false
+		for _, m := range mirrors {
+			if !m.allow() {
+				continue
+			}
+			tried = true
+			v, err := attempt(m)
+			if err == nil {
+				m.succeeded()
+				return v, nil
+			}
+			var notFoundErr *NotFoundError
+			if errors.As(err, &notFoundErr) {
+				m.succeeded()
+				if f.notFoundFinal {
+					return zero, err
+				}
+				notFound = true
+				continue
+			}
+			if ctx.Err() != nil {
+				// The caller gave up; that says nothing about the mirror.
+				m.abandoned()
+				return zero, &UnavailableError{Err: ctx.Err()}
+			}
+			m.failed()
+			slog.Warn(f.what+" request failed", "error", err, "mirror", m.baseURL, "path", f.path)
+			lastErr = err
+		}
+		if !tried || notFound {
+			break
+		}
+	}
+	if notFound {
+		return zero, &NotFoundError{Path: f.path}
+	}
+	return zero, &UnavailableError{Err: lastErr}
+}
+
+// backoff doubles per round, with up to as much again added at random so
+// instances don't retry in lockstep.
+func backoff(round int) time.Duration {
+	d := baseBackoff << (round - 1)
+	return d + time.Duration(rand.Int63n(int64(d)))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,291 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// calls records which fake mirror each request went to, in order.
+type calls struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (c *calls) add(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.names = append(c.names, name)
+}
+
+// take returns the calls made since the last take.
+func (c *calls) take() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := c.names
+	c.names = nil
+	return names
+}
+
+// fakeMirror answers every request with its current status, and with body
+// when that's 200. Once next is set, it takes over after the next request.
+type fakeMirror struct {
+	mu     sync.Mutex
+	status int
+	body   string
+	next   *fakeMirror
+}
+
+func (f *fakeMirror) set(status int, body string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status, f.body = status, body
+}
+
+func startMirror(t *testing.T, log *calls, name string, status int, body string) (*fakeMirror, string) {
+	t.Helper()
+	f := &fakeMirror{status: status, body: body}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.add(name)
+		f.mu.Lock()
+		status, body := f.status, f.body
+		if f.next != nil {
+			f.status, f.body, f.next = f.next.status, f.next.body, nil
+		}
+		f.mu.Unlock()
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			io.WriteString(w, body)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return f, srv.URL
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func download(t *testing.T, d *Downloader) (string, error) {
+	t.Helper()
+	dl, err := d.Download(context.Background(), 1, false, "")
+	if err != nil {
+		return "", err
+	}
+	defer dl.Body.Close()
+	body, err := io.ReadAll(dl.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body), nil
+}
+
+func (m *mirror) state() (failures int, open bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.failures, m.failures >= breakerThreshold && time.Now().Before(m.openUntil)
+}
+
+// endCooldown lets an open breaker's trial request through straight away.
+func (m *mirror) endCooldown() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.openUntil = time.Now()
+}
+
+func TestFailoverOrder(t *testing.T) {
+	log := &calls{}
+	_, a := startMirror(t, log, "a", http.StatusBadGateway, "")
+	_, b := startMirror(t, log, "b", http.StatusOK, "from b")
+	_, c := startMirror(t, log, "c", http.StatusOK, "from c")
+	d := NewDownloader([]string{a, b + "/", c})
+
+	body, err := download(t, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "from b" {
+		t.Errorf("body = %q, want from b", body)
+	}
+	if got := log.take(); !equal(got, []string{"a", "b"}) {
+		t.Errorf("calls = %v, want [a b]", got)
+	}
+	if failures, _ := d.mirrors[0].state(); failures != 1 {
+		t.Errorf("a has %d failures, want 1", failures)
+	}
+	if failures, _ := d.mirrors[1].state(); failures != 0 {
+		t.Errorf("b has %d failures, want 0", failures)
+	}
+}
+
+func TestFailoverRetriesRounds(t *testing.T) {
+	log := &calls{}
+	fa, a := startMirror(t, log, "a", http.StatusServiceUnavailable, "")
+	_, b := startMirror(t, log, "b", http.StatusServiceUnavailable, "")
+	c := New([]string{a, b})
+
+	_, err := c.GetBeatmapSet(context.Background(), 1)
+	var unavailable *UnavailableError
+	if !errors.As(err, &unavailable) {
+		t.Fatalf("err = %v, want UnavailableError", err)
+	}
+	var status *statusError
+	if !errors.As(err, &status) || status.status != http.StatusServiceUnavailable {
+		t.Errorf("err = %v, want the last mirror's 503", err)
+	}
+	if got := log.take(); !equal(got, []string{"a", "b", "a", "b"}) {
+		t.Errorf("calls = %v, want [a b a b]", got)
+	}
+
+	// A mirror that recovers by the next round answers it.
+	fa.mu.Lock()
+	fa.next = &fakeMirror{status: http.StatusOK, body: `{"SetID":1}`}
+	fa.mu.Unlock()
+	set, err := c.GetBeatmapSet(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.ID != 1 {
+		t.Errorf("set ID = %d, want 1", set.ID)
+	}
+	if got := log.take(); !equal(got, []string{"a", "b", "a"}) {
+		t.Errorf("calls = %v, want [a b a]", got)
+	}
+	if failures, _ := c.mirrors[0].state(); failures != 0 {
+		t.Errorf("a has %d failures after answering, want 0", failures)
+	}
+	if failures, _ := c.mirrors[1].state(); failures != 3 {
+		t.Errorf("b has %d failures, want 3", failures)
+	}
+}
+
+func TestNotFoundDoesNotTrip(t *testing.T) {
+	log := &calls{}
+	_, a := startMirror(t, log, "a", http.StatusNotFound, "")
+	_, b := startMirror(t, log, "b", http.StatusOK, `{"SetID":1}`)
+	c := New([]string{a, b})
+
+	// Lookups stop at the first not-found answer.
+	for i := 0; i < breakerThreshold+1; i++ {
+		_, err := c.GetBeatmapSet(context.Background(), 1)
+		var notFound *NotFoundError
+		if !errors.As(err, &notFound) {
+			t.Fatalf("err = %v, want NotFoundError", err)
+		}
+	}
+	if got := log.take(); len(got) != breakerThreshold+1 || got[len(got)-1] != "a" {
+		t.Errorf("calls = %v, want only a", got)
+	}
+	if failures, open := c.mirrors[0].state(); failures != 0 || open {
+		t.Errorf("a has %d failures, open %v; want 0, closed", failures, open)
+	}
+
+	// Downloads ask every mirror before giving up.
+	_, b404 := startMirror(t, log, "b", http.StatusNotFound, "")
+	d := NewDownloader([]string{a, b404})
+	_, err := download(t, d)
+	var notFound *NotFoundError
+	if !errors.As(err, &notFound) {
+		t.Fatalf("err = %v, want NotFoundError", err)
+	}
+	if got := log.take(); !equal(got, []string{"a", "b"}) {
+		t.Errorf("calls = %v, want [a b]", got)
+	}
+	for i, m := range d.mirrors {
+		if failures, _ := m.state(); failures != 0 {
+			t.Errorf("mirror %d has %d failures, want 0", i, failures)
+		}
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	log := &calls{}
+	fa, a := startMirror(t, log, "a", http.StatusInternalServerError, "")
+	_, b := startMirror(t, log, "b", http.StatusOK, "from b")
+	d := NewDownloader([]string{a, b})
+	ma := d.mirrors[0]
+
+	// Closed: every failure still goes to a first.
+	for i := 0; i < breakerThreshold; i++ {
+		if _, err := download(t, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := log.take(); len(got) != 2*breakerThreshold {
+		t.Errorf("calls = %v, want a then b %d times", got, breakerThreshold)
+	}
+	if failures, open := ma.state(); failures != breakerThreshold || !open {
+		t.Fatalf("a has %d failures, open %v; want %d, open", failures, open, breakerThreshold)
+	}
+
+	// Open: a is skipped.
+	if _, err := download(t, d); err != nil {
+		t.Fatal(err)
+	}
+	if got := log.take(); !equal(got, []string{"b"}) {
+		t.Errorf("calls while open = %v, want [b]", got)
+	}
+
+	// Half-open: one trial goes to a, and failing it opens the breaker again.
+	ma.endCooldown()
+	if _, err := download(t, d); err != nil {
+		t.Fatal(err)
+	}
+	if got := log.take(); !equal(got, []string{"a", "b"}) {
+		t.Errorf("calls after cooldown = %v, want [a b]", got)
+	}
+	if _, open := ma.state(); !open {
+		t.Error("breaker didn't reopen after a failed trial")
+	}
+	if _, err := download(t, d); err != nil {
+		t.Fatal(err)
+	}
+	if got := log.take(); !equal(got, []string{"b"}) {
+		t.Errorf("calls after failed trial = %v, want [b]", got)
+	}
+
+	// Only one trial is let through at a time.
+	ma.endCooldown()
+	if !ma.allow() {
+		t.Fatal("trial request wasn't allowed after the cooldown")
+	}
+	if ma.allow() {
+		t.Error("second request allowed while the trial is in flight")
+	}
+	ma.abandoned()
+
+	// A successful trial closes the breaker.
+	fa.set(http.StatusOK, "from a")
+	body, err := download(t, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "from a" {
+		t.Errorf("body = %q, want from a", body)
+	}
+	if got := log.take(); !equal(got, []string{"a"}) {
+		t.Errorf("calls for trial = %v, want [a]", got)
+	}
+	if failures, open := ma.state(); failures != 0 || open {
+		t.Errorf("a has %d failures, open %v; want 0, closed", failures, open)
+	}
+	if _, err := download(t, d); err != nil {
+		t.Fatal(err)
+	}
+	if got := log.take(); !equal(got, []string{"a"}) {
+		t.Errorf("calls after closing = %v, want [a]", got)
+	}
+}
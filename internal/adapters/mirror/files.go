@@ -0,0 +1,69 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// fileSource fetches small files whole from a list of servers, in order,
+// moving on to the next when one fails or its breaker is open.
+type fileSource struct {
+	what       string
+	mirrors    []*mirror
+	httpClient *http.Client
+	maxSize    int64
+}
+
+func newFileSource(what string, baseURLs []string, maxSize int64) *fileSource {
+	return &fileSource{
+		what:       what,
+		mirrors:    newMirrors(baseURLs),
+		httpClient: &http.Client{},
+		maxSize:    maxSize,
+	}
+}
+
+func (s *fileSource) get(ctx context.Context, path string) ([]byte, error) {
+	f := failover{what: s.what, path: path}
+	return tryMirrors(ctx, s.mirrors, f, func(m *mirror) ([]byte, error) {
+		return s.fetch(ctx, m, path)
+	})
+}
+
+func (s *fileSource) fetch(ctx context.Context, m *mirror, path string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &NotFoundError{Path: path}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &statusError{mirror: m.baseURL, status: resp.StatusCode}
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return nil, fmt.Errorf("%s answered a page instead of a %s", m.baseURL, s.what)
+	}
+
+	body, err := readLimited(resp.Body, s.maxSize)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s from %s: %w", s.what, m.baseURL, err)
+	}
+	// osu.ppy.sh answers an unknown ID with an empty 200.
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, &NotFoundError{Path: path}
+	}
+	return body, nil
+}
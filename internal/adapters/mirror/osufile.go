@@ -0,0 +1,23 @@
+package mirror
+
+import (
+	"context"
+	"strconv"
+)
+
+// maxOsuFileSize is well above any real .osu file.
+const maxOsuFileSize = 16 << 20
+
+// OsuFiles fetches a difficulty's .osu file from a list of sources serving
+// them as <base>/<beatmap ID>, such as osu.ppy.sh/osu, in order.
+type OsuFiles struct {
+	source *fileSource
+}
+
+func NewOsuFiles(baseURLs []string) *OsuFiles {
+	return &OsuFiles{source: newFileSource("osu file", baseURLs, maxOsuFileSize)}
+}
+
+func (f *OsuFiles) Get(ctx context.Context, beatmapID int) ([]byte, error) {
+	return f.source.get(ctx, "/"+strconv.Itoa(beatmapID))
+}
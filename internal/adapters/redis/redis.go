@@ -3,12 +3,26 @@ package redis
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"strconv"
 	"time"
 
 	"github.com/RealistikOsu/soumetsu/internal/config"
 	"gopkg.in/redis.v5"
 )
 
+// Nil is the error Get returns for a key that doesn't exist.
+const Nil = redis.Nil
+
+type (
+	PubSub  = redis.PubSub
+	Message = redis.Message
+)
+
+// resubscribeWait is how long Listen waits before subscribing again after
+// losing a subscription.
+const resubscribeWait = 5 * time.Second
+
 type Client struct {
 	*redis.Client
 }
@@ -50,6 +64,10 @@ func (c *Client) Publish(ctx context.Context, channel string, message any) error
 	return c.Client.Publish(channel, msg).Err()
 }
 
+func (c *Client) Incr(ctx context.Context, key string) (int64, error) {
+	return c.Client.Incr(key).Result()
+}
+
 func (c *Client) Exists(ctx context.Context, key string) (bool, error) {
 	result, err := c.Client.Exists(key).Result()
 	return result, err
@@ -59,6 +77,129 @@ func (c *Client) Expire(ctx context.Context, key string, expiration time.Duratio
 	return c.Client.Expire(key, expiration).Err()
 }
 
+func (c *Client) ZRem(ctx context.Context, key string, members ...any) error {
+	return c.Client.ZRem(key, members...).Err()
+}
+
+// ZRemCount is ZRem returning how many members were removed.
+func (c *Client) ZRemCount(ctx context.Context, key string, members ...any) (int64, error) {
+	return c.Client.ZRem(key, members...).Result()
+}
+
+func (c *Client) ZAdd(ctx context.Context, key string, score float64, member any) error {
+	return c.Client.ZAdd(key, redis.Z{Score: score, Member: member}).Err()
+}
+
+// ZRangeByScoreUpTo returns up to count members scoring at most max, lowest
+// first.
+func (c *Client) ZRangeByScoreUpTo(ctx context.Context, key string, max float64, count int64) ([]string, error) {
+	return c.Client.ZRangeByScore(key, redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatFloat(max, 'f', -1, 64),
+		Count: count,
+	}).Result()
+}
+
+func (c *Client) ZCard(ctx context.Context, key string) (int64, error) {
+	return c.Client.ZCard(key).Result()
+}
+
+// SetNX sets key only if it doesn't exist yet, reporting whether it did.
+func (c *Client) SetNX(ctx context.Context, key string, value any, expiration time.Duration) (bool, error) {
+	return c.Client.SetNX(key, value, expiration).Result()
+}
+
+func (c *Client) SAdd(ctx context.Context, key string, members ...any) error {
+	return c.Client.SAdd(key, members...).Err()
+}
+
+func (c *Client) SMembers(ctx context.Context, key string) ([]string, error) {
+	return c.Client.SMembers(key).Result()
+}
+
+// Subscribe listens on channels. Close the returned PubSub to stop.
+func (c *Client) Subscribe(ctx context.Context, channels ...string) (*PubSub, error) {
+	return c.Client.Subscribe(channels...)
+}
+
+// Listen passes each message published on channels to handle until done is
+// closed. A lost subscription is logged, with what saying what it was for,
+// and made again after a pause; messages published in between are missed.
+func (c *Client) Listen(done <-chan struct{}, what string, handle func(*Message), channels ...string) {
+	ctx := context.Background()
+	for {
+		pubsub, err := c.Subscribe(ctx, channels...)
+		if err == nil {
+			stopped := make(chan struct{})
+			go func() {
+				select {
+				case <-done:
+					pubsub.Close()
+				case <-stopped:
+				}
+			}()
+			err = receive(pubsub, handle)
+			close(stopped)
+			pubsub.Close()
+		}
+
+		select {
+		case <-done:
+			return
+		default:
+		}
+		slog.Error("lost "+what+" subscription", "error", err)
+
+		select {
+		case <-done:
+			return
+		case <-time.After(resubscribeWait):
+		}
+	}
+}
+
+func receive(pubsub *PubSub, handle func(*Message)) error {
+	for {
+		msg, err := pubsub.ReceiveMessage()
+		if err != nil {
+			return err
+		}
+		handle(msg)
+	}
+}
+
+// renewIfHeld and releaseIfHeld only touch a lease still holding the
+// caller's value, so one that expired and was taken by someone else is left
+// alone.
+var (
+	renewIfHeld = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+	releaseIfHeld = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// RenewLease extends key's expiry if it still holds value, reporting whether
+// it did.
+func (c *Client) RenewLease(ctx context.Context, key, value string, expiration time.Duration) (bool, error) {
+	n, err := renewIfHeld.Run(c.Client, []string{key}, value, expiration.Milliseconds()).Result()
+	if err != nil {
+		return false, err
+	}
+	renewed, _ := n.(int64)
+	return renewed == 1, nil
+}
+
+// ReleaseLease deletes key if it still holds value.
+func (c *Client) ReleaseLease(ctx context.Context, key, value string) error {
+	return releaseIfHeld.Run(c.Client, []string{key}, value).Err()
+}
+
 func (c *Client) Close() error {
 	return c.Client.Close()
 }
@@ -0,0 +1,85 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/RealistikOsu/soumetsu/internal/config"
+)
+
+func newTestClient(t *testing.T, mr *miniredis.Miniredis) *Client {
+	t.Helper()
+	port, err := strconv.Atoi(mr.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := New(config.RedisConfig{Host: mr.Host(), Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestListen(t *testing.T) {
+	mr := miniredis.RunT(t)
+	c := newTestClient(t, mr)
+
+	got := make(chan *Message, 1)
+	done := make(chan struct{})
+	returned := make(chan struct{})
+	go func() {
+		c.Listen(done, "test", func(msg *Message) { got <- msg }, "a", "b")
+		close(returned)
+	}()
+
+	// Publish until the subscription is made; nothing is buffered before.
+	ctx := context.Background()
+	deadline := time.After(5 * time.Second)
+	var msg *Message
+	for msg == nil {
+		if err := c.Publish(ctx, "b", "hello"); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case msg = <-got:
+		case <-time.After(10 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("no message received")
+		}
+	}
+	if msg.Channel != "b" || msg.Payload != "hello" {
+		t.Errorf("got %s on %s, want hello on b", msg.Payload, msg.Channel)
+	}
+
+	close(done)
+	select {
+	case <-returned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Listen didn't return after done was closed")
+	}
+}
+
+func TestListenStopsWhileWaiting(t *testing.T) {
+	mr := miniredis.RunT(t)
+	c := newTestClient(t, mr)
+	mr.Close()
+
+	done := make(chan struct{})
+	returned := make(chan struct{})
+	go func() {
+		c.Listen(done, "test", func(*Message) {}, "a")
+		close(returned)
+	}()
+
+	close(done)
+	select {
+	case <-returned:
+	case <-time.After(resubscribeWait / 2):
+		t.Fatal("Listen kept waiting to resubscribe after done was closed")
+	}
+}
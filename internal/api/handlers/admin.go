@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+
+	apicontext "github.com/RealistikOsu/soumetsu/internal/api/context"
+	"github.com/RealistikOsu/soumetsu/internal/api/response"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+)
+
+// adminPages lists the admin sections in sidebar order, each with the
+// privilege it needs. /admin sends staff to the first one they can use.
+var adminPages = []struct {
+	Path      string
+	Privilege models.UserPrivileges
+}{
+	{"/admin/reports", models.AdminPrivilegeManageReports},
+	{"/admin/users", models.AdminPrivilegeManageUsers},
+	{"/admin/clans", models.AdminPrivilegeManageUsers},
+	{"/admin/betakeys", models.AdminPrivilegeManageBetakeys},
+	{"/admin/rank-requests", models.AdminPrivilegeManageBeatmaps},
+	{"/admin/beatmaps", models.AdminPrivilegeManageBeatmaps},
+	{"/admin/announcements", models.AdminPrivilegeSendAlerts},
+	{"/admin/docs", models.AdminPrivilegeManageDocs},
+	{"/admin/rules", models.AdminPrivilegeManageDocs},
+	{"/admin/maintenance", models.AdminPrivilegeManageSettings},
+	{"/admin/webhooks", models.AdminPrivilegeManageSettings},
+	{"/admin/jobs", models.AdminPrivilegeManageSettings},
+	{"/admin/analytics", models.AdminPrivilegeAccessRAP},
+}
+
+type AdminHandler struct {
+	templates *response.TemplateEngine
+}
+
+func NewAdminHandler(templates *response.TemplateEngine) *AdminHandler {
+	return &AdminHandler{templates: templates}
+}
+
+func (h *AdminHandler) Index(w http.ResponseWriter, r *http.Request) {
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	for _, page := range adminPages {
+		if reqCtx.User.HasPrivilege(page.Privilege) {
+			http.Redirect(w, r, page.Path, http.StatusFound)
+			return
+		}
+	}
+
+	h.templates.Forbidden(w, r)
+}
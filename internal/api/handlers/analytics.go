@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RealistikOsu/soumetsu/internal/api/response"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/analytics"
+)
+
+const defaultAnalyticsDays = 30
+
+type AnalyticsHandler struct {
+	analytics *analytics.Service
+	templates *response.TemplateEngine
+}
+
+func NewAnalyticsHandler(analyticsService *analytics.Service, templates *response.TemplateEngine) *AnalyticsHandler {
+	return &AnalyticsHandler{analytics: analyticsService, templates: templates}
+}
+
+// Dashboard shows the last ?days= days, 30 by default.
+func (h *AnalyticsHandler) Dashboard(w http.ResponseWriter, r *http.Request) {
+	days := analyticsDays(r)
+
+	dashboard, err := h.analytics.Dashboard(r.Context(), days)
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.templates.RenderWithRequest(w, r, "admin/analytics.html", &response.TemplateData{
+		TitleBar: "Analytics",
+		Extra: map[string]interface{}{
+			"Dashboard": dashboard,
+			"Ranges":    analytics.Ranges,
+			"Series":    analytics.Series,
+		},
+	})
+}
+
+// Export downloads one of the dashboard's data sets, picked with ?series=,
+// as CSV.
+func (h *AnalyticsHandler) Export(w http.ResponseWriter, r *http.Request) {
+	series := r.URL.Query().Get("series")
+	days := analyticsDays(r)
+
+	rows, err := h.analytics.Export(r.Context(), series, days)
+	if err != nil {
+		if _, ok := err.(*services.ServiceError); ok {
+			h.templates.NotFound(w, r)
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	filename := fmt.Sprintf("analytics-%s-%dd-%s.csv", series, days, time.Now().UTC().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(rows); err != nil {
+		slog.Error("failed to write analytics export", "error", err, "series", series)
+	}
+}
+
+func analyticsDays(r *http.Request) int {
+	days, err := strconv.Atoi(r.URL.Query().Get("days"))
+	if err != nil || !analytics.ValidRange(days) {
+		return defaultAnalyticsDays
+	}
+	return days
+}
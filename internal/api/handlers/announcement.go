@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	apicontext "github.com/RealistikOsu/soumetsu/internal/api/context"
+	"github.com/RealistikOsu/soumetsu/internal/api/middleware"
+	"github.com/RealistikOsu/soumetsu/internal/api/response"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/announcement"
+)
+
+type AnnouncementHandler struct {
+	announcements *announcement.Service
+	csrf          middleware.CSRFService
+	store         middleware.SessionStore
+	templates     *response.TemplateEngine
+}
+
+func NewAnnouncementHandler(
+	announcementService *announcement.Service,
+	csrf middleware.CSRFService,
+	store middleware.SessionStore,
+	templates *response.TemplateEngine,
+) *AnnouncementHandler {
+	return &AnnouncementHandler{
+		announcements: announcementService,
+		csrf:          csrf,
+		store:         store,
+		templates:     templates,
+	}
+}
+
+func (h *AnnouncementHandler) List(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("p"))
+	if page < 1 {
+		page = 1
+	}
+
+	list, err := h.announcements.List(r.Context(), page)
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+	hasNext := len(list) > announcement.PageSize
+	if hasNext {
+		list = list[:announcement.PageSize]
+	}
+
+	h.templates.RenderWithRequest(w, r, "admin/announcements.html", &response.TemplateData{
+		TitleBar: "Announcements",
+		Extra: map[string]interface{}{
+			"Announcements": list,
+			"Page":          page,
+			"HasNext":       hasNext,
+			"Now":           time.Now().Unix(),
+		},
+	})
+}
+
+func (h *AnnouncementHandler) New(w http.ResponseWriter, r *http.Request) {
+	h.editResp(w, r, &models.Announcement{Audience: models.AudienceEveryone, Dismissible: true})
+}
+
+func (h *AnnouncementHandler) Edit(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return
+	}
+
+	a, err := h.announcements.Get(r.Context(), id)
+	if err != nil {
+		if _, ok := err.(*services.ServiceError); ok {
+			h.templates.NotFound(w, r)
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.editResp(w, r, a)
+}
+
+// Save handles both the new and edit forms; the edit form posts to the
+// announcement's own URL.
+func (h *AnnouncementHandler) Save(w http.ResponseWriter, r *http.Request) {
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	var id int64
+	if param := chi.URLParam(r, "id"); param != "" {
+		var err error
+		id, err = strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			h.templates.NotFound(w, r)
+			return
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.editResp(w, r, &models.Announcement{ID: id}, models.NewError("Invalid form data."))
+		return
+	}
+
+	privileges, _ := strconv.Atoi(r.FormValue("privileges"))
+	startsAt, startErr := parseDatetimeLocal(r.FormValue("starts_at"))
+	endsAt, endErr := parseDatetimeLocal(r.FormValue("ends_at"))
+	input := announcement.Input{
+		Title:       r.FormValue("title"),
+		Body:        r.FormValue("body"),
+		Audience:    models.AnnouncementAudience(r.FormValue("audience")),
+		Privileges:  models.UserPrivileges(privileges),
+		StartsAt:    startsAt,
+		EndsAt:      endsAt,
+		Dismissible: r.FormValue("dismissible") != "",
+		PushIngame:  r.FormValue("push_ingame") != "",
+	}
+
+	// Re-render with what was submitted so a failed save doesn't lose it.
+	submitted := &models.Announcement{
+		ID:          id,
+		Title:       input.Title,
+		Body:        input.Body,
+		Audience:    input.Audience,
+		Privileges:  input.Privileges,
+		Dismissible: input.Dismissible,
+		PushIngame:  input.PushIngame,
+	}
+	if !startsAt.IsZero() {
+		submitted.StartsAt = startsAt.Unix()
+	}
+	if !endsAt.IsZero() {
+		submitted.EndsAt = endsAt.Unix()
+	}
+
+	if ok, _ := h.csrf.Validate(reqCtx.User.ID, r.FormValue("csrf")); !ok {
+		h.editResp(w, r, submitted, models.NewError("Your session has expired. Please try redoing what you were trying to do."))
+		return
+	}
+	if startErr != nil {
+		h.editResp(w, r, submitted, models.NewError("Invalid start time."))
+		return
+	}
+	if endErr != nil {
+		h.editResp(w, r, submitted, models.NewError("Invalid end time."))
+		return
+	}
+
+	if _, err := h.announcements.Save(r.Context(), id, reqCtx.User.ID, input); err != nil {
+		if svcErr, ok := err.(*services.ServiceError); ok {
+			if svcErr.Code == "not_found" {
+				h.templates.NotFound(w, r)
+				return
+			}
+			h.editResp(w, r, submitted, models.NewError(svcErr.Message))
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	RedirectWithMessage(w, r, h.store, "/admin/announcements", models.NewSuccess("Announcement saved."))
+}
+
+func (h *AnnouncementHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+	redirect := "/admin/announcements"
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		RedirectWithMessage(w, r, h.store, redirect, models.NewError("Invalid form data."))
+		return
+	}
+	if ok, _ := h.csrf.Validate(reqCtx.User.ID, r.FormValue("csrf")); !ok {
+		RedirectWithMessage(w, r, h.store, redirect, models.NewError("Your session has expired. Please try redoing what you were trying to do."))
+		return
+	}
+
+	if err := h.announcements.Delete(r.Context(), id); err != nil {
+		if svcErr, ok := err.(*services.ServiceError); ok {
+			RedirectWithMessage(w, r, h.store, redirect, models.NewError(svcErr.Message))
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	RedirectWithMessage(w, r, h.store, redirect, models.NewSuccess("Announcement removed."))
+}
+
+func (h *AnnouncementHandler) editResp(w http.ResponseWriter, r *http.Request, a *models.Announcement, messages ...models.Message) {
+	title := "New announcement"
+	if a.ID != 0 {
+		title = "Edit announcement"
+	}
+
+	h.templates.RenderWithRequest(w, r, "admin/announcement.html", &response.TemplateData{
+		TitleBar: title,
+		Messages: messages,
+		Extra: map[string]interface{}{
+			"Announcement": a,
+			"Audiences":    models.AnnouncementAudiences,
+		},
+	})
+}
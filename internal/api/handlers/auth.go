@@ -17,12 +17,14 @@ import (
 	"github.com/RealistikOsu/soumetsu/internal/pkg/crypto"
 	"github.com/RealistikOsu/soumetsu/internal/services"
 	"github.com/RealistikOsu/soumetsu/internal/services/auth"
+	"github.com/RealistikOsu/soumetsu/internal/services/betakeys"
 	"github.com/gorilla/sessions"
 )
 
 type AuthHandler struct {
 	config      *config.Config
 	authService *auth.Service
+	betaKeys    *betakeys.Service
 	apiClient   *api.Client
 	csrf        middleware.CSRFService
 	store       middleware.SessionStore
@@ -32,6 +34,7 @@ type AuthHandler struct {
 func NewAuthHandler(
 	cfg *config.Config,
 	authService *auth.Service,
+	betaKeyService *betakeys.Service,
 	apiClient *api.Client,
 	csrf middleware.CSRFService,
 	store middleware.SessionStore,
@@ -40,6 +43,7 @@ func NewAuthHandler(
 	return &AuthHandler{
 		config:      cfg,
 		authService: authService,
+		betaKeys:    betaKeyService,
 		apiClient:   apiClient,
 		csrf:        csrf,
 		store:       store,
@@ -216,8 +220,27 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		Captcha:  r.FormValue("h-captcha-response"),
 	}
 
+	// The key is reserved before the account is created so two people can't
+	// race for the last use of it.
+	var key *models.RegistrationKey
+	if h.betaKeys.InviteOnly(r.Context()) {
+		var err error
+		key, err = h.betaKeys.Reserve(r.Context(), r.FormValue("key"))
+		if err != nil {
+			if svcErr, ok := err.(*services.ServiceError); ok {
+				h.registerResp(w, r, models.NewError(svcErr.Message))
+				return
+			}
+			h.templates.InternalError(w, r, err)
+			return
+		}
+	}
+
 	userID, err := h.authService.Register(r.Context(), input)
 	if err != nil {
+		if key != nil {
+			h.betaKeys.Release(r.Context(), key)
+		}
 		if svcErr, ok := err.(*services.ServiceError); ok {
 			h.registerResp(w, r, models.NewError(svcErr.Message))
 			return
@@ -226,9 +249,15 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	clientIP := apicontext.ClientIP(r)
+	if key != nil {
+		if err := h.betaKeys.Redeem(r.Context(), key, userID, clientIP); err != nil {
+			slog.Error("failed to record beta key use", "error", err, "user_id", userID, "key_id", key.ID)
+		}
+	}
+
 	h.setIdentityCookie(w, r, userID)
 
-	clientIP := apicontext.ClientIP(r)
 	if err := h.authService.LogIP(r.Context(), userID, clientIP); err != nil {
 		slog.Error("failed to log IP", "error", err, "user_id", userID, "ip", clientIP)
 	}
@@ -309,6 +338,10 @@ func (h *AuthHandler) registerResp(w http.ResponseWriter, r *http.Request, messa
 		Scripts:   []string{"https://js.hcaptcha.com/1/api.js"},
 		Messages:  messages,
 		FormData:  NormaliseURLValues(r.PostForm),
+		Extra: map[string]interface{}{
+			"InviteOnly": h.betaKeys.InviteOnly(r.Context()),
+			"Key":        r.FormValue("key"),
+		},
 	})
 }
 
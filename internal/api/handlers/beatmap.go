@@ -1,30 +1,50 @@
 package handlers
 
 import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
+	apicontext "github.com/RealistikOsu/soumetsu/internal/api/context"
 	"github.com/RealistikOsu/soumetsu/internal/api/response"
 	"github.com/RealistikOsu/soumetsu/internal/config"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/services"
 	"github.com/RealistikOsu/soumetsu/internal/services/beatmap"
+	"github.com/RealistikOsu/soumetsu/internal/services/collections"
+	"github.com/RealistikOsu/soumetsu/internal/services/download"
+	"github.com/RealistikOsu/soumetsu/internal/services/media"
 )
 
 type BeatmapHandler struct {
 	config         *config.Config
 	beatmapService *beatmap.Service
+	downloads      *download.Service
+	collections    *collections.Service
 	templates      *response.TemplateEngine
 }
 
 func NewBeatmapHandler(
 	cfg *config.Config,
 	beatmapService *beatmap.Service,
+	downloads *download.Service,
+	collectionService *collections.Service,
 	templates *response.TemplateEngine,
 ) *BeatmapHandler {
 	return &BeatmapHandler{
 		config:         cfg,
 		beatmapService: beatmapService,
+		downloads:      downloads,
+		collections:    collectionService,
 		templates:      templates,
 	}
 }
@@ -34,48 +54,460 @@ type BeatmapPageData struct {
 }
 
 func (h *BeatmapHandler) BeatmapPage(w http.ResponseWriter, r *http.Request) {
-	beatmapID := chi.URLParam(r, "id")
+	beatmapID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || beatmapID <= 0 {
+		h.templates.NotFound(w, r)
+		return
+	}
 
-	h.templates.RenderWithRequest(w, r, "beatmaps/beatmap.html", &response.TemplateData{
+	data := &response.TemplateData{
 		TitleBar:  "Beatmap",
 		DisableHH: true,
 		Extra: map[string]interface{}{
 			"BeatmapID": beatmapID,
 		},
+	}
+
+	bm, set, err := h.beatmapService.GetBeatmapWithSet(r.Context(), beatmapID)
+	if isNotFound(err) {
+		h.templates.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		// The page loads the map itself, so it works without the metadata.
+		slog.Warn("failed to load beatmap for page metadata", "error", err, "beatmap_id", beatmapID)
+	} else {
+		data.TitleBar = beatmapTitle(bm, set)
+		data.Meta = h.beatmapMeta(bm, set)
+	}
+
+	h.templates.RenderWithRequest(w, r, "beatmaps/beatmap.html", data)
+}
+
+func (h *BeatmapHandler) baseURL() string {
+	return strings.TrimRight(h.config.App.BaseURL, "/")
+}
+
+// coverURL is the set's proxied cover, for embeds that need a full URL.
+func (h *BeatmapHandler) coverURL(setID int) string {
+	return h.baseURL() + media.CoverPath(setID)
+}
+
+func beatmapTitle(bm *models.Beatmap, set *models.BeatmapSet) string {
+	return fmt.Sprintf("%s - %s [%s]", set.Artist, set.Title, bm.DiffName)
+}
+
+func (h *BeatmapHandler) beatmapMeta(bm *models.Beatmap, set *models.BeatmapSet) *response.PageMeta {
+	pageURL := h.baseURL() + "/beatmaps/" + strconv.Itoa(bm.ID)
+	cover := h.coverURL(set.ID)
+
+	mode := "osu!"
+	if bm.Mode >= 0 && bm.Mode < len(models.ModeNames) {
+		mode = models.ModeNames[bm.Mode]
+	}
+	description := fmt.Sprintf("%s %s beatmap mapped by %s. %.2f★ · %s · %s BPM · AR %s · OD %s",
+		models.RankedStatusLabel(set.RankedStatus), mode, set.Creator, bm.DifficultyRating, bm.LengthLabel(),
+		strconv.FormatFloat(bm.BPM, 'f', -1, 64),
+		strconv.FormatFloat(float64(bm.AR), 'f', -1, 32),
+		strconv.FormatFloat(float64(bm.OD), 'f', -1, 32))
+
+	return &response.PageMeta{
+		Title:       beatmapTitle(bm, set),
+		Description: description,
+		URL:         pageURL,
+		Image:       cover,
+		Type:        "music.song",
+		OEmbedURL:   h.baseURL() + "/oembed?format=json&url=" + url.QueryEscape(pageURL),
+		JSONLD:      recordingJSONLD(set, set.Title+" ["+bm.DiffName+"]", pageURL, cover, bm.TotalLength),
+	}
+}
+
+func recordingJSONLD(set *models.BeatmapSet, name, pageURL, image string, length int) map[string]interface{} {
+	return map[string]interface{}{
+		"@context": "https://schema.org",
+		"@type":    "MusicRecording",
+		"name":     name,
+		"url":      pageURL,
+		"image":    image,
+		"duration": fmt.Sprintf("PT%dM%dS", length/60, length%60),
+		"byArtist": map[string]interface{}{
+			"@type": "MusicGroup",
+			"name":  set.Artist,
+		},
+		"creator": map[string]interface{}{
+			"@type": "Person",
+			"name":  set.Creator,
+		},
+		"keywords": set.Tags,
+	}
+}
+
+// oEmbedResponse is a link-type oEmbed response, see https://oembed.com.
+type oEmbedResponse struct {
+	Version         string `json:"version"`
+	Type            string `json:"type"`
+	Title           string `json:"title"`
+	AuthorName      string `json:"author_name"`
+	ProviderName    string `json:"provider_name"`
+	ProviderURL     string `json:"provider_url"`
+	CacheAge        int    `json:"cache_age"`
+	ThumbnailURL    string `json:"thumbnail_url"`
+	ThumbnailWidth  int    `json:"thumbnail_width"`
+	ThumbnailHeight int    `json:"thumbnail_height"`
+}
+
+// OEmbed describes a beatmap or set page linked to by the url parameter.
+func (h *BeatmapHandler) OEmbed(w http.ResponseWriter, r *http.Request) {
+	if format := r.URL.Query().Get("format"); format != "" && format != "json" {
+		response.JSONError(w, http.StatusNotImplemented, "Only the json format is supported.")
+		return
+	}
+	link, ok := h.parseBeatmapLink(r.URL.Query().Get("url"))
+	if !ok {
+		response.JSONError(w, http.StatusNotFound, "That isn't a beatmap link.")
+		return
+	}
+
+	var title string
+	var set *models.BeatmapSet
+	var err error
+	if link.set {
+		set, err = h.beatmapService.GetBeatmapSet(r.Context(), link.id)
+		if err == nil {
+			title = set.Artist + " - " + set.Title
+		}
+	} else {
+		var bm *models.Beatmap
+		bm, set, err = h.beatmapService.GetBeatmapWithSet(r.Context(), link.id)
+		if err == nil {
+			title = beatmapTitle(bm, set)
+		}
+	}
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, oEmbedResponse{
+		Version:         "1.0",
+		Type:            "link",
+		Title:           title,
+		AuthorName:      set.Creator,
+		ProviderName:    "RealistikOsu!",
+		ProviderURL:     h.baseURL(),
+		CacheAge:        int(time.Hour / time.Second),
+		ThumbnailURL:    h.coverURL(set.ID),
+		ThumbnailWidth:  900,
+		ThumbnailHeight: 250,
 	})
 }
 
+type beatmapLink struct {
+	id  int
+	set bool
+}
+
+// parseBeatmapLink accepts links to beatmap and set pages on this site,
+// including the /b/ and /s/ short forms.
+func (h *BeatmapHandler) parseBeatmapLink(raw string) (beatmapLink, bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return beatmapLink{}, false
+	}
+	base, err := url.Parse(h.baseURL())
+	if err != nil || !strings.EqualFold(u.Host, base.Host) {
+		return beatmapLink{}, false
+	}
+
+	for prefix, set := range map[string]bool{"/beatmaps/": false, "/b/": false, "/beatmapsets/": true, "/s/": true} {
+		rest, found := strings.CutPrefix(u.Path, prefix)
+		if !found {
+			continue
+		}
+		id, err := strconv.Atoi(rest)
+		if err != nil || id <= 0 {
+			return beatmapLink{}, false
+		}
+		return beatmapLink{id: id, set: set}, true
+	}
+	return beatmapLink{}, false
+}
+
+func isNotFound(err error) bool {
+	svcErr, ok := err.(*services.ServiceError)
+	return ok && svcErr.StatusCode == http.StatusNotFound
+}
+
 type BeatmapSetPageData struct {
 	SetID string
 }
 
+// BeatmapSetPage shows a set with one of its difficulties, picked by ?b=,
+// selected.
 func (h *BeatmapHandler) BeatmapSetPage(w http.ResponseWriter, r *http.Request) {
-	setID := chi.URLParam(r, "sid")
+	setID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || setID <= 0 {
+		h.templates.NotFound(w, r)
+		return
+	}
+
+	set, err := h.beatmapService.GetBeatmapSet(r.Context(), setID)
+	if isNotFound(err) || (err == nil && len(set.ChildrenBeatmaps) == 0) {
+		h.templates.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		svcErr, ok := err.(*services.ServiceError)
+		if !ok {
+			h.templates.InternalError(w, r, err)
+			return
+		}
+		h.templates.RenderWithRequest(w, r, "beatmaps/beatmap_set.html", &response.TemplateData{
+			TitleBar:  "Beatmap Set",
+			DisableHH: true,
+			Messages:  []models.Message{models.NewError(svcErr.Message)},
+		})
+		return
+	}
+
+	difficulties := set.SortedDifficulties()
+	selected := difficulties[0]
+	if b, err := strconv.Atoi(r.URL.Query().Get("b")); err == nil {
+		for _, d := range difficulties {
+			if d.ID == b {
+				selected = d
+				break
+			}
+		}
+	}
+
+	downloads, err := h.downloads.Count(r.Context(), set.ID)
+	if err != nil {
+		slog.Error("failed to get beatmap download count", "error", err, "set_id", set.ID)
+	}
+	userID := apicontext.GetUserID(r.Context())
+	favourites, favourited, err := h.collections.FavouriteState(r.Context(), userID, set.ID)
+	if err != nil {
+		slog.Error("failed to get beatmap favourites", "error", err, "set_id", set.ID)
+	}
+	var userCollections []models.BeatmapCollection
+	if userID != 0 {
+		userCollections, err = h.collections.List(r.Context(), userID, userID)
+		if err != nil {
+			slog.Error("failed to get user collections", "error", err, "user_id", userID)
+		}
+	}
 
 	h.templates.RenderWithRequest(w, r, "beatmaps/beatmap_set.html", &response.TemplateData{
-		TitleBar:  "Beatmap Set",
+		TitleBar:  set.Artist + " - " + set.Title,
 		DisableHH: true,
+		Meta:      h.beatmapSetMeta(set, difficulties),
 		Extra: map[string]interface{}{
-			"SetID": setID,
+			"Set":          set,
+			"Difficulties": difficulties,
+			"Selected":     selected,
+			"Cover":        media.CoverPath(set.ID),
+			"Downloads":    downloads,
+			"Favourites":   favourites,
+			"Favourited":   favourited,
+			"Collections":  userCollections,
 		},
 	})
 }
 
-func (h *BeatmapHandler) BeatmapSetRedirect(w http.ResponseWriter, r *http.Request) {
-	setID := chi.URLParam(r, "bsetid")
+func (h *BeatmapHandler) beatmapSetMeta(set *models.BeatmapSet, difficulties []models.Beatmap) *response.PageMeta {
+	pageURL := h.baseURL() + "/beatmapsets/" + strconv.Itoa(set.ID)
+	cover := h.coverURL(set.ID)
+
+	lowest, highest := difficulties[0].DifficultyRating, difficulties[0].DifficultyRating
+	for _, d := range difficulties {
+		lowest = math.Min(lowest, d.DifficultyRating)
+		highest = math.Max(highest, d.DifficultyRating)
+	}
+	stars := fmt.Sprintf("%.2f★", highest)
+	if highest-lowest >= 0.01 {
+		stars = fmt.Sprintf("%.2f–%.2f★", lowest, highest)
+	}
+	count := "1 difficulty"
+	if len(difficulties) > 1 {
+		count = strconv.Itoa(len(difficulties)) + " difficulties"
+	}
+	first := difficulties[0]
+
+	return &response.PageMeta{
+		Title: set.Artist + " - " + set.Title,
+		Description: fmt.Sprintf("%s beatmap set mapped by %s. %s · %s · %s",
+			models.RankedStatusLabel(set.RankedStatus), set.Creator, count, stars, first.LengthLabel()),
+		URL:       pageURL,
+		Image:     cover,
+		Type:      "music.song",
+		OEmbedURL: h.baseURL() + "/oembed?format=json&url=" + url.QueryEscape(pageURL),
+		JSONLD:    recordingJSONLD(set, set.Title, pageURL, cover, first.TotalLength),
+	}
+}
+
+// BeatmapRedirect sends /b/{id} to the beatmap's page.
+func (h *BeatmapHandler) BeatmapRedirect(w http.ResponseWriter, r *http.Request) {
+	beatmapID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || beatmapID <= 0 {
+		h.templates.NotFound(w, r)
+		return
+	}
+	if _, err := h.beatmapService.GetBeatmap(r.Context(), beatmapID); isNotFound(err) {
+		h.templates.NotFound(w, r)
+		return
+	}
+	// Any other error is left for the page to show.
+	http.Redirect(w, r, "/beatmaps/"+strconv.Itoa(beatmapID)+queryString(r), http.StatusMovedPermanently)
+}
 
-	bset, err := h.beatmapService.GetBeatmapSet(r.Context(), setID)
-	if err != nil || len(bset.ChildrenBeatmaps) == 0 {
-		http.Redirect(w, r, "/s/"+setID, http.StatusFound)
+// BeatmapSetRedirect sends /s/{id} to the set's page.
+func (h *BeatmapHandler) BeatmapSetRedirect(w http.ResponseWriter, r *http.Request) {
+	setID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || setID <= 0 {
+		h.templates.NotFound(w, r)
+		return
+	}
+	if _, err := h.beatmapService.GetBeatmapSet(r.Context(), setID); isNotFound(err) {
+		h.templates.NotFound(w, r)
 		return
 	}
+	http.Redirect(w, r, "/beatmapsets/"+strconv.Itoa(setID)+queryString(r), http.StatusMovedPermanently)
+}
 
-	http.Redirect(w, r, "/b/"+strconv.Itoa(bset.ChildrenBeatmaps[0].ID), http.StatusFound)
+func queryString(r *http.Request) string {
+	if r.URL.RawQuery == "" {
+		return ""
+	}
+	return "?" + r.URL.RawQuery
 }
 
+// DownloadBeatmap sends the set's .osz, either by redirecting to the download
+// mirror or, with the proxy on, streaming it through the download cache.
+// ?noVideo=1 leaves the video out. HEAD requests don't count as a download.
+// Only a cached copy of the set is used to name the file, so a slow metadata
+// mirror never holds a download up.
 func (h *BeatmapHandler) DownloadBeatmap(w http.ResponseWriter, r *http.Request) {
-	setID := chi.URLParam(r, "sid")
+	setID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || setID <= 0 {
+		h.templates.NotFound(w, r)
+		return
+	}
+	set := h.beatmapService.CachedBeatmapSet(r.Context(), setID)
+	noVideo := r.URL.Query().Get("noVideo") == "1"
+
+	if r.Method == http.MethodHead {
+		h.headBeatmap(w, r, setID, set, noVideo)
+		return
+	}
+
+	rangeHeader := r.Header.Get("Range")
+	resume := rangeHeader != "" && !strings.HasPrefix(rangeHeader, "bytes=0-")
+	fresh, err := h.downloads.Allow(r.Context(), apicontext.GetUserID(r.Context()), apicontext.ClientIP(r), setID, resume)
+	if err != nil {
+		if svcErr, ok := err.(*services.ServiceError); ok {
+			w.Header().Set("Retry-After", "3600")
+			http.Error(w, svcErr.Message, svcErr.StatusCode)
+			return
+		}
+		// Redis being down shouldn't stop downloads.
+		slog.Error("failed to check beatmap download limits", "error", err)
+		fresh = !resume
+	}
+
+	if !h.downloads.Proxying() {
+		if fresh {
+			h.downloads.Record(r.Context(), setID)
+		}
+		http.Redirect(w, r, h.beatmapService.GetDownloadURL(setID, noVideo), http.StatusFound)
+		return
+	}
+
+	file, err := h.downloads.Open(r.Context(), setID, noVideo, rangeHeader)
+	if err != nil {
+		h.downloadError(w, r, err)
+		return
+	}
+	defer file.Close()
+	if fresh {
+		h.downloads.Record(r.Context(), setID)
+	}
+
+	name := setDownloadHeaders(w, setID, set)
+	if file.Content != nil {
+		http.ServeContent(w, r, name, file.ModTime, file.Content)
+		return
+	}
+	copyDownloadHeaders(w, file)
+	w.WriteHeader(file.Status)
+	if _, err := io.Copy(w, file.Body); err != nil {
+		slog.Debug("beatmap download interrupted", "error", err, "set_id", setID)
+	}
+}
+
+// headBeatmap answers a HEAD for a download with the status and headers a
+// GET would get.
+func (h *BeatmapHandler) headBeatmap(w http.ResponseWriter, r *http.Request, setID int, set *models.BeatmapSet, noVideo bool) {
+	if !h.downloads.Proxying() {
+		http.Redirect(w, r, h.beatmapService.GetDownloadURL(setID, noVideo), http.StatusFound)
+		return
+	}
+
+	file, err := h.downloads.Head(r.Context(), setID, noVideo)
+	if err != nil {
+		h.downloadError(w, r, err)
+		return
+	}
+	defer file.Close()
+	name := setDownloadHeaders(w, setID, set)
+	if file.Content != nil {
+		http.ServeContent(w, r, name, file.ModTime, file.Content)
+		return
+	}
+	copyDownloadHeaders(w, file)
+	w.WriteHeader(file.Status)
+}
+
+func (h *BeatmapHandler) downloadError(w http.ResponseWriter, r *http.Request, err error) {
+	if svcErr, ok := err.(*services.ServiceError); ok {
+		if svcErr.StatusCode == http.StatusNotFound {
+			h.templates.NotFound(w, r)
+			return
+		}
+		http.Error(w, svcErr.Message, svcErr.StatusCode)
+		return
+	}
+	h.templates.InternalError(w, r, err)
+}
+
+// copyDownloadHeaders passes on the headers of a mirror's answer that
+// describe the file.
+func copyDownloadHeaders(w http.ResponseWriter, file *download.File) {
+	for _, header := range []string{"Content-Length", "Content-Range", "Accept-Ranges", "Last-Modified", "ETag"} {
+		if v := file.Header.Get(header); v != "" {
+			w.Header().Set(header, v)
+		}
+	}
+}
+
+// setDownloadHeaders sets the content type and file name of a set's .osz and
+// returns the name.
+func setDownloadHeaders(w http.ResponseWriter, setID int, set *models.BeatmapSet) string {
+	name := strconv.Itoa(setID) + ".osz"
+	if set != nil {
+		name = strconv.Itoa(setID) + " " + downloadName(set.Artist+" - "+set.Title) + ".osz"
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	w.Header().Set("Content-Type", "application/x-osu-beatmap-archive")
+	return name
+}
 
-	downloadURL := h.beatmapService.GetDownloadURL(setID)
-	http.Redirect(w, r, downloadURL, http.StatusFound)
+// downloadName strips characters file systems don't allow in names.
+func downloadName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return -1
+		}
+		return r
+	}, s)
 }
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/RealistikOsu/soumetsu/internal/api/response"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/beatmap"
+)
+
+type searchOption struct {
+	Value    string
+	Label    string
+	Count    int
+	Selected bool
+}
+
+type searchRange struct {
+	Name  string
+	Label string
+	Step  string
+	Range beatmap.Range
+}
+
+// BeatmapListing is the beatmap search page. Searches are plain GET forms, so
+// any search or page of results can be bookmarked.
+func (h *BeatmapHandler) BeatmapListing(w http.ResponseWriter, r *http.Request) {
+	data := &response.TemplateData{
+		TitleBar:  "Beatmaps",
+		KyutGrill: "leaderboard2.jpg",
+		DisableHH: true,
+		Scripts:   []string{"/static/js/beatmap-search.js"},
+	}
+
+	q, err := beatmap.ParseSearchQuery(r.URL.Query())
+	if err != nil {
+		h.searchError(w, r, data, &beatmap.SearchQuery{Mode: -1, Status: "ranked"}, err)
+		return
+	}
+
+	// Send equivalent searches to one URL, so they share a cache entry and a
+	// bookmark reads the same as the form that made it.
+	canonical := q.Values()
+	if q.Cursor != "" {
+		canonical.Set("cursor", q.Cursor)
+	}
+	if canonical.Encode() != r.URL.RawQuery {
+		http.Redirect(w, r, searchURL(canonical), http.StatusFound)
+		return
+	}
+
+	page, err := h.beatmapService.Search(r.Context(), q)
+	if err != nil {
+		h.searchError(w, r, data, q, err)
+		return
+	}
+
+	data.Extra = searchExtra(q, page)
+	h.templates.RenderWithRequest(w, r, "beatmaps/beatmap_listing.html", data)
+}
+
+func (h *BeatmapHandler) searchError(w http.ResponseWriter, r *http.Request, data *response.TemplateData, q *beatmap.SearchQuery, err error) {
+	svcErr, ok := err.(*services.ServiceError)
+	if !ok {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+	data.Messages = []models.Message{models.NewError(svcErr.Message)}
+	data.Extra = searchExtra(q, nil)
+	h.templates.RenderWithRequest(w, r, "beatmaps/beatmap_listing.html", data)
+}
+
+// SearchBeatmaps is the JSON form of the search page, taking the same
+// parameters.
+func (h *BeatmapHandler) SearchBeatmaps(w http.ResponseWriter, r *http.Request) {
+	q, err := beatmap.ParseSearchQuery(r.URL.Query())
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	page, err := h.beatmapService.Search(r.Context(), q)
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	response.JSONSuccess(w, page)
+}
+
+func searchExtra(q *beatmap.SearchQuery, page *beatmap.SearchPage) map[string]interface{} {
+	facets := beatmap.SearchFacets{}
+	if page != nil {
+		facets = page.Facets
+	}
+
+	modes := []searchOption{{Value: "", Label: "Any", Selected: q.Mode < 0}}
+	for mode, label := range []string{"osu!", "Taiko", "Catch", "Mania"} {
+		modes = append(modes, searchOption{
+			Value:    strconv.Itoa(mode),
+			Label:    label,
+			Count:    facets.Modes[mode],
+			Selected: q.Mode == mode,
+		})
+	}
+
+	statuses := []searchOption{{Value: "any", Label: "Any", Selected: q.Status == ""}}
+	for _, name := range []string{"ranked", "approved", "qualified", "loved", "pending"} {
+		status := beatmap.SearchStatuses[name]
+		statuses = append(statuses, searchOption{
+			Value:    name,
+			Label:    models.RankedStatusLabel(status),
+			Count:    facets.Statuses[status],
+			Selected: q.Status == name,
+		})
+	}
+
+	sorts := []searchOption{
+		{Value: beatmap.SortRelevance, Label: "Relevance"},
+		{Value: beatmap.SortPlays, Label: "Plays"},
+		{Value: beatmap.SortDate, Label: "Last updated"},
+		{Value: beatmap.SortDifficulty, Label: "Difficulty"},
+	}
+	for i := range sorts {
+		sorts[i].Selected = sorts[i].Value == q.Sort
+	}
+
+	extra := map[string]interface{}{
+		"Query":    q,
+		"Modes":    modes,
+		"Statuses": statuses,
+		"Sorts":    sorts,
+		"Ranges": []searchRange{
+			{"stars", "Stars", "0.1", q.Stars},
+			{"bpm", "BPM", "1", q.BPM},
+			{"length", "Length (s)", "1", q.Length},
+			{"ar", "AR", "0.1", q.AR},
+			{"od", "OD", "0.1", q.OD},
+			{"cs", "CS", "0.1", q.CS},
+			{"hp", "HP", "0.1", q.HP},
+		},
+		"Page":     page,
+		"Capped":   page != nil && page.Capped,
+		"PoolSize": beatmap.SearchPoolSize,
+	}
+	if page != nil && page.Next != "" {
+		next := q.Values()
+		next.Set("cursor", page.Next)
+		extra["NextURL"] = searchURL(next)
+	}
+	if q.Cursor != "" {
+		extra["FirstURL"] = searchURL(q.Values())
+	}
+	return extra
+}
+
+func searchURL(v url.Values) string {
+	if len(v) == 0 {
+		return "/beatmaps"
+	}
+	return "/beatmaps?" + v.Encode()
+}
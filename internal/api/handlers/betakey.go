@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	apicontext "github.com/RealistikOsu/soumetsu/internal/api/context"
+	"github.com/RealistikOsu/soumetsu/internal/api/middleware"
+	"github.com/RealistikOsu/soumetsu/internal/api/response"
+	"github.com/RealistikOsu/soumetsu/internal/config"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/betakeys"
+)
+
+type BetaKeyHandler struct {
+	config    *config.Config
+	betaKeys  *betakeys.Service
+	csrf      middleware.CSRFService
+	store     middleware.SessionStore
+	templates *response.TemplateEngine
+}
+
+func NewBetaKeyHandler(
+	cfg *config.Config,
+	betaKeyService *betakeys.Service,
+	csrf middleware.CSRFService,
+	store middleware.SessionStore,
+	templates *response.TemplateEngine,
+) *BetaKeyHandler {
+	return &BetaKeyHandler{
+		config:    cfg,
+		betaKeys:  betaKeyService,
+		csrf:      csrf,
+		store:     store,
+		templates: templates,
+	}
+}
+
+// List shows staff keys, and personal invites too with ?personal=1.
+func (h *BetaKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	h.listResp(w, r)
+}
+
+func (h *BetaKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !h.checkForm(w, r, "/admin/betakeys") {
+		return
+	}
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	maxUses, err := strconv.Atoi(r.FormValue("max_uses"))
+	if err != nil {
+		h.listResp(w, r, models.NewError("Please enter how many times the key can be used."))
+		return
+	}
+	expiresAt, err := parseDatetimeLocal(r.FormValue("expires_at"))
+	if err != nil {
+		h.listResp(w, r, models.NewError("Invalid expiry."))
+		return
+	}
+
+	key, err := h.betaKeys.Create(r.Context(), reqCtx.User.ID, betakeys.CreateInput{
+		MaxUses:   maxUses,
+		ExpiresAt: expiresAt,
+		Note:      r.FormValue("note"),
+	})
+	if err != nil {
+		if svcErr, ok := err.(*services.ServiceError); ok {
+			h.listResp(w, r, models.NewError(svcErr.Message))
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	RedirectWithMessage(w, r, h.store, betaKeyAdminURL(key.ID), models.NewSuccess("Key created."))
+}
+
+func (h *BetaKeyHandler) SetInviteOnly(w http.ResponseWriter, r *http.Request) {
+	if !h.checkForm(w, r, "/admin/betakeys") {
+		return
+	}
+
+	on := r.FormValue("invite_only") == "1"
+	if err := h.betaKeys.SetInviteOnly(r.Context(), on); err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	message := "Anyone can register again."
+	if on {
+		message = "Registration is now invite-only."
+	}
+	RedirectWithMessage(w, r, h.store, "/admin/betakeys", models.NewSuccess(message))
+}
+
+func (h *BetaKeyHandler) Show(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return
+	}
+
+	key, uses, err := h.betaKeys.Key(r.Context(), id)
+	if err != nil {
+		if _, ok := err.(*services.ServiceError); ok {
+			h.templates.NotFound(w, r)
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.templates.RenderWithRequest(w, r, "admin/betakey.html", &response.TemplateData{
+		TitleBar: "Beta key",
+		Extra: map[string]interface{}{
+			"Key":       key,
+			"Uses":      uses,
+			"InviteURL": h.inviteURL(key.Key),
+			"Now":       time.Now().Unix(),
+		},
+	})
+}
+
+func (h *BetaKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return
+	}
+	redirect := betaKeyAdminURL(id)
+	if !h.checkForm(w, r, redirect) {
+		return
+	}
+
+	err = h.betaKeys.Revoke(r.Context(), id)
+	h.afterAction(w, r, redirect, err, "Key revoked.")
+}
+
+// InvitesPage lists the user's personal invites.
+func (h *BetaKeyHandler) InvitesPage(w http.ResponseWriter, r *http.Request) {
+	h.invitesResp(w, r)
+}
+
+func (h *BetaKeyHandler) CreateInvite(w http.ResponseWriter, r *http.Request) {
+	if !h.checkForm(w, r, "/settings/invites") {
+		return
+	}
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	_, err := h.betaKeys.CreateInvite(r.Context(), reqCtx.User.ID, reqCtx.User.Privileges)
+	h.afterAction(w, r, "/settings/invites", err, "Invite created. Send the link to whoever you're inviting.")
+}
+
+func (h *BetaKeyHandler) RevokeInvite(w http.ResponseWriter, r *http.Request) {
+	if !h.checkForm(w, r, "/settings/invites") {
+		return
+	}
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	err := h.betaKeys.RevokeInvite(r.Context(), reqCtx.User.ID, id)
+	h.afterAction(w, r, "/settings/invites", err, "Invite revoked.")
+}
+
+func (h *BetaKeyHandler) listResp(w http.ResponseWriter, r *http.Request, messages ...models.Message) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("p"))
+	if page < 1 {
+		page = 1
+	}
+	personal := r.URL.Query().Get("personal") == "1"
+
+	keys, hasNext, err := h.betaKeys.List(r.Context(), personal, page)
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.templates.RenderWithRequest(w, r, "admin/betakeys.html", &response.TemplateData{
+		TitleBar: "Beta keys",
+		Messages: messages,
+		FormData: NormaliseURLValues(r.PostForm),
+		Extra: map[string]interface{}{
+			"Keys":       keys,
+			"Personal":   personal,
+			"InviteOnly": h.betaKeys.InviteOnly(r.Context()),
+			"Page":       page,
+			"HasNext":    hasNext,
+			"Now":        time.Now().Unix(),
+		},
+	})
+}
+
+func (h *BetaKeyHandler) invitesResp(w http.ResponseWriter, r *http.Request, messages ...models.Message) {
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	invites, remaining, err := h.betaKeys.Invites(r.Context(), reqCtx.User.ID, reqCtx.User.Privileges)
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.templates.RenderWithRequest(w, r, "settings/invites.html", &response.TemplateData{
+		TitleBar: "Invites",
+		Messages: messages,
+		Extra: map[string]interface{}{
+			"Invites":    invites,
+			"Remaining":  remaining,
+			"Allowance":  h.betaKeys.InviteAllowance(reqCtx.User.Privileges),
+			"InviteOnly": h.betaKeys.InviteOnly(r.Context()),
+			"InviteURL":  h.inviteURL(""),
+			"Now":        time.Now().Unix(),
+		},
+	})
+}
+
+// checkForm parses the form and checks the CSRF token, redirecting to
+// redirect when something is wrong.
+func (h *BetaKeyHandler) checkForm(w http.ResponseWriter, r *http.Request, redirect string) bool {
+	if err := r.ParseForm(); err != nil {
+		RedirectWithMessage(w, r, h.store, redirect, models.NewError("Invalid form data."))
+		return false
+	}
+
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+	if ok, _ := h.csrf.Validate(reqCtx.User.ID, r.FormValue("csrf")); !ok {
+		RedirectWithMessage(w, r, h.store, redirect, models.NewError("Your session has expired. Please try redoing what you were trying to do."))
+		return false
+	}
+	return true
+}
+
+func (h *BetaKeyHandler) afterAction(w http.ResponseWriter, r *http.Request, redirect string, err error, success string) {
+	if err != nil {
+		if svcErr, ok := err.(*services.ServiceError); ok {
+			RedirectWithMessage(w, r, h.store, redirect, models.NewError(svcErr.Message))
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+	RedirectWithMessage(w, r, h.store, redirect, models.NewSuccess(success))
+}
+
+// inviteURL is the registration link with key filled in.
+func (h *BetaKeyHandler) inviteURL(key string) string {
+	return strings.TrimRight(h.config.App.BaseURL, "/") + "/register?key=" + key
+}
+
+func betaKeyAdminURL(id int64) string {
+	return "/admin/betakeys/" + strconv.FormatInt(id, 10)
+}
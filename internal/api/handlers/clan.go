@@ -13,12 +13,14 @@ import (
 	"github.com/RealistikOsu/soumetsu/internal/api/response"
 	"github.com/RealistikOsu/soumetsu/internal/config"
 	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/services/notes"
 	"github.com/gorilla/sessions"
 )
 
 type ClanHandler struct {
 	config    *config.Config
 	apiClient *api.Client
+	notes     *notes.Service
 	csrf      middleware.CSRFService
 	store     middleware.SessionStore
 	templates *response.TemplateEngine
@@ -27,6 +29,7 @@ type ClanHandler struct {
 func NewClanHandler(
 	cfg *config.Config,
 	apiClient *api.Client,
+	noteService *notes.Service,
 	csrf middleware.CSRFService,
 	store middleware.SessionStore,
 	templates *response.TemplateEngine,
@@ -34,6 +37,7 @@ func NewClanHandler(
 	return &ClanHandler{
 		config:    cfg,
 		apiClient: apiClient,
+		notes:     noteService,
 		csrf:      csrf,
 		store:     store,
 		templates: templates,
@@ -45,7 +49,7 @@ func (h *ClanHandler) ClanPage(w http.ResponseWriter, r *http.Request) {
 	clanParam := chi.URLParam(r, "id")
 	clanID, _ := strconv.Atoi(clanParam)
 
-	h.templates.Render(w, "clans/clan.html", &response.TemplateData{
+	data := &response.TemplateData{
 		TitleBar:  "Clan",
 		DisableHH: true,
 		Context:   reqCtx,
@@ -53,7 +57,18 @@ func (h *ClanHandler) ClanPage(w http.ResponseWriter, r *http.Request) {
 			"ClanID":    clanID,
 			"ClanParam": clanParam,
 		},
-	})
+	}
+
+	if clanID > 0 && reqCtx.User.HasPrivilege(models.AdminPrivilegeAccessRAP) {
+		staffNotes, err := h.notes.Panel(r.Context(), models.AdminTargetClan, clanID)
+		if err != nil {
+			h.templates.InternalError(w, r, err)
+			return
+		}
+		data.Extra["StaffNotes"] = staffNotes
+	}
+
+	h.templates.RenderWithRequest(w, r, "clans/clan.html", data)
 }
 
 func (h *ClanHandler) CreatePage(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	apicontext "github.com/RealistikOsu/soumetsu/internal/api/context"
+	"github.com/RealistikOsu/soumetsu/internal/api/middleware"
+	"github.com/RealistikOsu/soumetsu/internal/api/response"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/clanadmin"
+	"github.com/RealistikOsu/soumetsu/internal/services/notes"
+)
+
+type ClanAdminHandler struct {
+	clans     *clanadmin.Service
+	notes     *notes.Service
+	csrf      middleware.CSRFService
+	store     middleware.SessionStore
+	templates *response.TemplateEngine
+}
+
+func NewClanAdminHandler(
+	clanAdminService *clanadmin.Service,
+	noteService *notes.Service,
+	csrf middleware.CSRFService,
+	store middleware.SessionStore,
+	templates *response.TemplateEngine,
+) *ClanAdminHandler {
+	return &ClanAdminHandler{
+		clans:     clanAdminService,
+		notes:     noteService,
+		csrf:      csrf,
+		store:     store,
+		templates: templates,
+	}
+}
+
+// Search shows the lookup form and its results, jumping straight to the clan
+// when only one matches.
+func (h *ClanAdminHandler) Search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		h.searchResp(w, r, q, nil)
+		return
+	}
+
+	clans, err := h.clans.Search(r.Context(), q)
+	if err != nil {
+		if svcErr, ok := err.(*services.ServiceError); ok {
+			h.searchResp(w, r, q, nil, models.NewError(svcErr.Message))
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	switch len(clans) {
+	case 0:
+		h.searchResp(w, r, q, nil, models.NewWarning("No clan matches that search."))
+	case 1:
+		http.Redirect(w, r, clanAdminURL(clans[0].ID), http.StatusFound)
+	default:
+		h.searchResp(w, r, q, clans)
+	}
+}
+
+func (h *ClanAdminHandler) Show(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return
+	}
+
+	overview, err := h.clans.Overview(r.Context(), id)
+	if err != nil {
+		if _, ok := err.(*services.ServiceError); ok {
+			h.templates.NotFound(w, r)
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+	staffNotes, err := h.notes.Panel(r.Context(), models.AdminTargetClan, id)
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.templates.RenderWithRequest(w, r, "admin/clan.html", &response.TemplateData{
+		TitleBar: overview.Clan.Name,
+		Extra: map[string]interface{}{
+			"Overview":   overview,
+			"StaffNotes": staffNotes,
+		},
+	})
+}
+
+func (h *ClanAdminHandler) Rename(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.clanForm(w, r)
+	if !ok {
+		return
+	}
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	err := h.clans.Rename(r.Context(), reqCtx.User.ID, id, r.FormValue("name"), r.FormValue("tag"))
+	h.afterAction(w, r, clanAdminURL(id), err, "Clan renamed.")
+}
+
+func (h *ClanAdminHandler) RemoveIcon(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.clanForm(w, r)
+	if !ok {
+		return
+	}
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	err := h.clans.RemoveIcon(r.Context(), reqCtx.User.ID, id, r.FormValue("reason"))
+	h.afterAction(w, r, clanAdminURL(id), err, "Icon removed.")
+}
+
+func (h *ClanAdminHandler) Kick(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.clanForm(w, r)
+	if !ok {
+		return
+	}
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	userID, _ := strconv.Atoi(r.FormValue("user_id"))
+	err := h.clans.Kick(r.Context(), reqCtx.User.ID, id, userID, r.FormValue("reason"))
+	h.afterAction(w, r, clanAdminURL(id), err, "Member kicked.")
+}
+
+func (h *ClanAdminHandler) Transfer(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.clanForm(w, r)
+	if !ok {
+		return
+	}
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	userID, _ := strconv.Atoi(r.FormValue("user_id"))
+	err := h.clans.Transfer(r.Context(), reqCtx.User.ID, id, userID)
+	h.afterAction(w, r, clanAdminURL(id), err, "Ownership transferred.")
+}
+
+func (h *ClanAdminHandler) Disband(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.clanForm(w, r)
+	if !ok {
+		return
+	}
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	err := h.clans.Disband(r.Context(), reqCtx.User.ID, id, r.FormValue("reason"))
+	if err != nil {
+		h.afterAction(w, r, clanAdminURL(id), err, "")
+		return
+	}
+	RedirectWithMessage(w, r, h.store, "/admin/clans", models.NewSuccess("Clan disbanded."))
+}
+
+// clanForm parses the form and checks the CSRF token, redirecting back to the
+// clan's page when something is wrong.
+func (h *ClanAdminHandler) clanForm(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return 0, false
+	}
+	if err := r.ParseForm(); err != nil {
+		RedirectWithMessage(w, r, h.store, clanAdminURL(id), models.NewError("Invalid form data."))
+		return 0, false
+	}
+
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+	if ok, _ := h.csrf.Validate(reqCtx.User.ID, r.FormValue("csrf")); !ok {
+		RedirectWithMessage(w, r, h.store, clanAdminURL(id), models.NewError("Your session has expired. Please try redoing what you were trying to do."))
+		return 0, false
+	}
+	return id, true
+}
+
+func (h *ClanAdminHandler) afterAction(w http.ResponseWriter, r *http.Request, redirect string, err error, success string) {
+	if err != nil {
+		if svcErr, ok := err.(*services.ServiceError); ok {
+			RedirectWithMessage(w, r, h.store, redirect, models.NewError(svcErr.Message))
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+	RedirectWithMessage(w, r, h.store, redirect, models.NewSuccess(success))
+}
+
+func (h *ClanAdminHandler) searchResp(w http.ResponseWriter, r *http.Request, q string, clans []models.Clan, messages ...models.Message) {
+	h.templates.RenderWithRequest(w, r, "admin/clans.html", &response.TemplateData{
+		TitleBar: "Clans",
+		Messages: messages,
+		Extra: map[string]interface{}{
+			"Query": q,
+			"Clans": clans,
+		},
+	})
+}
+
+func clanAdminURL(id int) string {
+	return "/admin/clans/" + strconv.Itoa(id)
+}
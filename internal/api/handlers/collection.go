@@ -0,0 +1,375 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	apicontext "github.com/RealistikOsu/soumetsu/internal/api/context"
+	"github.com/RealistikOsu/soumetsu/internal/api/middleware"
+	"github.com/RealistikOsu/soumetsu/internal/api/response"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/collections"
+)
+
+// CollectionHandler serves beatmap favourites and collections, and moves
+// collections in and out of osu!'s collection.db.
+type CollectionHandler struct {
+	collections *collections.Service
+	csrf        middleware.CSRFService
+	store       middleware.SessionStore
+	templates   *response.TemplateEngine
+}
+
+func NewCollectionHandler(
+	collectionService *collections.Service,
+	csrf middleware.CSRFService,
+	store middleware.SessionStore,
+	templates *response.TemplateEngine,
+) *CollectionHandler {
+	return &CollectionHandler{
+		collections: collectionService,
+		csrf:        csrf,
+		store:       store,
+		templates:   templates,
+	}
+}
+
+// FavouritesPage lists a user's favourite beatmap sets.
+func (h *CollectionHandler) FavouritesPage(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("p"))
+	if page < 1 {
+		page = 1
+	}
+
+	favourites, hasNext, err := h.collections.Favourites(r.Context(), user.ID, page)
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.templates.RenderWithRequest(w, r, "collections/favourites.html", &response.TemplateData{
+		TitleBar:  user.Username + "'s favourites",
+		DisableHH: true,
+		Extra: map[string]interface{}{
+			"User":       user,
+			"Favourites": favourites,
+			"Page":       page,
+			"HasNext":    hasNext,
+		},
+	})
+}
+
+// ListPage lists a user's collections, with private ones and the import
+// form for the owner.
+func (h *CollectionHandler) ListPage(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	viewerID := apicontext.GetUserID(r.Context())
+
+	list, err := h.collections.List(r.Context(), user.ID, viewerID)
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.templates.RenderWithRequest(w, r, "collections/list.html", &response.TemplateData{
+		TitleBar:  user.Username + "'s collections",
+		DisableHH: true,
+		Extra: map[string]interface{}{
+			"User":        user,
+			"Collections": list,
+			"Owner":       user.ID == viewerID,
+		},
+	})
+}
+
+func (h *CollectionHandler) Show(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return
+	}
+	viewerID := apicontext.GetUserID(r.Context())
+
+	c, items, err := h.collections.Get(r.Context(), id, viewerID)
+	if err != nil {
+		if _, ok := err.(*services.ServiceError); ok {
+			h.templates.NotFound(w, r)
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.templates.RenderWithRequest(w, r, "collections/show.html", &response.TemplateData{
+		TitleBar:  c.Name,
+		DisableHH: true,
+		Extra: map[string]interface{}{
+			"Collection": c,
+			"Items":      items,
+			"Owner":      c.UserID == viewerID,
+		},
+	})
+}
+
+// Export sends one collection as a collection.db.
+func (h *CollectionHandler) Export(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return
+	}
+
+	_, data, err := h.collections.Export(r.Context(), id, apicontext.GetUserID(r.Context()))
+	if err != nil {
+		if _, ok := err.(*services.ServiceError); ok {
+			h.templates.NotFound(w, r)
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+	sendCollectionDB(w, r, data)
+}
+
+// ExportAll sends every collection of a user's that the visitor can see as
+// one collection.db.
+func (h *CollectionHandler) ExportAll(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+
+	data, err := h.collections.ExportAll(r.Context(), user.ID, apicontext.GetUserID(r.Context()))
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+	sendCollectionDB(w, r, data)
+}
+
+// Favourite adds the set to the user's favourites, or with favourite=0
+// takes it out.
+func (h *CollectionHandler) Favourite(w http.ResponseWriter, r *http.Request) {
+	setID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || setID <= 0 {
+		h.templates.NotFound(w, r)
+		return
+	}
+	redirect := "/beatmapsets/" + strconv.Itoa(setID)
+	if !h.checkForm(w, r, redirect) {
+		return
+	}
+	if next := r.FormValue("next"); next != "" {
+		redirect = safeNext(next)
+	}
+	userID := apicontext.GetUserID(r.Context())
+
+	if r.FormValue("favourite") == "0" {
+		err = h.collections.Unfavourite(r.Context(), userID, setID)
+		h.afterAction(w, r, redirect, err, "Removed from your favourites.")
+		return
+	}
+	err = h.collections.Favourite(r.Context(), userID, setID)
+	h.afterAction(w, r, redirect, err, "Added to your favourites.")
+}
+
+func (h *CollectionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	redirect := ownCollectionsURL(r)
+	if !h.checkForm(w, r, redirect) {
+		return
+	}
+
+	c, err := h.collections.Create(r.Context(), apicontext.GetUserID(r.Context()),
+		r.FormValue("name"), r.FormValue("public") == "1")
+	if err != nil {
+		h.afterAction(w, r, redirect, err, "")
+		return
+	}
+	RedirectWithMessage(w, r, h.store, collectionURL(c.ID), models.NewSuccess("Collection created."))
+}
+
+// Import reads an uploaded collection.db into the user's collections.
+func (h *CollectionHandler) Import(w http.ResponseWriter, r *http.Request) {
+	redirect := ownCollectionsURL(r)
+	r.Body = http.MaxBytesReader(w, r.Body, collections.MaxImportSize+1<<20)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		RedirectWithMessage(w, r, h.store, redirect, models.NewError("Please choose a collection.db file, up to 32MB."))
+		return
+	}
+	if !h.checkForm(w, r, redirect) {
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		RedirectWithMessage(w, r, h.store, redirect, models.NewError("Please choose a collection.db file."))
+		return
+	}
+	defer file.Close()
+
+	result, err := h.collections.Import(r.Context(), apicontext.GetUserID(r.Context()), file)
+	if err != nil {
+		h.afterAction(w, r, redirect, err, "")
+		return
+	}
+	message := fmt.Sprintf("Imported %d %s into %d new and %d existing collections.",
+		result.Beatmaps, plural(result.Beatmaps, "beatmap", "beatmaps"), result.Created, result.Merged)
+	if result.Skipped > 0 {
+		message += fmt.Sprintf(" %d more %s didn't fit under the collection limit.",
+			result.Skipped, plural(result.Skipped, "collection", "collections"))
+	}
+	RedirectWithMessage(w, r, h.store, redirect, models.NewSuccess(message))
+}
+
+func (h *CollectionHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, redirect, ok := h.collectionForm(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.collections.Update(r.Context(), apicontext.GetUserID(r.Context()), id,
+		r.FormValue("name"), r.FormValue("public") == "1")
+	h.afterAction(w, r, redirect, err, "Collection saved.")
+}
+
+func (h *CollectionHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, redirect, ok := h.collectionForm(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.collections.Delete(r.Context(), apicontext.GetUserID(r.Context()), id)
+	if err != nil {
+		h.afterAction(w, r, redirect, err, "")
+		return
+	}
+	RedirectWithMessage(w, r, h.store, ownCollectionsURL(r), models.NewSuccess("Collection deleted."))
+}
+
+// AddBeatmap adds the form's difficulty to the chosen collection, going
+// back to the difficulty afterwards.
+func (h *CollectionHandler) AddBeatmap(w http.ResponseWriter, r *http.Request) {
+	redirect := "/beatmaps"
+	if !h.checkForm(w, r, redirect) {
+		return
+	}
+	beatmapID, _ := strconv.Atoi(r.FormValue("beatmap"))
+	if next := r.FormValue("next"); next != "" {
+		redirect = safeNext(next)
+	} else if beatmapID > 0 {
+		redirect = "/beatmaps/" + strconv.Itoa(beatmapID)
+	}
+	id, err := strconv.ParseInt(r.FormValue("collection"), 10, 64)
+	if err != nil {
+		RedirectWithMessage(w, r, h.store, redirect, models.NewError("Please choose a collection."))
+		return
+	}
+
+	c, _, err := h.collections.AddBeatmap(r.Context(), apicontext.GetUserID(r.Context()), id, beatmapID)
+	if err != nil {
+		h.afterAction(w, r, redirect, err, "")
+		return
+	}
+	RedirectWithMessage(w, r, h.store, redirect, models.NewSuccess("Added to "+c.Name+"."))
+}
+
+func (h *CollectionHandler) RemoveBeatmap(w http.ResponseWriter, r *http.Request) {
+	id, redirect, ok := h.collectionForm(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.collections.RemoveBeatmap(r.Context(), apicontext.GetUserID(r.Context()), id, chi.URLParam(r, "md5"))
+	h.afterAction(w, r, redirect, err, "Removed from the collection.")
+}
+
+// user finds the user a profile page is about, answering not found itself.
+func (h *CollectionHandler) user(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	user, err := h.collections.User(r.Context(), chi.URLParam(r, "id"), apicontext.GetUserID(r.Context()))
+	if err != nil {
+		if _, ok := err.(*services.ServiceError); ok {
+			h.templates.NotFound(w, r)
+			return nil, false
+		}
+		h.templates.InternalError(w, r, err)
+		return nil, false
+	}
+	return user, true
+}
+
+// collectionForm reads the collection ID from the URL and checks the form,
+// returning the collection's page to go back to.
+func (h *CollectionHandler) collectionForm(w http.ResponseWriter, r *http.Request) (int64, string, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return 0, "", false
+	}
+	redirect := collectionURL(id)
+	return id, redirect, h.checkForm(w, r, redirect)
+}
+
+// checkForm parses the form and checks the CSRF token, redirecting to
+// redirect when something is wrong.
+func (h *CollectionHandler) checkForm(w http.ResponseWriter, r *http.Request, redirect string) bool {
+	if err := r.ParseForm(); err != nil {
+		RedirectWithMessage(w, r, h.store, redirect, models.NewError("Invalid form data."))
+		return false
+	}
+
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+	if ok, _ := h.csrf.Validate(reqCtx.User.ID, r.FormValue("csrf")); !ok {
+		RedirectWithMessage(w, r, h.store, redirect, models.NewError("Your session has expired. Please try redoing what you were trying to do."))
+		return false
+	}
+	return true
+}
+
+func (h *CollectionHandler) afterAction(w http.ResponseWriter, r *http.Request, redirect string, err error, success string) {
+	if err != nil {
+		if svcErr, ok := err.(*services.ServiceError); ok {
+			RedirectWithMessage(w, r, h.store, redirect, models.NewError(svcErr.Message))
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+	RedirectWithMessage(w, r, h.store, redirect, models.NewSuccess(success))
+}
+
+// sendCollectionDB sends data as a download named collection.db, which is
+// the name osu! looks for.
+func sendCollectionDB(w http.ResponseWriter, r *http.Request, data []byte) {
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "collection.db"}))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	http.ServeContent(w, r, "collection.db", time.Time{}, bytes.NewReader(data))
+}
+
+func collectionURL(id int64) string {
+	return "/collections/" + strconv.FormatInt(id, 10)
+}
+
+func ownCollectionsURL(r *http.Request) string {
+	return "/u/" + strconv.Itoa(apicontext.GetUserID(r.Context())) + "/collections"
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
@@ -0,0 +1,406 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	apicontext "github.com/RealistikOsu/soumetsu/internal/api/context"
+	"github.com/RealistikOsu/soumetsu/internal/api/middleware"
+	"github.com/RealistikOsu/soumetsu/internal/api/response"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/docs"
+)
+
+// docImportHandlers are the static template pages that can be moved into the
+// docs system. Once a page with the same slug is published it replaces the
+// template page at its old URL.
+var docImportHandlers = []string{"/rules", "/about", "/connection", "/patcher", "/donate"}
+
+type DocHandler struct {
+	docs      *docs.Service
+	csrf      middleware.CSRFService
+	store     middleware.SessionStore
+	templates *response.TemplateEngine
+	imports   []PageConfig
+}
+
+func NewDocHandler(
+	docsService *docs.Service,
+	csrf middleware.CSRFService,
+	store middleware.SessionStore,
+	templates *response.TemplateEngine,
+	pages []PageConfig,
+) *DocHandler {
+	var imports []PageConfig
+	for _, page := range pages {
+		for _, handler := range docImportHandlers {
+			if page.Handler == handler {
+				imports = append(imports, page)
+			}
+		}
+	}
+
+	return &DocHandler{
+		docs:      docsService,
+		csrf:      csrf,
+		store:     store,
+		templates: templates,
+		imports:   imports,
+	}
+}
+
+// IsImportable reports whether the simple page at handler can be replaced by
+// a published doc page.
+func IsImportable(handler string) bool {
+	for _, h := range docImportHandlers {
+		if h == handler {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *DocHandler) Index(w http.ResponseWriter, r *http.Request) {
+	list, err := h.docs.ListPublished(r.Context())
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.templates.RenderWithRequest(w, r, "docs/index.html", &response.TemplateData{
+		TitleBar:  "Documentation",
+		DisableHH: true,
+		Extra: map[string]interface{}{
+			"Docs": list,
+		},
+	})
+}
+
+func (h *DocHandler) Page(w http.ResponseWriter, r *http.Request) {
+	doc, ok := h.docs.Published(r.Context(), chi.URLParam(r, "slug"))
+	if !ok {
+		h.templates.NotFound(w, r)
+		return
+	}
+	h.render(w, r, doc)
+}
+
+// Override serves the published doc for slug in place of next, letting an
+// imported page take over the template page's route.
+func (h *DocHandler) Override(slug string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if doc, ok := h.docs.Published(r.Context(), slug); ok {
+			h.render(w, r, doc)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Fallback serves /{slug} for published docs whose slug no other route
+// claims, and hands everything else to next.
+func (h *DocHandler) Fallback(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := strings.Trim(r.URL.Path, "/")
+		if r.Method == http.MethodGet && slug != "" && !strings.Contains(slug, "/") {
+			if doc, ok := h.docs.Published(r.Context(), slug); ok {
+				h.render(w, r, doc)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+func (h *DocHandler) render(w http.ResponseWriter, r *http.Request, doc *models.PublishedDoc) {
+	h.templates.RenderWithRequest(w, r, "docs/page.html", &response.TemplateData{
+		TitleBar:  doc.Title,
+		DisableHH: true,
+		Extra: map[string]interface{}{
+			"Doc": doc,
+		},
+	})
+}
+
+func (h *DocHandler) AdminList(w http.ResponseWriter, r *http.Request) {
+	h.listResp(w, r)
+}
+
+func (h *DocHandler) Create(w http.ResponseWriter, r *http.Request) {
+	staffID, ok := h.adminForm(w, r, "/admin/docs")
+	if !ok {
+		return
+	}
+
+	id, err := h.docs.Create(r.Context(), staffID, r.FormValue("slug"), r.FormValue("title"))
+	if err != nil {
+		h.afterAction(w, r, "/admin/docs", err, "")
+		return
+	}
+	RedirectWithMessage(w, r, h.store, docAdminURL(id), models.NewSuccess("Page created. Write something and publish it when it's ready."))
+}
+
+// Import snapshots one of the template pages, as a logged-out visitor would
+// see it, into a draft.
+func (h *DocHandler) Import(w http.ResponseWriter, r *http.Request) {
+	staffID, ok := h.adminForm(w, r, "/admin/docs")
+	if !ok {
+		return
+	}
+
+	var page *PageConfig
+	for i := range h.imports {
+		if h.imports[i].Handler == r.FormValue("handler") {
+			page = &h.imports[i]
+		}
+	}
+	if page == nil {
+		RedirectWithMessage(w, r, h.store, "/admin/docs", models.NewError("That page can't be imported."))
+		return
+	}
+
+	html, err := h.templates.RenderFragment(page.Template, &response.TemplateData{
+		TitleBar:  page.TitleBar,
+		KyutGrill: page.KyutGrill,
+	})
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	slug := strings.TrimPrefix(page.Handler, "/")
+	title := page.TitleBar
+	if title == "" {
+		title = strings.ToUpper(slug[:1]) + slug[1:]
+	}
+
+	id, err := h.docs.Import(r.Context(), staffID, slug, title, strings.TrimSpace(html))
+	if err != nil {
+		h.afterAction(w, r, "/admin/docs", err, "")
+		return
+	}
+	RedirectWithMessage(w, r, h.store, docAdminURL(id), models.NewSuccess("Page imported as a draft. Publishing it replaces the old page at "+page.Handler+"."))
+}
+
+func (h *DocHandler) Edit(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return
+	}
+	h.editResp(w, r, id, nil)
+}
+
+func (h *DocHandler) Save(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return
+	}
+	staffID, ok := h.adminForm(w, r, docAdminURL(id))
+	if !ok {
+		return
+	}
+
+	input := docs.SaveInput{
+		Title:   r.FormValue("title"),
+		Body:    r.FormValue("body"),
+		Format:  models.DocFormat(r.FormValue("format")),
+		Summary: r.FormValue("summary"),
+		Publish: r.FormValue("action") == "publish",
+	}
+	if err := h.docs.Save(r.Context(), id, staffID, input); err != nil {
+		if svcErr, ok := err.(*services.ServiceError); ok {
+			if svcErr.Code == "not_found" {
+				h.templates.NotFound(w, r)
+				return
+			}
+			// Keep what was typed rather than bouncing back to the stored draft.
+			h.editResp(w, r, id, &models.DocRevision{
+				Title:   input.Title,
+				Body:    input.Body,
+				Format:  input.Format,
+				Summary: input.Summary,
+			}, models.NewError(svcErr.Message))
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	msg := "Draft saved."
+	if input.Publish {
+		msg = "Page published."
+	}
+	RedirectWithMessage(w, r, h.store, docAdminURL(id), models.NewSuccess(msg))
+}
+
+func (h *DocHandler) Publish(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return
+	}
+	if _, ok := h.adminForm(w, r, docAdminURL(id)); !ok {
+		return
+	}
+
+	revID, _ := strconv.ParseInt(r.FormValue("revision"), 10, 64)
+	err = h.docs.Publish(r.Context(), id, revID)
+	h.afterAction(w, r, docAdminURL(id), err, "Revision #"+strconv.FormatInt(revID, 10)+" is now live.")
+}
+
+func (h *DocHandler) Unpublish(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return
+	}
+	if _, ok := h.adminForm(w, r, docAdminURL(id)); !ok {
+		return
+	}
+
+	err = h.docs.Unpublish(r.Context(), id)
+	h.afterAction(w, r, docAdminURL(id), err, "Page unpublished.")
+}
+
+func (h *DocHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return
+	}
+	if _, ok := h.adminForm(w, r, docAdminURL(id)); !ok {
+		return
+	}
+
+	if err := h.docs.Delete(r.Context(), id); err != nil {
+		h.afterAction(w, r, docAdminURL(id), err, "")
+		return
+	}
+	RedirectWithMessage(w, r, h.store, "/admin/docs", models.NewSuccess("Page deleted."))
+}
+
+func (h *DocHandler) Revision(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return
+	}
+	revID, err := strconv.ParseInt(chi.URLParam(r, "rev"), 10, 64)
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return
+	}
+
+	diff, err := h.docs.Diff(r.Context(), id, revID)
+	if err != nil {
+		if _, ok := err.(*services.ServiceError); ok {
+			h.templates.NotFound(w, r)
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.templates.RenderWithRequest(w, r, "admin/doc_revision.html", &response.TemplateData{
+		TitleBar: "Revision #" + strconv.FormatInt(revID, 10),
+		Extra: map[string]interface{}{
+			"PageID": id,
+			"Diff":   diff,
+		},
+	})
+}
+
+func (h *DocHandler) listResp(w http.ResponseWriter, r *http.Request, messages ...models.Message) {
+	pages, err := h.docs.Pages(r.Context())
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	// Only offer imports for template pages that haven't been moved yet.
+	taken := make(map[string]bool, len(pages))
+	for _, p := range pages {
+		taken["/"+p.Slug] = true
+	}
+	var imports []PageConfig
+	for _, page := range h.imports {
+		if !taken[page.Handler] {
+			imports = append(imports, page)
+		}
+	}
+
+	h.templates.RenderWithRequest(w, r, "admin/docs.html", &response.TemplateData{
+		TitleBar: "Docs",
+		Messages: messages,
+		Extra: map[string]interface{}{
+			"Pages":   pages,
+			"Imports": imports,
+		},
+	})
+}
+
+// editResp renders the editor. draft, when set, replaces the newest revision
+// in the form so a failed save keeps the staff member's changes.
+func (h *DocHandler) editResp(w http.ResponseWriter, r *http.Request, id int64, draft *models.DocRevision, messages ...models.Message) {
+	page, revisions, latest, err := h.docs.Page(r.Context(), id)
+	if err != nil {
+		if _, ok := err.(*services.ServiceError); ok {
+			h.templates.NotFound(w, r)
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+	if draft == nil {
+		draft = latest
+	}
+
+	h.templates.RenderWithRequest(w, r, "admin/doc.html", &response.TemplateData{
+		TitleBar: page.Title,
+		Messages: messages,
+		Extra: map[string]interface{}{
+			"Page":      page,
+			"Revisions": revisions,
+			"Draft":     draft,
+			"Formats":   models.DocFormats,
+		},
+	})
+}
+
+// adminForm parses the form and checks the CSRF token, redirecting to
+// redirect when something is wrong.
+func (h *DocHandler) adminForm(w http.ResponseWriter, r *http.Request, redirect string) (int, bool) {
+	if err := r.ParseForm(); err != nil {
+		RedirectWithMessage(w, r, h.store, redirect, models.NewError("Invalid form data."))
+		return 0, false
+	}
+
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+	if ok, _ := h.csrf.Validate(reqCtx.User.ID, r.FormValue("csrf")); !ok {
+		RedirectWithMessage(w, r, h.store, redirect, models.NewError("Your session has expired. Please try redoing what you were trying to do."))
+		return 0, false
+	}
+	return reqCtx.User.ID, true
+}
+
+func (h *DocHandler) afterAction(w http.ResponseWriter, r *http.Request, redirect string, err error, success string) {
+	if err != nil {
+		if svcErr, ok := err.(*services.ServiceError); ok {
+			RedirectWithMessage(w, r, h.store, redirect, models.NewError(svcErr.Message))
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+	RedirectWithMessage(w, r, h.store, redirect, models.NewSuccess(success))
+}
+
+func docAdminURL(id int64) string {
+	return "/admin/docs/" + strconv.FormatInt(id, 10)
+}
@@ -32,6 +32,10 @@ func (h *ErrorsHandler) Forbidden(w http.ResponseWriter, r *http.Request) {
 	h.templates.Forbidden(w, r)
 }
 
+func (h *ErrorsHandler) ServiceUnavailable(w http.ResponseWriter, r *http.Request) {
+	h.templates.ServiceUnavailable(w, r)
+}
+
 func (h *ErrorsHandler) MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusMethodNotAllowed)
 	h.templates.Render(w, "errors/error_empty.html", &response.TemplateData{
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"net/http"
+
+	apicontext "github.com/RealistikOsu/soumetsu/internal/api/context"
+	"github.com/RealistikOsu/soumetsu/internal/api/middleware"
+	"github.com/RealistikOsu/soumetsu/internal/api/response"
+	"github.com/RealistikOsu/soumetsu/internal/jobs"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	jobFailuresShown = 20
+	jobHistoryShown  = 50
+)
+
+type JobHandler struct {
+	scheduler *jobs.Scheduler
+	csrf      middleware.CSRFService
+	store     middleware.SessionStore
+	templates *response.TemplateEngine
+}
+
+func NewJobHandler(
+	scheduler *jobs.Scheduler,
+	csrf middleware.CSRFService,
+	store middleware.SessionStore,
+	templates *response.TemplateEngine,
+) *JobHandler {
+	return &JobHandler{
+		scheduler: scheduler,
+		csrf:      csrf,
+		store:     store,
+		templates: templates,
+	}
+}
+
+func (h *JobHandler) Index(w http.ResponseWriter, r *http.Request) {
+	statuses, err := h.scheduler.Statuses(r.Context())
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+	failures, err := h.scheduler.Failures(r.Context(), jobFailuresShown)
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+	leader, err := h.scheduler.Leader(r.Context())
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.templates.RenderWithRequest(w, r, "admin/jobs.html", &response.TemplateData{
+		TitleBar: "Jobs",
+		Extra: map[string]interface{}{
+			"Jobs":     statuses,
+			"Failures": failures,
+			"Leader":   leader,
+			"Instance": h.scheduler.Instance(),
+		},
+	})
+}
+
+func (h *JobHandler) View(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	status, err := h.scheduler.Job(r.Context(), name)
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+	if status == nil {
+		h.templates.NotFound(w, r)
+		return
+	}
+	runs, err := h.scheduler.History(r.Context(), name, jobHistoryShown)
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.templates.RenderWithRequest(w, r, "admin/job.html", &response.TemplateData{
+		TitleBar: status.Name,
+		Extra: map[string]interface{}{
+			"Job":  status,
+			"Runs": runs,
+		},
+	})
+}
+
+// Run starts a job now, on the leader if it's a shared job.
+func (h *JobHandler) Run(w http.ResponseWriter, r *http.Request) {
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+	name := chi.URLParam(r, "name")
+	url := "/admin/jobs/" + name
+
+	if ok, _ := h.csrf.Validate(reqCtx.User.ID, r.FormValue("csrf")); !ok {
+		RedirectWithMessage(w, r, h.store, url, models.NewError("Your session has expired. Please try redoing what you were trying to do."))
+		return
+	}
+
+	instance, err := h.scheduler.RunNow(r.Context(), name)
+	if err == jobs.ErrUnknownJob {
+		h.templates.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		RedirectWithMessage(w, r, h.store, url, models.NewError(err.Error()))
+		return
+	}
+
+	RedirectWithMessage(w, r, h.store, url, models.NewSuccess(name+" started on "+instance+"."))
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	apicontext "github.com/RealistikOsu/soumetsu/internal/api/context"
+	"github.com/RealistikOsu/soumetsu/internal/api/middleware"
+	"github.com/RealistikOsu/soumetsu/internal/api/response"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/services/settings"
+)
+
+// datetimeLocalLayout matches the value of an <input type="datetime-local">.
+const datetimeLocalLayout = "2006-01-02T15:04"
+
+type MaintenanceHandler struct {
+	settings  *settings.Service
+	csrf      middleware.CSRFService
+	store     middleware.SessionStore
+	templates *response.TemplateEngine
+}
+
+func NewMaintenanceHandler(
+	settingsService *settings.Service,
+	csrf middleware.CSRFService,
+	store middleware.SessionStore,
+	templates *response.TemplateEngine,
+) *MaintenanceHandler {
+	return &MaintenanceHandler{
+		settings:  settingsService,
+		csrf:      csrf,
+		store:     store,
+		templates: templates,
+	}
+}
+
+func (h *MaintenanceHandler) Page(w http.ResponseWriter, r *http.Request) {
+	h.page(w, r)
+}
+
+func (h *MaintenanceHandler) Update(w http.ResponseWriter, r *http.Request) {
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	if err := r.ParseForm(); err != nil {
+		h.page(w, r, models.NewError("Invalid form data."))
+		return
+	}
+
+	if ok, _ := h.csrf.Validate(reqCtx.User.ID, r.FormValue("csrf")); !ok {
+		h.page(w, r, models.NewError("Your session has expired. Please try redoing what you were trying to do."))
+		return
+	}
+
+	startsAt, err := parseDatetimeLocal(r.FormValue("starts_at"))
+	if err != nil {
+		h.page(w, r, models.NewError("Invalid window start."))
+		return
+	}
+	endsAt, err := parseDatetimeLocal(r.FormValue("ends_at"))
+	if err != nil {
+		h.page(w, r, models.NewError("Invalid window end."))
+		return
+	}
+	if !startsAt.IsZero() && !endsAt.IsZero() && !endsAt.After(startsAt) {
+		h.page(w, r, models.NewError("The window must end after it starts."))
+		return
+	}
+
+	err = h.settings.SetMaintenance(r.Context(), models.Maintenance{
+		Enabled:  r.FormValue("enabled") != "",
+		StartsAt: startsAt,
+		EndsAt:   endsAt,
+		Message:  strings.TrimSpace(r.FormValue("message")),
+	})
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	RedirectWithMessage(w, r, h.store, "/admin/maintenance", models.NewSuccess("Maintenance settings saved."))
+}
+
+func (h *MaintenanceHandler) page(w http.ResponseWriter, r *http.Request, messages ...models.Message) {
+	m, err := h.settings.GetMaintenance(r.Context())
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.templates.RenderWithRequest(w, r, "admin/maintenance.html", &response.TemplateData{
+		TitleBar: "Maintenance",
+		Messages: messages,
+		Extra: map[string]interface{}{
+			"Maintenance": m,
+		},
+	})
+}
+
+func parseDatetimeLocal(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(datetimeLocalLayout, value, time.UTC)
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RealistikOsu/soumetsu/internal/api/response"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/media"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// Artwork and previews hardly ever change once a set is uploaded.
+	mediaMaxAge = 7 * 24 * time.Hour
+	// A placeholder is only kept briefly, so the real artwork is tried
+	// again soon.
+	placeholderMaxAge = 10 * time.Minute
+)
+
+// MediaHandler serves beatmap set artwork and audio previews through this
+// server, so visitors never talk to the asset servers themselves.
+type MediaHandler struct {
+	media     *media.Service
+	templates *response.TemplateEngine
+}
+
+func NewMediaHandler(mediaService *media.Service, templates *response.TemplateEngine) *MediaHandler {
+	return &MediaHandler{
+		media:     mediaService,
+		templates: templates,
+	}
+}
+
+func (h *MediaHandler) Cover(w http.ResponseWriter, r *http.Request) {
+	h.serveImage(w, r, media.Cover)
+}
+
+func (h *MediaHandler) Card(w http.ResponseWriter, r *http.Request) {
+	h.serveImage(w, r, media.Card)
+}
+
+func (h *MediaHandler) Thumbnail(w http.ResponseWriter, r *http.Request) {
+	h.serveImage(w, r, media.Thumbnail)
+}
+
+// serveImage always sends an image, falling back to a placeholder, so pages
+// never show a broken one.
+func (h *MediaHandler) serveImage(w http.ResponseWriter, r *http.Request, img media.Image) {
+	setID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || setID <= 0 {
+		h.templates.NotFound(w, r)
+		return
+	}
+
+	maxAge := mediaMaxAge
+	file, err := h.media.Image(r.Context(), setID, img)
+	if err != nil {
+		if _, ok := err.(*services.ServiceError); !ok {
+			slog.Error("failed to load beatmap artwork", "error", err, "set_id", setID, "size", img.Name)
+		}
+		file = h.media.Placeholder(img)
+		maxAge = placeholderMaxAge
+	}
+	serveMedia(w, r, img.Name+".jpg", "image/jpeg", file, maxAge)
+}
+
+// BeatmapPreview sends the audio preview of a difficulty's set.
+func (h *MediaHandler) BeatmapPreview(w http.ResponseWriter, r *http.Request) {
+	beatmapID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || beatmapID <= 0 {
+		h.templates.NotFound(w, r)
+		return
+	}
+	file, err := h.media.Preview(r.Context(), beatmapID)
+	h.servePreview(w, r, file, err)
+}
+
+func (h *MediaHandler) SetPreview(w http.ResponseWriter, r *http.Request) {
+	setID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || setID <= 0 {
+		h.templates.NotFound(w, r)
+		return
+	}
+	file, err := h.media.SetPreview(r.Context(), setID)
+	h.servePreview(w, r, file, err)
+}
+
+// servePreview sends a failure as a plain error status rather than a
+// placeholder like artwork gets. An audio player shows a failed preview as
+// one it can't play, whereas silence in its place would pass for a working
+// preview.
+func (h *MediaHandler) servePreview(w http.ResponseWriter, r *http.Request, file *media.File, err error) {
+	if err != nil {
+		if svcErr, ok := err.(*services.ServiceError); ok {
+			http.Error(w, svcErr.Message, svcErr.StatusCode)
+			return
+		}
+		slog.Error("failed to load beatmap preview", "error", err)
+		http.Error(w, "The preview couldn't be loaded.", http.StatusInternalServerError)
+		return
+	}
+	serveMedia(w, r, "preview.mp3", "audio/mpeg", file, mediaMaxAge)
+}
+
+// serveMedia streams the file and closes it, answering conditional and range
+// requests from its ETag and modification time.
+func serveMedia(w http.ResponseWriter, r *http.Request, name, contentType string, file *media.File, maxAge time.Duration) {
+	defer file.Close()
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", file.ETag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge/time.Second)))
+	http.ServeContent(w, r, name, file.ModTime, file.Content)
+}
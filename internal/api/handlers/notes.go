@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	apicontext "github.com/RealistikOsu/soumetsu/internal/api/context"
+	"github.com/RealistikOsu/soumetsu/internal/api/middleware"
+	"github.com/RealistikOsu/soumetsu/internal/api/response"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/notes"
+)
+
+type NoteHandler struct {
+	notes     *notes.Service
+	csrf      middleware.CSRFService
+	store     middleware.SessionStore
+	templates *response.TemplateEngine
+}
+
+func NewNoteHandler(
+	noteService *notes.Service,
+	csrf middleware.CSRFService,
+	store middleware.SessionStore,
+	templates *response.TemplateEngine,
+) *NoteHandler {
+	return &NoteHandler{
+		notes:     noteService,
+		csrf:      csrf,
+		store:     store,
+		templates: templates,
+	}
+}
+
+// Create adds a note. Like the other note actions it redirects back to the
+// page in "next", which is where the notes panel was shown.
+func (h *NoteHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !h.checkForm(w, r) {
+		return
+	}
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	targetType := models.AdminTarget(r.FormValue("target_type"))
+	targetID, _ := strconv.Atoi(r.FormValue("target_id"))
+	note, err := h.notes.Add(r.Context(), reqCtx.User.ID, targetType, targetID, r.FormValue("body"), r.FormValue("pinned") == "1")
+	h.afterAction(w, r, note, err, "Note added.")
+}
+
+func (h *NoteHandler) Edit(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.noteForm(w, r)
+	if !ok {
+		return
+	}
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	note, err := h.notes.Edit(r.Context(), reqCtx.User.ID, id, r.FormValue("body"))
+	h.afterAction(w, r, note, err, "Note updated.")
+}
+
+func (h *NoteHandler) Pin(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.noteForm(w, r)
+	if !ok {
+		return
+	}
+
+	pinned := r.FormValue("pinned") == "1"
+	note, err := h.notes.Pin(r.Context(), id, pinned)
+	message := "Note unpinned."
+	if pinned {
+		message = "Note pinned."
+	}
+	h.afterAction(w, r, note, err, message)
+}
+
+func (h *NoteHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.noteForm(w, r)
+	if !ok {
+		return
+	}
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	note, err := h.notes.Delete(r.Context(), reqCtx.User.ID, reqCtx.User.Privileges, id)
+	h.afterAction(w, r, note, err, "Note deleted.")
+}
+
+// History shows every version of a note.
+func (h *NoteHandler) History(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return
+	}
+
+	note, revisions, err := h.notes.History(r.Context(), id)
+	if err != nil {
+		if _, ok := err.(*services.ServiceError); ok {
+			h.templates.NotFound(w, r)
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.templates.RenderWithRequest(w, r, "admin/note.html", &response.TemplateData{
+		TitleBar: "Staff note",
+		Extra: map[string]interface{}{
+			"Note":      note,
+			"Revisions": revisions,
+			"TargetURL": note.TargetType.AdminURL(note.TargetID),
+		},
+	})
+}
+
+func (h *NoteHandler) noteForm(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return 0, false
+	}
+	return id, h.checkForm(w, r)
+}
+
+// checkForm parses the form and checks the CSRF token, redirecting back when
+// something is wrong.
+func (h *NoteHandler) checkForm(w http.ResponseWriter, r *http.Request) bool {
+	if err := r.ParseForm(); err != nil {
+		RedirectWithMessage(w, r, h.store, noteRedirect(r, nil), models.NewError("Invalid form data."))
+		return false
+	}
+
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+	if ok, _ := h.csrf.Validate(reqCtx.User.ID, r.FormValue("csrf")); !ok {
+		RedirectWithMessage(w, r, h.store, noteRedirect(r, nil), models.NewError("Your session has expired. Please try redoing what you were trying to do."))
+		return false
+	}
+	return true
+}
+
+func (h *NoteHandler) afterAction(w http.ResponseWriter, r *http.Request, note *models.StaffNote, err error, success string) {
+	redirect := noteRedirect(r, note)
+	if err != nil {
+		if svcErr, ok := err.(*services.ServiceError); ok {
+			RedirectWithMessage(w, r, h.store, redirect, models.NewError(svcErr.Message))
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+	RedirectWithMessage(w, r, h.store, redirect, models.NewSuccess(success))
+}
+
+// noteRedirect is the form's "next" page, falling back to the admin page of
+// what the note is about.
+func noteRedirect(r *http.Request, note *models.StaffNote) string {
+	if next := r.FormValue("next"); next != "" {
+		return safeNext(next)
+	}
+	if note != nil {
+		return note.TargetType.AdminURL(note.TargetID)
+	}
+	return "/admin"
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/RealistikOsu/soumetsu/internal/api/response"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/performance"
+)
+
+type PerformanceHandler struct {
+	performance *performance.Service
+	templates   *response.TemplateEngine
+}
+
+func NewPerformanceHandler(performanceService *performance.Service, templates *response.TemplateEngine) *PerformanceHandler {
+	return &PerformanceHandler{
+		performance: performanceService,
+		templates:   templates,
+	}
+}
+
+type modOption struct {
+	Acronym string
+	Checked bool
+}
+
+// CalculatorPage is the pp calculator. Like the search page it's a GET form,
+// so a calculation can be linked to.
+func (h *PerformanceHandler) CalculatorPage(w http.ResponseWriter, r *http.Request) {
+	data := &response.TemplateData{
+		TitleBar:  "PP Calculator",
+		KyutGrill: "leaderboard2.jpg",
+		DisableHH: true,
+	}
+	values := r.URL.Query()
+	// Keep the boxes that were ticked even if the combination is refused.
+	selected, _ := performance.ParseMods(strings.Join(values["mods"], ""))
+	extra := map[string]interface{}{
+		"Form": values,
+		"Mods": modOptions(selected),
+	}
+	data.Extra = extra
+	if values.Get("beatmap") == "" {
+		h.templates.RenderWithRequest(w, r, "tools/pp.html", data)
+		return
+	}
+
+	result, err := h.calculate(r)
+	if err != nil {
+		svcErr, ok := err.(*services.ServiceError)
+		if !ok {
+			h.templates.InternalError(w, r, err)
+			return
+		}
+		data.Messages = []models.Message{models.NewError(svcErr.Message)}
+		h.templates.RenderWithRequest(w, r, "tools/pp.html", data)
+		return
+	}
+	extra["Result"] = result
+	h.templates.RenderWithRequest(w, r, "tools/pp.html", data)
+}
+
+// Calculate is the calculator's JSON endpoint, taking the same parameters
+// as the page.
+func (h *PerformanceHandler) Calculate(w http.ResponseWriter, r *http.Request) {
+	result, err := h.calculate(r)
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	response.JSONSuccess(w, result)
+}
+
+func (h *PerformanceHandler) calculate(r *http.Request) (*performance.Result, error) {
+	q, err := performance.ParseQuery(r.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+	return h.performance.Calculate(r.Context(), q)
+}
+
+func modOptions(selected []string) []modOption {
+	options := make([]modOption, 0, len(performance.Mods))
+	for _, m := range performance.Mods {
+		option := modOption{Acronym: m}
+		for _, s := range selected {
+			if s == m {
+				option.Checked = true
+			}
+		}
+		options = append(options, option)
+	}
+	return options
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	apicontext "github.com/RealistikOsu/soumetsu/internal/api/context"
+	"github.com/RealistikOsu/soumetsu/internal/api/middleware"
+	"github.com/RealistikOsu/soumetsu/internal/api/response"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/rankrequest"
+)
+
+type RankRequestHandler struct {
+	rankRequests *rankrequest.Service
+	csrf         middleware.CSRFService
+	store        middleware.SessionStore
+	templates    *response.TemplateEngine
+}
+
+func NewRankRequestHandler(
+	rankRequestService *rankrequest.Service,
+	csrf middleware.CSRFService,
+	store middleware.SessionStore,
+	templates *response.TemplateEngine,
+) *RankRequestHandler {
+	return &RankRequestHandler{
+		rankRequests: rankRequestService,
+		csrf:         csrf,
+		store:        store,
+		templates:    templates,
+	}
+}
+
+type submitRankRequest struct {
+	URL string `json:"url"`
+}
+
+// Status backs the queue summary on the rank request page.
+func (h *RankRequestHandler) Status(w http.ResponseWriter, r *http.Request) {
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	status, err := h.rankRequests.Status(r.Context(), reqCtx.User.ID)
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	response.JSONSuccess(w, status)
+}
+
+// List returns requests for the public listing, pending first by default.
+func (h *RankRequestHandler) List(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	limit, _ := strconv.Atoi(query.Get("limit"))
+
+	status := models.RankRequestStatus(query.Get("status"))
+	if status == "" {
+		status = models.RankRequestPending
+	} else if status == "all" || !status.Valid() {
+		status = ""
+	}
+
+	reqs, hasMore, err := h.rankRequests.List(r.Context(), status, page, limit)
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	if reqs == nil {
+		reqs = []models.RankRequest{}
+	}
+
+	response.JSONSuccess(w, map[string]interface{}{
+		"requests": reqs,
+		"has_more": hasMore,
+	})
+}
+
+func (h *RankRequestHandler) Submit(w http.ResponseWriter, r *http.Request) {
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	if ok, _ := h.csrf.Validate(reqCtx.User.ID, r.Header.Get("X-CSRF-Token")); !ok {
+		response.JSONError(w, http.StatusForbidden, "Your session has expired. Please refresh the page and try again.")
+		return
+	}
+
+	var body submitRankRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		response.JSONError(w, http.StatusBadRequest, "Invalid request body.")
+		return
+	}
+
+	req, err := h.rankRequests.Submit(r.Context(), reqCtx.User.ID, body.URL)
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	response.JSONSuccess(w, req)
+}
+
+// Queue is the BAT review page.
+func (h *RankRequestHandler) Queue(w http.ResponseWriter, r *http.Request) {
+	h.queueResp(w, r)
+}
+
+func (h *RankRequestHandler) Review(w http.ResponseWriter, r *http.Request) {
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+	redirect := "/admin/rank-requests"
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		RedirectWithMessage(w, r, h.store, redirect, models.NewError("Invalid form data."))
+		return
+	}
+	if ok, _ := h.csrf.Validate(reqCtx.User.ID, r.FormValue("csrf")); !ok {
+		RedirectWithMessage(w, r, h.store, redirect, models.NewError("Your session has expired. Please try redoing what you were trying to do."))
+		return
+	}
+
+	req, err := h.rankRequests.Review(r.Context(), id, reqCtx.User.ID,
+		models.RankRequestStatus(r.FormValue("decision")), r.FormValue("reason"))
+	if err != nil {
+		if svcErr, ok := err.(*services.ServiceError); ok {
+			RedirectWithMessage(w, r, h.store, redirect, models.NewError(svcErr.Message))
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	RedirectWithMessage(w, r, h.store, redirect,
+		models.NewSuccess(req.SongName+" has been marked as "+req.Status.Label()+"."))
+}
+
+func (h *RankRequestHandler) queueResp(w http.ResponseWriter, r *http.Request, messages ...models.Message) {
+	query := r.URL.Query()
+
+	status := models.RankRequestStatus(query.Get("status"))
+	if !status.Valid() {
+		status = models.RankRequestPending
+	}
+	page, _ := strconv.Atoi(query.Get("p"))
+	if page < 1 {
+		page = 1
+	}
+
+	reqs, hasMore, err := h.rankRequests.List(r.Context(), status, page, rankrequest.PageSize)
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+	counts, err := h.rankRequests.StatusCounts(r.Context())
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.templates.RenderWithRequest(w, r, "admin/rank_requests.html", &response.TemplateData{
+		TitleBar: "Rank Requests",
+		Messages: messages,
+		Extra: map[string]interface{}{
+			"Requests": reqs,
+			"Counts":   counts,
+			"Statuses": models.RankRequestStatuses,
+			"Status":   status,
+			"Page":     page,
+			"HasNext":  hasMore,
+		},
+	})
+}
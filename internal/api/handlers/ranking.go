@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	apicontext "github.com/RealistikOsu/soumetsu/internal/api/context"
+	"github.com/RealistikOsu/soumetsu/internal/api/middleware"
+	"github.com/RealistikOsu/soumetsu/internal/api/response"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/notes"
+	"github.com/RealistikOsu/soumetsu/internal/services/ranking"
+	"github.com/RealistikOsu/soumetsu/internal/services/rankrequest"
+)
+
+type statusOption struct {
+	Value int
+	Label string
+}
+
+type RankingHandler struct {
+	ranking   *ranking.Service
+	notes     *notes.Service
+	csrf      middleware.CSRFService
+	store     middleware.SessionStore
+	templates *response.TemplateEngine
+}
+
+func NewRankingHandler(
+	rankingService *ranking.Service,
+	noteService *notes.Service,
+	csrf middleware.CSRFService,
+	store middleware.SessionStore,
+	templates *response.TemplateEngine,
+) *RankingHandler {
+	return &RankingHandler{
+		ranking:   rankingService,
+		notes:     noteService,
+		csrf:      csrf,
+		store:     store,
+		templates: templates,
+	}
+}
+
+// Index shows the set lookup and recent changes, or jumps straight to the
+// console when ?q= holds a beatmap link or ID.
+func (h *RankingHandler) Index(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		h.indexResp(w, r, q)
+		return
+	}
+
+	requestType, id, ok := rankrequest.ParseLink(q)
+	if !ok {
+		h.indexResp(w, r, q, models.NewError("That doesn't look like a beatmap link or ID."))
+		return
+	}
+
+	setID := id
+	if requestType == models.RankRequestTypeBeatmap {
+		var err error
+		setID, err = h.ranking.FindSetID(r.Context(), id)
+		if err != nil {
+			if svcErr, ok := err.(*services.ServiceError); ok {
+				h.indexResp(w, r, q, models.NewError(svcErr.Message))
+				return
+			}
+			h.templates.InternalError(w, r, err)
+			return
+		}
+	}
+
+	http.Redirect(w, r, consoleURL(setID), http.StatusFound)
+}
+
+func (h *RankingHandler) Console(w http.ResponseWriter, r *http.Request) {
+	setID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return
+	}
+
+	console, err := h.ranking.Load(r.Context(), setID)
+	if err != nil {
+		if svcErr, ok := err.(*services.ServiceError); ok {
+			h.indexResp(w, r, strconv.Itoa(setID), models.NewError(svcErr.Message))
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+	staffNotes, err := h.notes.Panel(r.Context(), models.AdminTargetBeatmapSet, setID)
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.templates.RenderWithRequest(w, r, "admin/beatmaps.html", &response.TemplateData{
+		TitleBar: console.Set.Artist + " - " + console.Set.Title,
+		Extra: map[string]interface{}{
+			"Query":      strconv.Itoa(setID),
+			"Console":    console,
+			"Log":        console.Log,
+			"Statuses":   statusOptions(),
+			"StaffNotes": staffNotes,
+		},
+	})
+}
+
+// SetStatus applies a status to the ticked difficulties, or to the whole set
+// when the "set" button was used.
+func (h *RankingHandler) SetStatus(w http.ResponseWriter, r *http.Request) {
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	setID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return
+	}
+	redirect := consoleURL(setID)
+
+	if err := r.ParseForm(); err != nil {
+		RedirectWithMessage(w, r, h.store, redirect, models.NewError("Invalid form data."))
+		return
+	}
+	if ok, _ := h.csrf.Validate(reqCtx.User.ID, r.FormValue("csrf")); !ok {
+		RedirectWithMessage(w, r, h.store, redirect, models.NewError("Your session has expired. Please try redoing what you were trying to do."))
+		return
+	}
+
+	status, err := strconv.Atoi(r.FormValue("status"))
+	if err != nil {
+		RedirectWithMessage(w, r, h.store, redirect, models.NewError("Please pick a status."))
+		return
+	}
+
+	change := ranking.Change{
+		SetID:  setID,
+		Status: status,
+		Reason: r.FormValue("reason"),
+		UserID: reqCtx.User.ID,
+	}
+	if r.FormValue("scope") != "set" {
+		for _, v := range r.Form["beatmap"] {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				continue
+			}
+			change.BeatmapIDs = append(change.BeatmapIDs, id)
+		}
+		if len(change.BeatmapIDs) == 0 {
+			RedirectWithMessage(w, r, h.store, redirect, models.NewError("Tick the difficulties to change, or apply the status to the whole set."))
+			return
+		}
+	}
+
+	result, err := h.ranking.SetStatus(r.Context(), change)
+	if err != nil {
+		if svcErr, ok := err.(*services.ServiceError); ok {
+			RedirectWithMessage(w, r, h.store, redirect, models.NewError(svcErr.Message))
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	msg := fmt.Sprintf("%d difficulties set to %s.", result.Changed, models.RankedStatusLabel(status))
+	if result.Changed == 0 {
+		msg = "Nothing changed."
+	}
+	if result.Unknown > 0 {
+		msg += fmt.Sprintf(" %d difficulties were skipped because the server hasn't loaded them yet; they can be changed once someone has played them.", result.Unknown)
+	}
+	if result.Changed == 0 {
+		RedirectWithMessage(w, r, h.store, redirect, models.NewWarning(msg))
+		return
+	}
+	RedirectWithMessage(w, r, h.store, redirect, models.NewSuccess(msg))
+}
+
+func (h *RankingHandler) indexResp(w http.ResponseWriter, r *http.Request, query string, messages ...models.Message) {
+	log, err := h.ranking.RecentLog(r.Context())
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.templates.RenderWithRequest(w, r, "admin/beatmaps.html", &response.TemplateData{
+		TitleBar: "Beatmaps",
+		Messages: messages,
+		Extra: map[string]interface{}{
+			"Query": query,
+			"Log":   log,
+		},
+	})
+}
+
+func statusOptions() []statusOption {
+	options := make([]statusOption, 0, len(models.SettableStatuses))
+	for _, s := range models.SettableStatuses {
+		options = append(options, statusOption{Value: s, Label: models.RankedStatusLabel(s)})
+	}
+	return options
+}
+
+func consoleURL(setID int) string {
+	return "/admin/beatmaps/" + strconv.Itoa(setID)
+}
@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	apicontext "github.com/RealistikOsu/soumetsu/internal/api/context"
+	"github.com/RealistikOsu/soumetsu/internal/api/middleware"
+	"github.com/RealistikOsu/soumetsu/internal/api/response"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/repositories"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/report"
+)
+
+type ReportHandler struct {
+	reports   *report.Service
+	csrf      middleware.CSRFService
+	store     middleware.SessionStore
+	templates *response.TemplateEngine
+}
+
+func NewReportHandler(
+	reportService *report.Service,
+	csrf middleware.CSRFService,
+	store middleware.SessionStore,
+	templates *response.TemplateEngine,
+) *ReportHandler {
+	return &ReportHandler{
+		reports:   reportService,
+		csrf:      csrf,
+		store:     store,
+		templates: templates,
+	}
+}
+
+// NewPage renders the report form for ?type=<target>&id=<id>.
+func (h *ReportHandler) NewPage(w http.ResponseWriter, r *http.Request) {
+	targetType := models.ReportTarget(r.URL.Query().Get("type"))
+	targetID, _ := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+
+	h.newResp(w, r, targetType, targetID, nil)
+}
+
+func (h *ReportHandler) Submit(w http.ResponseWriter, r *http.Request) {
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	if err := r.ParseForm(); err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	targetType := models.ReportTarget(r.FormValue("type"))
+	targetID, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+
+	if ok, _ := h.csrf.Validate(reqCtx.User.ID, r.FormValue("csrf")); !ok {
+		h.newResp(w, r, targetType, targetID, r.Form, models.NewError("Your session has expired. Please try redoing what you were trying to do."))
+		return
+	}
+
+	_, err := h.reports.Submit(r.Context(), report.SubmitInput{
+		ReporterID: reqCtx.User.ID,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Category:   r.FormValue("category"),
+		Reason:     r.FormValue("reason"),
+		Evidence:   r.FormValue("evidence"),
+	})
+	if err != nil {
+		if svcErr, ok := err.(*services.ServiceError); ok {
+			h.newResp(w, r, targetType, targetID, r.Form, models.NewError(svcErr.Message))
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	RedirectWithMessage(w, r, h.store, "/reports", models.NewSuccess("Thank you! Your report has been sent to our staff. You'll be notified in-game once it has been reviewed."))
+}
+
+func (h *ReportHandler) MyReports(w http.ResponseWriter, r *http.Request) {
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	reports, err := h.reports.ListForReporter(r.Context(), reqCtx.User.ID)
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.templates.RenderWithRequest(w, r, "reports/list.html", &response.TemplateData{
+		TitleBar:  "My Reports",
+		DisableHH: true,
+		Extra: map[string]interface{}{
+			"Reports": reports,
+		},
+	})
+}
+
+// Queue is the staff triage list, filterable by status and assignee.
+func (h *ReportHandler) Queue(w http.ResponseWriter, r *http.Request) {
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+	query := r.URL.Query()
+
+	filter := repositories.ReportFilter{
+		Status:     models.ReportStatus(query.Get("status")),
+		TargetType: models.ReportTarget(query.Get("target_type")),
+	}
+	if query.Get("status") == "" {
+		filter.Status = models.ReportStatusOpen
+	} else if query.Get("status") == "all" || !filter.Status.Valid() {
+		filter.Status = ""
+	}
+	if !filter.TargetType.Valid() {
+		filter.TargetType = ""
+	}
+	if query.Get("mine") == "1" {
+		filter.AssigneeID = reqCtx.User.ID
+	}
+	filter.TargetID, _ = strconv.ParseInt(query.Get("target_id"), 10, 64)
+	page, _ := strconv.Atoi(query.Get("p"))
+	if page < 1 {
+		page = 1
+	}
+
+	reports, total, err := h.reports.List(r.Context(), filter, page)
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+	counts, err := h.reports.StatusCounts(r.Context())
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.templates.RenderWithRequest(w, r, "admin/reports.html", &response.TemplateData{
+		TitleBar: "Reports",
+		Extra: map[string]interface{}{
+			"Reports":      reports,
+			"Total":        total,
+			"Counts":       counts,
+			"Statuses":     models.ReportStatuses,
+			"Filter":       filter,
+			"StatusFilter": query.Get("status"),
+			"Mine":         query.Get("mine") == "1",
+			"Page":         page,
+			"HasNext":      page*report.PageSize < total,
+		},
+	})
+}
+
+func (h *ReportHandler) View(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return
+	}
+
+	rep, notes, err := h.reports.Get(r.Context(), id)
+	if err != nil {
+		if svcErr, ok := err.(*services.ServiceError); ok && svcErr.Code == "not_found" {
+			h.templates.NotFound(w, r)
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	// The target may have been deleted since the report was filed; the report
+	// itself is still worth showing.
+	target, _ := h.reports.GetTarget(r.Context(), rep.TargetType, rep.TargetID)
+
+	staff, err := h.reports.Staff(r.Context())
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.templates.RenderWithRequest(w, r, "admin/report.html", &response.TemplateData{
+		TitleBar: "Report #" + strconv.FormatInt(rep.ID, 10),
+		Extra: map[string]interface{}{
+			"Report":      rep,
+			"Target":      target,
+			"Notes":       notes,
+			"Staff":       staff,
+			"Resolutions": models.ReportResolutions,
+		},
+	})
+}
+
+func (h *ReportHandler) Assign(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.adminForm(w, r)
+	if !ok {
+		return
+	}
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	assigneeID, _ := strconv.Atoi(r.FormValue("assignee"))
+	err := h.reports.Assign(r.Context(), id, reqCtx.User.ID, assigneeID)
+	h.afterAction(w, r, id, err, "Assignment updated.")
+}
+
+func (h *ReportHandler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.adminForm(w, r)
+	if !ok {
+		return
+	}
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	err := h.reports.SetStatus(r.Context(), id, reqCtx.User.ID, models.ReportStatus(r.FormValue("status")))
+	h.afterAction(w, r, id, err, "Status updated.")
+}
+
+func (h *ReportHandler) AddNote(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.adminForm(w, r)
+	if !ok {
+		return
+	}
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	err := h.reports.AddNote(r.Context(), id, reqCtx.User.ID, r.FormValue("content"))
+	h.afterAction(w, r, id, err, "Note added.")
+}
+
+func (h *ReportHandler) Close(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.adminForm(w, r)
+	if !ok {
+		return
+	}
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	err := h.reports.Close(r.Context(), id, reqCtx.User.ID, r.FormValue("resolution"), r.FormValue("message"))
+	h.afterAction(w, r, id, err, "Report closed and the reporter has been notified.")
+}
+
+// adminForm parses the report ID and form and checks the CSRF token, writing
+// the response itself when something is wrong.
+func (h *ReportHandler) adminForm(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return 0, false
+	}
+
+	if err := r.ParseForm(); err != nil {
+		RedirectWithMessage(w, r, h.store, reportURL(id), models.NewError("Invalid form data."))
+		return 0, false
+	}
+
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+	if ok, _ := h.csrf.Validate(reqCtx.User.ID, r.FormValue("csrf")); !ok {
+		RedirectWithMessage(w, r, h.store, reportURL(id), models.NewError("Your session has expired. Please try redoing what you were trying to do."))
+		return 0, false
+	}
+
+	return id, true
+}
+
+func (h *ReportHandler) afterAction(w http.ResponseWriter, r *http.Request, id int64, err error, success string) {
+	if err != nil {
+		if svcErr, ok := err.(*services.ServiceError); ok {
+			RedirectWithMessage(w, r, h.store, reportURL(id), models.NewError(svcErr.Message))
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+	RedirectWithMessage(w, r, h.store, reportURL(id), models.NewSuccess(success))
+}
+
+func (h *ReportHandler) newResp(w http.ResponseWriter, r *http.Request, targetType models.ReportTarget, targetID int64, form map[string][]string, messages ...models.Message) {
+	target, err := h.reports.GetTarget(r.Context(), targetType, targetID)
+	if err != nil {
+		if _, ok := err.(*services.ServiceError); ok {
+			h.templates.NotFound(w, r)
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	var categories []models.ReportCategory
+	for _, c := range models.ReportCategories {
+		if c.AppliesTo(targetType) {
+			categories = append(categories, c)
+		}
+	}
+
+	h.templates.RenderWithRequest(w, r, "reports/new.html", &response.TemplateData{
+		TitleBar:  "Report " + strings.ToLower(targetType.Label()),
+		DisableHH: true,
+		Messages:  messages,
+		FormData:  form,
+		Extra: map[string]interface{}{
+			"Target":     target,
+			"Categories": categories,
+		},
+	})
+}
+
+func reportURL(id int64) string {
+	return "/admin/reports/" + strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	apicontext "github.com/RealistikOsu/soumetsu/internal/api/context"
+	"github.com/RealistikOsu/soumetsu/internal/api/middleware"
+	"github.com/RealistikOsu/soumetsu/internal/api/response"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/rules"
+)
+
+type RulesHandler struct {
+	rules     *rules.Service
+	csrf      middleware.CSRFService
+	store     middleware.SessionStore
+	templates *response.TemplateEngine
+}
+
+func NewRulesHandler(
+	rulesService *rules.Service,
+	csrf middleware.CSRFService,
+	store middleware.SessionStore,
+	templates *response.TemplateEngine,
+) *RulesHandler {
+	return &RulesHandler{
+		rules:     rulesService,
+		csrf:      csrf,
+		store:     store,
+		templates: templates,
+	}
+}
+
+// AcceptPage is the interstitial the rules middleware sends users to.
+func (h *RulesHandler) AcceptPage(w http.ResponseWriter, r *http.Request) {
+	h.acceptResp(w, r, safeNext(r.URL.Query().Get("next")))
+}
+
+func (h *RulesHandler) Accept(w http.ResponseWriter, r *http.Request) {
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	if err := r.ParseForm(); err != nil {
+		h.acceptResp(w, r, "/", models.NewError("Invalid form data."))
+		return
+	}
+	next := safeNext(r.FormValue("next"))
+
+	if ok, _ := h.csrf.Validate(reqCtx.User.ID, r.FormValue("csrf")); !ok {
+		h.acceptResp(w, r, next, models.NewError("Your session has expired. Please try redoing what you were trying to do."))
+		return
+	}
+
+	versionID, _ := strconv.ParseInt(r.FormValue("version"), 10, 64)
+	if err := h.rules.Accept(r.Context(), reqCtx.User.ID, versionID, apicontext.ClientIP(r)); err != nil {
+		if svcErr, ok := err.(*services.ServiceError); ok {
+			h.acceptResp(w, r, next, models.NewWarning(svcErr.Message))
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	RedirectWithMessage(w, r, h.store, next, models.NewSuccess("Thanks for accepting the rules!"))
+}
+
+func (h *RulesHandler) AdminPage(w http.ResponseWriter, r *http.Request) {
+	h.adminResp(w, r)
+}
+
+// Require turns the live rules into a new version everyone has to accept.
+func (h *RulesHandler) Require(w http.ResponseWriter, r *http.Request) {
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+	redirect := "/admin/rules"
+
+	if err := r.ParseForm(); err != nil {
+		RedirectWithMessage(w, r, h.store, redirect, models.NewError("Invalid form data."))
+		return
+	}
+	if ok, _ := h.csrf.Validate(reqCtx.User.ID, r.FormValue("csrf")); !ok {
+		RedirectWithMessage(w, r, h.store, redirect, models.NewError("Your session has expired. Please try redoing what you were trying to do."))
+		return
+	}
+
+	if err := h.rules.Require(r.Context(), reqCtx.User.ID, r.FormValue("summary")); err != nil {
+		if svcErr, ok := err.(*services.ServiceError); ok {
+			RedirectWithMessage(w, r, h.store, redirect, models.NewError(svcErr.Message))
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	RedirectWithMessage(w, r, h.store, redirect, models.NewSuccess("Users will be asked to accept the new rules."))
+}
+
+func (h *RulesHandler) acceptResp(w http.ResponseWriter, r *http.Request, next string, messages ...models.Message) {
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	needs, err := h.rules.NeedsAcceptance(r.Context(), reqCtx.User.ID)
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+	if !needs {
+		http.Redirect(w, r, next, http.StatusFound)
+		return
+	}
+
+	version, rev, err := h.rules.Pending(r.Context())
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.templates.RenderWithRequest(w, r, "rules/accept.html", &response.TemplateData{
+		TitleBar:  "Rules update",
+		DisableHH: true,
+		Messages:  messages,
+		Extra: map[string]interface{}{
+			"Version":  version,
+			"Revision": rev,
+			"Next":     next,
+		},
+	})
+}
+
+func (h *RulesHandler) adminResp(w http.ResponseWriter, r *http.Request) {
+	versions, err := h.rules.Versions(r.Context())
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	live := h.rules.Live(r.Context())
+	var pending bool
+	if live != nil {
+		pending = len(versions) == 0 || versions[0].RevisionID != live.ID
+	}
+
+	h.templates.RenderWithRequest(w, r, "admin/rules.html", &response.TemplateData{
+		TitleBar: "Rules",
+		Extra: map[string]interface{}{
+			"Versions": versions,
+			"Live":     live,
+			"Pending":  pending,
+		},
+	})
+}
+
+// safeNext only allows redirects back onto this site.
+func safeNext(next string) string {
+	if len(next) == 0 || next[0] != '/' || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/RealistikOsu/soumetsu/internal/api/response"
 	"github.com/RealistikOsu/soumetsu/internal/config"
 	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/services/notes"
 	"github.com/gorilla/sessions"
 )
 
@@ -24,6 +25,7 @@ const discordOAuthStateKey = "discord_oauth_state"
 type UserHandler struct {
 	config    *config.Config
 	apiClient *api.Client
+	notes     *notes.Service
 	csrf      middleware.CSRFService
 	store     middleware.SessionStore
 	templates *response.TemplateEngine
@@ -32,6 +34,7 @@ type UserHandler struct {
 func NewUserHandler(
 	cfg *config.Config,
 	apiClient *api.Client,
+	noteService *notes.Service,
 	csrf middleware.CSRFService,
 	store middleware.SessionStore,
 	templates *response.TemplateEngine,
@@ -39,6 +42,7 @@ func NewUserHandler(
 	return &UserHandler{
 		config:    cfg,
 		apiClient: apiClient,
+		notes:     noteService,
 		csrf:      csrf,
 		store:     store,
 		templates: templates,
@@ -66,7 +70,16 @@ func (h *UserHandler) Profile(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 
-	h.templates.Render(w, "profile.html", data)
+	if reqCtx.User.HasPrivilege(models.AdminPrivilegeAccessRAP) {
+		staffNotes, err := h.notes.UserPanel(r.Context(), userParam)
+		if err != nil {
+			h.templates.InternalError(w, r, err)
+			return
+		}
+		data.Extra["StaffNotes"] = staffNotes
+	}
+
+	h.templates.RenderWithRequest(w, r, "profile.html", data)
 }
 
 func (h *UserHandler) SettingsPage(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	apicontext "github.com/RealistikOsu/soumetsu/internal/api/context"
+	"github.com/RealistikOsu/soumetsu/internal/api/middleware"
+	"github.com/RealistikOsu/soumetsu/internal/api/response"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/notes"
+	"github.com/RealistikOsu/soumetsu/internal/services/useradmin"
+)
+
+type UserAdminHandler struct {
+	users     *useradmin.Service
+	notes     *notes.Service
+	csrf      middleware.CSRFService
+	store     middleware.SessionStore
+	templates *response.TemplateEngine
+}
+
+func NewUserAdminHandler(
+	userAdminService *useradmin.Service,
+	noteService *notes.Service,
+	csrf middleware.CSRFService,
+	store middleware.SessionStore,
+	templates *response.TemplateEngine,
+) *UserAdminHandler {
+	return &UserAdminHandler{
+		users:     userAdminService,
+		notes:     noteService,
+		csrf:      csrf,
+		store:     store,
+		templates: templates,
+	}
+}
+
+// Search shows the lookup form and its results, jumping straight to the user
+// when only one account matches.
+func (h *UserAdminHandler) Search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		h.searchResp(w, r, q, nil)
+		return
+	}
+
+	matches, err := h.users.Search(r.Context(), q)
+	if err != nil {
+		if svcErr, ok := err.(*services.ServiceError); ok {
+			h.searchResp(w, r, q, nil, models.NewError(svcErr.Message))
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	switch len(matches) {
+	case 0:
+		h.searchResp(w, r, q, nil, models.NewWarning("No user matches that search."))
+	case 1:
+		http.Redirect(w, r, userAdminURL(matches[0].User.ID), http.StatusFound)
+	default:
+		h.searchResp(w, r, q, matches)
+	}
+}
+
+func (h *UserAdminHandler) Show(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return
+	}
+
+	mode, _ := strconv.Atoi(r.URL.Query().Get("mode"))
+	customMode, _ := strconv.Atoi(r.URL.Query().Get("cm"))
+	if mode < 0 || mode > 3 {
+		mode = 0
+	}
+	if customMode < 0 || customMode > 2 {
+		customMode = 0
+	}
+
+	overview, err := h.users.Overview(r.Context(), id, mode, customMode)
+	if err != nil {
+		if _, ok := err.(*services.ServiceError); ok {
+			h.templates.NotFound(w, r)
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+	staffNotes, err := h.notes.Panel(r.Context(), models.AdminTargetUser, id)
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.templates.RenderWithRequest(w, r, "admin/user.html", &response.TemplateData{
+		TitleBar: overview.User.Username,
+		Extra: map[string]interface{}{
+			"Overview":   overview,
+			"StaffNotes": staffNotes,
+			"Now":        time.Now().Unix(),
+		},
+	})
+}
+
+func (h *UserAdminHandler) Rename(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.userForm(w, r)
+	if !ok {
+		return
+	}
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	err := h.users.Rename(r.Context(), reqCtx.User.ID, id, r.FormValue("username"))
+	h.afterAction(w, r, userAdminURL(id), err, "Username changed.")
+}
+
+// Restrict restricts the user for the given number of days, or for good
+// when it's 0 or left out.
+func (h *UserAdminHandler) Restrict(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.userForm(w, r)
+	if !ok {
+		return
+	}
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	days := 0
+	if v := strings.TrimSpace(r.FormValue("days")); v != "" {
+		var err error
+		if days, err = strconv.Atoi(v); err != nil {
+			RedirectWithMessage(w, r, h.store, userAdminURL(id), models.NewError("Please give the length in whole days."))
+			return
+		}
+	}
+	err := h.users.Restrict(r.Context(), reqCtx.User.ID, id, days, r.FormValue("reason"))
+	h.afterAction(w, r, userAdminURL(id), err, "User restricted.")
+}
+
+func (h *UserAdminHandler) WipePage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return
+	}
+	h.wipeResp(w, r, id, wipeForm{Mode: -1, CustomMode: -1}, nil)
+}
+
+// Wipe previews the wipe first; the preview page posts back with
+// action=wipe to actually run it.
+func (h *UserAdminHandler) Wipe(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.userForm(w, r)
+	if !ok {
+		return
+	}
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	form := wipeForm{Reason: r.FormValue("reason")}
+	form.Mode, _ = strconv.Atoi(r.FormValue("mode"))
+	form.CustomMode, _ = strconv.Atoi(r.FormValue("custom_mode"))
+
+	if r.FormValue("action") != "wipe" {
+		previews, err := h.users.WipePreview(r.Context(), id, form.Mode, form.CustomMode)
+		if err != nil {
+			if svcErr, ok := err.(*services.ServiceError); ok {
+				h.wipeResp(w, r, id, form, nil, models.NewError(svcErr.Message))
+				return
+			}
+			h.templates.InternalError(w, r, err)
+			return
+		}
+		h.wipeResp(w, r, id, form, previews)
+		return
+	}
+
+	deleted, err := h.users.Wipe(r.Context(), reqCtx.User.ID, id, form.Mode, form.CustomMode, form.Reason)
+	if err != nil {
+		if svcErr, ok := err.(*services.ServiceError); ok {
+			h.wipeResp(w, r, id, form, nil, models.NewError(svcErr.Message))
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	RedirectWithMessage(w, r, h.store, userAdminURL(id)+"/wipe", models.NewSuccess("Wiped "+strconv.FormatInt(deleted, 10)+" scores."))
+}
+
+type wipeForm struct {
+	Mode       int
+	CustomMode int
+	Reason     string
+}
+
+func (h *UserAdminHandler) wipeResp(w http.ResponseWriter, r *http.Request, id int, form wipeForm, previews []models.WipePreview, messages ...models.Message) {
+	user, err := h.users.User(r.Context(), id)
+	if err != nil {
+		if _, ok := err.(*services.ServiceError); ok {
+			h.templates.NotFound(w, r)
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.templates.RenderWithRequest(w, r, "admin/user_wipe.html", &response.TemplateData{
+		TitleBar: "Wipe " + user.Username,
+		Messages: messages,
+		Extra: map[string]interface{}{
+			"User":            user,
+			"Form":            form,
+			"Previews":        previews,
+			"ModeNames":       models.ModeNames,
+			"CustomModeNames": models.CustomModeNames,
+		},
+	})
+}
+
+// userForm parses the form and checks the CSRF token, redirecting back to the
+// user's page when something is wrong.
+func (h *UserAdminHandler) userForm(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		h.templates.NotFound(w, r)
+		return 0, false
+	}
+	if err := r.ParseForm(); err != nil {
+		RedirectWithMessage(w, r, h.store, userAdminURL(id), models.NewError("Invalid form data."))
+		return 0, false
+	}
+
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+	if ok, _ := h.csrf.Validate(reqCtx.User.ID, r.FormValue("csrf")); !ok {
+		RedirectWithMessage(w, r, h.store, userAdminURL(id), models.NewError("Your session has expired. Please try redoing what you were trying to do."))
+		return 0, false
+	}
+	return id, true
+}
+
+func (h *UserAdminHandler) afterAction(w http.ResponseWriter, r *http.Request, redirect string, err error, success string) {
+	if err != nil {
+		if svcErr, ok := err.(*services.ServiceError); ok {
+			RedirectWithMessage(w, r, h.store, redirect, models.NewError(svcErr.Message))
+			return
+		}
+		h.templates.InternalError(w, r, err)
+		return
+	}
+	RedirectWithMessage(w, r, h.store, redirect, models.NewSuccess(success))
+}
+
+func (h *UserAdminHandler) searchResp(w http.ResponseWriter, r *http.Request, q string, matches []useradmin.Match, messages ...models.Message) {
+	h.templates.RenderWithRequest(w, r, "admin/users.html", &response.TemplateData{
+		TitleBar: "Users",
+		Messages: messages,
+		Extra: map[string]interface{}{
+			"Query":   q,
+			"Matches": matches,
+		},
+	})
+}
+
+func userAdminURL(id int) string {
+	return "/admin/users/" + strconv.Itoa(id)
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"net/http"
+
+	apicontext "github.com/RealistikOsu/soumetsu/internal/api/context"
+	"github.com/RealistikOsu/soumetsu/internal/api/middleware"
+	"github.com/RealistikOsu/soumetsu/internal/api/response"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/webhooks"
+)
+
+type WebhookHandler struct {
+	webhooks  *webhooks.Service
+	csrf      middleware.CSRFService
+	store     middleware.SessionStore
+	templates *response.TemplateEngine
+}
+
+func NewWebhookHandler(
+	webhooksService *webhooks.Service,
+	csrf middleware.CSRFService,
+	store middleware.SessionStore,
+	templates *response.TemplateEngine,
+) *WebhookHandler {
+	return &WebhookHandler{
+		webhooks:  webhooksService,
+		csrf:      csrf,
+		store:     store,
+		templates: templates,
+	}
+}
+
+func (h *WebhookHandler) Page(w http.ResponseWriter, r *http.Request) {
+	h.page(w, r)
+}
+
+// Update saves the routes for every event, from one routes_<event> field
+// each.
+func (h *WebhookHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if !h.checkForm(w, r) {
+		return
+	}
+
+	for _, t := range webhooks.EventTypes {
+		if err := h.webhooks.SetRoute(r.Context(), t, r.FormValue("routes_"+string(t))); err != nil {
+			h.fail(w, r, err)
+			return
+		}
+	}
+
+	RedirectWithMessage(w, r, h.store, "/admin/webhooks", models.NewSuccess("Webhook routes saved."))
+}
+
+// Test queues a test event to the URLs routed for ?event=.
+func (h *WebhookHandler) Test(w http.ResponseWriter, r *http.Request) {
+	if !h.checkForm(w, r) {
+		return
+	}
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	t := webhooks.EventType(r.FormValue("event"))
+	if !t.Valid() {
+		h.page(w, r, models.NewError("Unknown event."))
+		return
+	}
+	if err := h.webhooks.SendTest(r.Context(), t, reqCtx.User.Username); err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	RedirectWithMessage(w, r, h.store, "/admin/webhooks", models.NewSuccess("Test queued for "+t.Label()+"."))
+}
+
+func (h *WebhookHandler) checkForm(w http.ResponseWriter, r *http.Request) bool {
+	reqCtx := apicontext.GetRequestContextFromRequest(r)
+
+	if err := r.ParseForm(); err != nil {
+		h.page(w, r, models.NewError("Invalid form data."))
+		return false
+	}
+	if ok, _ := h.csrf.Validate(reqCtx.User.ID, r.FormValue("csrf")); !ok {
+		h.page(w, r, models.NewError("Your session has expired. Please try redoing what you were trying to do."))
+		return false
+	}
+	return true
+}
+
+func (h *WebhookHandler) fail(w http.ResponseWriter, r *http.Request, err error) {
+	if svcErr, ok := err.(*services.ServiceError); ok {
+		h.page(w, r, models.NewError(svcErr.Message))
+		return
+	}
+	h.templates.InternalError(w, r, err)
+}
+
+func (h *WebhookHandler) page(w http.ResponseWriter, r *http.Request, messages ...models.Message) {
+	routes, err := h.webhooks.Routes(r.Context())
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+	pending, err := h.webhooks.Pending(r.Context())
+	if err != nil {
+		h.templates.InternalError(w, r, err)
+		return
+	}
+
+	h.templates.RenderWithRequest(w, r, "admin/webhooks.html", &response.TemplateData{
+		TitleBar: "Webhooks",
+		Messages: messages,
+		Extra: map[string]interface{}{
+			"Routes":  routes,
+			"Pending": pending,
+			"Signed":  h.webhooks.Signed(),
+		},
+	})
+}
@@ -136,6 +136,25 @@ func RequireAuth(next http.Handler) http.Handler {
 	})
 }
 
+// RequirePrivilege rejects requests from users lacking any of the given
+// privileges. Guests get the same 401 as RequireAuth.
+func RequirePrivilege(privs models.UserPrivileges) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := apicontext.GetRequestContextFromRequest(r)
+			if reqCtx.User.ID == 0 {
+				http.Error(w, "Unauthorised", http.StatusUnauthorized)
+				return
+			}
+			if !reqCtx.User.HasPrivilege(privs) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func RequireGuest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		reqCtx := apicontext.GetRequestContextFromRequest(r)
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	apicontext "github.com/RealistikOsu/soumetsu/internal/api/context"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+)
+
+type MaintenanceSource interface {
+	GetMaintenance(ctx context.Context) (models.Maintenance, error)
+}
+
+// maintenanceExemptSections, and everything under them, stay reachable
+// during maintenance so staff can still log in and the 503 page can load its
+// assets.
+var maintenanceExemptSections = []string{
+	"/static",
+	"/favicon.ico",
+	"/login",
+	"/logout",
+}
+
+// unknownMaintenanceRetry is the Retry-After sent when the maintenance state
+// can't be loaded at all.
+const unknownMaintenanceRetry = 30 * time.Second
+
+// Maintenance serves the unavailable handler with a Retry-After header to
+// everyone but staff while website maintenance is active. It must run after
+// SessionInitializer so the user's privileges are known.
+func Maintenance(source MaintenanceSource, unavailable http.HandlerFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isMaintenanceExempt(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			reqCtx := apicontext.GetRequestContextFromRequest(r)
+			if reqCtx.User.HasPrivilege(models.AdminPrivilegeAccessRAP) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// The source serves its last known state through errors, so an
+			// error here means none was ever loaded. Fail closed: this is most
+			// likely a migration, which is what maintenance is for.
+			m, err := source.GetMaintenance(r.Context())
+			if err != nil {
+				slog.Error("failed to load maintenance state", "error", err)
+				w.Header().Set("Retry-After", strconv.Itoa(int(unknownMaintenanceRetry.Seconds())))
+				unavailable(w, r)
+				return
+			}
+
+			now := time.Now()
+			if !m.ActiveAt(now) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			retryAfter := int(m.RetryAfter(now).Seconds())
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			unavailable(w, r)
+		})
+	}
+}
+
+func isMaintenanceExempt(path string) bool {
+	for _, section := range maintenanceExemptSections {
+		if path == section || strings.HasPrefix(path, section+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"sync"
 	"time"
@@ -13,8 +14,7 @@ type RateLimiter struct {
 	buckets  map[string]*tokenBucket
 	rate     int           // tokens per second
 	capacity int           // max tokens in bucket
-	cleanup  time.Duration // cleanup interval for expired buckets
-	done     chan struct{} // channel to signal shutdown
+	idle     time.Duration // how long an unused bucket is kept
 }
 
 type tokenBucket struct {
@@ -23,42 +23,27 @@ type tokenBucket struct {
 }
 
 func NewRateLimiter(rate, capacity int) *RateLimiter {
-	rl := &RateLimiter{
+	return &RateLimiter{
 		buckets:  make(map[string]*tokenBucket),
 		rate:     rate,
 		capacity: capacity,
-		cleanup:  5 * time.Minute,
-		done:     make(chan struct{}),
+		idle:     5 * time.Minute,
 	}
-
-	go rl.cleanupLoop()
-
-	return rl
 }
 
-func (rl *RateLimiter) cleanupLoop() {
-	ticker := time.NewTicker(rl.cleanup)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ticker.C:
-			rl.mu.Lock()
-			cutoff := time.Now().Add(-rl.cleanup)
-			for ip, bucket := range rl.buckets {
-				if bucket.lastUpdate.Before(cutoff) {
-					delete(rl.buckets, ip)
-				}
-			}
-			rl.mu.Unlock()
-		case <-rl.done:
-			return
+// Cleanup forgets buckets that haven't been used for a while. It's run by
+// the ratelimit.cleanup job.
+func (rl *RateLimiter) Cleanup(ctx context.Context) error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	cutoff := time.Now().Add(-rl.idle)
+	for ip, bucket := range rl.buckets {
+		if bucket.lastUpdate.Before(cutoff) {
+			delete(rl.buckets, ip)
 		}
 	}
-}
-
-// Stop gracefully shuts down the rate limiter's cleanup goroutine
-func (rl *RateLimiter) Stop() {
-	close(rl.done)
+	return nil
 }
 
 func (rl *RateLimiter) Allow(ip string) bool {
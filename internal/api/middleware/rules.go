@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	apicontext "github.com/RealistikOsu/soumetsu/internal/api/context"
+)
+
+type RulesSource interface {
+	NeedsAcceptance(ctx context.Context, userID int) (bool, error)
+}
+
+// RulesAcceptancePath is the interstitial users are sent to when there is a
+// new version of the rules for them to accept.
+const RulesAcceptancePath = "/rules/accept"
+
+// rulesExemptPrefixes stay reachable before accepting so users can read the
+// rules, log out, and the interstitial can load its assets.
+var rulesExemptPrefixes = []string{
+	"/static/",
+	"/favicon.ico",
+	"/logout",
+}
+
+// rulesExemptSections are exempt along with everything under them, but not
+// paths that merely start with the same letters.
+var rulesExemptSections = []string{
+	"/rules",
+	"/docs",
+}
+
+// RulesAcceptance sends logged-in users to the acceptance interstitial until
+// they accept the current rules. Only page navigations are redirected;
+// form posts and background requests pass through so nothing in flight is
+// lost. It must run after SessionInitializer.
+func RulesAcceptance(source RulesSource) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := apicontext.GetRequestContextFromRequest(r)
+			if reqCtx.User.ID == 0 || r.Method != http.MethodGet || !acceptsHTML(r) || isRulesExempt(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			needs, err := source.NeedsAcceptance(r.Context(), reqCtx.User.ID)
+			if err != nil {
+				slog.Error("failed to check rules acceptance", "error", err, "user_id", reqCtx.User.ID)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !needs {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			http.Redirect(w, r, RulesAcceptancePath+"?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+		})
+	}
+}
+
+func acceptsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+func isRulesExempt(path string) bool {
+	for _, prefix := range rulesExemptPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	for _, section := range rulesExemptSections {
+		if path == section || strings.HasPrefix(path, section+"/") {
+			return true
+		}
+	}
+	return false
+}
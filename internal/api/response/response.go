@@ -2,6 +2,7 @@ package response
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
@@ -77,6 +78,22 @@ type TemplateData struct {
 	SystemSettings map[string]interface{} // System settings (pre-fetched to avoid template queries)
 	Session        *SessionWrapper        // Session access wrapper
 	ServerStats    ServerStats            // Server statistics (online/registered users)
+	Announcements  []models.Announcement  // Live announcements for the current user
+	Meta           *PageMeta              // Link preview metadata, if the page has any
+}
+
+// PageMeta describes a page to link previews: OpenGraph and Twitter card tags,
+// an oEmbed discovery link and JSON-LD. URLs must be absolute.
+type PageMeta struct {
+	Title       string
+	Description string
+	URL         string
+	Image       string
+	// Type is the og:type, "website" if empty.
+	Type      string
+	OEmbedURL string
+	// JSONLD is marshalled into an application/ld+json script.
+	JSONLD interface{}
 }
 
 func (td *TemplateData) Get(endpoint string, args ...interface{}) interface{} {
@@ -107,10 +124,22 @@ func NewSessionWrapper(sess *sessions.Session) *SessionWrapper {
 	return &SessionWrapper{values: sess.Values}
 }
 
+// SystemSettingsSource supplies the system settings exposed to every template.
+type SystemSettingsSource interface {
+	TemplateSettings(ctx context.Context) map[string]interface{}
+}
+
+// AnnouncementSource supplies the announcement banners shown to a user.
+type AnnouncementSource interface {
+	ForUser(ctx context.Context, user models.SessionUser) []models.Announcement
+}
+
 type TemplateEngine struct {
-	templates map[string]*template.Template
-	funcMap   template.FuncMap
-	config    interface{} // Config for template access
+	templates     map[string]*template.Template
+	funcMap       template.FuncMap
+	config        interface{} // Config for template access
+	settings      SystemSettingsSource
+	announcements AnnouncementSource
 }
 
 func NewTemplateEngine(templates map[string]*template.Template, funcMap template.FuncMap) *TemplateEngine {
@@ -124,6 +153,32 @@ func (e *TemplateEngine) SetConfig(config interface{}) {
 	e.config = config
 }
 
+func (e *TemplateEngine) SetSystemSettingsSource(source SystemSettingsSource) {
+	e.settings = source
+}
+
+func (e *TemplateEngine) SetAnnouncementSource(source AnnouncementSource) {
+	e.announcements = source
+}
+
+func (e *TemplateEngine) systemSettings() map[string]interface{} {
+	if e.settings == nil {
+		return make(map[string]interface{})
+	}
+	return e.settings.TemplateSettings(context.Background())
+}
+
+func (e *TemplateEngine) announcementsFor(reqCtx interface{}) []models.Announcement {
+	if e.announcements == nil {
+		return nil
+	}
+	var user models.SessionUser
+	if c, ok := reqCtx.(*apicontext.RequestContext); ok && c != nil {
+		user = c.User
+	}
+	return e.announcements.ForUser(context.Background(), user)
+}
+
 func (e *TemplateEngine) RenderWithStatus(w http.ResponseWriter, name string, data *TemplateData, statusCode int) error {
 	if data == nil {
 		data = &TemplateData{}
@@ -138,7 +193,7 @@ func (e *TemplateEngine) RenderWithStatus(w http.ResponseWriter, name string, da
 		data.Params = make(map[string]string)
 	}
 	if data.SystemSettings == nil {
-		data.SystemSettings = make(map[string]interface{})
+		data.SystemSettings = e.systemSettings()
 	}
 	if data.Context == nil {
 		data.Context = &apicontext.RequestContext{}
@@ -200,7 +255,7 @@ func (e *TemplateEngine) Render(w http.ResponseWriter, name string, data *Templa
 		data.Params = make(map[string]string)
 	}
 	if data.SystemSettings == nil {
-		data.SystemSettings = make(map[string]interface{})
+		data.SystemSettings = e.systemSettings()
 	}
 	if data.Context == nil {
 		data.Context = &apicontext.RequestContext{}
@@ -208,6 +263,9 @@ func (e *TemplateEngine) Render(w http.ResponseWriter, name string, data *Templa
 	if data.Session == nil {
 		data.Session = &SessionWrapper{values: make(map[interface{}]interface{})}
 	}
+	if data.Announcements == nil {
+		data.Announcements = e.announcementsFor(data.Context)
+	}
 
 	tmpl, ok := e.templates[name]
 	if !ok {
@@ -264,6 +322,43 @@ func (e *TemplateEngine) Render(w http.ResponseWriter, name string, data *Templa
 	return err
 }
 
+// RenderFragment executes only the "tpl" block of a page, without the base
+// layout, and returns the output.
+func (e *TemplateEngine) RenderFragment(name string, data *TemplateData) (string, error) {
+	tmpl, ok := e.templates[name]
+	if !ok || tmpl.Lookup("tpl") == nil {
+		return "", fmt.Errorf("template not found: %s", name)
+	}
+
+	if data == nil {
+		data = &TemplateData{}
+	}
+	if data.Conf == nil && e.config != nil {
+		data.Conf = e.config
+	}
+	if data.QueryParams == nil {
+		data.QueryParams = make(map[string]string)
+	}
+	if data.Params == nil {
+		data.Params = make(map[string]string)
+	}
+	if data.SystemSettings == nil {
+		data.SystemSettings = e.systemSettings()
+	}
+	if data.Context == nil {
+		data.Context = &apicontext.RequestContext{}
+	}
+	if data.Session == nil {
+		data.Session = &SessionWrapper{values: make(map[interface{}]interface{})}
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "tpl", data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
 type bufferedResponseWriter struct {
 	http.ResponseWriter
 	buffer         *bytes.Buffer
@@ -379,6 +474,24 @@ func (e *TemplateEngine) Forbidden(w http.ResponseWriter, r *http.Request) {
 	e.RenderWithStatus(w, "errors/error_403.html", data, http.StatusForbidden)
 }
 
+// ServiceUnavailable renders the maintenance page. Callers are expected to
+// have set Retry-After already.
+func (e *TemplateEngine) ServiceUnavailable(w http.ResponseWriter, r *http.Request) {
+	var reqCtx interface{}
+	var path string
+	if r != nil {
+		reqCtx = apicontext.GetRequestContextFromRequest(r)
+		path = r.URL.Path
+	}
+
+	data := &TemplateData{
+		TitleBar: "Maintenance",
+		Path:     path,
+		Context:  reqCtx,
+	}
+	e.RenderWithStatus(w, "errors/error_503.html", data, http.StatusServiceUnavailable)
+}
+
 func AddMessage(messages *[]models.Message, msg models.Message) {
 	*messages = append(*messages, msg)
 }
@@ -8,17 +8,37 @@ import (
 	"strings"
 
 	"github.com/RealistikOsu/soumetsu/internal/adapters/api"
+	"github.com/RealistikOsu/soumetsu/internal/adapters/beatmapcache"
+	"github.com/RealistikOsu/soumetsu/internal/adapters/mirror"
 	"github.com/RealistikOsu/soumetsu/internal/adapters/mysql"
 	"github.com/RealistikOsu/soumetsu/internal/adapters/redis"
 	"github.com/RealistikOsu/soumetsu/internal/api/handlers"
 	"github.com/RealistikOsu/soumetsu/internal/api/middleware"
 	"github.com/RealistikOsu/soumetsu/internal/api/response"
 	"github.com/RealistikOsu/soumetsu/internal/config"
+	"github.com/RealistikOsu/soumetsu/internal/jobs"
 	"github.com/RealistikOsu/soumetsu/internal/models"
 	"github.com/RealistikOsu/soumetsu/internal/repositories"
+	"github.com/RealistikOsu/soumetsu/internal/services/analytics"
+	"github.com/RealistikOsu/soumetsu/internal/services/announcement"
 	"github.com/RealistikOsu/soumetsu/internal/services/auth"
 	"github.com/RealistikOsu/soumetsu/internal/services/beatmap"
+	"github.com/RealistikOsu/soumetsu/internal/services/betakeys"
+	"github.com/RealistikOsu/soumetsu/internal/services/clanadmin"
+	"github.com/RealistikOsu/soumetsu/internal/services/collections"
+	"github.com/RealistikOsu/soumetsu/internal/services/docs"
+	"github.com/RealistikOsu/soumetsu/internal/services/download"
+	"github.com/RealistikOsu/soumetsu/internal/services/media"
+	"github.com/RealistikOsu/soumetsu/internal/services/notes"
+	"github.com/RealistikOsu/soumetsu/internal/services/performance"
+	"github.com/RealistikOsu/soumetsu/internal/services/ranking"
+	"github.com/RealistikOsu/soumetsu/internal/services/rankrequest"
+	"github.com/RealistikOsu/soumetsu/internal/services/report"
+	"github.com/RealistikOsu/soumetsu/internal/services/rules"
+	"github.com/RealistikOsu/soumetsu/internal/services/settings"
 	"github.com/RealistikOsu/soumetsu/internal/services/stats"
+	"github.com/RealistikOsu/soumetsu/internal/services/useradmin"
+	"github.com/RealistikOsu/soumetsu/internal/services/webhooks"
 	"github.com/RealistikOsu/soumetsu/web/templates"
 	"github.com/boj/redistore"
 	"github.com/gorilla/sessions"
@@ -27,16 +47,57 @@ import (
 type App struct {
 	Config *config.Config
 
-	DB        *mysql.DB
-	Redis     *redis.Client
-	APIClient *api.Client
-
-	TokenRepo *repositories.TokenRepository
-	UserRepo  *repositories.UserRepository
-
-	AuthService    *auth.Service
-	BeatmapService *beatmap.Service
-	StatsService   *stats.Service
+	DB         *mysql.DB
+	Redis      *redis.Client
+	APIClient  *api.Client
+	Mirror     *mirror.Client
+	Downloader *mirror.Downloader
+	OsuFiles   *mirror.OsuFiles
+	Assets     *mirror.Assets
+	Beatmaps   *beatmapcache.Cache
+
+	TokenRepo           *repositories.TokenRepository
+	UserRepo            *repositories.UserRepository
+	SystemRepo          *repositories.SystemRepository
+	ClanRepo            *repositories.ClanRepository
+	ReportRepo          *repositories.ReportRepository
+	BeatmapRepo         *repositories.BeatmapRepository
+	RankRequestRepo     *repositories.RankRequestRepository
+	AnnouncementRepo    *repositories.AnnouncementRepository
+	DocRepo             *repositories.DocRepository
+	RulesRepo           *repositories.RulesRepository
+	DiscordRepo         *repositories.DiscordRepository
+	StatsRepo           *repositories.StatsRepository
+	AdminLogRepo        *repositories.AdminLogRepository
+	RestrictionRepo     *repositories.RestrictionRepository
+	RegistrationKeyRepo *repositories.RegistrationKeyRepository
+	StaffNoteRepo       *repositories.StaffNoteRepository
+	AnalyticsRepo       *repositories.AnalyticsRepository
+	JobRunRepo          *repositories.JobRunRepository
+	CollectionRepo      *repositories.CollectionRepository
+
+	AuthService         *auth.Service
+	BeatmapService      *beatmap.Service
+	DownloadService     *download.Service
+	PerformanceService  *performance.Service
+	MediaService        *media.Service
+	StatsService        *stats.Service
+	SettingsService     *settings.Service
+	ReportService       *report.Service
+	RankingService      *ranking.Service
+	RankRequestService  *rankrequest.Service
+	AnnouncementService *announcement.Service
+	DocService          *docs.Service
+	RulesService        *rules.Service
+	UserAdminService    *useradmin.Service
+	ClanAdminService    *clanadmin.Service
+	BetaKeyService      *betakeys.Service
+	NoteService         *notes.Service
+	AnalyticsService    *analytics.Service
+	WebhookService      *webhooks.Service
+	CollectionService   *collections.Service
+
+	Scheduler *jobs.Scheduler
 
 	CSRF         middleware.CSRFService
 	SessionStore middleware.SessionStore
@@ -52,6 +113,25 @@ type App struct {
 	BeatmapHandler  *handlers.BeatmapHandler
 	PagesHandler    *handlers.PagesHandler
 	ErrorsHandler   *handlers.ErrorsHandler
+
+	MaintenanceHandler  *handlers.MaintenanceHandler
+	ReportHandler       *handlers.ReportHandler
+	RankRequestHandler  *handlers.RankRequestHandler
+	RankingHandler      *handlers.RankingHandler
+	AnnouncementHandler *handlers.AnnouncementHandler
+	DocHandler          *handlers.DocHandler
+	RulesHandler        *handlers.RulesHandler
+	UserAdminHandler    *handlers.UserAdminHandler
+	ClanAdminHandler    *handlers.ClanAdminHandler
+	BetaKeyHandler      *handlers.BetaKeyHandler
+	NoteHandler         *handlers.NoteHandler
+	WebhookHandler      *handlers.WebhookHandler
+	AnalyticsHandler    *handlers.AnalyticsHandler
+	JobHandler          *handlers.JobHandler
+	AdminHandler        *handlers.AdminHandler
+	PerformanceHandler  *handlers.PerformanceHandler
+	MediaHandler        *handlers.MediaHandler
+	CollectionHandler   *handlers.CollectionHandler
 }
 
 func New(cfg *config.Config) (*App, error) {
@@ -79,6 +159,10 @@ func New(cfg *config.Config) (*App, error) {
 
 	app.initHandlers()
 
+	if err := app.initJobs(); err != nil {
+		return nil, err
+	}
+
 	return app, nil
 }
 
@@ -95,7 +179,13 @@ func (a *App) initAdapters() error {
 	}
 	a.Redis = redisClient
 
-	a.APIClient = api.New(a.Config.App.APIURL)
+	a.Beatmaps = beatmapcache.New(a.Redis)
+	a.Beatmaps.Start()
+	a.APIClient = api.New(a.Config.App.APIURL, a.Beatmaps)
+	a.Mirror = mirror.New(a.Config.Beatmap.MirrorAPIURLs)
+	a.Downloader = mirror.NewDownloader(a.Config.Beatmap.DownloadMirrorURLs)
+	a.OsuFiles = mirror.NewOsuFiles(a.Config.Beatmap.OsuFileURLs)
+	a.Assets = mirror.NewAssets(a.Config.Beatmap.CoverURLs, a.Config.Beatmap.PreviewURLs)
 
 	return nil
 }
@@ -103,6 +193,23 @@ func (a *App) initAdapters() error {
 func (a *App) initRepositories() {
 	a.TokenRepo = repositories.NewTokenRepository(a.DB)
 	a.UserRepo = repositories.NewUserRepository(a.DB)
+	a.SystemRepo = repositories.NewSystemRepository(a.DB)
+	a.ClanRepo = repositories.NewClanRepository(a.DB)
+	a.ReportRepo = repositories.NewReportRepository(a.DB)
+	a.BeatmapRepo = repositories.NewBeatmapRepository(a.DB)
+	a.RankRequestRepo = repositories.NewRankRequestRepository(a.DB)
+	a.AnnouncementRepo = repositories.NewAnnouncementRepository(a.DB)
+	a.DocRepo = repositories.NewDocRepository(a.DB)
+	a.RulesRepo = repositories.NewRulesRepository(a.DB)
+	a.DiscordRepo = repositories.NewDiscordRepository(a.DB)
+	a.StatsRepo = repositories.NewStatsRepository(a.DB)
+	a.AdminLogRepo = repositories.NewAdminLogRepository(a.DB)
+	a.RestrictionRepo = repositories.NewRestrictionRepository(a.DB)
+	a.RegistrationKeyRepo = repositories.NewRegistrationKeyRepository(a.DB)
+	a.StaffNoteRepo = repositories.NewStaffNoteRepository(a.DB)
+	a.AnalyticsRepo = repositories.NewAnalyticsRepository(a.DB)
+	a.JobRunRepo = repositories.NewJobRunRepository(a.DB)
+	a.CollectionRepo = repositories.NewCollectionRepository(a.DB)
 }
 
 func (a *App) initServices() error {
@@ -114,8 +221,65 @@ func (a *App) initServices() error {
 		a.Redis,
 	)
 
-	a.BeatmapService = beatmap.NewService(a.Config)
-	a.StatsService = stats.NewService(a.Redis)
+	a.BeatmapService = beatmap.NewService(a.Config, a.Mirror, a.APIClient, a.Beatmaps)
+	downloadService, err := download.NewService(a.Config, a.Downloader, a.BeatmapRepo, a.Redis)
+	if err != nil {
+		return err
+	}
+	a.DownloadService = downloadService
+	a.PerformanceService = performance.NewService(a.BeatmapService, a.OsuFiles, a.Beatmaps)
+	mediaService, err := media.NewService(a.Config, a.Assets, a.BeatmapService, a.Redis)
+	if err != nil {
+		return err
+	}
+	a.MediaService = mediaService
+	a.StatsService = stats.NewService(a.Redis, a.UserRepo)
+	a.SettingsService = settings.NewService(a.SystemRepo)
+	a.WebhookService = webhooks.NewService(a.Config, a.SettingsService, a.UserRepo, a.Redis)
+	a.WebhookService.StartWorker()
+	a.ReportService = report.NewService(
+		a.ReportRepo,
+		a.UserRepo,
+		a.ClanRepo,
+		a.APIClient,
+		a.Redis,
+		a.WebhookService,
+	)
+	a.RankingService = ranking.NewService(
+		a.BeatmapRepo,
+		a.BeatmapService,
+		a.Redis,
+		a.WebhookService,
+	)
+	a.RankRequestService = rankrequest.NewService(
+		a.Config,
+		a.RankRequestRepo,
+		a.BeatmapService,
+		a.RankingService,
+	)
+	a.AnnouncementService = announcement.NewService(a.AnnouncementRepo, a.Redis)
+	a.DocService = docs.NewService(a.DocRepo)
+	a.RulesService = rules.NewService(a.RulesRepo, a.DocService)
+	a.BetaKeyService = betakeys.NewService(a.Config, a.RegistrationKeyRepo, a.SettingsService)
+	a.UserAdminService = useradmin.NewService(
+		a.UserRepo,
+		a.TokenRepo,
+		a.DiscordRepo,
+		a.ClanRepo,
+		a.ReportRepo,
+		a.StatsRepo,
+		a.AdminLogRepo,
+		a.RestrictionRepo,
+		a.APIClient,
+		a.Redis,
+		a.RulesService,
+		a.BetaKeyService,
+	)
+	a.ClanAdminService = clanadmin.NewService(a.ClanRepo, a.AdminLogRepo, a.Redis, a.WebhookService)
+	a.NoteService = notes.NewService(a.StaffNoteRepo, a.UserRepo)
+	a.AnalyticsService = analytics.NewService(a.AnalyticsRepo, a.StatsService)
+	a.CollectionService = collections.NewService(a.CollectionRepo, a.UserRepo, a.BeatmapService)
+	a.Scheduler = jobs.NewScheduler(a.JobRunRepo, a.Redis)
 
 	return nil
 }
@@ -167,6 +331,8 @@ func (a *App) initTemplates() error {
 	a.ResponseEngine = response.NewTemplateEngine(engine.GetTemplates(), funcMap)
 
 	a.ResponseEngine.SetConfig(a.Config)
+	a.ResponseEngine.SetSystemSettingsSource(a.SettingsService)
+	a.ResponseEngine.SetAnnouncementSource(a.AnnouncementService)
 
 	return nil
 }
@@ -175,6 +341,7 @@ func (a *App) initHandlers() {
 	a.AuthHandler = handlers.NewAuthHandler(
 		a.Config,
 		a.AuthService,
+		a.BetaKeyService,
 		a.APIClient,
 		a.CSRF,
 		a.SessionStore,
@@ -184,6 +351,7 @@ func (a *App) initHandlers() {
 	a.UserHandler = handlers.NewUserHandler(
 		a.Config,
 		a.APIClient,
+		a.NoteService,
 		a.CSRF,
 		a.SessionStore,
 		a.ResponseEngine,
@@ -192,6 +360,7 @@ func (a *App) initHandlers() {
 	a.ClanHandler = handlers.NewClanHandler(
 		a.Config,
 		a.APIClient,
+		a.NoteService,
 		a.CSRF,
 		a.SessionStore,
 		a.ResponseEngine,
@@ -208,6 +377,16 @@ func (a *App) initHandlers() {
 	a.BeatmapHandler = handlers.NewBeatmapHandler(
 		a.Config,
 		a.BeatmapService,
+		a.DownloadService,
+		a.CollectionService,
+		a.ResponseEngine,
+	)
+	a.PerformanceHandler = handlers.NewPerformanceHandler(a.PerformanceService, a.ResponseEngine)
+	a.MediaHandler = handlers.NewMediaHandler(a.MediaService, a.ResponseEngine)
+	a.CollectionHandler = handlers.NewCollectionHandler(
+		a.CollectionService,
+		a.CSRF,
+		a.SessionStore,
 		a.ResponseEngine,
 	)
 
@@ -234,6 +413,106 @@ func (a *App) initHandlers() {
 	)
 
 	a.ErrorsHandler = handlers.NewErrorsHandler(a.ResponseEngine)
+
+	a.MaintenanceHandler = handlers.NewMaintenanceHandler(
+		a.SettingsService,
+		a.CSRF,
+		a.SessionStore,
+		a.ResponseEngine,
+	)
+
+	a.ReportHandler = handlers.NewReportHandler(
+		a.ReportService,
+		a.CSRF,
+		a.SessionStore,
+		a.ResponseEngine,
+	)
+
+	a.RankRequestHandler = handlers.NewRankRequestHandler(
+		a.RankRequestService,
+		a.CSRF,
+		a.SessionStore,
+		a.ResponseEngine,
+	)
+
+	a.RankingHandler = handlers.NewRankingHandler(
+		a.RankingService,
+		a.NoteService,
+		a.CSRF,
+		a.SessionStore,
+		a.ResponseEngine,
+	)
+
+	a.AnnouncementHandler = handlers.NewAnnouncementHandler(
+		a.AnnouncementService,
+		a.CSRF,
+		a.SessionStore,
+		a.ResponseEngine,
+	)
+
+	a.DocHandler = handlers.NewDocHandler(
+		a.DocService,
+		a.CSRF,
+		a.SessionStore,
+		a.ResponseEngine,
+		pageConfigs,
+	)
+
+	a.RulesHandler = handlers.NewRulesHandler(
+		a.RulesService,
+		a.CSRF,
+		a.SessionStore,
+		a.ResponseEngine,
+	)
+
+	a.UserAdminHandler = handlers.NewUserAdminHandler(
+		a.UserAdminService,
+		a.NoteService,
+		a.CSRF,
+		a.SessionStore,
+		a.ResponseEngine,
+	)
+
+	a.BetaKeyHandler = handlers.NewBetaKeyHandler(
+		a.Config,
+		a.BetaKeyService,
+		a.CSRF,
+		a.SessionStore,
+		a.ResponseEngine,
+	)
+
+	a.ClanAdminHandler = handlers.NewClanAdminHandler(
+		a.ClanAdminService,
+		a.NoteService,
+		a.CSRF,
+		a.SessionStore,
+		a.ResponseEngine,
+	)
+
+	a.NoteHandler = handlers.NewNoteHandler(
+		a.NoteService,
+		a.CSRF,
+		a.SessionStore,
+		a.ResponseEngine,
+	)
+
+	a.AnalyticsHandler = handlers.NewAnalyticsHandler(a.AnalyticsService, a.ResponseEngine)
+
+	a.JobHandler = handlers.NewJobHandler(
+		a.Scheduler,
+		a.CSRF,
+		a.SessionStore,
+		a.ResponseEngine,
+	)
+
+	a.WebhookHandler = handlers.NewWebhookHandler(
+		a.WebhookService,
+		a.CSRF,
+		a.SessionStore,
+		a.ResponseEngine,
+	)
+
+	a.AdminHandler = handlers.NewAdminHandler(a.ResponseEngine)
 }
 
 type sessionStoreWrapper struct {
@@ -247,6 +526,16 @@ func (w *sessionStoreWrapper) Get(r *http.Request, name string) (*sessions.Sessi
 func (a *App) Close() error {
 	var errs []error
 
+	if a.Scheduler != nil {
+		a.Scheduler.Stop()
+	}
+	if a.WebhookService != nil {
+		a.WebhookService.Stop()
+	}
+	if a.Beatmaps != nil {
+		a.Beatmaps.Stop()
+	}
+
 	if a.Redis != nil {
 		if err := a.Redis.Close(); err != nil {
 			slog.Error("Failed to close Redis connection", "error", err)
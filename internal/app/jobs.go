@@ -0,0 +1,67 @@
+package app
+
+import (
+	"github.com/RealistikOsu/soumetsu/internal/jobs"
+	"github.com/RealistikOsu/soumetsu/internal/services/analytics"
+)
+
+func (a *App) initJobs() error {
+	for _, job := range []jobs.Job{
+		{
+			Name:        "ratelimit.cleanup",
+			Description: "Forgets rate limit buckets for IPs that have gone quiet.",
+			Schedule:    "*/5 * * * *",
+			Local:       true,
+			Run:         a.RateLimiter.Cleanup,
+		},
+		{
+			Name:        "analytics.sample",
+			Description: "Records the online user count, today's active users and the highest clan ID.",
+			Schedule:    analytics.SampleSchedule,
+			Run:         a.AnalyticsService.Sample,
+		},
+		{
+			Name:        "announcements.push",
+			Description: "Pushes announcements that have gone live to players in-game.",
+			Schedule:    "* * * * *",
+			Run:         a.AnnouncementService.PushIngame,
+		},
+		{
+			Name:        "penalties.expire",
+			Description: "Lifts timed restrictions that have run out and clears silences that have ended.",
+			Schedule:    "*/5 * * * *",
+			Run:         a.UserAdminService.ExpirePenalties,
+		},
+		{
+			Name:        "invites.expire",
+			Description: "Revokes personal invites that expired unused, freeing their slots.",
+			Schedule:    "15 * * * *",
+			Run:         a.BetaKeyService.ExpireInvites,
+		},
+		{
+			Name:        "password_resets.expire",
+			Description: "Deletes password reset keys older than a day.",
+			Schedule:    "45 * * * *",
+			Run:         a.AuthService.ExpirePasswordResetKeys,
+		},
+		{
+			Name:        "stats.registered_users",
+			Description: "Recounts the registered user count shown on the home page.",
+			Schedule:    "*/10 * * * *",
+			Run:         a.StatsService.RefreshRegisteredUsers,
+		},
+		{
+			Name:        "jobs.prune_history",
+			Description: "Deletes job runs older than a month.",
+			Schedule:    "30 4 * * *",
+			Run:         a.Scheduler.PruneHistory,
+		},
+	} {
+		if err := a.Scheduler.Register(job); err != nil {
+			return err
+		}
+	}
+
+	a.Scheduler.Start()
+	return nil
+}
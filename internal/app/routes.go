@@ -2,6 +2,7 @@ package app
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/RealistikOsu/soumetsu/internal/api/handlers"
 	apimiddleware "github.com/RealistikOsu/soumetsu/internal/api/middleware"
@@ -21,6 +22,8 @@ func (a *App) Routes() chi.Router {
 	r.Use(a.ErrorsHandler.Recoverer)
 	r.Use(sessionsMiddleware(a.SessionStore))
 	r.Use(apimiddleware.SessionInitializer(a.SessionStore, a.DB))
+	r.Use(apimiddleware.Maintenance(a.SettingsService, a.ErrorsHandler.ServiceUnavailable))
+	r.Use(apimiddleware.RulesAcceptance(a.RulesService))
 	r.Use(apimiddleware.ActivityTracker(a.SessionStore, a.DB))
 	r.Use(a.RateLimiter.Middleware())
 
@@ -46,6 +49,9 @@ func (a *App) Routes() chi.Router {
 	r.Group(func(r chi.Router) {
 		r.Use(apimiddleware.RequireAuth)
 
+		r.Get("/rules/accept", a.RulesHandler.AcceptPage)
+		r.Post("/rules/accept", a.RulesHandler.Accept)
+
 		r.Get("/settings", a.UserHandler.SettingsPage)
 		r.Post("/settings", a.UserHandler.UpdateSettings)
 		r.Get("/settings/password", a.PasswordHandler.ChangePage)
@@ -62,6 +68,10 @@ func (a *App) Routes() chi.Router {
 		r.Get("/settings/user-page", a.UserHandler.UserpageSettingsPage)
 		r.Post("/settings/user-page", a.UserHandler.UpdateUserpage)
 
+		r.Get("/settings/invites", a.BetaKeyHandler.InvitesPage)
+		r.Post("/settings/invites", a.BetaKeyHandler.CreateInvite)
+		r.Post("/settings/invites/{id}/revoke", a.BetaKeyHandler.RevokeInvite)
+
 		r.Post("/settings/profbanner/{type}", func(w http.ResponseWriter, r *http.Request) {
 			routeType := chi.URLParam(r, "type")
 			http.Redirect(w, r, "/settings/profile-banner/"+routeType, http.StatusTemporaryRedirect)
@@ -83,6 +93,127 @@ func (a *App) Routes() chi.Router {
 		// clan-settings.js straight against the soumetsu-api endpoints. No
 		// server-side POST handlers live on this path.
 		r.Get("/clans/{id}/settings", a.ClanHandler.ManagePage)
+
+		r.Get("/beatmaps/rank-request/status", a.RankRequestHandler.Status)
+		r.Get("/beatmaps/rank-request/requests", a.RankRequestHandler.List)
+		r.Post("/beatmaps/rank-request", a.RankRequestHandler.Submit)
+
+		r.Post("/beatmapsets/{id}/favourite", a.CollectionHandler.Favourite)
+		r.Post("/collections", a.CollectionHandler.Create)
+		r.Post("/collections/import", a.CollectionHandler.Import)
+		r.Post("/collections/beatmaps", a.CollectionHandler.AddBeatmap)
+		r.Post("/collections/{id}/edit", a.CollectionHandler.Update)
+		r.Post("/collections/{id}/delete", a.CollectionHandler.Delete)
+		r.Post("/collections/{id}/beatmaps/{md5}/remove", a.CollectionHandler.RemoveBeatmap)
+
+		r.Get("/reports", a.ReportHandler.MyReports)
+		r.Get("/reports/new", a.ReportHandler.NewPage)
+		r.Post("/reports/new", a.ReportHandler.Submit)
+	})
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(apimiddleware.RequirePrivilege(models.AdminPrivilegeAccessRAP))
+
+		r.Get("/", a.AdminHandler.Index)
+
+		r.Post("/notes", a.NoteHandler.Create)
+		r.Get("/notes/{id}", a.NoteHandler.History)
+		r.Post("/notes/{id}", a.NoteHandler.Edit)
+		r.Post("/notes/{id}/pin", a.NoteHandler.Pin)
+		r.Post("/notes/{id}/delete", a.NoteHandler.Delete)
+
+		r.Get("/analytics", a.AnalyticsHandler.Dashboard)
+		r.Get("/analytics/export", a.AnalyticsHandler.Export)
+
+		r.Group(func(r chi.Router) {
+			r.Use(apimiddleware.RequirePrivilege(models.AdminPrivilegeManageSettings))
+			r.Get("/maintenance", a.MaintenanceHandler.Page)
+			r.Post("/maintenance", a.MaintenanceHandler.Update)
+			r.Get("/webhooks", a.WebhookHandler.Page)
+			r.Post("/webhooks", a.WebhookHandler.Update)
+			r.Post("/webhooks/test", a.WebhookHandler.Test)
+			r.Get("/jobs", a.JobHandler.Index)
+			r.Get("/jobs/{name}", a.JobHandler.View)
+			r.Post("/jobs/{name}/run", a.JobHandler.Run)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(apimiddleware.RequirePrivilege(models.AdminPrivilegeManageReports))
+			r.Get("/reports", a.ReportHandler.Queue)
+			r.Get("/reports/{id}", a.ReportHandler.View)
+			r.Post("/reports/{id}/assign", a.ReportHandler.Assign)
+			r.Post("/reports/{id}/status", a.ReportHandler.UpdateStatus)
+			r.Post("/reports/{id}/notes", a.ReportHandler.AddNote)
+			r.Post("/reports/{id}/close", a.ReportHandler.Close)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(apimiddleware.RequirePrivilege(models.AdminPrivilegeManageUsers))
+			r.Get("/users", a.UserAdminHandler.Search)
+			r.Get("/users/{id}", a.UserAdminHandler.Show)
+			r.Post("/users/{id}/rename", a.UserAdminHandler.Rename)
+			r.Get("/clans", a.ClanAdminHandler.Search)
+			r.Get("/clans/{id}", a.ClanAdminHandler.Show)
+			r.Post("/clans/{id}/rename", a.ClanAdminHandler.Rename)
+			r.Post("/clans/{id}/icon/remove", a.ClanAdminHandler.RemoveIcon)
+			r.Post("/clans/{id}/kick", a.ClanAdminHandler.Kick)
+			r.Post("/clans/{id}/transfer", a.ClanAdminHandler.Transfer)
+			r.Post("/clans/{id}/disband", a.ClanAdminHandler.Disband)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(apimiddleware.RequirePrivilege(models.AdminPrivilegeBanUsers))
+			r.Post("/users/{id}/restrict", a.UserAdminHandler.Restrict)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(apimiddleware.RequirePrivilege(models.AdminPrivilegeWipeUsers))
+			r.Get("/users/{id}/wipe", a.UserAdminHandler.WipePage)
+			r.Post("/users/{id}/wipe", a.UserAdminHandler.Wipe)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(apimiddleware.RequirePrivilege(models.AdminPrivilegeManageBetakeys))
+			r.Get("/betakeys", a.BetaKeyHandler.List)
+			r.Post("/betakeys", a.BetaKeyHandler.Create)
+			r.Post("/betakeys/invite-only", a.BetaKeyHandler.SetInviteOnly)
+			r.Get("/betakeys/{id}", a.BetaKeyHandler.Show)
+			r.Post("/betakeys/{id}/revoke", a.BetaKeyHandler.Revoke)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(apimiddleware.RequirePrivilege(models.AdminPrivilegeManageBeatmaps))
+			r.Get("/rank-requests", a.RankRequestHandler.Queue)
+			r.Post("/rank-requests/{id}/review", a.RankRequestHandler.Review)
+			r.Get("/beatmaps", a.RankingHandler.Index)
+			r.Get("/beatmaps/{id}", a.RankingHandler.Console)
+			r.Post("/beatmaps/{id}/status", a.RankingHandler.SetStatus)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(apimiddleware.RequirePrivilege(models.AdminPrivilegeSendAlerts))
+			r.Get("/announcements", a.AnnouncementHandler.List)
+			r.Post("/announcements", a.AnnouncementHandler.Save)
+			r.Get("/announcements/new", a.AnnouncementHandler.New)
+			r.Get("/announcements/{id}", a.AnnouncementHandler.Edit)
+			r.Post("/announcements/{id}", a.AnnouncementHandler.Save)
+			r.Post("/announcements/{id}/delete", a.AnnouncementHandler.Delete)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(apimiddleware.RequirePrivilege(models.AdminPrivilegeManageDocs))
+			r.Get("/docs", a.DocHandler.AdminList)
+			r.Post("/docs", a.DocHandler.Create)
+			r.Post("/docs/import", a.DocHandler.Import)
+			r.Get("/docs/{id}", a.DocHandler.Edit)
+			r.Post("/docs/{id}", a.DocHandler.Save)
+			r.Post("/docs/{id}/publish", a.DocHandler.Publish)
+			r.Post("/docs/{id}/unpublish", a.DocHandler.Unpublish)
+			r.Post("/docs/{id}/delete", a.DocHandler.Delete)
+			r.Get("/docs/{id}/revisions/{rev}", a.DocHandler.Revision)
+			r.Get("/rules", a.RulesHandler.AdminPage)
+			r.Post("/rules", a.RulesHandler.Require)
+		})
 	})
 
 	r.Get("/clans/{id}", a.ClanHandler.ClanPage)
@@ -103,6 +234,11 @@ func (a *App) Routes() chi.Router {
 
 	r.Get("/u/{id}", a.UserHandler.Profile)
 	r.Get("/users/{id}", a.UserHandler.Profile)
+	r.Get("/u/{id}/favourites", a.CollectionHandler.FavouritesPage)
+	r.Get("/u/{id}/collections", a.CollectionHandler.ListPage)
+	r.Get("/u/{id}/collections/collection.db", a.CollectionHandler.ExportAll)
+	r.Get("/collections/{id}", a.CollectionHandler.Show)
+	r.Get("/collections/{id}/collection.db", a.CollectionHandler.Export)
 
 	r.Get("/rx/u/{user}", func(w http.ResponseWriter, r *http.Request) {
 		user := chi.URLParam(r, "user")
@@ -115,20 +251,32 @@ func (a *App) Routes() chi.Router {
 
 	r.Get("/team", a.UserHandler.TeamPage)
 
+	r.Get("/docs", a.DocHandler.Index)
+	r.Get("/docs/{slug}", a.DocHandler.Page)
+
 	// Load simple pages first so specific routes like /beatmaps/rank-request
 	// are registered before the wildcard /beatmaps/{id} route
 	a.loadSimplePages(r)
 
-	r.Get("/b/{id}", func(w http.ResponseWriter, r *http.Request) {
-		id := chi.URLParam(r, "id")
-		http.Redirect(w, r, "/beatmaps/"+id, http.StatusMovedPermanently)
-	})
+	r.Get("/beatmaps", a.BeatmapHandler.BeatmapListing)
+	r.Get("/beatmaps/search", a.BeatmapHandler.SearchBeatmaps)
+	r.Get("/b/{id}", a.BeatmapHandler.BeatmapRedirect)
+	r.Get("/s/{id}", a.BeatmapHandler.BeatmapSetRedirect)
 	r.Get("/beatmaps/{id}", a.BeatmapHandler.BeatmapPage)
-	r.Get("/beatmapsets/{id}", func(w http.ResponseWriter, r *http.Request) {
-		id := chi.URLParam(r, "id")
-		http.Redirect(w, r, "/beatmaps/"+id, http.StatusMovedPermanently)
-	})
+	r.Get("/beatmapsets/{id}", a.BeatmapHandler.BeatmapSetPage)
 	r.Get("/beatmapsets/{id}/download", a.BeatmapHandler.DownloadBeatmap)
+	r.Head("/beatmapsets/{id}/download", a.BeatmapHandler.DownloadBeatmap)
+	r.Get("/beatmapsets/{id}/cover.jpg", a.MediaHandler.Cover)
+	r.Get("/beatmapsets/{id}/card.jpg", a.MediaHandler.Card)
+	r.Get("/beatmapsets/{id}/thumb.jpg", a.MediaHandler.Thumbnail)
+	r.Get("/beatmapsets/{id}/preview.mp3", a.MediaHandler.SetPreview)
+	r.Get("/beatmaps/{id}/preview.mp3", a.MediaHandler.BeatmapPreview)
+	r.Get("/d/{id}", a.BeatmapHandler.DownloadBeatmap)
+	r.Head("/d/{id}", a.BeatmapHandler.DownloadBeatmap)
+	r.Get("/oembed", a.BeatmapHandler.OEmbed)
+
+	r.Get("/tools/pp", a.PerformanceHandler.CalculatorPage)
+	r.Get("/tools/pp/calculate", a.PerformanceHandler.Calculate)
 
 	r.Get("/rank_request", func(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/beatmaps/rank-request", http.StatusMovedPermanently)
@@ -163,7 +311,7 @@ func (a *App) Routes() chi.Router {
 		http.Redirect(w, r, "/settings/avatar", http.StatusMovedPermanently)
 	})
 
-	r.NotFound(a.ErrorsHandler.NotFound)
+	r.NotFound(a.DocHandler.Fallback(a.ErrorsHandler.NotFound))
 	r.MethodNotAllowed(a.ErrorsHandler.MethodNotAllowed)
 
 	return r
@@ -195,6 +343,12 @@ func (a *App) loadSimplePages(r chi.Router) {
 			page.MinPrivileges,
 		)
 
+		// Pages that have been moved into the docs system are served from
+		// there once published.
+		if handlers.IsImportable(page.Handler) {
+			handler = a.DocHandler.Override(strings.TrimPrefix(page.Handler, "/"), handler)
+		}
+
 		if page.MinPrivileges > 0 {
 			r.Group(func(r chi.Router) {
 				r.Use(apimiddleware.RequireAuth)
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -74,8 +75,37 @@ type DiscordConfig struct {
 }
 
 type BeatmapConfig struct {
+	// MirrorAPIURL is the mirror the browser talks to. MirrorAPIURLs are the
+	// ones the server tries, in order; they default to just MirrorAPIURL.
 	MirrorAPIURL      string
+	MirrorAPIURLs     []string
 	DownloadMirrorURL string
+	// DownloadProxy streams downloads through this server from
+	// DownloadMirrorURLs, tried in order, rather than redirecting to
+	// DownloadMirrorURL. Proxied files are kept in DownloadCacheDir, up to
+	// DownloadCacheMB.
+	DownloadProxy      bool
+	DownloadMirrorURLs []string
+	DownloadCacheDir   string
+	DownloadCacheMB    int
+	// DownloadsPerUser and DownloadsPerIP cap downloads per hour. 0 means no
+	// limit.
+	DownloadsPerUser int
+	DownloadsPerIP   int
+	// OsuFileURLs serve .osu files as <url>/<beatmap ID>, for difficulty
+	// and pp calculations. They're tried in order.
+	OsuFileURLs []string
+	// CoverURLs serve set artwork as <url>/<set ID>/covers/<name> and
+	// PreviewURLs serve audio previews as <url>/<set ID>.mp3, each tried in
+	// order. Both are proxied and kept in AssetCacheDir, up to AssetCacheMB.
+	CoverURLs     []string
+	PreviewURLs   []string
+	AssetCacheDir string
+	AssetCacheMB  int
+	// RankRequestQueueSize is how many rank requests everyone together may
+	// submit per 24 hours; RankRequestsPerUser is the per-user share of that.
+	RankRequestQueueSize int
+	RankRequestsPerUser  int
 }
 
 type SecurityConfig struct {
@@ -83,6 +113,12 @@ type SecurityConfig struct {
 	RecaptchaSecretKey string
 	IPLookupURL        string
 	PayPalEmail        string
+	// InvitesPerUser is how many personal invites each supporter may have
+	// while registration is invite-only.
+	InvitesPerUser int
+	// WebhookSecret signs outgoing JSON webhooks. Discord webhooks aren't
+	// signed.
+	WebhookSecret string
 }
 
 type LinksConfig struct {
@@ -92,6 +128,9 @@ type LinksConfig struct {
 func Load() (*Config, error) {
 	loadEnvFile()
 
+	mirrorAPIURL := mustEnv("SOUMETSU_BEATMAP_MIRROR_API_URL")
+	downloadMirrorURL := mustEnv("SOUMETSU_BEATMAP_DOWNLOAD_MIRROR_URL")
+
 	cfg := &Config{
 		App: AppConfig{
 			Port:          mustEnvInt("SOUMETSU_PORT"),
@@ -129,14 +168,30 @@ func Load() (*Config, error) {
 			UserLookupURL:   mustEnv("DISCORD_USER_LOOKUP_URL"),
 		},
 		Beatmap: BeatmapConfig{
-			MirrorAPIURL:      mustEnv("SOUMETSU_BEATMAP_MIRROR_API_URL"),
-			DownloadMirrorURL: mustEnv("SOUMETSU_BEATMAP_DOWNLOAD_MIRROR_URL"),
+			MirrorAPIURL:         mirrorAPIURL,
+			MirrorAPIURLs:        optionalEnvList("SOUMETSU_BEATMAP_MIRROR_API_URLS", []string{mirrorAPIURL}),
+			DownloadMirrorURL:    downloadMirrorURL,
+			DownloadProxy:        optionalEnvBool("SOUMETSU_BEATMAP_DOWNLOAD_PROXY", false),
+			DownloadMirrorURLs:   optionalEnvList("SOUMETSU_BEATMAP_DOWNLOAD_MIRROR_URLS", []string{downloadMirrorURL}),
+			DownloadCacheDir:     optionalEnv("SOUMETSU_BEATMAP_DOWNLOAD_CACHE_DIR", "./data/downloads"),
+			DownloadCacheMB:      optionalEnvInt("SOUMETSU_BEATMAP_DOWNLOAD_CACHE_MB", 10240),
+			DownloadsPerUser:     optionalEnvInt("SOUMETSU_BEATMAP_DOWNLOADS_PER_USER", 100),
+			DownloadsPerIP:       optionalEnvInt("SOUMETSU_BEATMAP_DOWNLOADS_PER_IP", 200),
+			OsuFileURLs:          optionalEnvList("SOUMETSU_BEATMAP_OSU_FILE_URLS", []string{"https://osu.ppy.sh/osu"}),
+			CoverURLs:            optionalEnvList("SOUMETSU_BEATMAP_COVER_URLS", []string{"https://assets.ppy.sh/beatmaps"}),
+			PreviewURLs:          optionalEnvList("SOUMETSU_BEATMAP_PREVIEW_URLS", []string{"https://b.ppy.sh/preview"}),
+			AssetCacheDir:        optionalEnv("SOUMETSU_BEATMAP_ASSET_CACHE_DIR", "./data/assets"),
+			AssetCacheMB:         optionalEnvInt("SOUMETSU_BEATMAP_ASSET_CACHE_MB", 2048),
+			RankRequestQueueSize: optionalEnvInt("SOUMETSU_RANK_REQUEST_QUEUE_SIZE", 50),
+			RankRequestsPerUser:  optionalEnvInt("SOUMETSU_RANK_REQUESTS_PER_USER", 3),
 		},
 		Security: SecurityConfig{
 			RecaptchaSiteKey:   mustEnv("RECAPTCHA_SITE_KEY"),
 			RecaptchaSecretKey: mustEnv("RECAPTCHA_SECRET_KEY"),
 			IPLookupURL:        mustEnv("IP_LOOKUP_URL"),
 			PayPalEmail:        mustEnv("PAYPAL_EMAIL_ADDRESS"),
+			InvitesPerUser:     optionalEnvInt("SOUMETSU_INVITES_PER_USER", 3),
+			WebhookSecret:      optionalEnv("SOUMETSU_WEBHOOK_SECRET", ""),
 		},
 		Links: LinksConfig{
 			GitHubOrgURL: optionalEnv("GITHUB_ORG_URL", "https://github.com/RealistikOsu"),
@@ -195,6 +250,48 @@ func optionalEnv(key string, fallback string) string {
 	return val
 }
 
+func optionalEnvInt(key string, fallback int) int {
+	val, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	i, err := strconv.Atoi(val)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid integer for %s: %s", key, val))
+	}
+	return i
+}
+
+// optionalEnvList reads a comma-separated list, ignoring blank entries.
+func optionalEnvList(key string, fallback []string) []string {
+	val, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	var list []string
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	if len(list) == 0 {
+		return fallback
+	}
+	return list
+}
+
+func optionalEnvBool(key string, fallback bool) bool {
+	val, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid boolean for %s: %s", key, val))
+	}
+	return b
+}
+
 func mustEnvInt(key string) int {
 	val := mustEnv(key)
 	i, err := strconv.Atoi(val)
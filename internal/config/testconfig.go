@@ -41,14 +41,29 @@ func NewTestConfig() *Config {
 			UserLookupURL:   "http://localhost:8080/users",
 		},
 		Beatmap: BeatmapConfig{
-			MirrorAPIURL:      "http://localhost:8080/api",
-			DownloadMirrorURL: "http://localhost:8080/d",
+			MirrorAPIURL:         "http://localhost:8080/api",
+			MirrorAPIURLs:        []string{"http://localhost:8080/api"},
+			DownloadMirrorURL:    "http://localhost:8080/d",
+			DownloadMirrorURLs:   []string{"http://localhost:8080/d"},
+			DownloadCacheDir:     "./data/downloads",
+			DownloadCacheMB:      10240,
+			DownloadsPerUser:     100,
+			DownloadsPerIP:       200,
+			OsuFileURLs:          []string{"http://localhost:8080/osu"},
+			CoverURLs:            []string{"http://localhost:8080/covers"},
+			PreviewURLs:          []string{"http://localhost:8080/previews"},
+			AssetCacheDir:        "./data/assets",
+			AssetCacheMB:         2048,
+			RankRequestQueueSize: 50,
+			RankRequestsPerUser:  3,
 		},
 		Security: SecurityConfig{
 			RecaptchaSiteKey:   "test-site-key",
 			RecaptchaSecretKey: "test-secret-key",
 			IPLookupURL:        "http://localhost:8080/ip",
 			PayPalEmail:        "test@paypal.com",
+			InvitesPerUser:     3,
+			WebhookSecret:      "test-webhook-secret",
 		},
 	}
 }
@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"log/slog"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/redis"
+)
+
+// runChannel carries the names of jobs an admin started on a follower, for
+// the leader to run.
+const runChannel = "soumetsu:jobs:run"
+
+// listenRuns starts jobs forwarded by RunNow on other instances while this
+// instance leads.
+func (s *Scheduler) listenRuns() {
+	defer s.wg.Done()
+	s.redis.Listen(s.ctx.Done(), "job run", func(msg *redis.Message) {
+		s.runForwarded(msg.Payload)
+	}, runChannel)
+}
+
+func (s *Scheduler) runForwarded(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[name]
+	switch {
+	case !ok, !s.leader, s.stopped:
+		return
+	case e.running:
+		slog.Warn("ignoring forwarded run of a job that's already running", "job", name)
+		return
+	}
+	s.start(e, true)
+}
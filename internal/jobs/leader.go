@@ -0,0 +1,77 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const (
+	// leaderKey holds the name of the instance running the shared jobs. The
+	// leader renews it well before it expires; if the leader dies, another
+	// instance takes over once it does.
+	leaderKey      = "soumetsu:jobs:leader"
+	leaderLease    = 30 * time.Second
+	leaderInterval = 10 * time.Second
+)
+
+func (s *Scheduler) elect() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(leaderInterval)
+	defer ticker.Stop()
+	for {
+		s.campaign()
+		select {
+		case <-ticker.C:
+		case <-s.ctx.Done():
+			s.resign()
+			return
+		}
+	}
+}
+
+// campaign renews the lease if this instance holds it, or takes it if
+// nobody does.
+func (s *Scheduler) campaign() {
+	ctx, cancel := context.WithTimeout(s.ctx, 5*time.Second)
+	defer cancel()
+
+	leader, err := s.redis.RenewLease(ctx, leaderKey, s.instance, leaderLease)
+	if err == nil && !leader {
+		leader, err = s.redis.SetNX(ctx, leaderKey, s.instance, leaderLease)
+	}
+	if err != nil {
+		// Without Redis nobody can tell who leads, so stand down rather than
+		// risk two instances running the same job.
+		slog.Error("failed to renew job leadership", "error", err, "instance", s.instance)
+		leader = false
+	}
+
+	s.mu.Lock()
+	changed := leader != s.leader
+	s.leader = leader
+	s.mu.Unlock()
+
+	if changed {
+		slog.Info("job leadership changed", "instance", s.instance, "leader", leader)
+	}
+}
+
+// resign hands leadership over on shutdown so another instance needn't wait
+// for the lease to run out.
+func (s *Scheduler) resign() {
+	s.mu.Lock()
+	leader := s.leader
+	s.leader = false
+	s.mu.Unlock()
+	if !leader {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.redis.ReleaseLease(ctx, leaderKey, s.instance); err != nil {
+		slog.Error("failed to release job leadership", "error", err, "instance", s.instance)
+	}
+}
@@ -0,0 +1,102 @@
+package jobs
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/redis"
+	"github.com/RealistikOsu/soumetsu/internal/config"
+)
+
+func newTestScheduler(t *testing.T, mr *miniredis.Miniredis) *Scheduler {
+	t.Helper()
+	client, err := redis.New(config.RedisConfig{Host: mr.Host(), Port: portOf(t, mr)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	s := NewScheduler(nil, client)
+	t.Cleanup(s.cancel)
+	return s
+}
+
+func portOf(t *testing.T, mr *miniredis.Miniredis) int {
+	t.Helper()
+	port, err := strconv.Atoi(mr.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return port
+}
+
+func (s *Scheduler) isLeader() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.leader
+}
+
+func TestLeaderLease(t *testing.T) {
+	mr := miniredis.RunT(t)
+	a := newTestScheduler(t, mr)
+	b := newTestScheduler(t, mr)
+
+	a.campaign()
+	b.campaign()
+	if !a.isLeader() || b.isLeader() {
+		t.Fatalf("leaders after first campaign: a %v, b %v; want only a", a.isLeader(), b.isLeader())
+	}
+
+	// Renewing keeps the lease going past its first expiry.
+	mr.FastForward(leaderLease / 2)
+	a.campaign()
+	mr.FastForward(leaderLease/2 + 1)
+	b.campaign()
+	if !a.isLeader() || b.isLeader() {
+		t.Fatalf("leaders after renewal: a %v, b %v; want only a", a.isLeader(), b.isLeader())
+	}
+
+	// A leader that stops renewing loses the lease once it runs out.
+	mr.FastForward(leaderLease)
+	b.campaign()
+	a.campaign()
+	if a.isLeader() || !b.isLeader() {
+		t.Fatalf("leaders after takeover: a %v, b %v; want only b", a.isLeader(), b.isLeader())
+	}
+	if got, _ := mr.Get(leaderKey); got != b.instance {
+		t.Errorf("lease held by %q, want %q", got, b.instance)
+	}
+
+	// Resigning hands over without waiting for the lease.
+	b.resign()
+	if mr.Exists(leaderKey) {
+		t.Error("lease still held after resigning")
+	}
+	a.campaign()
+	if !a.isLeader() {
+		t.Error("a didn't take over after b resigned")
+	}
+
+	// Resigning a lease someone else holds leaves it alone.
+	b.leader = true
+	b.resign()
+	if got, _ := mr.Get(leaderKey); got != a.instance {
+		t.Errorf("lease held by %q after b resigned, want %q", got, a.instance)
+	}
+}
+
+func TestLeaderStandsDownWithoutRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	s := newTestScheduler(t, mr)
+	s.campaign()
+	if !s.isLeader() {
+		t.Fatal("not leader with Redis up")
+	}
+
+	mr.Close()
+	s.campaign()
+	if s.isLeader() {
+		t.Error("still leader with Redis down")
+	}
+}
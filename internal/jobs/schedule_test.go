@@ -0,0 +1,114 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		spec string
+		ok   bool
+	}{
+		{"* * * * *", true},
+		{"*/5 * * * *", true},
+		{"0,30 8-18/2 1,15 */3 1-5", true},
+		{"5/15 * * * *", true},
+		{"0 0 * * 7", true},
+		{" @daily ", true},
+		{"@hourly", true},
+		{"", false},
+		{"* * * *", false},
+		{"* * * * * *", false},
+		{"@yearly", false},
+		{"60 * * * *", false},
+		{"* 24 * * *", false},
+		{"* * 0 * *", false},
+		{"* * 32 * *", false},
+		{"* * * 0 *", false},
+		{"* * * 13 *", false},
+		{"* * * * 8", false},
+		{"*/0 * * * *", false},
+		{"*/x * * * *", false},
+		{"5-1 * * * *", false},
+		{"1-x * * * *", false},
+		{"a * * * *", false},
+		{"1,,2 * * * *", false},
+	}
+	for _, tt := range tests {
+		_, err := ParseSchedule(tt.spec)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseSchedule(%q) error = %v, want ok %v", tt.spec, err, tt.ok)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.Parse("2006-01-02 15:04:05", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		spec string
+		from string
+		want string // empty for never
+	}{
+		// Always strictly after the given time.
+		{"* * * * *", "2025-01-01 10:00:00", "2025-01-01 10:01:00"},
+		{"* * * * *", "2025-01-01 10:00:59", "2025-01-01 10:01:00"},
+		{"*/5 * * * *", "2025-01-01 10:03:00", "2025-01-01 10:05:00"},
+		{"5/15 * * * *", "2025-01-01 10:21:00", "2025-01-01 10:35:00"},
+		{"@hourly", "2025-01-01 10:00:30", "2025-01-01 11:00:00"},
+		{"0 0 * * *", "2025-01-01 23:59:00", "2025-01-02 00:00:00"},
+		{"0 0 * * *", "2025-12-31 23:59:00", "2026-01-01 00:00:00"},
+		{"30 4 * * *", "2025-01-01 04:30:00", "2025-01-02 04:30:00"},
+		// Months without the day are skipped.
+		{"0 0 1 * *", "2025-01-31 12:00:00", "2025-02-01 00:00:00"},
+		{"0 0 31 * *", "2025-02-01 00:00:00", "2025-03-31 00:00:00"},
+		{"0 0 31 * *", "2025-04-01 00:00:00", "2025-05-31 00:00:00"},
+		{"0 0 1 6 *", "2025-07-01 00:00:00", "2026-06-01 00:00:00"},
+		{"0 0 29 2 *", "2025-03-01 00:00:00", "2028-02-29 00:00:00"},
+		{"0 0 31 2 *", "2025-01-01 00:00:00", ""},
+		// 2025-01-01 is a Wednesday; Sunday is both 0 and 7.
+		{"0 0 * * 0", "2025-01-01 00:00:00", "2025-01-05 00:00:00"},
+		{"0 0 * * 7", "2025-01-01 00:00:00", "2025-01-05 00:00:00"},
+		{"@weekly", "2025-01-01 00:00:00", "2025-01-05 00:00:00"},
+		{"30 9 * * 1-5", "2025-01-03 10:00:00", "2025-01-06 09:30:00"},
+		// With both day fields restricted either one matching is enough;
+		// with one of them * only the other counts.
+		{"0 0 13 * 5", "2025-01-01 00:00:00", "2025-01-03 00:00:00"},
+		{"0 0 13 * *", "2025-01-01 00:00:00", "2025-01-13 00:00:00"},
+		{"0 0 * * 5", "2025-01-04 00:00:00", "2025-01-10 00:00:00"},
+	}
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseSchedule(%q): %v", tt.spec, err)
+		}
+		got := s.Next(at(tt.from))
+		var want time.Time
+		if tt.want != "" {
+			want = at(tt.want)
+		}
+		if !got.Equal(want) {
+			t.Errorf("%q.Next(%s) = %v, want %v", tt.spec, tt.from, got, want)
+		}
+	}
+}
+
+func TestScheduleNextIsUTC(t *testing.T) {
+	s, err := ParseSchedule("0 0 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokyo := time.FixedZone("JST", 9*60*60)
+	// 08:00 in Tokyo is 23:00 UTC the day before.
+	got := s.Next(time.Date(2025, 1, 2, 8, 0, 0, 0, tokyo))
+	if want := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Next = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,393 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/redis"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/pkg/crypto"
+	"github.com/RealistikOsu/soumetsu/internal/repositories"
+)
+
+const (
+	defaultTimeout = 5 * time.Minute
+
+	// maxErrorLength keeps one bad error message from bloating job_runs.
+	maxErrorLength = 2000
+
+	// historyRetention is how long PruneHistory keeps runs for.
+	historyRetention = 30 * 24 * time.Hour
+)
+
+var (
+	ErrUnknownJob = errors.New("unknown job")
+	ErrNoLeader   = errors.New("no instance is running the shared jobs right now")
+)
+
+// Job is a piece of periodic work.
+type Job struct {
+	Name        string
+	Description string
+	// Schedule is a cron expression; see ParseSchedule.
+	Schedule string
+	// Local jobs look after state held in this process, so they run on every
+	// instance. Everything else only runs on the leader.
+	Local bool
+	// Timeout bounds a run, five minutes by default.
+	Timeout time.Duration
+	Run     func(ctx context.Context) error
+}
+
+type entry struct {
+	job      Job
+	schedule *Schedule
+	next     time.Time
+	running  bool
+}
+
+// Status is a job as shown on the admin page.
+type Status struct {
+	Job
+	Next        time.Time
+	Running     bool
+	LastRun     *models.JobRun
+	LastFailure *models.JobRun
+}
+
+// Scheduler runs registered jobs on their schedules until Stop is called.
+// Runs are recorded in job_runs.
+type Scheduler struct {
+	runs     *repositories.JobRunRepository
+	redis    *redis.Client
+	instance string
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	leader  bool
+	// stopped is set under mu by Stop, after which nothing may be added to
+	// wg.
+	stopped bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler(runs *repositories.JobRunRepository, redisClient *redis.Client) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		runs:     runs,
+		redis:    redisClient,
+		instance: instanceName(),
+		entries:  make(map[string]*entry),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// instanceName identifies this process in leader election and run history.
+func instanceName() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "soumetsu"
+	}
+	suffix, err := crypto.GenerateRandomHex(3)
+	if err != nil {
+		return host
+	}
+	return host + "-" + suffix
+}
+
+// Register adds a job. It must be called before Start.
+func (s *Scheduler) Register(job Job) error {
+	schedule, err := ParseSchedule(job.Schedule)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", job.Name, err)
+	}
+	if job.Timeout == 0 {
+		job.Timeout = defaultTimeout
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[job.Name]; ok {
+		return fmt.Errorf("job %s is already registered", job.Name)
+	}
+	s.entries[job.Name] = &entry{job: job, schedule: schedule}
+	return nil
+}
+
+// Start begins running jobs and competing for leadership.
+func (s *Scheduler) Start() {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return
+	}
+	for _, e := range s.entries {
+		e.next = e.schedule.Next(now)
+	}
+
+	s.wg.Add(3)
+	go s.loop()
+	go s.elect()
+	go s.listenRuns()
+}
+
+// Stop cancels running jobs and waits for them to return.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	s.stopped = true
+	s.cancel()
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Instance is this process's name in the run history.
+func (s *Scheduler) Instance() string {
+	return s.instance
+}
+
+// IsLeader reports whether this instance currently runs the shared jobs.
+func (s *Scheduler) IsLeader() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.leader
+}
+
+// Leader returns the instance holding the lease, if any.
+func (s *Scheduler) Leader(ctx context.Context) (string, error) {
+	leader, err := s.redis.Get(ctx, leaderKey)
+	if err == redis.Nil {
+		return "", nil
+	}
+	return leader, err
+}
+
+// RunNow starts a job straight away and returns the instance running it.
+// Local jobs run here. Shared jobs run here if this instance leads, and are
+// otherwise forwarded to the leader so only one instance ever runs them.
+func (s *Scheduler) RunNow(ctx context.Context, name string) (string, error) {
+	s.mu.Lock()
+	e, ok := s.entries[name]
+	switch {
+	case !ok:
+		s.mu.Unlock()
+		return "", ErrUnknownJob
+	case s.stopped:
+		s.mu.Unlock()
+		return "", errors.New("the scheduler is shutting down")
+	case !e.job.Local && !s.leader:
+		s.mu.Unlock()
+		return s.forward(ctx, name)
+	case e.running:
+		s.mu.Unlock()
+		return "", fmt.Errorf("%s is already running", name)
+	}
+	s.start(e, true)
+	s.mu.Unlock()
+	return s.instance, nil
+}
+
+// forward asks the leader to run a shared job.
+func (s *Scheduler) forward(ctx context.Context, name string) (string, error) {
+	leader, err := s.Leader(ctx)
+	if err != nil {
+		return "", err
+	}
+	if leader == "" {
+		return "", ErrNoLeader
+	}
+	if err := s.redis.Publish(ctx, runChannel, name); err != nil {
+		return "", err
+	}
+	return leader, nil
+}
+
+// Statuses lists every job with its latest runs, by name.
+func (s *Scheduler) Statuses(ctx context.Context) ([]Status, error) {
+	latest, err := s.runs.Latest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	failures, err := s.runs.LatestFailures(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	statuses := make([]Status, 0, len(s.entries))
+	for name, e := range s.entries {
+		status := Status{Job: e.job, Next: e.next, Running: e.running}
+		if run, ok := latest[name]; ok {
+			status.LastRun = &run
+		}
+		if run, ok := failures[name]; ok {
+			status.LastFailure = &run
+		}
+		statuses = append(statuses, status)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses, nil
+}
+
+// Job returns a registered job's status, or nil if there's no such job.
+func (s *Scheduler) Job(ctx context.Context, name string) (*Status, error) {
+	statuses, err := s.Statuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range statuses {
+		if status.Name == name {
+			return &status, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *Scheduler) History(ctx context.Context, name string, limit int) ([]models.JobRun, error) {
+	return s.runs.ListForJob(ctx, name, limit)
+}
+
+func (s *Scheduler) Failures(ctx context.Context, limit int) ([]models.JobRun, error) {
+	return s.runs.ListFailures(ctx, limit)
+}
+
+// PruneHistory deletes runs older than a month.
+func (s *Scheduler) PruneHistory(ctx context.Context) error {
+	n, err := s.runs.DeleteBefore(ctx, time.Now().Add(-historyRetention).Unix())
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		slog.Info("pruned job history", "count", n)
+	}
+	return nil
+}
+
+func (s *Scheduler) loop() {
+	defer s.wg.Done()
+
+	for {
+		timer := time.NewTimer(time.Until(s.dispatchDue()))
+		select {
+		case <-timer.C:
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// dispatchDue starts every job that's due and returns when the next one is.
+func (s *Scheduler) dispatchDue() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	wakeAt := now.Add(time.Minute)
+	for _, e := range s.entries {
+		if e.next.IsZero() {
+			continue
+		}
+		if !e.next.After(now) {
+			switch {
+			case s.stopped:
+			case !e.job.Local && !s.leader:
+			case e.running:
+				slog.Warn("skipping job still running from last time", "job", e.job.Name)
+			default:
+				s.start(e, false)
+			}
+			e.next = e.schedule.Next(now)
+		}
+		if !e.next.IsZero() && e.next.Before(wakeAt) {
+			wakeAt = e.next
+		}
+	}
+	return wakeAt
+}
+
+// start runs e in the background. s.mu must be held and s.stopped unset.
+func (s *Scheduler) start(e *entry, manual bool) {
+	e.running = true
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.run(e.job, manual)
+
+		s.mu.Lock()
+		e.running = false
+		s.mu.Unlock()
+	}()
+}
+
+func (s *Scheduler) run(job Job, manual bool) {
+	started := time.Now()
+	// History is written with its own context so a run cut short by
+	// shutdown is still recorded.
+	record, cancelRecord := context.WithTimeout(context.Background(), 5*time.Second)
+	id, err := s.runs.Start(record, &models.JobRun{
+		Job:       job.Name,
+		Instance:  s.instance,
+		Manual:    manual,
+		StartedAt: started.Unix(),
+	})
+	cancelRecord()
+	if err != nil {
+		slog.Error("failed to record job start", "error", err, "job", job.Name)
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, job.Timeout)
+	runErr := safeRun(ctx, job)
+	cancel()
+
+	status, errMsg := models.JobRunSucceeded, ""
+	if runErr != nil {
+		status, errMsg = models.JobRunFailed, runErr.Error()
+		errMsg = truncate(errMsg, maxErrorLength)
+		slog.Error("job failed", "error", runErr, "job", job.Name)
+	}
+	if id == 0 {
+		return
+	}
+
+	record, cancelRecord = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelRecord()
+	if err := s.runs.Finish(record, id, status, errMsg, time.Since(started).Milliseconds()); err != nil {
+		slog.Error("failed to record job result", "error", err, "job", job.Name)
+	}
+}
+
+// truncate cuts s to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// safeRun turns a panicking job into a failed run.
+func safeRun(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
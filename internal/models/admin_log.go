@@ -0,0 +1,41 @@
+package models
+
+import "fmt"
+
+// AdminTarget is the kind of thing a staff action or note is about.
+type AdminTarget string
+
+const (
+	AdminTargetUser       AdminTarget = "user"
+	AdminTargetClan       AdminTarget = "clan"
+	AdminTargetBeatmapSet AdminTarget = "beatmapset"
+)
+
+func (t AdminTarget) Valid() bool {
+	return t == AdminTargetUser || t == AdminTargetClan || t == AdminTargetBeatmapSet
+}
+
+// AdminURL is the admin page for the target with the given ID.
+func (t AdminTarget) AdminURL(id int) string {
+	switch t {
+	case AdminTargetUser:
+		return fmt.Sprintf("/admin/users/%d", id)
+	case AdminTargetClan:
+		return fmt.Sprintf("/admin/clans/%d", id)
+	case AdminTargetBeatmapSet:
+		return fmt.Sprintf("/admin/beatmaps/%d", id)
+	}
+	return "/admin"
+}
+
+// AdminAction is one entry in the staff action log.
+type AdminAction struct {
+	ID         int64       `db:"id"`
+	StaffID    int         `db:"staff_id"`
+	StaffName  string      `db:"staff_name"`
+	TargetType AdminTarget `db:"target_type"`
+	TargetID   int         `db:"target_id"`
+	Action     string      `db:"action"`
+	Detail     string      `db:"detail"`
+	CreatedAt  int64       `db:"created_at"`
+}
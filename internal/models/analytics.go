@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// DailyCount is a count for one UTC day, which starts at Day.
+type DailyCount struct {
+	Day   int64 `db:"day"`
+	Count int   `db:"count"`
+}
+
+type CountryCount struct {
+	Country string `db:"country"`
+	Count   int    `db:"count"`
+}
+
+// OnlinePoint summarises the online-user samples taken in one bucket of time.
+type OnlinePoint struct {
+	At      int64   `db:"at"`
+	Peak    int     `db:"peak"`
+	Average float64 `db:"average"`
+}
+
+// Cohort is the users who registered in one week and how many of them were
+// still seen 1, 7 and 30 days after registering. Since only the latest
+// activity is stored, a user counts as retained if they were last seen at
+// least that long after registering.
+type Cohort struct {
+	Start      int64 `db:"start"`
+	Registered int   `db:"registered"`
+	Day1       int   `db:"day1"`
+	Day7       int   `db:"day7"`
+	Day30      int   `db:"day30"`
+}
+
+// Week is the UTC date the cohort's week starts on.
+func (c Cohort) Week() string {
+	return time.Unix(c.Start, 0).UTC().Format("2 Jan 2006")
+}
+
+// Rate is n as a percentage of the cohort.
+func (c Cohort) Rate(n int) float64 {
+	if c.Registered == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(c.Registered)
+}
@@ -0,0 +1,101 @@
+package models
+
+import "time"
+
+type AnnouncementAudience string
+
+const (
+	AudienceEveryone   AnnouncementAudience = "everyone"
+	AudienceLoggedIn   AnnouncementAudience = "logged_in"
+	AudienceDonors     AnnouncementAudience = "donors"
+	AudiencePrivileges AnnouncementAudience = "privileges"
+)
+
+var AnnouncementAudiences = []AnnouncementAudience{
+	AudienceEveryone,
+	AudienceLoggedIn,
+	AudienceDonors,
+	AudiencePrivileges,
+}
+
+func (a AnnouncementAudience) Valid() bool {
+	for _, audience := range AnnouncementAudiences {
+		if a == audience {
+			return true
+		}
+	}
+	return false
+}
+
+func (a AnnouncementAudience) Label() string {
+	switch a {
+	case AudienceEveryone:
+		return "Everyone"
+	case AudienceLoggedIn:
+		return "Logged-in users"
+	case AudienceDonors:
+		return "Donors"
+	case AudiencePrivileges:
+		return "Privilege mask"
+	}
+	return string(a)
+}
+
+type Announcement struct {
+	ID          int64                `db:"id"`
+	Title       string               `db:"title"`
+	Body        string               `db:"body"`
+	Audience    AnnouncementAudience `db:"audience"`
+	Privileges  UserPrivileges       `db:"privileges"`
+	StartsAt    int64                `db:"starts_at"`
+	EndsAt      int64                `db:"ends_at"`
+	Dismissible bool                 `db:"dismissible"`
+	PushIngame  bool                 `db:"push_ingame"`
+	PushedAt    int64                `db:"pushed_at"`
+	CreatedBy   int                  `db:"created_by"`
+	CreatorName string               `db:"creator_name"`
+	CreatedAt   int64                `db:"created_at"`
+	UpdatedAt   int64                `db:"updated_at"`
+}
+
+func (a Announcement) StartsAtTime() time.Time {
+	return time.Unix(a.StartsAt, 0).UTC()
+}
+
+func (a Announcement) EndsAtTime() time.Time {
+	return time.Unix(a.EndsAt, 0).UTC()
+}
+
+func (a Announcement) IsLive(now int64) bool {
+	return a.StartsAt <= now && (a.EndsAt == 0 || a.EndsAt > now)
+}
+
+func (a Announcement) IsScheduled(now int64) bool {
+	return a.StartsAt > now
+}
+
+func (a Announcement) VisibleTo(user SessionUser) bool {
+	switch a.Audience {
+	case AudienceEveryone:
+		return true
+	case AudienceLoggedIn:
+		return user.IsLoggedIn()
+	case AudienceDonors:
+		return user.IsDonor()
+	case AudiencePrivileges:
+		return user.IsLoggedIn() && user.HasPrivilege(a.Privileges)
+	}
+	return false
+}
+
+// IngamePrivileges is the privilege mask bancho should target when pushing
+// the announcement in-game. Everyone online is logged in, so 0 means all.
+func (a Announcement) IngamePrivileges() UserPrivileges {
+	switch a.Audience {
+	case AudienceDonors:
+		return UserPrivilegeDonor
+	case AudiencePrivileges:
+		return a.Privileges
+	}
+	return 0
+}
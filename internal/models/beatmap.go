@@ -1,5 +1,10 @@
 package models
 
+import (
+	"fmt"
+	"sort"
+)
+
 type Beatmap struct {
 	ID               int     `json:"BeatmapID"`
 	ParentSetID      int     `json:"ParentSetID"`
@@ -19,6 +24,19 @@ type Beatmap struct {
 	DifficultyRating float64 `json:"DifficultyRating"`
 }
 
+// LengthLabel and DrainLabel format the total and drain times as m:ss.
+func (b Beatmap) LengthLabel() string {
+	return formatLength(b.TotalLength)
+}
+
+func (b Beatmap) DrainLabel() string {
+	return formatLength(b.HitLength)
+}
+
+func formatLength(seconds int) string {
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
 type BeatmapSet struct {
 	ID               int       `json:"SetID"`
 	ChildrenBeatmaps []Beatmap `json:"ChildrenBeatmaps"`
@@ -40,6 +58,97 @@ const (
 	StatusLoved     = 4
 )
 
+// The score server keeps its own scale in beatmaps.ranked, which is also what
+// the API reports.
+const (
+	ServerStatusPending     = 0
+	ServerStatusNeedsUpdate = 1
+	ServerStatusRanked      = 2
+	ServerStatusApproved    = 3
+	ServerStatusQualified   = 4
+	ServerStatusLoved       = 5
+)
+
+// ServerStatus converts a status to the score server's scale. The server
+// has no graveyard or WIP, so those are pending.
+func ServerStatus(status int) int {
+	switch status {
+	case StatusRanked:
+		return ServerStatusRanked
+	case StatusApproved:
+		return ServerStatusApproved
+	case StatusQualified:
+		return ServerStatusQualified
+	case StatusLoved:
+		return ServerStatusLoved
+	}
+	return ServerStatusPending
+}
+
+// StatusFromServer converts a status from the score server's scale.
+func StatusFromServer(status int) int {
+	switch status {
+	case ServerStatusRanked:
+		return StatusRanked
+	case ServerStatusApproved:
+		return StatusApproved
+	case ServerStatusQualified:
+		return StatusQualified
+	case ServerStatusLoved:
+		return StatusLoved
+	}
+	return StatusPending
+}
+
+// SortedDifficulties returns the set's difficulties by mode, then by star
+// rating.
+func (b BeatmapSet) SortedDifficulties() []Beatmap {
+	diffs := append([]Beatmap(nil), b.ChildrenBeatmaps...)
+	sort.SliceStable(diffs, func(i, j int) bool {
+		if diffs[i].Mode != diffs[j].Mode {
+			return diffs[i].Mode < diffs[j].Mode
+		}
+		return diffs[i].DifficultyRating < diffs[j].DifficultyRating
+	})
+	return diffs
+}
+
+func (b BeatmapSet) StatusLabel() string {
+	return RankedStatusLabel(b.RankedStatus)
+}
+
 func (b BeatmapSet) IsRanked() bool {
 	return b.RankedStatus == StatusRanked || b.RankedStatus == StatusApproved
 }
+
+// SettableStatuses are the statuses staff can set from the ranking console.
+var SettableStatuses = []int{StatusRanked, StatusApproved, StatusQualified, StatusLoved, StatusPending}
+
+func RankedStatusLabel(status int) string {
+	switch status {
+	case StatusGraveyard:
+		return "Graveyard"
+	case StatusWIP:
+		return "WIP"
+	case StatusPending:
+		return "Pending"
+	case StatusRanked:
+		return "Ranked"
+	case StatusApproved:
+		return "Approved"
+	case StatusQualified:
+		return "Qualified"
+	case StatusLoved:
+		return "Loved"
+	}
+	return "Unknown"
+}
+
+func IsSettableStatus(status int) bool {
+	for _, s := range SettableStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,23 @@
+package models
+
+// BeatmapRankingLog records one ranked status change made by staff.
+type BeatmapRankingLog struct {
+	ID           int64  `db:"id"`
+	BeatmapID    int    `db:"beatmap_id"`
+	BeatmapsetID int    `db:"beatmapset_id"`
+	SongName     string `db:"song_name"`
+	OldStatus    int    `db:"old_status"`
+	NewStatus    int    `db:"new_status"`
+	Reason       string `db:"reason"`
+	UserID       int    `db:"user_id"`
+	Username     string `db:"username"`
+	CreatedAt    int64  `db:"created_at"`
+}
+
+func (l BeatmapRankingLog) OldStatusLabel() string {
+	return RankedStatusLabel(l.OldStatus)
+}
+
+func (l BeatmapRankingLog) NewStatusLabel() string {
+	return RankedStatusLabel(l.NewStatus)
+}
@@ -22,3 +22,16 @@ type ClanInvite struct {
 	ClanID int    `db:"clan"`
 	Invite string `db:"invite"`
 }
+
+// ClanMemberUser is a clan member along with their name, for staff views.
+type ClanMemberUser struct {
+	UserID   int    `db:"user"`
+	ClanID   int    `db:"clan"`
+	Perms    int    `db:"perms"`
+	Username string `db:"username"`
+	Country  string `db:"country"`
+}
+
+func (m ClanMemberUser) IsOwner() bool {
+	return m.Perms == ClanPermOwner
+}
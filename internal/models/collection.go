@@ -0,0 +1,36 @@
+package models
+
+// BeatmapFavourite is a beatmap set a user has favourited. SongName is
+// "Artist - Title" as it was when favourited.
+type BeatmapFavourite struct {
+	UserID       int    `db:"user_id"`
+	BeatmapsetID int    `db:"beatmapset_id"`
+	SongName     string `db:"song_name"`
+	Creator      string `db:"creator"`
+	CreatedAt    int64  `db:"created_at"`
+}
+
+// BeatmapCollection is a named list of difficulties a user keeps. Private
+// ones are only shown to their owner.
+type BeatmapCollection struct {
+	ID        int64  `db:"id"`
+	UserID    int    `db:"user_id"`
+	Username  string `db:"username"`
+	Name      string `db:"name"`
+	Public    bool   `db:"public"`
+	Items     int    `db:"items"`
+	CreatedAt int64  `db:"created_at"`
+	UpdatedAt int64  `db:"updated_at"`
+}
+
+// BeatmapCollectionItem is a difficulty in a collection. Imported ones the
+// score server has never seen are only known by MD5, and have no IDs or
+// name.
+type BeatmapCollectionItem struct {
+	CollectionID int64  `db:"collection_id"`
+	BeatmapMD5   string `db:"beatmap_md5"`
+	BeatmapID    int    `db:"beatmap_id"`
+	BeatmapsetID int    `db:"beatmapset_id"`
+	SongName     string `db:"song_name"`
+	AddedAt      int64  `db:"added_at"`
+}
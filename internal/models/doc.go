@@ -0,0 +1,63 @@
+package models
+
+type DocFormat string
+
+const (
+	DocFormatMarkdown DocFormat = "markdown"
+	DocFormatHTML     DocFormat = "html"
+)
+
+var DocFormats = []DocFormat{DocFormatMarkdown, DocFormatHTML}
+
+func (f DocFormat) Valid() bool {
+	return f == DocFormatMarkdown || f == DocFormatHTML
+}
+
+func (f DocFormat) Label() string {
+	switch f {
+	case DocFormatMarkdown:
+		return "Markdown"
+	case DocFormatHTML:
+		return "HTML"
+	}
+	return string(f)
+}
+
+// DocPage is a documentation page along with the title of its newest
+// revision, which is what staff see in the admin list.
+type DocPage struct {
+	ID                  int64  `db:"id"`
+	Slug                string `db:"slug"`
+	Title               string `db:"title"`
+	PublishedRevisionID int64  `db:"published_revision_id"`
+	LatestRevisionID    int64  `db:"latest_revision_id"`
+	CreatedAt           int64  `db:"created_at"`
+	UpdatedAt           int64  `db:"updated_at"`
+}
+
+func (p DocPage) IsPublished() bool {
+	return p.PublishedRevisionID != 0
+}
+
+// HasDraft reports whether there are saved changes that aren't published.
+func (p DocPage) HasDraft() bool {
+	return p.LatestRevisionID > p.PublishedRevisionID
+}
+
+type DocRevision struct {
+	ID         int64     `db:"id"`
+	PageID     int64     `db:"page_id"`
+	Title      string    `db:"title"`
+	Body       string    `db:"body"`
+	Format     DocFormat `db:"format"`
+	Summary    string    `db:"summary"`
+	AuthorID   int       `db:"author_id"`
+	AuthorName string    `db:"author_name"`
+	CreatedAt  int64     `db:"created_at"`
+}
+
+// PublishedDoc is the live revision of a page, as served to the public.
+type PublishedDoc struct {
+	Slug string `db:"slug"`
+	DocRevision
+}
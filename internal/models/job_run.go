@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+type JobRunStatus string
+
+const (
+	JobRunRunning   JobRunStatus = "running"
+	JobRunSucceeded JobRunStatus = "succeeded"
+	JobRunFailed    JobRunStatus = "failed"
+)
+
+// JobRun is one run of a scheduled job on one instance.
+type JobRun struct {
+	ID        int64        `db:"id"`
+	Job       string       `db:"job"`
+	Instance  string       `db:"instance"`
+	Manual    bool         `db:"manual"`
+	Status    JobRunStatus `db:"status"`
+	Error     string       `db:"error"`
+	StartedAt int64        `db:"started_at"`
+	// DurationMS is zero until the run finishes.
+	DurationMS int64 `db:"duration_ms"`
+}
+
+func (r JobRun) Duration() time.Duration {
+	return time.Duration(r.DurationMS) * time.Millisecond
+}
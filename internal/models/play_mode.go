@@ -0,0 +1,75 @@
+package models
+
+import "fmt"
+
+var (
+	ModeNames       = []string{"osu!", "Taiko", "Catch", "Mania"}
+	CustomModeNames = []string{"Vanilla", "Relax", "Autopilot"}
+
+	// modeSuffixes name the per-mode columns of the stats tables.
+	modeSuffixes = []string{"std", "taiko", "ctb", "mania"}
+)
+
+// PlayMode is a game mode together with its custom mode (vanilla, relax or
+// autopilot).
+type PlayMode struct {
+	Mode       int
+	CustomMode int
+}
+
+// Valid reports whether the combination exists: relax has no mania, and
+// autopilot is osu! only.
+func (m PlayMode) Valid() bool {
+	if m.Mode < 0 || m.Mode >= len(ModeNames) || m.CustomMode < 0 || m.CustomMode >= len(CustomModeNames) {
+		return false
+	}
+	switch m.CustomMode {
+	case 1:
+		return m.Mode != 3
+	case 2:
+		return m.Mode == 0
+	}
+	return true
+}
+
+func (m PlayMode) Label() string {
+	if m.CustomMode == 0 {
+		return ModeNames[m.Mode]
+	}
+	return fmt.Sprintf("%s (%s)", ModeNames[m.Mode], CustomModeNames[m.CustomMode])
+}
+
+// Suffix is the column suffix used for this mode in the stats tables.
+func (m PlayMode) Suffix() string {
+	return modeSuffixes[m.Mode]
+}
+
+// PlayModes expands a mode and custom mode, either of which may be -1 for
+// all of them, into the valid combinations.
+func PlayModes(mode, customMode int) []PlayMode {
+	var modes []PlayMode
+	for cm := range CustomModeNames {
+		if customMode != -1 && cm != customMode {
+			continue
+		}
+		for m := range ModeNames {
+			if mode != -1 && m != mode {
+				continue
+			}
+			if pm := (PlayMode{Mode: m, CustomMode: cm}); pm.Valid() {
+				modes = append(modes, pm)
+			}
+		}
+	}
+	return modes
+}
+
+// WipePreview is what wiping one play mode would remove.
+type WipePreview struct {
+	PlayMode
+	Scores      int   `db:"scores"`
+	RankedScore int64 `db:"ranked_score"`
+	TotalScore  int64 `db:"total_score"`
+	Playcount   int   `db:"playcount"`
+	PP          int   `db:"pp"`
+}
@@ -78,3 +78,14 @@ func (p UserPrivileges) String() string {
 
 	return strings.Join(parts, ", ")
 }
+
+// Names lists the set privileges by name, lowest bit first.
+func (p UserPrivileges) Names() []string {
+	var names []string
+	for priv := UserPrivilegePublic; priv <= UserPrivilegeTournamentStaff; priv <<= 1 {
+		if p&priv != 0 {
+			names = append(names, privilegeNames[priv])
+		}
+	}
+	return names
+}
@@ -0,0 +1,105 @@
+package models
+
+import "encoding/json"
+
+type RankRequestStatus string
+
+const (
+	RankRequestPending RankRequestStatus = "pending"
+	RankRequestRanked  RankRequestStatus = "ranked"
+	RankRequestLoved   RankRequestStatus = "loved"
+	RankRequestDenied  RankRequestStatus = "denied"
+)
+
+var RankRequestStatuses = []RankRequestStatus{
+	RankRequestPending,
+	RankRequestRanked,
+	RankRequestLoved,
+	RankRequestDenied,
+}
+
+func (s RankRequestStatus) Valid() bool {
+	for _, status := range RankRequestStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func (s RankRequestStatus) Label() string {
+	switch s {
+	case RankRequestPending:
+		return "Pending"
+	case RankRequestRanked:
+		return "Ranked"
+	case RankRequestLoved:
+		return "Loved"
+	case RankRequestDenied:
+		return "Denied"
+	}
+	return string(s)
+}
+
+// RankedStatus is the beatmap status a decision applies, or false for
+// decisions that leave the map alone.
+func (s RankRequestStatus) RankedStatus() (int, bool) {
+	switch s {
+	case RankRequestRanked:
+		return StatusRanked, true
+	case RankRequestLoved:
+		return StatusLoved, true
+	}
+	return 0, false
+}
+
+const (
+	RankRequestTypeSet     = "s"
+	RankRequestTypeBeatmap = "b"
+)
+
+// RankRequestBeatmap is the per-difficulty snapshot stored with a request.
+// The JSON shape matches what the rank request page renders.
+type RankRequestBeatmap struct {
+	BeatmapID     int     `json:"beatmap_id"`
+	BeatmapsetID  int     `json:"beatmapset_id"`
+	SongName      string  `json:"song_name"`
+	Mode          int     `json:"mode"`
+	DifficultyStd float64 `json:"difficulty_std"`
+}
+
+type RankRequest struct {
+	ID           int64             `db:"id" json:"request_id"`
+	UserID       int               `db:"user_id" json:"user_id"`
+	Username     string            `db:"username" json:"username"`
+	Type         string            `db:"request_type" json:"request_type"`
+	BeatmapID    int               `db:"beatmap_id" json:"beatmap_id"`
+	BeatmapsetID int               `db:"beatmapset_id" json:"beatmapset_id"`
+	SongName     string            `db:"song_name" json:"song_name"`
+	BeatmapsJSON string            `db:"beatmaps" json:"-"`
+	Status       RankRequestStatus `db:"status" json:"status"`
+	Reason       string            `db:"reason" json:"reason"`
+	ReviewerID   int               `db:"reviewer_id" json:"-"`
+	ReviewerName string            `db:"reviewer_name" json:"reviewer"`
+	RequestedAt  int64             `db:"requested_at" json:"requested_at"`
+	ReviewedAt   int64             `db:"reviewed_at" json:"reviewed_at"`
+}
+
+// Beatmaps decodes the difficulty snapshot. A corrupt snapshot yields no
+// difficulties rather than an error, since it's only used for display.
+func (r RankRequest) Beatmaps() []RankRequestBeatmap {
+	var beatmaps []RankRequestBeatmap
+	if err := json.Unmarshal([]byte(r.BeatmapsJSON), &beatmaps); err != nil {
+		return nil
+	}
+	return beatmaps
+}
+
+// MarshalJSON includes the decoded difficulty snapshot.
+func (r RankRequest) MarshalJSON() ([]byte, error) {
+	type plain RankRequest
+	return json.Marshal(struct {
+		plain
+		Beatmaps []RankRequestBeatmap `json:"beatmaps"`
+	}{plain(r), r.Beatmaps()})
+}
@@ -0,0 +1,40 @@
+package models
+
+// RegistrationKey lets someone register while registration is invite-only.
+// Personal invites have an OwnerID; staff keys don't.
+type RegistrationKey struct {
+	ID          int64  `db:"id"`
+	Key         string `db:"key"`
+	MaxUses     int    `db:"max_uses"`
+	Uses        int    `db:"uses"`
+	ExpiresAt   int64  `db:"expires_at"`
+	Note        string `db:"note"`
+	CreatedBy   int    `db:"created_by"`
+	CreatorName string `db:"creator_name"`
+	OwnerID     int    `db:"owner_id"`
+	OwnerName   string `db:"owner_name"`
+	Revoked     bool   `db:"revoked"`
+	CreatedAt   int64  `db:"created_at"`
+}
+
+func (k RegistrationKey) Personal() bool {
+	return k.OwnerID != 0
+}
+
+// Usable reports whether the key can still register an account at now.
+func (k RegistrationKey) Usable(now int64) bool {
+	return !k.Revoked && k.Uses < k.MaxUses && (k.ExpiresAt == 0 || k.ExpiresAt > now)
+}
+
+// RegistrationKeyUse is an account created with a key.
+type RegistrationKeyUse struct {
+	UserID    int    `db:"user_id"`
+	Username  string `db:"username"`
+	KeyID     int64  `db:"key_id"`
+	Key       string `db:"key"`
+	Note      string `db:"note"`
+	OwnerID   int    `db:"owner_id"`
+	OwnerName string `db:"owner_name"`
+	UsedAt    int64  `db:"used_at"`
+	IP        string `db:"ip"`
+}
@@ -0,0 +1,238 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+)
+
+type ReportTarget string
+
+const (
+	ReportTargetUser     ReportTarget = "user"
+	ReportTargetScore    ReportTarget = "score"
+	ReportTargetUserpage ReportTarget = "userpage"
+	ReportTargetClan     ReportTarget = "clan"
+)
+
+func (t ReportTarget) Valid() bool {
+	switch t {
+	case ReportTargetUser, ReportTargetScore, ReportTargetUserpage, ReportTargetClan:
+		return true
+	}
+	return false
+}
+
+func (t ReportTarget) Label() string {
+	switch t {
+	case ReportTargetUser:
+		return "Player"
+	case ReportTargetScore:
+		return "Score"
+	case ReportTargetUserpage:
+		return "Userpage"
+	case ReportTargetClan:
+		return "Clan"
+	}
+	return string(t)
+}
+
+// URL is the public page the reported content lives on. Scores have no page
+// of their own, so staff get the score details on the report instead.
+func (t ReportTarget) URL(id int64) string {
+	switch t {
+	case ReportTargetUser, ReportTargetUserpage:
+		return "/u/" + strconv.FormatInt(id, 10)
+	case ReportTargetClan:
+		return "/clans/" + strconv.FormatInt(id, 10)
+	}
+	return ""
+}
+
+type ReportStatus string
+
+const (
+	ReportStatusOpen       ReportStatus = "open"
+	ReportStatusInProgress ReportStatus = "in_progress"
+	ReportStatusResolved   ReportStatus = "resolved"
+	ReportStatusRejected   ReportStatus = "rejected"
+)
+
+// ReportStatuses lists every status in the order the triage queue shows them.
+var ReportStatuses = []ReportStatus{
+	ReportStatusOpen,
+	ReportStatusInProgress,
+	ReportStatusResolved,
+	ReportStatusRejected,
+}
+
+func (s ReportStatus) Valid() bool {
+	for _, status := range ReportStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func (s ReportStatus) IsClosed() bool {
+	return s == ReportStatusResolved || s == ReportStatusRejected
+}
+
+func (s ReportStatus) Label() string {
+	switch s {
+	case ReportStatusOpen:
+		return "Open"
+	case ReportStatusInProgress:
+		return "In progress"
+	case ReportStatusResolved:
+		return "Resolved"
+	case ReportStatusRejected:
+		return "Rejected"
+	}
+	return string(s)
+}
+
+type ReportCategory struct {
+	Key     string
+	Label   string
+	Targets []ReportTarget
+}
+
+func (c ReportCategory) AppliesTo(t ReportTarget) bool {
+	for _, target := range c.Targets {
+		if target == t {
+			return true
+		}
+	}
+	return false
+}
+
+var ReportCategories = []ReportCategory{
+	{Key: "cheating", Label: "Cheating", Targets: []ReportTarget{ReportTargetUser, ReportTargetScore}},
+	{Key: "multi_account", Label: "Multi-accounting", Targets: []ReportTarget{ReportTargetUser}},
+	{Key: "score_farming", Label: "Score farming or abuse", Targets: []ReportTarget{ReportTargetUser, ReportTargetScore}},
+	{Key: "inappropriate_name", Label: "Inappropriate name", Targets: []ReportTarget{ReportTargetUser, ReportTargetClan}},
+	{Key: "offensive_content", Label: "Offensive content", Targets: []ReportTarget{ReportTargetUserpage, ReportTargetClan}},
+	{Key: "harassment", Label: "Harassment", Targets: []ReportTarget{ReportTargetUser, ReportTargetUserpage}},
+	{Key: "spam", Label: "Spam or advertising", Targets: []ReportTarget{ReportTargetUserpage, ReportTargetClan}},
+	{Key: "other", Label: "Other", Targets: []ReportTarget{ReportTargetUser, ReportTargetScore, ReportTargetUserpage, ReportTargetClan}},
+}
+
+func FindReportCategory(key string) (ReportCategory, bool) {
+	for _, c := range ReportCategories {
+		if c.Key == key {
+			return c, true
+		}
+	}
+	return ReportCategory{}, false
+}
+
+// ReportResolution is a canned closing outcome. Message is sent to the
+// reporter, so keep it free of anything staff-internal.
+type ReportResolution struct {
+	Key     string
+	Label   string
+	Status  ReportStatus
+	Message string
+}
+
+var ReportResolutions = []ReportResolution{
+	{
+		Key:     "actioned",
+		Label:   "Action taken",
+		Status:  ReportStatusResolved,
+		Message: "Thank you for your report. We have looked into it and taken appropriate action.",
+	},
+	{
+		Key:     "already_actioned",
+		Label:   "Already handled",
+		Status:  ReportStatusResolved,
+		Message: "Thank you for your report. This has already been dealt with.",
+	},
+	{
+		Key:     "insufficient_evidence",
+		Label:   "Insufficient evidence",
+		Status:  ReportStatusRejected,
+		Message: "Thank you for your report. We could not find enough evidence to act on it. Feel free to report again with more evidence.",
+	},
+	{
+		Key:     "no_violation",
+		Label:   "No rule violation",
+		Status:  ReportStatusRejected,
+		Message: "Thank you for your report. After reviewing it, we did not find a violation of the rules.",
+	},
+	{
+		Key:     "duplicate",
+		Label:   "Duplicate",
+		Status:  ReportStatusRejected,
+		Message: "Thank you for your report. This has already been reported and is being handled.",
+	},
+}
+
+func FindReportResolution(key string) (ReportResolution, bool) {
+	for _, r := range ReportResolutions {
+		if r.Key == key {
+			return r, true
+		}
+	}
+	return ReportResolution{}, false
+}
+
+type Report struct {
+	ID                int64        `db:"id"`
+	ReporterID        int          `db:"reporter_id"`
+	ReporterName      string       `db:"reporter_name"`
+	TargetType        ReportTarget `db:"target_type"`
+	TargetID          int64        `db:"target_id"`
+	TargetName        string       `db:"target_name"`
+	Category          string       `db:"category"`
+	Reason            string       `db:"reason"`
+	Evidence          string       `db:"evidence"`
+	Status            ReportStatus `db:"status"`
+	AssigneeID        int          `db:"assignee_id"`
+	AssigneeName      string       `db:"assignee_name"`
+	Resolution        string       `db:"resolution"`
+	ResolutionMessage string       `db:"resolution_message"`
+	CreatedAt         int64        `db:"created_at"`
+	UpdatedAt         int64        `db:"updated_at"`
+	ClosedAt          int64        `db:"closed_at"`
+}
+
+func (r Report) CategoryLabel() string {
+	if c, ok := FindReportCategory(r.Category); ok {
+		return c.Label
+	}
+	return r.Category
+}
+
+func (r Report) ResolutionLabel() string {
+	if res, ok := FindReportResolution(r.Resolution); ok {
+		return res.Label
+	}
+	return r.Resolution
+}
+
+// EvidenceLinks splits the stored evidence into one link per line.
+func (r Report) EvidenceLinks() []string {
+	var links []string
+	for _, line := range strings.Split(r.Evidence, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			links = append(links, line)
+		}
+	}
+	return links
+}
+
+func (r Report) TargetURL() string {
+	return r.TargetType.URL(r.TargetID)
+}
+
+type ReportNote struct {
+	ID         int64  `db:"id"`
+	ReportID   int64  `db:"report_id"`
+	AuthorID   int    `db:"author_id"`
+	AuthorName string `db:"author_name"`
+	Content    string `db:"content"`
+	IsEvent    bool   `db:"is_event"`
+	CreatedAt  int64  `db:"created_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+// RulesVersion is a material change to the rules that users must accept.
+type RulesVersion struct {
+	ID          int64  `db:"id"`
+	RevisionID  int64  `db:"revision_id"`
+	Summary     string `db:"summary"`
+	CreatedBy   int    `db:"created_by"`
+	CreatorName string `db:"creator_name"`
+	CreatedAt   int64  `db:"created_at"`
+	Acceptances int    `db:"acceptances"`
+}
+
+// RulesAcceptance is a rules version as seen by one user. AcceptedAt is 0 for
+// versions the user never accepted.
+type RulesAcceptance struct {
+	VersionID  int64  `db:"version_id"`
+	Summary    string `db:"summary"`
+	CreatedAt  int64  `db:"created_at"`
+	AcceptedAt int64  `db:"accepted_at"`
+	IP         string `db:"ip"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+type SystemSetting struct {
+	Name        string `db:"name"`
+	ValueInt    int64  `db:"value_int"`
+	ValueString string `db:"value_string"`
+}
+
+// Maintenance describes the website maintenance state. Enabled is the manual
+// switch; StartsAt/EndsAt describe an optional scheduled window which takes
+// the site down on its own once it begins.
+type Maintenance struct {
+	Enabled  bool
+	StartsAt time.Time
+	EndsAt   time.Time
+	Message  string
+}
+
+// ActiveAt reports whether the site is in maintenance at the given time.
+func (m Maintenance) ActiveAt(now time.Time) bool {
+	if m.Enabled {
+		return m.EndsAt.IsZero() || now.Before(m.EndsAt)
+	}
+	if m.StartsAt.IsZero() {
+		return false
+	}
+	return !now.Before(m.StartsAt) && (m.EndsAt.IsZero() || now.Before(m.EndsAt))
+}
+
+// UpcomingAt reports whether a scheduled window has been announced but has
+// not begun yet.
+func (m Maintenance) UpcomingAt(now time.Time) bool {
+	return !m.ActiveAt(now) && !m.StartsAt.IsZero() && now.Before(m.StartsAt)
+}
+
+// RetryAfter estimates how long clients should wait before trying again.
+func (m Maintenance) RetryAfter(now time.Time) time.Duration {
+	if !m.EndsAt.IsZero() && m.EndsAt.After(now) {
+		return m.EndsAt.Sub(now)
+	}
+	return 5 * time.Minute
+}
+
+func (m Maintenance) IsActive() bool {
+	return m.ActiveAt(time.Now())
+}
+
+func (m Maintenance) IsUpcoming() bool {
+	return m.UpcomingAt(time.Now())
+}
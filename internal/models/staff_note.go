@@ -0,0 +1,29 @@
+package models
+
+// StaffNote is a private note staff keep on a user, clan or beatmap set.
+type StaffNote struct {
+	ID         int64       `db:"id"`
+	TargetType AdminTarget `db:"target_type"`
+	TargetID   int         `db:"target_id"`
+	Body       string      `db:"body"`
+	Pinned     bool        `db:"pinned"`
+	AuthorID   int         `db:"author_id"`
+	AuthorName string      `db:"author_name"`
+	EditorID   int         `db:"editor_id"`
+	EditorName string      `db:"editor_name"`
+	CreatedAt  int64       `db:"created_at"`
+	UpdatedAt  int64       `db:"updated_at"`
+}
+
+func (n StaffNote) Edited() bool {
+	return n.UpdatedAt != n.CreatedAt
+}
+
+type StaffNoteRevision struct {
+	ID         int64  `db:"id"`
+	NoteID     int64  `db:"note_id"`
+	Body       string `db:"body"`
+	EditorID   int    `db:"editor_id"`
+	EditorName string `db:"editor_name"`
+	CreatedAt  int64  `db:"created_at"`
+}
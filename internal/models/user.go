@@ -102,3 +102,46 @@ type UserStats struct {
 	ReplayViews int     `db:"replays_watched"`
 	Level       float64 `db:"level"`
 }
+
+type UsernameChange struct {
+	Username  string `db:"username"`
+	ChangedAt int64  `db:"changed_datetime"`
+}
+
+// UserIP is an address a user has logged in from. SharedWith counts the other
+// accounts seen on it.
+type UserIP struct {
+	IP          string `db:"ip"`
+	Occurrences int    `db:"occurencies"`
+	SharedWith  int    `db:"shared_with"`
+}
+
+type IdentityToken struct {
+	Token      string `db:"token"`
+	SharedWith int    `db:"shared_with"`
+}
+
+// ModerationState is the restriction and silence state kept on the users row,
+// along with the free-form staff notes.
+type ModerationState struct {
+	BannedAt      int64  `db:"ban_datetime"`
+	SilenceEnd    int64  `db:"silence_end"`
+	SilenceReason string `db:"silence_reason"`
+	Notes         string `db:"notes"`
+}
+
+// Silence is a user's silence as kept on the users row.
+type Silence struct {
+	UserID int    `db:"id"`
+	End    int64  `db:"silence_end"`
+	Reason string `db:"silence_reason"`
+}
+
+// TimedRestriction is a restriction that's lifted once EndsAt passes.
+type TimedRestriction struct {
+	UserID       int    `db:"user_id"`
+	RestrictedAt int64  `db:"restricted_at"`
+	EndsAt       int64  `db:"ends_at"`
+	Reason       string `db:"reason"`
+	CreatedBy    int    `db:"created_by"`
+}
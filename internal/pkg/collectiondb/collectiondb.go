@@ -0,0 +1,161 @@
+// Package collectiondb reads and writes osu!'s collection.db, which holds
+// named collections of difficulties, each listed by its .osu file's MD5.
+package collectiondb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// Version is written to exported files. osu! reads older versions and
+// upgrades them itself.
+const Version = 20150203
+
+// maxStringLength is far above any collection name or MD5, and stops a bad
+// length from allocating a lot.
+const maxStringLength = 1 << 12
+
+// ErrMalformed is returned for files that aren't a collection.db.
+var ErrMalformed = errors.New("collectiondb: malformed file")
+
+type Collection struct {
+	Name string
+	MD5s []string
+}
+
+func Write(w io.Writer, collections []Collection) error {
+	bw := bufio.NewWriter(w)
+	writeInt(bw, Version)
+	writeInt(bw, int32(len(collections)))
+	for _, c := range collections {
+		writeString(bw, c.Name)
+		writeInt(bw, int32(len(c.MD5s)))
+		for _, md5 := range c.MD5s {
+			writeString(bw, md5)
+		}
+	}
+	return bw.Flush()
+}
+
+func Read(r io.Reader) ([]Collection, error) {
+	br := bufio.NewReader(r)
+	if _, err := readInt(br); err != nil {
+		return nil, err
+	}
+	count, err := readInt(br)
+	if err != nil {
+		return nil, err
+	}
+
+	// Counts aren't trusted for allocation; a short file runs out first.
+	collections := make([]Collection, 0, min(count, 256))
+	for i := 0; i < count; i++ {
+		name, err := readString(br)
+		if err != nil {
+			return nil, err
+		}
+		n, err := readInt(br)
+		if err != nil {
+			return nil, err
+		}
+		c := Collection{Name: name, MD5s: make([]string, 0, min(n, 1024))}
+		for j := 0; j < n; j++ {
+			md5, err := readString(br)
+			if err != nil {
+				return nil, err
+			}
+			c.MD5s = append(c.MD5s, md5)
+		}
+		collections = append(collections, c)
+	}
+	return collections, nil
+}
+
+// writeInt and writeString leave errors to the final Flush, which reports
+// the first one.
+func writeInt(w *bufio.Writer, v int32) {
+	binary.Write(w, binary.LittleEndian, v)
+}
+
+// writeString writes an osu! string: 0x0b, the length as ULEB128, then the
+// UTF-8 bytes.
+func writeString(w *bufio.Writer, s string) {
+	w.WriteByte(0x0b)
+	n := uint64(len(s))
+	for {
+		b := byte(n & 0x7f)
+		n >>= 7
+		if n != 0 {
+			b |= 0x80
+		}
+		w.WriteByte(b)
+		if n == 0 {
+			break
+		}
+	}
+	w.WriteString(s)
+}
+
+func readInt(r *bufio.Reader) (int, error) {
+	var v int32
+	if err := binary.Read(r, binary.LittleEndian, &v); err != nil {
+		return 0, malformed(err)
+	}
+	if v < 0 {
+		return 0, fmt.Errorf("%w: negative count", ErrMalformed)
+	}
+	return int(v), nil
+}
+
+// readString reads an osu! string. 0x00 marks one that isn't there, which
+// reads as empty.
+func readString(r *bufio.Reader) (string, error) {
+	marker, err := r.ReadByte()
+	if err != nil {
+		return "", malformed(err)
+	}
+	switch marker {
+	case 0x00:
+		return "", nil
+	case 0x0b:
+	default:
+		return "", fmt.Errorf("%w: bad string marker %#x", ErrMalformed, marker)
+	}
+
+	var n uint64
+	for shift := uint(0); ; shift += 7 {
+		if shift > 28 {
+			return "", fmt.Errorf("%w: string too long", ErrMalformed)
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", malformed(err)
+		}
+		n |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	if n > maxStringLength {
+		return "", fmt.Errorf("%w: string too long", ErrMalformed)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", malformed(err)
+	}
+	if !utf8.Valid(buf) {
+		return "", fmt.Errorf("%w: string isn't UTF-8", ErrMalformed)
+	}
+	return string(buf), nil
+}
+
+func malformed(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: file ends early", ErrMalformed)
+	}
+	return err
+}
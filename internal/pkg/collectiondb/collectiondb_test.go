@@ -0,0 +1,113 @@
+package collectiondb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func equal(a, b []Collection) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || len(a[i].MD5s) != len(b[i].MD5s) {
+			return false
+		}
+		for j := range a[i].MD5s {
+			if a[i].MD5s[j] != b[i].MD5s[j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := [][]Collection{
+		nil,
+		{{Name: "Empty"}},
+		{
+			{Name: "Farm maps", MD5s: []string{"d41d8cd98f00b204e9800998ecf8427e", "0cc175b9c0f1b6a831c399e269772661"}},
+			{Name: ""},
+			{Name: "東方 ☆ Ünïcödé", MD5s: []string{"92eb5ffee6ae2fec3ad71c777531578f"}},
+			// Over 127 bytes, so its length takes two bytes.
+			{Name: strings.Repeat("長い", 30)},
+		},
+	}
+	for _, collections := range tests {
+		var buf bytes.Buffer
+		if err := Write(&buf, collections); err != nil {
+			t.Fatal(err)
+		}
+		got, err := Read(&buf)
+		if err != nil {
+			t.Fatalf("reading back %v: %v", collections, err)
+		}
+		if !equal(got, collections) {
+			t.Errorf("read back %v, want %v", got, collections)
+		}
+	}
+}
+
+// testdata/collection.db is laid out the way osu! writes it: a collection
+// with two MD5s, one with an empty name and none, one with a non-ASCII
+// name, and one with a 200 byte name whose only MD5 is absent (0x00).
+func TestReadFixture(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "collection.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	got, err := Read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Collection{
+		{Name: "Farm maps", MD5s: []string{"d41d8cd98f00b204e9800998ecf8427e", "d41d8cd98f00b204e9800998ecf8427f"}},
+		{Name: ""},
+		{Name: "東方 ☆ Ünïcödé", MD5s: []string{"d41d8cd98f00b204e9800998ecf84280"}},
+		{Name: strings.Repeat("x", 200), MD5s: []string{""}},
+	}
+	if !equal(got, want) {
+		t.Errorf("Read = %q, want %q", got, want)
+	}
+}
+
+func TestWriteLayout(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, []Collection{{Name: "é", MD5s: []string{"ab"}}}); err != nil {
+		t.Fatal(err)
+	}
+	want := binary.LittleEndian.AppendUint32(nil, Version)
+	want = binary.LittleEndian.AppendUint32(want, 1)
+	want = append(want, 0x0b, 2, 0xc3, 0xa9)
+	want = binary.LittleEndian.AppendUint32(want, 1)
+	want = append(want, 0x0b, 2, 'a', 'b')
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("Write = % x, want % x", buf.Bytes(), want)
+	}
+}
+
+func TestReadMalformed(t *testing.T) {
+	header := binary.LittleEndian.AppendUint32(nil, Version)
+	tests := map[string][]byte{
+		"empty":          {},
+		"no count":       header,
+		"negative count": binary.LittleEndian.AppendUint32(append([]byte(nil), header...), 0xffffffff),
+		"short":          append(binary.LittleEndian.AppendUint32(append([]byte(nil), header...), 1), 0x0b, 5, 'a'),
+		"bad marker":     append(binary.LittleEndian.AppendUint32(append([]byte(nil), header...), 1), 0x0c),
+		"not UTF-8":      append(binary.LittleEndian.AppendUint32(append([]byte(nil), header...), 1), 0x0b, 1, 0xff),
+		"too long":       append(binary.LittleEndian.AppendUint32(append([]byte(nil), header...), 1), 0x0b, 0xff, 0xff, 0xff, 0xff, 0x0f),
+	}
+	for name, data := range tests {
+		if _, err := Read(bytes.NewReader(data)); !errors.Is(err, ErrMalformed) {
+			t.Errorf("%s: Read error = %v, want ErrMalformed", name, err)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// Package diskcache keeps files in a directory, evicting the least recently
+// used once their total size passes a limit.
+package diskcache
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tmpPrefix marks files still being written. They're never served, and ones
+// left over from a crash are removed by New.
+const tmpPrefix = ".tmp-"
+
+// ErrTooLarge is returned by a Writer once the file would pass its limit.
+// The Writer must still be aborted.
+var ErrTooLarge = errors.New("diskcache: file too large")
+
+// Cache is safe for concurrent use. Recency is tracked in memory and kept in
+// each file's modification time, so it survives a restart.
+type Cache struct {
+	dir     string
+	maxSize int64
+
+	mu      sync.Mutex
+	size    int64
+	order   *list.List // most recently used first
+	entries map[string]*list.Element
+}
+
+type entry struct {
+	key  string
+	size int64
+}
+
+// New opens the cache in dir, creating it if needed, and evicts down to
+// maxSize bytes.
+func New(dir string, maxSize int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	c := &Cache{
+		dir:     dir,
+		maxSize: maxSize,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	type existing struct {
+		entry
+		modTime time.Time
+	}
+	var found []existing
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		if strings.HasPrefix(f.Name(), tmpPrefix) {
+			os.Remove(filepath.Join(dir, f.Name()))
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		found = append(found, existing{entry{f.Name(), info.Size()}, info.ModTime()})
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].modTime.After(found[j].modTime) })
+	for _, f := range found {
+		c.entries[f.key] = c.order.PushBack(&entry{f.key, f.size})
+		c.size += f.size
+	}
+
+	c.mu.Lock()
+	c.evict()
+	c.mu.Unlock()
+	return c, nil
+}
+
+// Size is the total size of the cached files in bytes.
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// Open returns the file cached under key, or an error satisfying
+// errors.Is(err, fs.ErrNotExist) if there isn't one.
+func (c *Cache) Open(key string) (*os.File, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	path := filepath.Join(c.dir, key)
+	f, err := os.Open(path)
+	if err != nil {
+		// Deleted behind our back.
+		c.remove(el)
+		return nil, err
+	}
+	c.order.MoveToFront(el)
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return f, nil
+}
+
+// Create starts writing a file to be cached under key. Nothing is visible
+// until Commit, and a file already cached under key is served until then.
+// Writes that would take the file past limit bytes fail with ErrTooLarge.
+// The cache's own size is the limit when limit is 0 or more.
+func (c *Cache) Create(key string, limit int64) (*Writer, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > c.maxSize {
+		limit = c.maxSize
+	}
+	f, err := os.CreateTemp(c.dir, tmpPrefix+"*")
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{cache: c, key: key, file: f, limit: limit}, nil
+}
+
+// remove forgets el and deletes its file. c.mu must be held.
+func (c *Cache) remove(el *list.Element) {
+	e := el.Value.(*entry)
+	c.order.Remove(el)
+	delete(c.entries, e.key)
+	c.size -= e.size
+	if err := os.Remove(filepath.Join(c.dir, e.key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("failed to remove cached file", "error", err, "key", e.key)
+	}
+}
+
+// evict removes the least recently used files until the cache fits. c.mu
+// must be held.
+func (c *Cache) evict() {
+	for c.size > c.maxSize && c.order.Len() > 0 {
+		c.remove(c.order.Back())
+	}
+}
+
+// add moves a finished temporary file into place under key.
+func (c *Cache) add(tmp, key string, size int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.Rename(tmp, filepath.Join(c.dir, key)); err != nil {
+		return err
+	}
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*entry)
+		c.size += size - e.size
+		e.size = size
+		c.order.MoveToFront(el)
+	} else {
+		c.entries[key] = c.order.PushFront(&entry{key, size})
+		c.size += size
+	}
+	c.evict()
+	return nil
+}
+
+// validKey keeps keys to plain file names inside the cache directory.
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, ".") || strings.ContainsAny(key, `/\`) {
+		return fmt.Errorf("diskcache: invalid key %q", key)
+	}
+	return nil
+}
+
+// Writer writes one file into the cache. Exactly one of Commit or Abort must
+// be called.
+type Writer struct {
+	cache *Cache
+	key   string
+	file  *os.File
+	size  int64
+	limit int64
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.size+int64(len(p)) > w.limit {
+		return 0, ErrTooLarge
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Commit makes the file visible under its key.
+func (w *Writer) Commit() error {
+	if err := w.file.Close(); err != nil {
+		os.Remove(w.file.Name())
+		return err
+	}
+	if err := w.cache.add(w.file.Name(), w.key, w.size); err != nil {
+		os.Remove(w.file.Name())
+		return err
+	}
+	return nil
+}
+
+// Abort throws the file away.
+func (w *Writer) Abort() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
@@ -0,0 +1,235 @@
+package diskcache
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func put(t *testing.T, c *Cache, key string, size int) {
+	t.Helper()
+	w, err := c.Create(key, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(strings.Repeat("x", size))); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func cached(c *Cache, key string) bool {
+	f, err := c.Open(key)
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return true
+}
+
+func TestEvictsLeastRecentlyUsed(t *testing.T) {
+	c, err := New(t.TempDir(), 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	put(t, c, "a", 10)
+	put(t, c, "b", 10)
+	put(t, c, "c", 10)
+
+	// Reading a makes b the least recently used.
+	if !cached(c, "a") {
+		t.Fatal("a isn't cached")
+	}
+	put(t, c, "d", 10)
+
+	if cached(c, "b") {
+		t.Error("b is still cached, want it evicted")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if !cached(c, key) {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+}
+
+func TestStaysWithinMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		put(t, c, key, 10)
+		if c.Size() > 25 {
+			t.Fatalf("size %d after adding %s, want at most 25", c.Size(), key)
+		}
+	}
+	if c.Size() != 20 {
+		t.Errorf("size %d, want 20", c.Size())
+	}
+
+	var onDisk int64
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		info, err := f.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		onDisk += info.Size()
+	}
+	if onDisk != c.Size() {
+		t.Errorf("%d bytes on disk, cache counts %d", onDisk, c.Size())
+	}
+
+	// Overwriting a key replaces its size rather than adding to it.
+	put(t, c, "e", 5)
+	if c.Size() != 15 {
+		t.Errorf("size %d after overwriting e, want 15", c.Size())
+	}
+}
+
+func TestNewEvictsByModTime(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for i, key := range []string{"old", "mid", "new"} {
+		path := filepath.Join(dir, key)
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", 10)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		at := now.Add(time.Duration(i-3) * time.Hour)
+		if err := os.Chtimes(path, at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, tmpPrefix+"left-over"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := New(dir, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached(c, "old") || !cached(c, "mid") || !cached(c, "new") {
+		t.Error("New didn't evict the oldest file first")
+	}
+	if _, err := os.Stat(filepath.Join(dir, tmpPrefix+"left-over")); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("New left an unfinished file behind")
+	}
+}
+
+func TestCommitAndAbort(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := c.Create("k", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("hello"))
+	if _, err := c.Open("k"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open before Commit: %v, want fs.ErrNotExist", err)
+	}
+	if err := w.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := c.Open("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(f)
+	f.Close()
+	if string(got) != "hello" {
+		t.Errorf("read %q, want hello", got)
+	}
+
+	// An aborted rewrite leaves the committed file alone and no temporary
+	// file behind.
+	w, err = c.Create("k", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("goodbye, world"))
+	w.Abort()
+
+	f, err = c.Open("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ = io.ReadAll(f)
+	f.Close()
+	if string(got) != "hello" {
+		t.Errorf("read %q after Abort, want hello", got)
+	}
+	if c.Size() != 5 {
+		t.Errorf("size %d after Abort, want 5", c.Size())
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("%d files in the cache directory, want 1", len(files))
+	}
+}
+
+func TestWriteLimit(t *testing.T) {
+	c, err := New(t.TempDir(), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		limit  int64
+		writes []int
+		ok     bool
+	}{
+		{10, []int{10}, true},
+		{10, []int{6, 4}, true},
+		{10, []int{6, 5}, false},
+		{0, []int{100}, true},
+		// No file can be bigger than the cache, whatever its limit.
+		{0, []int{101}, false},
+		{1000, []int{60, 60}, false},
+	}
+	for _, tt := range tests {
+		w, err := c.Create("k", tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var writeErr error
+		for _, n := range tt.writes {
+			if _, writeErr = w.Write(make([]byte, n)); writeErr != nil {
+				break
+			}
+		}
+		w.Abort()
+		if tt.ok && writeErr != nil {
+			t.Errorf("limit %d, writes %v: %v", tt.limit, tt.writes, writeErr)
+		}
+		if !tt.ok && !errors.Is(writeErr, ErrTooLarge) {
+			t.Errorf("limit %d, writes %v: error %v, want ErrTooLarge", tt.limit, tt.writes, writeErr)
+		}
+	}
+}
+
+func TestRejectsKeysOutsideDir(t *testing.T) {
+	c, err := New(t.TempDir(), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"", ".hidden", "../escape", `a\b`, tmpPrefix + "x"} {
+		if _, err := c.Create(key, 0); err == nil {
+			t.Errorf("Create(%q) succeeded", key)
+		}
+	}
+}
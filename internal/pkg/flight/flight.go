@@ -0,0 +1,96 @@
+// Package flight makes concurrent work on the same key happen only once.
+package flight
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// PanicError is what every caller of Do gets when fn panics.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("flight: panic: %v\n\n%s", e.Value, e.Stack)
+}
+
+// Group tracks the work in progress for each key. The zero value is ready
+// to use.
+type Group[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*call[T]
+}
+
+type call[T any] struct {
+	done chan struct{}
+	val  T
+	err  error
+}
+
+// Do runs fn for key unless it's already running, then waits for the result
+// or for ctx to end. fn runs in its own goroutine, with a context that isn't
+// cancelled along with ctx, so a caller giving up doesn't fail everyone else
+// waiting on it. A panic in fn is recovered and returned to every caller as
+// a *PanicError.
+func (g *Group[T]) Do(ctx context.Context, key string, fn func(context.Context) (T, error)) (T, error) {
+	g.mu.Lock()
+	c, ok := g.calls[key]
+	if !ok {
+		c = g.start(key)
+		go func() {
+			defer g.finish(key, c)
+			defer func() {
+				if r := recover(); r != nil {
+					c.err = &PanicError{Value: r, Stack: debug.Stack()}
+				}
+			}()
+			c.val, c.err = fn(context.WithoutCancel(ctx))
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Claim marks key as in progress for work whose result isn't shared, such
+// as writing a file once while every caller streams it. It reports false if
+// the key is already in progress; otherwise release must be called when the
+// work is done. A Do for a claimed key waits for the release and gets the
+// zero value.
+func (g *Group[T]) Claim(key string) (release func(), ok bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.calls[key]; ok {
+		return nil, false
+	}
+	c := g.start(key)
+	var once sync.Once
+	return func() { once.Do(func() { g.finish(key, c) }) }, true
+}
+
+// start adds a call for key. g.mu must be held.
+func (g *Group[T]) start(key string) *call[T] {
+	if g.calls == nil {
+		g.calls = make(map[string]*call[T])
+	}
+	c := &call[T]{done: make(chan struct{})}
+	g.calls[key] = c
+	return c
+}
+
+func (g *Group[T]) finish(key string, c *call[T]) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(c.done)
+}
@@ -0,0 +1,132 @@
+package flight
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDoShares(t *testing.T) {
+	var g Group[int]
+	var calls atomic.Int32
+	const n = 10
+
+	// started is done once every caller is about to call Do and the first
+	// fn is running; value is then sent, and passed back for any fn that
+	// runs after the first.
+	var started, finished sync.WaitGroup
+	value := make(chan int, 1)
+	fn := func(context.Context) (int, error) {
+		if calls.Add(1) == 1 {
+			started.Done()
+		}
+		v := <-value
+		value <- v
+		return v, nil
+	}
+
+	results := make([]int, n)
+	started.Add(1)
+	for i := range results {
+		started.Add(1)
+		finished.Add(1)
+		go func(i int) {
+			defer finished.Done()
+			started.Done()
+			results[i], _ = g.Do(context.Background(), "k", fn)
+		}(i)
+	}
+	started.Wait()
+	value <- 42
+	finished.Wait()
+
+	if got := calls.Load(); got <= 0 || got >= n {
+		t.Errorf("fn ran %d times, want more than 0 and fewer than %d", got, n)
+	}
+	for i, v := range results {
+		if v != 42 {
+			t.Errorf("caller %d got %d, want 42", i, v)
+		}
+	}
+}
+
+func TestDoOutlivesCaller(t *testing.T) {
+	var g Group[int]
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := g.Do(ctx, "k", func(ctx context.Context) (int, error) {
+			close(started)
+			<-finish
+			return 1, ctx.Err()
+		})
+		done <- err
+	}()
+	<-started
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("cancelled caller got %v, want context.Canceled", err)
+	}
+
+	// A later caller joins the call still running, which wasn't cancelled.
+	go close(finish)
+	v, err := g.Do(context.Background(), "k", func(context.Context) (int, error) { return 2, nil })
+	if err != nil || (v != 1 && v != 2) {
+		t.Errorf("Do = %d, %v", v, err)
+	}
+}
+
+func TestDoPanic(t *testing.T) {
+	var g Group[int]
+	release := make(chan struct{})
+	fn := func(context.Context) (int, error) {
+		<-release
+		panic("boom")
+	}
+
+	const n = 3
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := g.Do(context.Background(), "key", fn)
+			errs <- err
+		}()
+	}
+	close(release)
+	for i := 0; i < n; i++ {
+		var panicErr *PanicError
+		if err := <-errs; !errors.As(err, &panicErr) || panicErr.Value != "boom" {
+			t.Errorf("err = %v, want the panic", err)
+		}
+	}
+
+	// The key is free again afterwards.
+	v, err := g.Do(context.Background(), "key", func(context.Context) (int, error) { return 1, nil })
+	if v != 1 || err != nil {
+		t.Errorf("Do after a panic = %d, %v; want 1, nil", v, err)
+	}
+}
+
+func TestClaim(t *testing.T) {
+	var g Group[struct{}]
+	release, ok := g.Claim("k")
+	if !ok {
+		t.Fatal("first Claim failed")
+	}
+	if _, ok := g.Claim("k"); ok {
+		t.Error("second Claim succeeded while the first was held")
+	}
+	if _, ok := g.Claim("other"); !ok {
+		t.Error("Claim of another key failed")
+	}
+	release()
+	release()
+	if _, ok := g.Claim("k"); !ok {
+		t.Error("Claim failed after release")
+	}
+}
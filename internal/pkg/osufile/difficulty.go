@@ -0,0 +1,436 @@
+package osufile
+
+import (
+	"errors"
+	"math"
+)
+
+// The speeds DT/NC and HT play at.
+const (
+	DoubleTimeSpeed = 1.5
+	HalfTimeSpeed   = 0.75
+)
+
+const (
+	normalisedRadius    = 50
+	minDeltaTime        = 25
+	maxSliderRadius     = normalisedRadius * 2.4
+	assumedSliderRadius = normalisedRadius * 1.8
+	stackDistance       = 3
+	starMultiplier      = 0.0675
+	// performanceBaseMultiplier is the pp formula's, which the star rating
+	// is scaled to match.
+	performanceBaseMultiplier = 1.14
+)
+
+var ErrUnsupportedMode = errors.New("osufile: star rating is only calculated for osu!standard")
+
+// Mods are the mods that change difficulty or performance. Hidden, NoFail
+// and SpunOut only affect performance.
+type Mods struct {
+	Easy     bool
+	HardRock bool
+	Hidden   bool
+	NoFail   bool
+	SpunOut  bool
+	// SpeedChange is how fast the map plays, with zero meaning 1.
+	SpeedChange float64
+}
+
+func (m Mods) rate() float64 {
+	if m.SpeedChange <= 0 {
+		return 1
+	}
+	return m.SpeedChange
+}
+
+// Attributes are a beatmap's difficulty with mods applied. AR and OD are
+// as they feel at the mods' speed.
+type Attributes struct {
+	StarRating     float64
+	Aim            float64
+	Speed          float64
+	SpeedNoteCount float64
+	// SliderFactor is how much of the aim rating is left without sliders.
+	SliderFactor float64
+
+	AR float64
+	OD float64
+	HP float64
+	CS float64
+
+	Circles  int
+	Sliders  int
+	Spinners int
+	MaxCombo int
+}
+
+// Difficulty calculates osu!standard star rating the way osu!lazer did from
+// its 2022 rework, which is what the score server's pp is based on.
+func (b *Beatmap) Difficulty(mods Mods) (*Attributes, error) {
+	if b.Mode != ModeStandard {
+		return nil, ErrUnsupportedMode
+	}
+
+	ar, od, hp, cs := b.AR, b.OD, b.HP, b.CS
+	if mods.HardRock {
+		cs = math.Min(cs*1.3, 10)
+		ar = math.Min(ar*1.4, 10)
+		od = math.Min(od*1.4, 10)
+		hp = math.Min(hp*1.4, 10)
+	}
+	if mods.Easy {
+		cs, ar, od, hp = cs*0.5, ar*0.5, od*0.5, hp*0.5
+	}
+
+	rate := mods.rate()
+	preempt := difficultyRange(ar, 1800, 1200, 450)
+	greatWindow := difficultyRange(od, 80, 50, 20)
+
+	counts := b.Counts()
+	attrs := &Attributes{
+		OD:       (80 - greatWindow/rate) / 6,
+		HP:       hp,
+		CS:       cs,
+		Circles:  counts.Circles,
+		Sliders:  counts.Sliders,
+		Spinners: counts.Spinners,
+		MaxCombo: b.MaxCombo(),
+	}
+	if p := preempt / rate; p > 1200 {
+		attrs.AR = (1800 - p) / 120
+	} else {
+		attrs.AR = (1200-p)/150 + 5
+	}
+
+	pf := newPlayfield(b, cs, preempt)
+	speed := &speedStrain{}
+	aim := newStrainSkill(&aimStrain{withSliders: true}, 10, 1.06)
+	aimNoSliders := newStrainSkill(&aimStrain{}, 10, 1.06)
+	speedSkill := newStrainSkill(speed, 5, 1.04)
+	for _, o := range pf.difficultyObjects(rate, 2*greatWindow/rate) {
+		aim.process(o)
+		aimNoSliders.process(o)
+		speedSkill.process(o)
+	}
+
+	aimRating := math.Sqrt(aim.difficulty()) * starMultiplier
+	aimNoSlidersRating := math.Sqrt(aimNoSliders.difficulty()) * starMultiplier
+	speedRating := math.Sqrt(speedSkill.difficulty()) * starMultiplier
+
+	attrs.SliderFactor = 1
+	if aimRating > 0 {
+		attrs.SliderFactor = aimNoSlidersRating / aimRating
+	}
+
+	basePerformance := math.Pow(
+		math.Pow(basePerformanceOf(aimRating), 1.1)+math.Pow(basePerformanceOf(speedRating), 1.1),
+		1/1.1,
+	)
+	if basePerformance > 0.00001 {
+		attrs.StarRating = math.Cbrt(performanceBaseMultiplier) * 0.027 *
+			(math.Cbrt(100000/math.Pow(2, 1/1.1)*basePerformance) + 4)
+	}
+	attrs.Aim = aimRating
+	attrs.Speed = speedRating
+	attrs.SpeedNoteCount = speed.relevantNotes()
+	return attrs, nil
+}
+
+func basePerformanceOf(rating float64) float64 {
+	return math.Pow(5*math.Max(1, rating/starMultiplier)-4, 3) / 100000
+}
+
+// difficultyRange maps a 0-10 difficulty setting onto a range of values
+// meeting at 5.
+func difficultyRange(difficulty, min, mid, max float64) float64 {
+	switch {
+	case difficulty > 5:
+		return mid + (max-mid)*(difficulty-5)/5
+	case difficulty < 5:
+		return mid - (mid-min)*(5-difficulty)/5
+	}
+	return mid
+}
+
+// playfield is the beatmap as it's laid out for a given circle size and
+// approach rate, with stacks offset.
+type playfield struct {
+	b       *Beatmap
+	scale   float64
+	radius  float64
+	stack   []int
+	sliders []*lazySlider
+}
+
+// lazySlider is the least a cursor has to move to follow a slider.
+type lazySlider struct {
+	endPosition    Vector
+	travelDistance float64
+	travelTime     float64
+}
+
+func newPlayfield(b *Beatmap, cs, preempt float64) *playfield {
+	scale := (1 - 0.7*(cs-5)/5) / 2
+	pf := &playfield{
+		b:       b,
+		scale:   scale,
+		radius:  64 * scale,
+		stack:   make([]int, len(b.HitObjects)),
+		sliders: make([]*lazySlider, len(b.HitObjects)),
+	}
+	threshold := preempt * b.StackLeniency
+	if b.FormatVersion >= 6 {
+		pf.applyStacking(threshold)
+	} else {
+		pf.applyStackingOld(threshold)
+	}
+	return pf
+}
+
+func (pf *playfield) offset(i int) Vector {
+	o := float64(pf.stack[i]) * pf.scale * -6.4
+	return Vector{o, o}
+}
+
+func (pf *playfield) position(i int) Vector {
+	return pf.b.HitObjects[i].Position.Add(pf.offset(i))
+}
+
+func (pf *playfield) applyStacking(threshold float64) {
+	objects := pf.b.HitObjects
+	for i := len(objects) - 1; i > 0; i-- {
+		if pf.stack[i] != 0 || objects[i].Kind == Spinner {
+			continue
+		}
+		top := i
+		if objects[i].Kind == Circle {
+			for n := i - 1; n >= 0; n-- {
+				if objects[n].Kind == Spinner {
+					continue
+				}
+				if objects[top].Time-objects[n].EndTime > threshold {
+					break
+				}
+				if objects[n].Kind == Slider && objects[n].endPosition().Distance(objects[top].Position) < stackDistance {
+					offset := pf.stack[top] - pf.stack[n] + 1
+					for j := n + 1; j <= i; j++ {
+						if objects[n].endPosition().Distance(objects[j].Position) < stackDistance {
+							pf.stack[j] -= offset
+						}
+					}
+					break
+				}
+				if objects[n].Position.Distance(objects[top].Position) < stackDistance {
+					pf.stack[n] = pf.stack[top] + 1
+					top = n
+				}
+			}
+		} else if objects[i].Kind == Slider {
+			for n := i - 1; n >= 0; n-- {
+				if objects[n].Kind == Spinner {
+					continue
+				}
+				if objects[top].Time-objects[n].Time > threshold {
+					break
+				}
+				if objects[n].endPosition().Distance(objects[top].Position) < stackDistance {
+					pf.stack[n] = pf.stack[top] + 1
+					top = n
+				}
+			}
+		}
+	}
+}
+
+// applyStackingOld is how maps from before format v6 were stacked.
+func (pf *playfield) applyStackingOld(threshold float64) {
+	objects := pf.b.HitObjects
+	for i := range objects {
+		curr := &objects[i]
+		if pf.stack[i] != 0 && curr.Kind != Slider {
+			continue
+		}
+		startTime := curr.EndTime
+		end := curr.Position
+		if curr.Kind == Slider {
+			end = curr.Slider.path.positionAt(1)
+		}
+		sliderStack := 0
+		for j := i + 1; j < len(objects); j++ {
+			if objects[j].Time-threshold > startTime {
+				break
+			}
+			if objects[j].Position.Distance(curr.Position) < stackDistance {
+				pf.stack[i]++
+				startTime = objects[j].EndTime
+			} else if objects[j].Position.Distance(end) < stackDistance {
+				sliderStack++
+				pf.stack[j] -= sliderStack
+				startTime = objects[j].EndTime
+			}
+		}
+	}
+}
+
+func (pf *playfield) lazySlider(i int) *lazySlider {
+	if l := pf.sliders[i]; l != nil {
+		return l
+	}
+	obj := &pf.b.HitObjects[i]
+	s := obj.Slider
+	offset := pf.offset(i)
+	nested := obj.nested()
+
+	l := &lazySlider{travelTime: nested[len(nested)-1].time - obj.Time}
+	progress := 0.0
+	if s.spanDuration > 0 {
+		progress = l.travelTime / s.spanDuration
+		if math.Mod(progress, 2) >= 1 {
+			progress = 1 - math.Mod(progress, 1)
+		} else {
+			progress = math.Mod(progress, 1)
+		}
+	}
+	l.endPosition = s.path.positionAt(progress).Add(offset)
+
+	cursor := obj.Position.Add(offset)
+	scaling := normalisedRadius / pf.radius
+	for j := 1; j < len(nested); j++ {
+		movement := nested[j].position.Add(offset).Sub(cursor)
+		required := assumedSliderRadius
+		last := j == len(nested)-1
+		if last {
+			// The cursor only has to get as far as the lazy end.
+			if lazy := l.endPosition.Sub(cursor); lazy.Length() < movement.Length() {
+				movement = lazy
+			}
+		} else if nested[j].kind == nestedRepeat {
+			required = normalisedRadius
+		}
+		length := scaling * movement.Length()
+		if length > required {
+			cursor = cursor.Add(movement.Scale((length - required) / length))
+			l.travelDistance += length - required
+		}
+		if last {
+			l.endPosition = cursor
+		}
+	}
+	l.travelDistance *= math.Pow(1+float64(s.Slides-1)/2.5, 1/2.5)
+
+	pf.sliders[i] = l
+	return l
+}
+
+// endCursorPosition is where the cursor is left after an object.
+func (pf *playfield) endCursorPosition(i int) Vector {
+	if pf.b.HitObjects[i].Kind == Slider {
+		return pf.lazySlider(i).endPosition
+	}
+	return pf.position(i)
+}
+
+// diffObject is a hit object with the movement and timing leading up to
+// it, which is what the skills rate.
+type diffObject struct {
+	obj   *HitObject
+	index int
+	all   []*diffObject
+
+	startTime      float64
+	deltaTime      float64
+	strainTime     float64
+	hitWindowGreat float64
+
+	lazyJumpDistance    float64
+	minimumJumpDistance float64
+	minimumJumpTime     float64
+	travelDistance      float64
+	travelTime          float64
+	angle               float64
+	hasAngle            bool
+}
+
+func (o *diffObject) previous(n int) *diffObject {
+	if i := o.index - n - 1; i >= 0 {
+		return o.all[i]
+	}
+	return nil
+}
+
+func (o *diffObject) next(n int) *diffObject {
+	if i := o.index + n + 1; i < len(o.all) {
+		return o.all[i]
+	}
+	return nil
+}
+
+func (pf *playfield) difficultyObjects(rate, hitWindowGreat float64) []*diffObject {
+	objects := pf.b.HitObjects
+	if len(objects) < 2 {
+		return nil
+	}
+	out := make([]*diffObject, 0, len(objects)-1)
+	for i := 1; i < len(objects); i++ {
+		o := &diffObject{
+			obj:            &objects[i],
+			index:          len(out),
+			startTime:      objects[i].Time / rate,
+			deltaTime:      (objects[i].Time - objects[i-1].Time) / rate,
+			hitWindowGreat: hitWindowGreat,
+		}
+		o.strainTime = math.Max(o.deltaTime, minDeltaTime)
+		pf.setDistances(o, i, rate)
+		out = append(out, o)
+	}
+	for _, o := range out {
+		o.all = out
+	}
+	return out
+}
+
+func (pf *playfield) setDistances(o *diffObject, i int, rate float64) {
+	objects := pf.b.HitObjects
+	curr, last := &objects[i], &objects[i-1]
+
+	if curr.Kind == Slider {
+		l := pf.lazySlider(i)
+		o.travelDistance = l.travelDistance
+		o.travelTime = math.Max(l.travelTime/rate, minDeltaTime)
+	}
+	if curr.Kind == Spinner || last.Kind == Spinner {
+		return
+	}
+
+	scaling := normalisedRadius / pf.radius
+	if pf.radius < 30 {
+		scaling *= 1 + math.Min(30-pf.radius, 5)/50
+	}
+
+	lastCursor := pf.endCursorPosition(i - 1)
+	o.lazyJumpDistance = pf.position(i).Scale(scaling).Sub(lastCursor.Scale(scaling)).Length()
+	o.minimumJumpTime = o.strainTime
+	o.minimumJumpDistance = o.lazyJumpDistance
+
+	if last.Kind == Slider {
+		lastTravelTime := math.Max(pf.lazySlider(i-1).travelTime/rate, minDeltaTime)
+		o.minimumJumpTime = math.Max(o.strainTime-lastTravelTime, minDeltaTime)
+		tail := last.endPosition().Add(pf.offset(i - 1))
+		tailJumpDistance := tail.Sub(pf.position(i)).Length() * scaling
+		o.minimumJumpDistance = math.Max(0, math.Min(
+			o.lazyJumpDistance-(maxSliderRadius-assumedSliderRadius),
+			tailJumpDistance-maxSliderRadius,
+		))
+	}
+
+	if i >= 2 && objects[i-2].Kind != Spinner {
+		lastLastCursor := pf.endCursorPosition(i - 2)
+		v1 := lastLastCursor.Sub(pf.position(i - 1))
+		v2 := pf.position(i).Sub(lastCursor)
+		dot := v1.Dot(v2)
+		det := v1.X*v2.Y - v1.Y*v2.X
+		o.angle = math.Abs(math.Atan2(det, dot))
+		o.hasAngle = true
+	}
+}
@@ -0,0 +1,411 @@
+// Package osufile reads .osu beatmap files and works out what the mirror
+// would otherwise be asked for: max combo, object counts, drain time and
+// osu!standard star rating, with or without mods.
+package osufile
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	ModeStandard = 0
+	ModeTaiko    = 1
+	ModeCatch    = 2
+	ModeMania    = 3
+)
+
+// maxFileSize bounds what Parse reads, well above any real beatmap.
+const maxFileSize = 16 << 20
+
+// Limits lazer puts on difficulty settings and sliders. Parse refuses a
+// slider with more slides than maxSlides, and clamps the rest.
+const (
+	maxSlides           = 9000
+	minSliderTickRate   = 0.5
+	maxSliderTickRate   = 8
+	minSliderMultiplier = 0.4
+	maxSliderMultiplier = 3.6
+)
+
+type Kind int
+
+const (
+	Circle Kind = iota
+	Slider
+	Spinner
+	Hold
+)
+
+type Vector struct {
+	X float64
+	Y float64
+}
+
+func (v Vector) Add(o Vector) Vector       { return Vector{v.X + o.X, v.Y + o.Y} }
+func (v Vector) Sub(o Vector) Vector       { return Vector{v.X - o.X, v.Y - o.Y} }
+func (v Vector) Scale(f float64) Vector    { return Vector{v.X * f, v.Y * f} }
+func (v Vector) Length() float64           { return math.Hypot(v.X, v.Y) }
+func (v Vector) Distance(o Vector) float64 { return v.Sub(o).Length() }
+func (v Vector) Dot(o Vector) float64      { return v.X*o.X + v.Y*o.Y }
+
+type Beatmap struct {
+	FormatVersion int
+
+	AudioFilename string
+	PreviewTime   int
+	Mode          int
+	StackLeniency float64
+
+	Title         string
+	TitleUnicode  string
+	Artist        string
+	ArtistUnicode string
+	Creator       string
+	Version       string
+	Source        string
+	Tags          string
+	BeatmapID     int
+	BeatmapSetID  int
+
+	HP               float64
+	CS               float64
+	OD               float64
+	AR               float64
+	SliderMultiplier float64
+	SliderTickRate   float64
+
+	Breaks       []Break
+	TimingPoints []TimingPoint
+	HitObjects   []HitObject
+}
+
+// Break is a break period, in milliseconds.
+type Break struct {
+	Start float64
+	End   float64
+}
+
+type TimingPoint struct {
+	Time float64
+	// BeatLength is in milliseconds on uninherited points. Inherited ones
+	// hold a negative slider velocity percentage instead.
+	BeatLength  float64
+	Meter       int
+	Uninherited bool
+	Kiai        bool
+}
+
+type HitObject struct {
+	Position Vector
+	Time     float64
+	// EndTime is when a slider, spinner or hold ends, and Time otherwise.
+	EndTime  float64
+	Kind     Kind
+	NewCombo bool
+	// Slider is set for sliders only.
+	Slider *SliderData
+}
+
+type SliderData struct {
+	CurveType     byte
+	ControlPoints []Vector
+	Slides        int
+	Length        float64
+
+	path         *path
+	spanDuration float64
+	velocity     float64
+	tickDistance float64
+}
+
+// Parse reads a .osu file. Only what's needed for stats and difficulty is
+// kept; storyboards, colours and hit sounds are skipped.
+func Parse(r io.Reader) (*Beatmap, error) {
+	b := &Beatmap{
+		StackLeniency:    0.7,
+		HP:               5,
+		CS:               5,
+		OD:               5,
+		AR:               -1,
+		SliderMultiplier: 1.4,
+		SliderTickRate:   1,
+	}
+
+	scanner := bufio.NewScanner(io.LimitReader(r, maxFileSize))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	section := ""
+	first := true
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+			first = false
+			if !strings.HasPrefix(line, "osu file format v") {
+				return nil, errors.New("osufile: not a .osu file")
+			}
+			v, err := strconv.Atoi(strings.TrimPrefix(line, "osu file format v"))
+			if err != nil {
+				return nil, fmt.Errorf("osufile: bad format version %q", line)
+			}
+			b.FormatVersion = v
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = line[1 : len(line)-1]
+			continue
+		}
+
+		var err error
+		switch section {
+		case "General", "Metadata", "Difficulty":
+			key, value, ok := strings.Cut(line, ":")
+			if ok {
+				err = b.setValue(section, strings.TrimSpace(key), strings.TrimSpace(value))
+			}
+		case "Events":
+			b.parseEvent(line)
+		case "TimingPoints":
+			err = b.parseTimingPoint(line)
+		case "HitObjects":
+			err = b.parseHitObject(line)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if first {
+		return nil, errors.New("osufile: empty file")
+	}
+
+	// Maps from before AR was split from OD use OD for both.
+	if b.AR < 0 {
+		b.AR = b.OD
+	}
+	b.prepareSliders()
+	return b, nil
+}
+
+type badHitObject string
+
+func (e badHitObject) Error() string {
+	return fmt.Sprintf("osufile: bad hit object %q", string(e))
+}
+
+func (b *Beatmap) setValue(section, key, value string) error {
+	var err error
+	switch section + "." + key {
+	case "General.AudioFilename":
+		b.AudioFilename = value
+	case "General.PreviewTime":
+		b.PreviewTime, err = strconv.Atoi(value)
+	case "General.Mode":
+		b.Mode, err = strconv.Atoi(value)
+	case "General.StackLeniency":
+		b.StackLeniency, err = parseFloat(value)
+	case "Metadata.Title":
+		b.Title = value
+	case "Metadata.TitleUnicode":
+		b.TitleUnicode = value
+	case "Metadata.Artist":
+		b.Artist = value
+	case "Metadata.ArtistUnicode":
+		b.ArtistUnicode = value
+	case "Metadata.Creator":
+		b.Creator = value
+	case "Metadata.Version":
+		b.Version = value
+	case "Metadata.Source":
+		b.Source = value
+	case "Metadata.Tags":
+		b.Tags = value
+	case "Metadata.BeatmapID":
+		b.BeatmapID, err = strconv.Atoi(value)
+	case "Metadata.BeatmapSetID":
+		b.BeatmapSetID, err = strconv.Atoi(value)
+	case "Difficulty.HPDrainRate":
+		b.HP, err = parseFloat(value)
+	case "Difficulty.CircleSize":
+		b.CS, err = parseFloat(value)
+	case "Difficulty.OverallDifficulty":
+		b.OD, err = parseFloat(value)
+	case "Difficulty.ApproachRate":
+		b.AR, err = parseFloat(value)
+	case "Difficulty.SliderMultiplier":
+		b.SliderMultiplier, err = parsePositive(value)
+		b.SliderMultiplier = math.Max(minSliderMultiplier, math.Min(maxSliderMultiplier, b.SliderMultiplier))
+	case "Difficulty.SliderTickRate":
+		b.SliderTickRate, err = parsePositive(value)
+		b.SliderTickRate = math.Max(minSliderTickRate, math.Min(maxSliderTickRate, b.SliderTickRate))
+	}
+	if err != nil {
+		return fmt.Errorf("osufile: bad %s value %q", key, value)
+	}
+	return nil
+}
+
+func (b *Beatmap) parseEvent(line string) {
+	fields := strings.Split(line, ",")
+	if len(fields) < 3 || (fields[0] != "2" && fields[0] != "Break") {
+		return
+	}
+	start, err1 := parseFloat(fields[1])
+	end, err2 := parseFloat(fields[2])
+	if err1 == nil && err2 == nil && end > start {
+		b.Breaks = append(b.Breaks, Break{Start: start, End: end})
+	}
+}
+
+func (b *Beatmap) parseTimingPoint(line string) error {
+	fields := strings.Split(line, ",")
+	if len(fields) < 2 {
+		return fmt.Errorf("osufile: bad timing point %q", line)
+	}
+	time, err := parseFloat(fields[0])
+	if err != nil {
+		return fmt.Errorf("osufile: bad timing point %q", line)
+	}
+	beatLength, err := parseFloat(fields[1])
+	if err != nil {
+		return fmt.Errorf("osufile: bad timing point %q", line)
+	}
+
+	tp := TimingPoint{Time: time, BeatLength: beatLength, Meter: 4, Uninherited: beatLength > 0}
+	if len(fields) > 2 {
+		if meter, err := strconv.Atoi(fields[2]); err == nil && meter > 0 {
+			tp.Meter = meter
+		}
+	}
+	if len(fields) > 6 {
+		tp.Uninherited = fields[6] == "1"
+	}
+	if len(fields) > 7 {
+		if effects, err := strconv.Atoi(fields[7]); err == nil {
+			tp.Kiai = effects&1 != 0
+		}
+	}
+	b.TimingPoints = append(b.TimingPoints, tp)
+	return nil
+}
+
+func (b *Beatmap) parseHitObject(line string) error {
+	fields := strings.Split(line, ",")
+	if len(fields) < 4 {
+		return badHitObject(line)
+	}
+	x, err1 := parseFloat(fields[0])
+	y, err2 := parseFloat(fields[1])
+	time, err3 := parseFloat(fields[2])
+	objectType, err4 := strconv.Atoi(fields[3])
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+		return badHitObject(line)
+	}
+
+	obj := HitObject{
+		Position: Vector{x, y},
+		Time:     time,
+		EndTime:  time,
+		NewCombo: objectType&4 != 0,
+	}
+	switch {
+	case objectType&1 != 0:
+		obj.Kind = Circle
+	case objectType&2 != 0:
+		obj.Kind = Slider
+		if len(fields) < 8 {
+			return badHitObject(line)
+		}
+		slider, err := parseSlider(obj.Position, fields[5], fields[6], fields[7])
+		if err != nil {
+			return badHitObject(line)
+		}
+		obj.Slider = slider
+	case objectType&8 != 0:
+		obj.Kind = Spinner
+		if len(fields) < 6 {
+			return badHitObject(line)
+		}
+		end, err := parseFloat(fields[5])
+		if err != nil {
+			return badHitObject(line)
+		}
+		obj.EndTime = math.Max(end, time)
+	case objectType&128 != 0:
+		obj.Kind = Hold
+		if len(fields) < 6 {
+			return badHitObject(line)
+		}
+		end, _, _ := strings.Cut(fields[5], ":")
+		endTime, err := parseFloat(end)
+		if err != nil {
+			return badHitObject(line)
+		}
+		obj.EndTime = math.Max(endTime, time)
+	default:
+		return badHitObject(line)
+	}
+	b.HitObjects = append(b.HitObjects, obj)
+	return nil
+}
+
+func parseSlider(start Vector, curve, slides, length string) (*SliderData, error) {
+	points := strings.Split(curve, "|")
+	s := &SliderData{CurveType: 'B', ControlPoints: []Vector{start}}
+	if len(points[0]) == 1 {
+		s.CurveType = points[0][0]
+		points = points[1:]
+	}
+	for _, p := range points {
+		xs, ys, ok := strings.Cut(p, ":")
+		if !ok {
+			return nil, errors.New("bad control point")
+		}
+		x, err1 := parseFloat(xs)
+		y, err2 := parseFloat(ys)
+		if err1 != nil || err2 != nil {
+			return nil, errors.New("bad control point")
+		}
+		s.ControlPoints = append(s.ControlPoints, Vector{x, y})
+	}
+
+	n, err := strconv.Atoi(slides)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxSlides {
+		return nil, errors.New("too many slides")
+	}
+	s.Slides = max(n, 1)
+	if s.Length, err = parseFloat(length); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func parseFloat(s string) (float64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, errors.New("bad number")
+	}
+	return f, nil
+}
+
+func parsePositive(s string) (float64, error) {
+	f, err := parseFloat(s)
+	if err != nil || f <= 0 {
+		return 0, errors.New("bad number")
+	}
+	return f, nil
+}
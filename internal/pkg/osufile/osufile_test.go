@@ -0,0 +1,151 @@
+package osufile
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func parseFixture(t *testing.T, name string) *Beatmap {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b, err := Parse(f)
+	if err != nil {
+		t.Fatalf("parsing %s: %v", name, err)
+	}
+	return b
+}
+
+// The fixtures are small enough to score by hand: a slider is its head,
+// tail, repeats and ticks, and drain time leaves out breaks.
+func TestFixtures(t *testing.T) {
+	tests := []struct {
+		file     string
+		counts   Counts
+		maxCombo int
+		length   float64
+		drain    float64
+	}{
+		// Three circles, a 3.6s break, then five more.
+		{"circles.osu", Counts{Circles: 8}, 8, 7, 3.4},
+		// A 280px slider going back once at tick rate 2 is 3 ticks a
+		// span plus its head, repeat and tail. The last slider is at
+		// twice the speed, so its tick distance covers the whole slider.
+		{"sliders.osu", Counts{Circles: 1, Sliders: 2, Spinners: 1}, 9 + 1 + 1 + 2, 5.75, 5.75},
+		{"mania.osu", Counts{Circles: 3, Holds: 1}, 4, 1.5, 1.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			b := parseFixture(t, tt.file)
+			if got := b.Counts(); got != tt.counts {
+				t.Errorf("Counts() = %+v, want %+v", got, tt.counts)
+			}
+			if got := b.MaxCombo(); got != tt.maxCombo {
+				t.Errorf("MaxCombo() = %d, want %d", got, tt.maxCombo)
+			}
+			if got := b.Length(); math.Abs(got-tt.length) > 0.001 {
+				t.Errorf("Length() = %v, want %v", got, tt.length)
+			}
+			if got := b.DrainTime(); math.Abs(got-tt.drain) > 0.001 {
+				t.Errorf("DrainTime() = %v, want %v", got, tt.drain)
+			}
+		})
+	}
+}
+
+func TestDifficultyMods(t *testing.T) {
+	for _, file := range []string{"circles.osu", "sliders.osu"} {
+		t.Run(file, func(t *testing.T) {
+			b := parseFixture(t, file)
+			stars := func(mods Mods) float64 {
+				attrs, err := b.Difficulty(mods)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return attrs.StarRating
+			}
+
+			nomod := stars(Mods{})
+			if nomod <= 0 {
+				t.Fatalf("star rating = %v, want above 0", nomod)
+			}
+			if dt := stars(Mods{SpeedChange: DoubleTimeSpeed}); dt <= nomod {
+				t.Errorf("DT star rating %v isn't above nomod's %v", dt, nomod)
+			}
+			if ht := stars(Mods{SpeedChange: HalfTimeSpeed}); ht >= nomod {
+				t.Errorf("HT star rating %v isn't below nomod's %v", ht, nomod)
+			}
+			if ez := stars(Mods{Easy: true}); ez > nomod {
+				t.Errorf("EZ star rating %v is above nomod's %v", ez, nomod)
+			}
+			if again := stars(Mods{}); again != nomod {
+				t.Errorf("star rating changed from %v to %v between runs", nomod, again)
+			}
+		})
+	}
+}
+
+func TestDifficultyUnsupportedMode(t *testing.T) {
+	b := parseFixture(t, "mania.osu")
+	if _, err := b.Difficulty(Mods{}); !errors.Is(err, ErrUnsupportedMode) {
+		t.Errorf("Difficulty() error = %v, want ErrUnsupportedMode", err)
+	}
+}
+
+const header = "osu file format v14\n\n[General]\nMode: 0\n\n"
+
+func TestParseClampsSliderTickRate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+	}{
+		{"100", maxSliderTickRate},
+		{"0.1", minSliderTickRate},
+		{"2", 2},
+	}
+	for _, tt := range tests {
+		b, err := Parse(strings.NewReader(header + "[Difficulty]\nSliderTickRate:" + tt.value + "\n"))
+		if err != nil {
+			t.Fatalf("SliderTickRate:%s: %v", tt.value, err)
+		}
+		if b.SliderTickRate != tt.want {
+			t.Errorf("SliderTickRate:%s parsed as %v, want %v", tt.value, b.SliderTickRate, tt.want)
+		}
+	}
+}
+
+func TestParseRejectsBadSliders(t *testing.T) {
+	tests := []string{
+		"[Difficulty]\nSliderTickRate:0\n",
+		"[Difficulty]\nSliderMultiplier:-1\n",
+		"[HitObjects]\n100,192,1000,2,0,L|380:192,9001,280\n",
+	}
+	for _, body := range tests {
+		if _, err := Parse(strings.NewReader(header + body)); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", body)
+		}
+	}
+}
+
+func TestSliderTicksCapped(t *testing.T) {
+	// At the slowest slider speed and the highest tick rate a tick is half
+	// a pixel apart, so each span of this slider would have nearly 1000.
+	b, err := Parse(strings.NewReader(header +
+		"[Difficulty]\nSliderMultiplier:0.4\nSliderTickRate:8\n\n" +
+		"[TimingPoints]\n0,500,4,2,0,100,1,0\n0,-1000,4,2,0,100,0,0\n\n" +
+		"[HitObjects]\n0,192,1000,2,0,L|500:192,100,500\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The head, the capped ticks, 99 repeats and the tail.
+	if got, want := b.MaxCombo(), 1+maxSliderTicks+99+1; got != want {
+		t.Errorf("MaxCombo() = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,261 @@
+package osufile
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	bezierTolerance = 0.25
+	circleTolerance = 0.1
+	catmullDetail   = 50
+	maxCirclePoints = 1000
+)
+
+// path is a slider's curve as line segments, trimmed or extended to the
+// length given in the file the same way osu! does.
+type path struct {
+	points     []Vector
+	cumulative []float64
+}
+
+func newPath(curveType byte, controls []Vector, expected float64) *path {
+	p := &path{}
+
+	// Two equal control points in a row start a new segment.
+	start := 0
+	for i := 1; i <= len(controls); i++ {
+		if i < len(controls) && controls[i] != controls[i-1] {
+			continue
+		}
+		for _, v := range approximate(curveType, controls[start:i]) {
+			if len(p.points) == 0 || p.points[len(p.points)-1] != v {
+				p.points = append(p.points, v)
+			}
+		}
+		start = i
+	}
+
+	p.measure(controls, expected)
+	return p
+}
+
+func approximate(curveType byte, points []Vector) []Vector {
+	if len(points) < 2 {
+		return points
+	}
+	switch curveType {
+	case 'L':
+		return points
+	case 'P':
+		if len(points) == 3 {
+			if arc, ok := approximateCircle(points); ok {
+				return arc
+			}
+		}
+	case 'C':
+		return approximateCatmull(points)
+	}
+	return approximateBezier(points)
+}
+
+func (p *path) measure(controls []Vector, expected float64) {
+	total := 0.0
+	p.cumulative = []float64{0}
+	for i := 1; i < len(p.points); i++ {
+		total += p.points[i].Distance(p.points[i-1])
+		p.cumulative = append(p.cumulative, total)
+	}
+	if expected <= 0 || total == expected {
+		return
+	}
+	// osu!stable doesn't extend a slider whose last two control points are
+	// the same.
+	n := len(controls)
+	if n >= 2 && controls[n-1] == controls[n-2] && expected > total {
+		return
+	}
+
+	p.cumulative = p.cumulative[:len(p.cumulative)-1]
+	end := len(p.points) - 1
+	if total > expected {
+		for len(p.cumulative) > 0 && p.cumulative[len(p.cumulative)-1] >= expected {
+			p.cumulative = p.cumulative[:len(p.cumulative)-1]
+			p.points = p.points[:end]
+			end--
+		}
+	}
+	if end <= 0 {
+		p.cumulative = append(p.cumulative, 0)
+		return
+	}
+
+	dir := p.points[end].Sub(p.points[end-1])
+	if l := dir.Length(); l > 0 {
+		dir = dir.Scale(1 / l)
+	}
+	p.points[end] = p.points[end-1].Add(dir.Scale(expected - p.cumulative[len(p.cumulative)-1]))
+	p.cumulative = append(p.cumulative, expected)
+}
+
+func (p *path) distance() float64 {
+	if len(p.cumulative) == 0 {
+		return 0
+	}
+	return p.cumulative[len(p.cumulative)-1]
+}
+
+// positionAt is the position the given fraction of the way along the path.
+func (p *path) positionAt(progress float64) Vector {
+	if len(p.points) == 0 {
+		return Vector{}
+	}
+	d := math.Max(0, math.Min(1, progress)) * p.distance()
+	i := sort.SearchFloat64s(p.cumulative, d)
+	if i <= 0 {
+		return p.points[0]
+	}
+	if i >= len(p.points) {
+		return p.points[len(p.points)-1]
+	}
+	p0, p1 := p.points[i-1], p.points[i]
+	d0, d1 := p.cumulative[i-1], p.cumulative[i]
+	if math.Abs(d1-d0) < 1e-3 {
+		return p0
+	}
+	return p0.Add(p1.Sub(p0).Scale((d - d0) / (d1 - d0)))
+}
+
+func approximateBezier(controls []Vector) []Vector {
+	n := len(controls)
+	var out []Vector
+	stack := [][]Vector{append([]Vector(nil), controls...)}
+	left := make([]Vector, n*2-1)
+	right := make([]Vector, n)
+	mid := make([]Vector, n)
+
+	for len(stack) > 0 {
+		parent := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if bezierFlat(parent) {
+			// Flat enough: emit the smoothed points of one more
+			// subdivision.
+			subdivide(parent, left, right, mid)
+			for i := 0; i < n-1; i++ {
+				left[n+i] = right[i+1]
+			}
+			out = append(out, parent[0])
+			for i := 1; i < n-1; i++ {
+				j := 2 * i
+				out = append(out, left[j-1].Add(left[j].Scale(2)).Add(left[j+1]).Scale(0.25))
+			}
+			continue
+		}
+
+		subdivide(parent, left, right, mid)
+		stack = append(stack, append([]Vector(nil), right...), append([]Vector(nil), left[:n]...))
+	}
+	return append(out, controls[n-1])
+}
+
+func bezierFlat(points []Vector) bool {
+	for i := 1; i < len(points)-1; i++ {
+		d := points[i-1].Sub(points[i].Scale(2)).Add(points[i+1])
+		if d.Dot(d) > bezierTolerance*bezierTolerance*4 {
+			return false
+		}
+	}
+	return true
+}
+
+// subdivide splits a Bézier curve in half with de Casteljau's algorithm.
+func subdivide(points, left, right, mid []Vector) {
+	n := len(points)
+	copy(mid, points)
+	for i := 0; i < n; i++ {
+		left[i] = mid[0]
+		right[n-i-1] = mid[n-i-1]
+		for j := 0; j < n-i-1; j++ {
+			mid[j] = mid[j].Add(mid[j+1]).Scale(0.5)
+		}
+	}
+}
+
+// approximateCircle returns false for three points on a line, which are
+// drawn as a Bézier curve instead.
+func approximateCircle(points []Vector) ([]Vector, bool) {
+	a, b, c := points[0], points[1], points[2]
+	if math.Abs((b.Y-a.Y)*(c.X-a.X)-(b.X-a.X)*(c.Y-a.Y)) < 1e-3 {
+		return nil, false
+	}
+
+	d := 2 * (a.X*(b.Y-c.Y) + b.X*(c.Y-a.Y) + c.X*(a.Y-b.Y))
+	aSq, bSq, cSq := a.Dot(a), b.Dot(b), c.Dot(c)
+	centre := Vector{
+		(aSq*(b.Y-c.Y) + bSq*(c.Y-a.Y) + cSq*(a.Y-b.Y)) / d,
+		(aSq*(c.X-b.X) + bSq*(a.X-c.X) + cSq*(b.X-a.X)) / d,
+	}
+
+	dA, dC := a.Sub(centre), c.Sub(centre)
+	radius := dA.Length()
+	thetaStart := math.Atan2(dA.Y, dA.X)
+	thetaEnd := math.Atan2(dC.Y, dC.X)
+	for thetaEnd < thetaStart {
+		thetaEnd += 2 * math.Pi
+	}
+	dir := 1.0
+	thetaRange := thetaEnd - thetaStart
+	ortho := Vector{c.Y - a.Y, -(c.X - a.X)}
+	if ortho.Dot(b.Sub(a)) < 0 {
+		dir = -1
+		thetaRange = 2*math.Pi - thetaRange
+	}
+
+	count := 2
+	if 2*radius > circleTolerance {
+		count = max(2, int(math.Ceil(thetaRange/(2*math.Acos(1-circleTolerance/radius)))))
+	}
+	count = min(count, maxCirclePoints)
+
+	out := make([]Vector, count)
+	for i := range out {
+		theta := thetaStart + dir*float64(i)/float64(count-1)*thetaRange
+		out[i] = centre.Add(Vector{math.Cos(theta), math.Sin(theta)}.Scale(radius))
+	}
+	return out, true
+}
+
+func approximateCatmull(points []Vector) []Vector {
+	out := make([]Vector, 0, (len(points)-1)*catmullDetail*2)
+	for i := 0; i < len(points)-1; i++ {
+		v1 := points[i]
+		if i > 0 {
+			v1 = points[i-1]
+		}
+		v2 := points[i]
+		v3 := v2.Scale(2).Sub(v1)
+		if i < len(points)-1 {
+			v3 = points[i+1]
+		}
+		v4 := v3.Scale(2).Sub(v2)
+		if i < len(points)-2 {
+			v4 = points[i+2]
+		}
+		for c := 0; c < catmullDetail; c++ {
+			out = append(out,
+				catmullPoint(v1, v2, v3, v4, float64(c)/catmullDetail),
+				catmullPoint(v1, v2, v3, v4, float64(c+1)/catmullDetail))
+		}
+	}
+	return out
+}
+
+func catmullPoint(v1, v2, v3, v4 Vector, t float64) Vector {
+	t2 := t * t
+	t3 := t * t2
+	f := func(a, b, c, d float64) float64 {
+		return 0.5 * (2*b + (-a+c)*t + (2*a-5*b+4*c-d)*t2 + (-a+3*b-3*c+d)*t3)
+	}
+	return Vector{f(v1.X, v2.X, v3.X, v4.X), f(v1.Y, v2.Y, v3.Y, v4.Y)}
+}
@@ -0,0 +1,197 @@
+package osufile
+
+import "math"
+
+// Score is the part of a play that performance depends on. Combo is the
+// play's max combo.
+type Score struct {
+	Count300 int
+	Count100 int
+	Count50  int
+	Misses   int
+	Combo    int
+}
+
+func (s Score) hits() int {
+	return s.Count300 + s.Count100 + s.Count50 + s.Misses
+}
+
+// Accuracy is the score's accuracy from 0 to 1.
+func (s Score) Accuracy() float64 {
+	if s.hits() == 0 {
+		return 0
+	}
+	return float64(6*s.Count300+2*s.Count100+s.Count50) / float64(6*s.hits())
+}
+
+// EstimateScore makes up hit counts near the given accuracy, from 0 to 1,
+// for a beatmap with the given number of objects. 100s are used before 50s.
+func EstimateScore(objects int, accuracy float64, misses int) Score {
+	misses = max(0, min(misses, objects))
+	accuracy = clamp(accuracy, 0, 1)
+	s := Score{Misses: misses}
+
+	s.Count100 = int(math.Round(-3 * ((accuracy-1)*float64(objects) + float64(misses)) * 0.5))
+	if s.Count100 > objects-misses {
+		s.Count100 = 0
+		s.Count50 = int(math.Round(-6 * ((accuracy-1)*float64(objects) + float64(misses)) * 0.5))
+		s.Count50 = min(s.Count50, objects-misses)
+	}
+	s.Count100 = max(0, s.Count100)
+	s.Count50 = max(0, s.Count50)
+	s.Count300 = objects - s.Count100 - s.Count50 - misses
+	return s
+}
+
+type Performance struct {
+	PP       float64
+	Aim      float64
+	Speed    float64
+	Accuracy float64
+	// EffectiveMisses counts combo breaks as misses.
+	EffectiveMisses float64
+}
+
+// Performance is the pp a score is worth on a beatmap with these attributes,
+// which must have been calculated with the same mods.
+func (a *Attributes) Performance(mods Mods, s Score) Performance {
+	hits := float64(s.hits())
+	if hits == 0 {
+		return Performance{}
+	}
+	p := Performance{EffectiveMisses: a.effectiveMisses(s)}
+
+	multiplier := performanceBaseMultiplier
+	if mods.NoFail {
+		multiplier *= math.Max(0.9, 1-0.02*p.EffectiveMisses)
+	}
+	if mods.SpunOut {
+		multiplier *= 1 - math.Pow(float64(a.Spinners)/hits, 0.85)
+	}
+
+	p.Aim = a.aimValue(mods, s, p.EffectiveMisses)
+	p.Speed = a.speedValue(mods, s, p.EffectiveMisses)
+	p.Accuracy = a.accuracyValue(mods, s)
+	p.PP = math.Pow(
+		math.Pow(p.Aim, 1.1)+math.Pow(p.Speed, 1.1)+math.Pow(p.Accuracy, 1.1),
+		1/1.1,
+	) * multiplier
+	return p
+}
+
+// effectiveMisses guesses how many times combo was broken, as a slider
+// break isn't counted as a miss.
+func (a *Attributes) effectiveMisses(s Score) float64 {
+	comboMisses := 0.0
+	if a.Sliders > 0 {
+		threshold := float64(a.MaxCombo) - 0.1*float64(a.Sliders)
+		if float64(s.Combo) < threshold {
+			comboMisses = threshold / math.Max(1, float64(s.Combo))
+		}
+	}
+	comboMisses = math.Min(comboMisses, float64(s.Count100+s.Count50+s.Misses))
+	return math.Max(float64(s.Misses), comboMisses)
+}
+
+func (a *Attributes) lengthBonus(hits float64) float64 {
+	bonus := 0.95 + 0.4*math.Min(1, hits/2000)
+	if hits > 2000 {
+		bonus += math.Log10(hits/2000) * 0.5
+	}
+	return bonus
+}
+
+func (a *Attributes) comboScaling(s Score) float64 {
+	if a.MaxCombo <= 0 {
+		return 1
+	}
+	return math.Min(math.Pow(float64(s.Combo), 0.8)/math.Pow(float64(a.MaxCombo), 0.8), 1)
+}
+
+func (a *Attributes) aimValue(mods Mods, s Score, misses float64) float64 {
+	hits := float64(s.hits())
+	value := basePerformanceOf(a.Aim)
+
+	lengthBonus := a.lengthBonus(hits)
+	value *= lengthBonus
+	if misses > 0 {
+		value *= 0.97 * math.Pow(1-math.Pow(misses/hits, 0.775), misses)
+	}
+	value *= a.comboScaling(s)
+
+	arFactor := 0.0
+	if a.AR > 10.33 {
+		arFactor = 0.3 * (a.AR - 10.33)
+	} else if a.AR < 8 {
+		arFactor = 0.05 * (8 - a.AR)
+	}
+	value *= 1 + arFactor*lengthBonus
+
+	if mods.Hidden {
+		value *= 1 + 0.04*(12-a.AR)
+	}
+
+	// Assume 15% of sliders are hard enough that their ends get dropped.
+	if a.Sliders > 0 {
+		difficultSliders := float64(a.Sliders) * 0.15
+		dropped := clamp(math.Min(float64(s.Count100+s.Count50+s.Misses), float64(a.MaxCombo-s.Combo)), 0, difficultSliders)
+		value *= (1-a.SliderFactor)*math.Pow(1-dropped/difficultSliders, 3) + a.SliderFactor
+	}
+
+	value *= s.Accuracy()
+	value *= 0.98 + a.OD*a.OD/2500
+	return value
+}
+
+func (a *Attributes) speedValue(mods Mods, s Score, misses float64) float64 {
+	hits := float64(s.hits())
+	value := basePerformanceOf(a.Speed)
+
+	lengthBonus := a.lengthBonus(hits)
+	value *= lengthBonus
+	if misses > 0 {
+		value *= 0.97 * math.Pow(1-math.Pow(misses/hits, 0.775), math.Pow(misses, 0.875))
+	}
+	value *= a.comboScaling(s)
+
+	if a.AR > 10.33 {
+		value *= 1 + 0.3*(a.AR-10.33)*lengthBonus
+	}
+	if mods.Hidden {
+		value *= 1 + 0.04*(12-a.AR)
+	}
+
+	// Only the accuracy on the notes that are hard to tap counts fully.
+	relevantAccuracy := 0.0
+	if a.SpeedNoteCount > 0 {
+		diff := hits - a.SpeedNoteCount
+		c300 := math.Max(0, float64(s.Count300)-diff)
+		c100 := math.Max(0, float64(s.Count100)-math.Max(0, diff-float64(s.Count300)))
+		c50 := math.Max(0, float64(s.Count50)-math.Max(0, diff-float64(s.Count300)-float64(s.Count100)))
+		relevantAccuracy = (c300*6 + c100*2 + c50) / (a.SpeedNoteCount * 6)
+	}
+	value *= (0.95 + a.OD*a.OD/750) * math.Pow((s.Accuracy()+relevantAccuracy)/2, (14.5-math.Max(a.OD, 8))/2)
+
+	if c50 := float64(s.Count50); c50 >= hits/500 {
+		value *= math.Pow(0.99, c50-hits/500)
+	}
+	return value
+}
+
+func (a *Attributes) accuracyValue(mods Mods, s Score) float64 {
+	// Only circles are judged on timing; slider heads and spinners are
+	// assumed to be 300s.
+	circles := float64(a.Circles)
+	if circles == 0 {
+		return 0
+	}
+	better := (float64(s.Count300-(s.hits()-a.Circles))*6 + float64(s.Count100)*2 + float64(s.Count50)) / (circles * 6)
+	better = math.Max(0, better)
+
+	value := math.Pow(1.52163, a.OD) * math.Pow(better, 24) * 2.83
+	value *= math.Min(1.15, math.Pow(circles/1000, 0.3))
+	if mods.Hidden {
+		value *= 1.08
+	}
+	return value
+}
@@ -0,0 +1,78 @@
+package osufile
+
+import (
+	"math"
+	"testing"
+)
+
+func TestScoreAccuracy(t *testing.T) {
+	tests := []struct {
+		score Score
+		want  float64
+	}{
+		{Score{}, 0},
+		{Score{Count300: 10}, 1},
+		{Score{Count300: 1, Count100: 1, Count50: 1, Misses: 1}, 9.0 / 24},
+		{Score{Count100: 3}, 1.0 / 3},
+	}
+	for _, tt := range tests {
+		if got := tt.score.Accuracy(); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%+v.Accuracy() = %v, want %v", tt.score, got, tt.want)
+		}
+	}
+}
+
+func TestEstimateScore(t *testing.T) {
+	tests := []struct {
+		objects  int
+		accuracy float64
+		misses   int
+		want     Score
+	}{
+		{100, 1, 0, Score{Count300: 100}},
+		{100, 1, 200, Score{Misses: 100}},
+		// 98% on 100 objects is three 100s: (97*6 + 3*2) / 600 = 0.98.
+		{100, 0.98, 0, Score{Count300: 97, Count100: 3}},
+		// Too low for 100s alone, so 50s are used.
+		{10, 0.2, 0, Score{Count300: 0, Count50: 10}},
+	}
+	for _, tt := range tests {
+		if got := EstimateScore(tt.objects, tt.accuracy, tt.misses); got != tt.want {
+			t.Errorf("EstimateScore(%d, %v, %d) = %+v, want %+v", tt.objects, tt.accuracy, tt.misses, got, tt.want)
+		}
+	}
+}
+
+func TestPerformance(t *testing.T) {
+	b := parseFixture(t, "sliders.osu")
+	attrs, err := b.Difficulty(Mods{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects := attrs.Circles + attrs.Sliders + attrs.Spinners
+	fc := EstimateScore(objects, 1, 0)
+	fc.Combo = attrs.MaxCombo
+
+	pp := func(mods Mods, s Score) float64 {
+		return attrs.Performance(mods, s).PP
+	}
+	best := pp(Mods{}, fc)
+	if best <= 0 {
+		t.Fatalf("full combo pp = %v, want above 0", best)
+	}
+
+	missed := EstimateScore(objects, 0.9, 1)
+	missed.Combo = attrs.MaxCombo / 2
+	if got := pp(Mods{}, missed); got >= best {
+		t.Errorf("pp with a miss %v isn't below the full combo's %v", got, best)
+	}
+	if got := pp(Mods{Hidden: true}, fc); got <= best {
+		t.Errorf("HD pp %v isn't above nomod's %v", got, best)
+	}
+	if got := pp(Mods{NoFail: true}, missed); got >= pp(Mods{}, missed) {
+		t.Errorf("NF pp %v isn't below nomod's with the same misses", got)
+	}
+	if got := pp(Mods{}, Score{}); got != 0 {
+		t.Errorf("pp with no hits = %v, want 0", got)
+	}
+}
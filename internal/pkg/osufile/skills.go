@@ -0,0 +1,326 @@
+package osufile
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	sectionLength    = 400
+	decayWeight      = 0.9
+	reducedBaseline  = 0.75
+	aimMultiplier    = 23.55
+	aimDecayBase     = 0.15
+	speedMultiplier  = 1375
+	speedDecayBase   = 0.3
+	wideAngleBonus   = 1.5
+	acuteAngleBonus  = 1.95
+	sliderBonus      = 1.35
+	velocityChange   = 0.75
+	singleSpacing    = 125
+	minSpeedBonus    = 75
+	speedBalancing   = 40
+	historyTimeMax   = 5000
+	historyObjectMax = 32
+	rhythmMultiplier = 0.75
+)
+
+// strainer is a skill's strain at each object, and what it has decayed to
+// by the start of a section.
+type strainer interface {
+	strainAt(o *diffObject) float64
+	initialStrain(time float64, o *diffObject) float64
+}
+
+// strainSkill keeps the peak strain of each 400ms section and weighs them
+// into a difficulty value, hardest first.
+type strainSkill struct {
+	strainer
+	reducedSections      int
+	difficultyMultiplier float64
+
+	sectionEnd  float64
+	sectionPeak float64
+	peaks       []float64
+}
+
+func newStrainSkill(s strainer, reducedSections int, difficultyMultiplier float64) *strainSkill {
+	return &strainSkill{strainer: s, reducedSections: reducedSections, difficultyMultiplier: difficultyMultiplier}
+}
+
+func (s *strainSkill) process(o *diffObject) {
+	if o.index == 0 {
+		s.sectionEnd = math.Ceil(o.startTime/sectionLength) * sectionLength
+	}
+	for o.startTime > s.sectionEnd {
+		s.peaks = append(s.peaks, s.sectionPeak)
+		s.sectionPeak = s.initialStrain(s.sectionEnd, o)
+		s.sectionEnd += sectionLength
+	}
+	s.sectionPeak = math.Max(s.strainAt(o), s.sectionPeak)
+}
+
+func (s *strainSkill) difficulty() float64 {
+	var strains []float64
+	for _, p := range append(s.peaks, s.sectionPeak) {
+		if p > 0 {
+			strains = append(strains, p)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(strains)))
+
+	// The hardest few sections are toned down so a single spike doesn't
+	// carry the whole map.
+	for i := 0; i < min(len(strains), s.reducedSections); i++ {
+		scale := math.Log10(lerp(1, 10, float64(i)/float64(s.reducedSections)))
+		strains[i] *= lerp(reducedBaseline, 1, scale)
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(strains)))
+
+	difficulty, weight := 0.0, 1.0
+	for _, strain := range strains {
+		difficulty += strain * weight
+		weight *= decayWeight
+	}
+	return difficulty * s.difficultyMultiplier
+}
+
+func strainDecay(base, ms float64) float64 {
+	return math.Pow(base, ms/1000)
+}
+
+func lerp(a, b, t float64) float64 {
+	return a + (b-a)*t
+}
+
+type aimStrain struct {
+	withSliders bool
+	strain      float64
+}
+
+func (a *aimStrain) strainAt(o *diffObject) float64 {
+	a.strain *= strainDecay(aimDecayBase, o.deltaTime)
+	a.strain += evaluateAim(o, a.withSliders) * aimMultiplier
+	return a.strain
+}
+
+func (a *aimStrain) initialStrain(time float64, o *diffObject) float64 {
+	return a.strain * strainDecay(aimDecayBase, time-o.previous(0).startTime)
+}
+
+func evaluateAim(curr *diffObject, withSliders bool) float64 {
+	if curr.obj.Kind == Spinner || curr.index <= 1 || curr.previous(0).obj.Kind == Spinner {
+		return 0
+	}
+	last, lastLast := curr.previous(0), curr.previous(1)
+
+	currVelocity := curr.lazyJumpDistance / curr.strainTime
+	if last.obj.Kind == Slider && withSliders {
+		travel := last.travelDistance / last.travelTime
+		movement := curr.minimumJumpDistance / curr.minimumJumpTime
+		currVelocity = math.Max(currVelocity, movement+travel)
+	}
+	prevVelocity := last.lazyJumpDistance / last.strainTime
+	if lastLast.obj.Kind == Slider && withSliders {
+		travel := lastLast.travelDistance / lastLast.travelTime
+		movement := last.minimumJumpDistance / last.minimumJumpTime
+		prevVelocity = math.Max(prevVelocity, movement+travel)
+	}
+
+	var wideBonus, acuteBonus, sliderTravel, velocityBonus float64
+	strain := currVelocity
+
+	// Angle bonuses only apply to objects at a steady rhythm.
+	if math.Max(curr.strainTime, last.strainTime) < 1.25*math.Min(curr.strainTime, last.strainTime) &&
+		curr.hasAngle && last.hasAngle && lastLast.hasAngle {
+		angleBonus := math.Min(currVelocity, prevVelocity)
+		wideBonus = wideAngle(curr.angle)
+		acuteBonus = acuteAngle(curr.angle)
+
+		if curr.strainTime > 100 {
+			acuteBonus = 0
+		} else {
+			acuteBonus *= acuteAngle(last.angle) *
+				math.Min(angleBonus, 125/curr.strainTime) *
+				math.Pow(math.Sin(math.Pi/2*math.Min(1, (100-curr.strainTime)/25)), 2) *
+				math.Pow(math.Sin(math.Pi/2*(clamp(curr.lazyJumpDistance, 50, 100)-50)/50), 2)
+		}
+		// Repeating the same angle is easier than the first one.
+		wideBonus *= angleBonus * (1 - math.Min(wideBonus, math.Pow(wideAngle(last.angle), 3)))
+		acuteBonus *= 0.5 + 0.5*(1-math.Min(acuteBonus, math.Pow(acuteAngle(lastLast.angle), 3)))
+	}
+
+	if math.Max(prevVelocity, currVelocity) != 0 {
+		prevVelocity = (last.lazyJumpDistance + lastLast.travelDistance) / last.strainTime
+		currVelocity = (curr.lazyJumpDistance + last.travelDistance) / curr.strainTime
+
+		distRatio := math.Pow(math.Sin(math.Pi/2*math.Abs(prevVelocity-currVelocity)/math.Max(prevVelocity, currVelocity)), 2)
+		overlap := math.Min(125/math.Min(curr.strainTime, last.strainTime), math.Abs(prevVelocity-currVelocity))
+		velocityBonus = overlap * distRatio *
+			math.Pow(math.Min(curr.strainTime, last.strainTime)/math.Max(curr.strainTime, last.strainTime), 2)
+	}
+
+	if last.obj.Kind == Slider {
+		sliderTravel = last.travelDistance / last.travelTime
+	}
+
+	strain += math.Max(acuteBonus*acuteAngleBonus, wideBonus*wideAngleBonus+velocityBonus*velocityChange)
+	if withSliders {
+		strain += sliderTravel * sliderBonus
+	}
+	return strain
+}
+
+func wideAngle(angle float64) float64 {
+	return math.Pow(math.Sin(3.0/4*(math.Min(5.0/6*math.Pi, math.Max(math.Pi/6, angle))-math.Pi/6)), 2)
+}
+
+func acuteAngle(angle float64) float64 {
+	return 1 - wideAngle(angle)
+}
+
+type speedStrain struct {
+	strain  float64
+	rhythm  float64
+	strains []float64
+}
+
+func (s *speedStrain) strainAt(o *diffObject) float64 {
+	s.strain *= strainDecay(speedDecayBase, o.strainTime)
+	s.strain += evaluateSpeed(o) * speedMultiplier
+	s.rhythm = evaluateRhythm(o)
+
+	total := s.strain * s.rhythm
+	s.strains = append(s.strains, total)
+	return total
+}
+
+func (s *speedStrain) initialStrain(time float64, o *diffObject) float64 {
+	return s.strain * s.rhythm * strainDecay(speedDecayBase, time-o.previous(0).startTime)
+}
+
+// relevantNotes is roughly how many notes are anywhere near the hardest
+// part of the map to tap.
+func (s *speedStrain) relevantNotes() float64 {
+	peak := 0.0
+	for _, strain := range s.strains {
+		peak = math.Max(peak, strain)
+	}
+	if peak == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, strain := range s.strains {
+		total += 1 / (1 + math.Exp(-(strain/peak*12 - 6)))
+	}
+	return total
+}
+
+func evaluateSpeed(curr *diffObject) float64 {
+	if curr.obj.Kind == Spinner {
+		return 0
+	}
+	prev, next := curr.previous(0), curr.next(0)
+
+	strainTime := curr.strainTime
+	greatWindow := curr.hitWindowGreat
+	// Nerf cheesable rhythms: very fast doubles with long gaps between.
+	if prev != nil && strainTime < greatWindow && prev.strainTime > strainTime {
+		strainTime = lerp(prev.strainTime, strainTime, strainTime/greatWindow)
+	}
+	strainTime /= clamp(strainTime/greatWindow/0.93, 0.92, 1)
+
+	doubletapness := 1.0
+	if next != nil {
+		currDelta := math.Max(1, curr.deltaTime)
+		nextDelta := math.Max(1, next.deltaTime)
+		speedRatio := currDelta / math.Max(currDelta, math.Abs(nextDelta-currDelta))
+		windowRatio := math.Pow(math.Min(1, currDelta/greatWindow), 2)
+		doubletapness = math.Pow(speedRatio, 1-windowRatio)
+	}
+
+	speedBonus := 1.0
+	if strainTime < minSpeedBonus {
+		speedBonus += 0.75 * math.Pow((minSpeedBonus-strainTime)/speedBalancing, 2)
+	}
+
+	travelDistance := 0.0
+	if prev != nil {
+		travelDistance = prev.travelDistance
+	}
+	distance := math.Min(singleSpacing, travelDistance+curr.minimumJumpDistance)
+	return (speedBonus + speedBonus*math.Pow(distance/singleSpacing, 3.5)) * doubletapness / strainTime
+}
+
+// evaluateRhythm rates how irregular the gaps leading up to an object are.
+func evaluateRhythm(curr *diffObject) float64 {
+	if curr.obj.Kind == Spinner {
+		return 0
+	}
+
+	previousIslandSize, islandSize := 0, 1
+	complexity, startRatio := 0.0, 0.0
+	firstDeltaSwitch := false
+
+	history := min(curr.index, historyObjectMax)
+	rhythmStart := 0
+	for rhythmStart < history-2 && curr.startTime-curr.previous(rhythmStart).startTime < historyTimeMax {
+		rhythmStart++
+	}
+
+	for i := rhythmStart; i > 0; i-- {
+		currObj, prevObj, lastObj := curr.previous(i-1), curr.previous(i), curr.previous(i+1)
+
+		decay := (historyTimeMax - (curr.startTime - currObj.startTime)) / historyTimeMax
+		decay = math.Min(float64(history-i)/float64(history), decay)
+
+		currDelta, prevDelta, lastDelta := currObj.strainTime, prevObj.strainTime, lastObj.strainTime
+		currRatio := 1 + 6*math.Min(0.5, math.Pow(math.Sin(math.Pi/(math.Min(prevDelta, currDelta)/math.Max(prevDelta, currDelta))), 2))
+
+		windowPenalty := math.Min(1, math.Max(0, math.Abs(prevDelta-currDelta)-currObj.hitWindowGreat*0.3)/(currObj.hitWindowGreat*0.3))
+		effectiveRatio := windowPenalty * currRatio
+
+		if firstDeltaSwitch {
+			if !(prevDelta > 1.25*currDelta || prevDelta*1.25 < currDelta) {
+				if islandSize < 7 {
+					islandSize++
+				}
+				continue
+			}
+			if currObj.obj.Kind == Slider {
+				effectiveRatio *= 0.125
+			}
+			if prevObj.obj.Kind == Slider {
+				effectiveRatio *= 0.25
+			}
+			if previousIslandSize == islandSize {
+				effectiveRatio *= 0.25
+			}
+			if previousIslandSize%2 == islandSize%2 {
+				effectiveRatio *= 0.5
+			}
+			if lastDelta > prevDelta+10 && prevDelta > currDelta+10 {
+				effectiveRatio *= 0.125
+			}
+
+			complexity += math.Sqrt(effectiveRatio*startRatio) * decay *
+				math.Sqrt(float64(4+islandSize)) / 2 * math.Sqrt(float64(4+previousIslandSize)) / 2
+
+			startRatio = effectiveRatio
+			previousIslandSize = islandSize
+			if prevDelta*1.25 < currDelta {
+				firstDeltaSwitch = false
+			}
+			islandSize = 1
+		} else if prevDelta > 1.25*currDelta {
+			firstDeltaSwitch = true
+			startRatio = effectiveRatio
+			islandSize = 1
+		}
+	}
+	return math.Sqrt(4+complexity*rhythmMultiplier) / 2
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
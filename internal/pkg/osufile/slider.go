@@ -0,0 +1,208 @@
+package osufile
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// legacyLastTickOffset is how long before its end osu!stable checks a
+	// slider is still being held.
+	legacyLastTickOffset = 36
+	maxSliderLength      = 100000
+	// maxSliderTicks bounds the ticks on one slider over all its spans, so
+	// a tiny tick distance can't make one take forever. No ranked map comes
+	// close.
+	maxSliderTicks = 10000
+)
+
+type nestedKind int
+
+const (
+	nestedHead nestedKind = iota
+	nestedTick
+	nestedRepeat
+	nestedTail
+)
+
+// nested is one of the points along a slider that's scored: its head,
+// ticks, repeats and tail.
+type nested struct {
+	kind     nestedKind
+	time     float64
+	position Vector
+}
+
+// prepareSliders works out each slider's curve, speed and end time from the
+// timing points.
+func (b *Beatmap) prepareSliders() {
+	timing := newTimingLookup(b.TimingPoints)
+	for i := range b.HitObjects {
+		obj := &b.HitObjects[i]
+		if obj.Kind != Slider {
+			continue
+		}
+		s := obj.Slider
+		s.path = newPath(s.CurveType, s.ControlPoints, s.Length)
+
+		beatLength, sv := timing.at(obj.Time)
+		scoringDistance := 100 * b.SliderMultiplier * sv
+		s.velocity = scoringDistance / beatLength
+		s.tickDistance = scoringDistance / b.SliderTickRate
+		if b.FormatVersion < 8 {
+			s.tickDistance /= sv
+		}
+		if s.velocity > 0 && !math.IsInf(s.velocity, 0) {
+			s.spanDuration = s.path.distance() / s.velocity
+		}
+		obj.EndTime = obj.Time + float64(s.Slides)*s.spanDuration
+	}
+}
+
+// timingLookup finds the beat length and slider velocity in effect at a
+// given time.
+type timingLookup struct {
+	beats []TimingPoint
+	svs   []svPoint
+}
+
+type svPoint struct {
+	time      float64
+	sv        float64
+	inherited bool
+}
+
+func newTimingLookup(points []TimingPoint) *timingLookup {
+	t := &timingLookup{}
+	sorted := append([]TimingPoint(nil), points...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time < sorted[j].Time })
+
+	for _, tp := range sorted {
+		sv := svPoint{time: tp.Time, sv: 1, inherited: !tp.Uninherited}
+		if tp.Uninherited {
+			t.beats = append(t.beats, tp)
+		} else if tp.BeatLength < 0 {
+			sv.sv = math.Max(0.1, math.Min(10, -100/tp.BeatLength))
+		}
+		// An uninherited point resets the velocity, unless an inherited
+		// one at the same time sets it.
+		if n := len(t.svs); n > 0 && t.svs[n-1].time == tp.Time {
+			if sv.inherited || !t.svs[n-1].inherited {
+				t.svs[n-1] = sv
+			}
+			continue
+		}
+		t.svs = append(t.svs, sv)
+	}
+	return t
+}
+
+func (t *timingLookup) at(time float64) (beatLength, sv float64) {
+	beatLength, sv = 1000, 1
+	if len(t.beats) > 0 {
+		beatLength = t.beats[0].BeatLength
+		for _, tp := range t.beats {
+			if tp.Time > time {
+				break
+			}
+			beatLength = tp.BeatLength
+		}
+	}
+	for _, p := range t.svs {
+		if p.time > time {
+			break
+		}
+		sv = p.sv
+	}
+	return beatLength, sv
+}
+
+// sliderPositionAt is where the ball is the given fraction of the way through
+// the slider's whole duration, following it back and forth over repeats.
+func (obj *HitObject) sliderPositionAt(progress float64) Vector {
+	s := obj.Slider
+	spans := progress * float64(s.Slides)
+	span := math.Floor(spans)
+	p := spans - span
+	if progress >= 1 {
+		span = float64(s.Slides - 1)
+		p = 1
+	}
+	if int(span)%2 == 1 {
+		p = 1 - p
+	}
+	return s.path.positionAt(p)
+}
+
+// endPosition is where a slider's last span finishes.
+func (obj *HitObject) endPosition() Vector {
+	if obj.Kind != Slider {
+		return obj.Position
+	}
+	return obj.sliderPositionAt(1)
+}
+
+// nested lists the slider's scored points in time order. The tail sits at
+// the legacy last tick, which is what difficulty uses.
+func (obj *HitObject) nested() []nested {
+	s := obj.Slider
+	out := []nested{{kind: nestedHead, time: obj.Time, position: obj.Position}}
+
+	length := math.Min(maxSliderLength, s.path.distance())
+	tickDistance := math.Max(0, math.Min(length, s.tickDistance))
+	minDistanceFromEnd := s.velocity * 10
+	tickCount := 0
+
+	for span := 0; span < s.Slides; span++ {
+		spanStart := obj.Time + float64(span)*s.spanDuration
+		reversed := span%2 == 1
+
+		var ticks []nested
+		if tickDistance > 0 {
+			for d := tickDistance; d <= length && tickCount < maxSliderTicks; d += tickDistance {
+				if d >= length-minDistanceFromEnd {
+					break
+				}
+				tickCount++
+				progress := d / length
+				timeProgress := progress
+				if reversed {
+					timeProgress = 1 - progress
+				}
+				ticks = append(ticks, nested{
+					kind:     nestedTick,
+					time:     spanStart + timeProgress*s.spanDuration,
+					position: s.path.positionAt(progress),
+				})
+			}
+		}
+		if reversed {
+			for i, j := 0, len(ticks)-1; i < j; i, j = i+1, j-1 {
+				ticks[i], ticks[j] = ticks[j], ticks[i]
+			}
+		}
+		out = append(out, ticks...)
+
+		if span < s.Slides-1 {
+			out = append(out, nested{
+				kind:     nestedRepeat,
+				time:     spanStart + s.spanDuration,
+				position: s.path.positionAt(float64((span + 1) % 2)),
+			})
+		}
+	}
+
+	out = append(out, nested{
+		kind:     nestedTail,
+		time:     obj.legacyLastTick(),
+		position: obj.endPosition(),
+	})
+	// A tick can land after the legacy last tick.
+	sort.SliceStable(out, func(i, j int) bool { return out[i].time < out[j].time })
+	return out
+}
+
+func (obj *HitObject) legacyLastTick() float64 {
+	duration := obj.EndTime - obj.Time
+	return math.Max(obj.Time+duration/2, obj.EndTime-legacyLastTickOffset)
+}
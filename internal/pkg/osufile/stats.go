@@ -0,0 +1,93 @@
+package osufile
+
+import "math"
+
+type Counts struct {
+	Circles  int
+	Sliders  int
+	Spinners int
+	Holds    int
+}
+
+func (b *Beatmap) Counts() Counts {
+	var c Counts
+	for _, obj := range b.HitObjects {
+		switch obj.Kind {
+		case Circle:
+			c.Circles++
+		case Slider:
+			c.Sliders++
+		case Spinner:
+			c.Spinners++
+		case Hold:
+			c.Holds++
+		}
+	}
+	return c
+}
+
+// MaxCombo is the highest combo a full combo gets in the beatmap's own mode.
+// Converts aren't handled; a standard map is counted as standard.
+func (b *Beatmap) MaxCombo() int {
+	combo := 0
+	for i := range b.HitObjects {
+		obj := &b.HitObjects[i]
+		switch obj.Kind {
+		case Circle, Hold:
+			combo++
+		case Spinner:
+			// Taiko's swells and catch's bananas don't add to combo.
+			if b.Mode == ModeStandard {
+				combo++
+			}
+		case Slider:
+			// Taiko's drumrolls don't add to combo either.
+			switch b.Mode {
+			case ModeStandard, ModeCatch:
+				combo += len(obj.nested())
+			case ModeMania:
+				combo++
+			}
+		}
+	}
+	return combo
+}
+
+// Length is the time from the first object to the end of the last, in
+// seconds.
+func (b *Beatmap) Length() float64 {
+	first, last, ok := b.span()
+	if !ok {
+		return 0
+	}
+	return (last - first) / 1000
+}
+
+// DrainTime is Length less any breaks, in seconds.
+func (b *Beatmap) DrainTime() float64 {
+	first, last, ok := b.span()
+	if !ok {
+		return 0
+	}
+	drain := last - first
+	for _, br := range b.Breaks {
+		start, end := math.Max(br.Start, first), math.Min(br.End, last)
+		if end > start {
+			drain -= end - start
+		}
+	}
+	return math.Max(0, drain) / 1000
+}
+
+func (b *Beatmap) span() (first, last float64, ok bool) {
+	if len(b.HitObjects) == 0 {
+		return 0, 0, false
+	}
+	first = math.Inf(1)
+	last = math.Inf(-1)
+	for _, obj := range b.HitObjects {
+		first = math.Min(first, obj.Time)
+		last = math.Max(last, obj.EndTime)
+	}
+	return first, last, true
+}
@@ -0,0 +1,93 @@
+// Package resize scales images to fixed sizes.
+package resize
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// Fill scales src to cover width x height and crops what's left over
+// equally from both sides, like CSS's object-fit: cover. Each output pixel
+// is the average of the source area it covers.
+func Fill(src image.Image, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	bounds := src.Bounds()
+	if bounds.Empty() || width <= 0 || height <= 0 {
+		return dst
+	}
+
+	// Decoded JPEGs are YCbCr; converting once up front is much quicker
+	// than going through At for every tap.
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	sw, sh := float64(bounds.Dx()), float64(bounds.Dy())
+	scale := math.Max(float64(width)/sw, float64(height)/sh)
+	cropW, cropH := float64(width)/scale, float64(height)/scale
+	xTaps := taps((sw-cropW)/2, cropW, width, bounds.Dx())
+	yTaps := taps((sh-cropH)/2, cropH, height, bounds.Dy())
+
+	// Scale each source row that's used horizontally, then the columns.
+	first := yTaps[0][0].i
+	last := yTaps[height-1][len(yTaps[height-1])-1].i
+	rows := make([]float32, (last-first+1)*width*4)
+	for y := first; y <= last; y++ {
+		line := rgba.Pix[y*rgba.Stride:]
+		row := rows[(y-first)*width*4:]
+		for x, ts := range xTaps {
+			var r, g, b, a float32
+			for _, t := range ts {
+				p := line[t.i*4:]
+				r += float32(p[0]) * t.w
+				g += float32(p[1]) * t.w
+				b += float32(p[2]) * t.w
+				a += float32(p[3]) * t.w
+			}
+			row[x*4], row[x*4+1], row[x*4+2], row[x*4+3] = r, g, b, a
+		}
+	}
+	for y, ts := range yTaps {
+		out := dst.Pix[y*dst.Stride:]
+		for x := 0; x < width*4; x++ {
+			var v float32
+			for _, t := range ts {
+				v += rows[(t.i-first)*width*4+x] * t.w
+			}
+			out[x] = uint8(math.Min(255, math.Round(float64(v))))
+		}
+	}
+	return dst
+}
+
+type tap struct {
+	i int
+	w float32
+}
+
+// taps lists, for each of n output pixels spread over [start, start+length)
+// of a line of size source pixels, the source pixels it covers and their
+// share of it.
+func taps(start, length float64, n, size int) [][]tap {
+	step := length / float64(n)
+	out := make([][]tap, n)
+	for o := range out {
+		lo := start + float64(o)*step
+		hi := lo + step
+		var ts []tap
+		var total float64
+		for i := int(math.Floor(lo)); float64(i) < hi; i++ {
+			w := math.Min(hi, float64(i+1)) - math.Max(lo, float64(i))
+			if w <= 0 {
+				continue
+			}
+			ts = append(ts, tap{min(max(i, 0), size-1), float32(w)})
+			total += w
+		}
+		for k := range ts {
+			ts[k].w /= float32(total)
+		}
+		out[o] = ts
+	}
+	return out
+}
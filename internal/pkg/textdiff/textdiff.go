@@ -0,0 +1,122 @@
+// Package textdiff produces line-based diffs for showing revision history.
+package textdiff
+
+import "strings"
+
+type Op string
+
+const (
+	Equal  Op = " "
+	Insert Op = "+"
+	Delete Op = "-"
+)
+
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// maxCells bounds the LCS table so a pathological revision can't eat memory;
+// past it the diff degrades to "everything removed, everything added".
+const maxCells = 4_000_000
+
+// Lines diffs a against b line by line.
+func Lines(a, b string) []Line {
+	al := split(a)
+	bl := split(b)
+
+	// Trim the common prefix and suffix; revisions usually touch a small part
+	// of the page, which keeps the table small.
+	prefix := 0
+	for prefix < len(al) && prefix < len(bl) && al[prefix] == bl[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(al)-prefix && suffix < len(bl)-prefix &&
+		al[len(al)-1-suffix] == bl[len(bl)-1-suffix] {
+		suffix++
+	}
+
+	var out []Line
+	for _, l := range al[:prefix] {
+		out = append(out, Line{Equal, l})
+	}
+	out = append(out, lcs(al[prefix:len(al)-suffix], bl[prefix:len(bl)-suffix])...)
+	for _, l := range al[len(al)-suffix:] {
+		out = append(out, Line{Equal, l})
+	}
+	return out
+}
+
+// Changed reports whether the diff contains any insertions or deletions.
+func Changed(lines []Line) bool {
+	for _, l := range lines {
+		if l.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+func lcs(a, b []string) []Line {
+	n, m := len(a), len(b)
+	if n*m > maxCells {
+		out := make([]Line, 0, n+m)
+		for _, l := range a {
+			out = append(out, Line{Delete, l})
+		}
+		for _, l := range b {
+			out = append(out, Line{Insert, l})
+		}
+		return out
+	}
+
+	// table[i][j] is the LCS length of a[i:] and b[j:].
+	table := make([][]int32, n+1)
+	for i := range table {
+		table[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				table[i][j] = table[i+1][j+1] + 1
+			} else if table[i+1][j] >= table[i][j+1] {
+				table[i][j] = table[i+1][j]
+			} else {
+				table[i][j] = table[i][j+1]
+			}
+		}
+	}
+
+	out := make([]Line, 0, n+m)
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, Line{Equal, a[i]})
+			i++
+			j++
+		case table[i+1][j] >= table[i][j+1]:
+			out = append(out, Line{Delete, a[i]})
+			i++
+		default:
+			out = append(out, Line{Insert, b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		out = append(out, Line{Delete, a[i]})
+	}
+	for ; j < m; j++ {
+		out = append(out, Line{Insert, b[j]})
+	}
+	return out
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,39 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/mysql"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+)
+
+type AdminLogRepository struct {
+	db *mysql.DB
+}
+
+func NewAdminLogRepository(db *mysql.DB) *AdminLogRepository {
+	return &AdminLogRepository{db: db}
+}
+
+func (r *AdminLogRepository) Record(ctx context.Context, a *models.AdminAction) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO admin_log(staff_id, target_type, target_id, action, detail, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		a.StaffID, a.TargetType, a.TargetID, a.Action, a.Detail, a.CreatedAt)
+	return err
+}
+
+func (r *AdminLogRepository) ListForTarget(ctx context.Context, targetType models.AdminTarget, targetID, limit int) ([]models.AdminAction, error) {
+	var actions []models.AdminAction
+	err := r.db.SelectContext(ctx, &actions, `
+		SELECT l.id, l.staff_id, COALESCE(u.username, '') AS staff_name, l.target_type,
+			l.target_id, l.action, l.detail, l.created_at
+		FROM admin_log l
+		LEFT JOIN users u ON u.id = l.staff_id
+		WHERE l.target_type = ? AND l.target_id = ?
+		ORDER BY l.created_at DESC, l.id DESC LIMIT ?`, targetType, targetID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return actions, nil
+}
@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/mysql"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+)
+
+type AnalyticsRepository struct {
+	db *mysql.DB
+}
+
+func NewAnalyticsRepository(db *mysql.DB) *AnalyticsRepository {
+	return &AnalyticsRepository{db: db}
+}
+
+// DailyRegistrations counts accounts registered per UTC day since since.
+// Days without registrations are left out.
+func (r *AnalyticsRepository) DailyRegistrations(ctx context.Context, since int64) ([]models.DailyCount, error) {
+	var counts []models.DailyCount
+	err := r.db.SelectContext(ctx, &counts, `
+		SELECT register_datetime - register_datetime % 86400 AS day, COUNT(*) AS count
+		FROM users
+		WHERE register_datetime >= ?
+		GROUP BY day
+		ORDER BY day`, since)
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// CountActiveSince counts users last seen at or after since.
+func (r *AnalyticsRepository) CountActiveSince(ctx context.Context, since int64) (int, error) {
+	var count int
+	err := r.db.GetContext(ctx, &count, "SELECT COUNT(*) FROM users WHERE latest_activity >= ?", since)
+	return count, err
+}
+
+// Countries counts public accounts per country, largest first.
+func (r *AnalyticsRepository) Countries(ctx context.Context, limit int) ([]models.CountryCount, error) {
+	var counts []models.CountryCount
+	err := r.db.SelectContext(ctx, &counts, `
+		SELECT country, COUNT(*) AS count
+		FROM users
+		WHERE privileges & 1 = 1
+		GROUP BY country
+		ORDER BY count DESC, country
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// CountPublicUsers is the total Countries is a share of.
+func (r *AnalyticsRepository) CountPublicUsers(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.GetContext(ctx, &count, "SELECT COUNT(*) FROM users WHERE privileges & 1 = 1")
+	return count, err
+}
+
+// WeeklyCohorts groups accounts registered since since into weeks starting
+// at since.
+func (r *AnalyticsRepository) WeeklyCohorts(ctx context.Context, since int64) ([]models.Cohort, error) {
+	var cohorts []models.Cohort
+	err := r.db.SelectContext(ctx, &cohorts, `
+		SELECT ? + FLOOR((register_datetime - ?) / 604800) * 604800 AS start,
+			COUNT(*) AS registered,
+			SUM(latest_activity >= register_datetime + 86400) AS day1,
+			SUM(latest_activity >= register_datetime + 604800) AS day7,
+			SUM(latest_activity >= register_datetime + 2592000) AS day30
+		FROM users
+		WHERE register_datetime >= ?
+		GROUP BY start
+		ORDER BY start`, since, since, since)
+	if err != nil {
+		return nil, err
+	}
+	return cohorts, nil
+}
+
+// MaxClanID is the highest clan ID handed out so far, or 0 without clans.
+// Clans don't store when they were created, so the sampler records this
+// daily and the difference between days is the number created.
+func (r *AnalyticsRepository) MaxClanID(ctx context.Context) (int, error) {
+	var id int
+	err := r.db.GetContext(ctx, &id, "SELECT COALESCE(MAX(id), 0) FROM clans")
+	return id, err
+}
+
+// RecordSample stores a metric's value at a time, replacing any value already
+// stored for that time.
+func (r *AnalyticsRepository) RecordSample(ctx context.Context, metric string, at int64, value int) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO analytics_samples(metric, sampled_at, value) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE value = VALUES(value)`, metric, at, value)
+	return err
+}
+
+// DailySamples returns a daily metric's values since since, oldest first.
+func (r *AnalyticsRepository) DailySamples(ctx context.Context, metric string, since int64) ([]models.DailyCount, error) {
+	var counts []models.DailyCount
+	err := r.db.SelectContext(ctx, &counts, `
+		SELECT sampled_at AS day, value AS count
+		FROM analytics_samples
+		WHERE metric = ? AND sampled_at >= ?
+		ORDER BY sampled_at`, metric, since)
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// SampleBuckets summarises a metric's samples since since into buckets of
+// bucket seconds, oldest first.
+func (r *AnalyticsRepository) SampleBuckets(ctx context.Context, metric string, since, bucket int64) ([]models.OnlinePoint, error) {
+	var points []models.OnlinePoint
+	err := r.db.SelectContext(ctx, &points, `
+		SELECT sampled_at - sampled_at % ? AS at, MAX(value) AS peak, AVG(value) AS average
+		FROM analytics_samples
+		WHERE metric = ? AND sampled_at >= ?
+		GROUP BY at
+		ORDER BY at`, bucket, metric, since)
+	if err != nil {
+		return nil, err
+	}
+	return points, nil
+}
@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/mysql"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+)
+
+type AnnouncementRepository struct {
+	db *mysql.DB
+}
+
+func NewAnnouncementRepository(db *mysql.DB) *AnnouncementRepository {
+	return &AnnouncementRepository{db: db}
+}
+
+const announcementSelect = `
+	SELECT a.id, a.title, a.body, a.audience, a.privileges, a.starts_at, a.ends_at,
+		a.dismissible, a.push_ingame, a.pushed_at, a.created_by,
+		COALESCE(u.username, '') AS creator_name, a.created_at, a.updated_at
+	FROM announcements a
+	LEFT JOIN users u ON u.id = a.created_by`
+
+func (r *AnnouncementRepository) Create(ctx context.Context, a *models.Announcement) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO announcements(title, body, audience, privileges, starts_at, ends_at,
+			dismissible, push_ingame, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.Title, a.Body, a.Audience, a.Privileges, a.StartsAt, a.EndsAt,
+		a.Dismissible, a.PushIngame, a.CreatedBy, a.CreatedAt, a.UpdatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (r *AnnouncementRepository) Update(ctx context.Context, a *models.Announcement) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE announcements SET title = ?, body = ?, audience = ?, privileges = ?,
+			starts_at = ?, ends_at = ?, dismissible = ?, push_ingame = ?, updated_at = ?
+		WHERE id = ?`,
+		a.Title, a.Body, a.Audience, a.Privileges, a.StartsAt, a.EndsAt,
+		a.Dismissible, a.PushIngame, a.UpdatedAt, a.ID)
+	return err
+}
+
+func (r *AnnouncementRepository) Delete(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM announcements WHERE id = ?", id)
+	return err
+}
+
+func (r *AnnouncementRepository) FindByID(ctx context.Context, id int64) (*models.Announcement, error) {
+	var a models.Announcement
+	err := r.db.GetContext(ctx, &a, announcementSelect+" WHERE a.id = ?", id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *AnnouncementRepository) List(ctx context.Context, limit, offset int) ([]models.Announcement, error) {
+	var announcements []models.Announcement
+	err := r.db.SelectContext(ctx, &announcements, announcementSelect+`
+		ORDER BY a.starts_at DESC, a.id DESC LIMIT ? OFFSET ?`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return announcements, nil
+}
+
+// FindLive returns the announcements whose window contains now, newest first.
+func (r *AnnouncementRepository) FindLive(ctx context.Context, now int64) ([]models.Announcement, error) {
+	var announcements []models.Announcement
+	err := r.db.SelectContext(ctx, &announcements, announcementSelect+`
+		WHERE a.starts_at <= ? AND (a.ends_at = 0 OR a.ends_at > ?)
+		ORDER BY a.starts_at DESC, a.id DESC`, now, now)
+	if err != nil {
+		return nil, err
+	}
+	return announcements, nil
+}
+
+// MarkPushed claims the in-game push for an announcement. It returns false if
+// another instance already pushed it.
+func (r *AnnouncementRepository) MarkPushed(ctx context.Context, id, pushedAt int64) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE announcements SET pushed_at = ? WHERE id = ? AND pushed_at = 0",
+		pushedAt, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
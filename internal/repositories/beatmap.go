@@ -0,0 +1,122 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/mysql"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+)
+
+type BeatmapRepository struct {
+	db *mysql.DB
+}
+
+func NewBeatmapRepository(db *mysql.DB) *BeatmapRepository {
+	return &BeatmapRepository{db: db}
+}
+
+// GetRankedStatuses returns the ranked status of each difficulty of the set
+// that the score server knows about, keyed by beatmap ID. Statuses are
+// converted from the server's scale.
+func (r *BeatmapRepository) GetRankedStatuses(ctx context.Context, setID int) (map[int]int, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT beatmap_id, ranked FROM beatmaps WHERE beatmapset_id = ?", setID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statuses := make(map[int]int)
+	for rows.Next() {
+		var beatmapID, status int
+		if err := rows.Scan(&beatmapID, &status); err != nil {
+			return nil, err
+		}
+		statuses[beatmapID] = models.StatusFromServer(status)
+	}
+	return statuses, rows.Err()
+}
+
+// ApplyRankedStatus sets the status of every difficulty in changes and records
+// each change in the ranking log. Changed difficulties are frozen so the score
+// server won't overwrite them from osu!.
+func (r *BeatmapRepository) ApplyRankedStatus(ctx context.Context, status int, changes []models.BeatmapRankingLog) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ids := make([]interface{}, 0, len(changes)+1)
+	ids = append(ids, models.ServerStatus(status))
+	for _, c := range changes {
+		ids = append(ids, c.BeatmapID)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(changes)), ", ")
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE beatmaps SET ranked = ?, ranked_status_freezed = 1 WHERE beatmap_id IN ("+placeholders+")",
+		ids...); err != nil {
+		return err
+	}
+
+	for _, c := range changes {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO beatmap_ranking_log(beatmap_id, beatmapset_id, song_name, old_status,
+				new_status, reason, user_id, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			c.BeatmapID, c.BeatmapsetID, c.SongName, c.OldStatus, c.NewStatus, c.Reason,
+			c.UserID, c.CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetRankingLog returns the most recent status changes, for one set or for
+// every set when setID is 0.
+func (r *BeatmapRepository) GetRankingLog(ctx context.Context, setID, limit int) ([]models.BeatmapRankingLog, error) {
+	query := `
+		SELECT l.id, l.beatmap_id, l.beatmapset_id, l.song_name, l.old_status, l.new_status,
+			l.reason, l.user_id, COALESCE(u.username, '') AS username, l.created_at
+		FROM beatmap_ranking_log l
+		LEFT JOIN users u ON u.id = l.user_id`
+	args := []interface{}{}
+	if setID != 0 {
+		query += " WHERE l.beatmapset_id = ?"
+		args = append(args, setID)
+	}
+	query += " ORDER BY l.created_at DESC, l.id DESC LIMIT ?"
+	args = append(args, limit)
+
+	var entries []models.BeatmapRankingLog
+	if err := r.db.SelectContext(ctx, &entries, query, args...); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (r *BeatmapRepository) RecordDownload(ctx context.Context, setID int, at int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO beatmap_downloads(beatmapset_id, downloads, last_downloaded_at)
+		VALUES (?, 1, ?)
+		ON DUPLICATE KEY UPDATE downloads = downloads + 1, last_downloaded_at = VALUES(last_downloaded_at)`,
+		setID, at)
+	return err
+}
+
+func (r *BeatmapRepository) GetDownloadCount(ctx context.Context, setID int) (int, error) {
+	var count int
+	err := r.db.GetContext(ctx, &count,
+		"SELECT downloads FROM beatmap_downloads WHERE beatmapset_id = ?", setID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return count, err
+}
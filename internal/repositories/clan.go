@@ -3,11 +3,15 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"strings"
 
 	"github.com/RealistikOsu/soumetsu/internal/adapters/mysql"
 	"github.com/RealistikOsu/soumetsu/internal/models"
 )
 
+// likeEscaper escapes user input for use in a LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 type ClanRepository struct {
 	db *mysql.DB
 }
@@ -206,3 +210,78 @@ func (r *ClanRepository) ClanExists(ctx context.Context, clanID int) (bool, erro
 	}
 	return true, nil
 }
+
+// Search finds clans whose name or tag starts with prefix.
+func (r *ClanRepository) Search(ctx context.Context, prefix string, limit int) ([]models.Clan, error) {
+	var clans []models.Clan
+	like := likeEscaper.Replace(prefix) + "%"
+	err := r.db.SelectContext(ctx, &clans, `
+		SELECT id, name, tag, description, mlimit FROM clans
+		WHERE name LIKE ? OR tag LIKE ?
+		ORDER BY id ASC LIMIT ?`, like, like, limit)
+	if err != nil {
+		return nil, err
+	}
+	return clans, nil
+}
+
+// ListMembers returns a clan's members, owner first.
+func (r *ClanRepository) ListMembers(ctx context.Context, clanID int) ([]models.ClanMemberUser, error) {
+	var members []models.ClanMemberUser
+	err := r.db.SelectContext(ctx, &members, `
+		SELECT uc.user, uc.clan, uc.perms, COALESCE(u.username, '') AS username,
+			COALESCE(u.country, '') AS country
+		FROM user_clans uc
+		LEFT JOIN users u ON u.id = uc.user
+		WHERE uc.clan = ?
+		ORDER BY uc.perms = ? DESC, u.username ASC`, clanID, models.ClanPermOwner)
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *ClanRepository) RemoveIcon(ctx context.Context, id int) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE clans SET icon = '' WHERE id = ?", id)
+	return err
+}
+
+// TransferOwnership demotes the current owner to a member and promotes
+// userID, who must already be in the clan.
+func (r *ClanRepository) TransferOwnership(ctx context.Context, clanID, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE user_clans SET perms = ? WHERE clan = ? AND perms = ?",
+		models.ClanPermMember, clanID, models.ClanPermOwner); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE user_clans SET perms = ? WHERE clan = ? AND user = ?",
+		models.ClanPermOwner, clanID, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Disband removes a clan along with its members and invites.
+func (r *ClanRepository) Disband(ctx context.Context, clanID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		"DELETE FROM user_clans WHERE clan = ?",
+		"DELETE FROM clans_invites WHERE clan = ?",
+		"DELETE FROM clans WHERE id = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, query, clanID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,255 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/mysql"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+)
+
+// collectionBatchSize bounds the rows in one INSERT or IN list.
+const collectionBatchSize = 500
+
+type CollectionRepository struct {
+	db *mysql.DB
+}
+
+func NewCollectionRepository(db *mysql.DB) *CollectionRepository {
+	return &CollectionRepository{db: db}
+}
+
+// AddFavourite does nothing if the set is already a favourite.
+func (r *CollectionRepository) AddFavourite(ctx context.Context, f *models.BeatmapFavourite) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT IGNORE INTO beatmap_favourites(user_id, beatmapset_id, song_name, creator, created_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		f.UserID, f.BeatmapsetID, f.SongName, f.Creator, f.CreatedAt)
+	return err
+}
+
+func (r *CollectionRepository) RemoveFavourite(ctx context.Context, userID, setID int) error {
+	_, err := r.db.ExecContext(ctx,
+		"DELETE FROM beatmap_favourites WHERE user_id = ? AND beatmapset_id = ?", userID, setID)
+	return err
+}
+
+func (r *CollectionRepository) IsFavourite(ctx context.Context, userID, setID int) (bool, error) {
+	var exists bool
+	err := r.db.GetContext(ctx, &exists,
+		"SELECT EXISTS(SELECT 1 FROM beatmap_favourites WHERE user_id = ? AND beatmapset_id = ?)", userID, setID)
+	return exists, err
+}
+
+func (r *CollectionRepository) CountFavourites(ctx context.Context, setID int) (int, error) {
+	var count int
+	err := r.db.GetContext(ctx, &count,
+		"SELECT COUNT(*) FROM beatmap_favourites WHERE beatmapset_id = ?", setID)
+	return count, err
+}
+
+// ListFavourites returns a user's favourites, newest first.
+func (r *CollectionRepository) ListFavourites(ctx context.Context, userID, offset, limit int) ([]models.BeatmapFavourite, error) {
+	var favourites []models.BeatmapFavourite
+	err := r.db.SelectContext(ctx, &favourites, `
+		SELECT user_id, beatmapset_id, song_name, creator, created_at
+		FROM beatmap_favourites
+		WHERE user_id = ?
+		ORDER BY created_at DESC, beatmapset_id DESC
+		LIMIT ? OFFSET ?`, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return favourites, nil
+}
+
+const collectionSelect = `
+	SELECT c.id, c.user_id, COALESCE(u.username, '') AS username, c.name, c.public,
+		(SELECT COUNT(*) FROM beatmap_collection_items i WHERE i.collection_id = c.id) AS items,
+		c.created_at, c.updated_at
+	FROM beatmap_collections c
+	LEFT JOIN users u ON u.id = c.user_id`
+
+// ListForUser returns a user's collections by name, leaving out private
+// ones unless includePrivate is set.
+func (r *CollectionRepository) ListForUser(ctx context.Context, userID int, includePrivate bool) ([]models.BeatmapCollection, error) {
+	query := collectionSelect + " WHERE c.user_id = ?"
+	if !includePrivate {
+		query += " AND c.public = 1"
+	}
+	query += " ORDER BY c.name, c.id"
+
+	var collections []models.BeatmapCollection
+	if err := r.db.SelectContext(ctx, &collections, query, userID); err != nil {
+		return nil, err
+	}
+	return collections, nil
+}
+
+func (r *CollectionRepository) CountForUser(ctx context.Context, userID int) (int, error) {
+	var count int
+	err := r.db.GetContext(ctx, &count,
+		"SELECT COUNT(*) FROM beatmap_collections WHERE user_id = ?", userID)
+	return count, err
+}
+
+func (r *CollectionRepository) FindByID(ctx context.Context, id int64) (*models.BeatmapCollection, error) {
+	return r.find(ctx, " WHERE c.id = ?", id)
+}
+
+func (r *CollectionRepository) FindByName(ctx context.Context, userID int, name string) (*models.BeatmapCollection, error) {
+	return r.find(ctx, " WHERE c.user_id = ? AND c.name = ?", userID, name)
+}
+
+func (r *CollectionRepository) find(ctx context.Context, where string, args ...interface{}) (*models.BeatmapCollection, error) {
+	var c models.BeatmapCollection
+	err := r.db.GetContext(ctx, &c, collectionSelect+where, args...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *CollectionRepository) Create(ctx context.Context, c *models.BeatmapCollection) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO beatmap_collections(user_id, name, public, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		c.UserID, c.Name, c.Public, c.CreatedAt, c.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (r *CollectionRepository) Update(ctx context.Context, id int64, name string, public bool, now int64) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE beatmap_collections SET name = ?, public = ?, updated_at = ? WHERE id = ?",
+		name, public, now, id)
+	return err
+}
+
+func (r *CollectionRepository) Delete(ctx context.Context, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM beatmap_collection_items WHERE collection_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM beatmap_collections WHERE id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListItems returns a collection's difficulties in the order they were
+// added.
+func (r *CollectionRepository) ListItems(ctx context.Context, collectionID int64) ([]models.BeatmapCollectionItem, error) {
+	var items []models.BeatmapCollectionItem
+	err := r.db.SelectContext(ctx, &items, `
+		SELECT collection_id, beatmap_md5, beatmap_id, beatmapset_id, song_name, added_at
+		FROM beatmap_collection_items
+		WHERE collection_id = ?
+		ORDER BY added_at, beatmap_md5`, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// AddItems adds difficulties to a collection, skipping ones already in it,
+// and returns how many were new.
+func (r *CollectionRepository) AddItems(ctx context.Context, collectionID int64, items []models.BeatmapCollectionItem, now int64) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	added := 0
+	for start := 0; start < len(items); start += collectionBatchSize {
+		batch := items[start:min(start+collectionBatchSize, len(items))]
+		args := make([]interface{}, 0, len(batch)*6)
+		for _, item := range batch {
+			args = append(args, collectionID, item.BeatmapMD5, item.BeatmapID, item.BeatmapsetID, item.SongName, now)
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("(?, ?, ?, ?, ?, ?), ", len(batch)), ", ")
+		result, err := tx.ExecContext(ctx, `
+			INSERT IGNORE INTO beatmap_collection_items(collection_id, beatmap_md5, beatmap_id,
+				beatmapset_id, song_name, added_at)
+			VALUES `+placeholders, args...)
+		if err != nil {
+			return 0, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		added += int(n)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE beatmap_collections SET updated_at = ? WHERE id = ?", now, collectionID); err != nil {
+		return 0, err
+	}
+	return added, tx.Commit()
+}
+
+func (r *CollectionRepository) RemoveItem(ctx context.Context, collectionID int64, md5 string, now int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		"DELETE FROM beatmap_collection_items WHERE collection_id = ? AND beatmap_md5 = ?",
+		collectionID, md5); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE beatmap_collections SET updated_at = ? WHERE id = ?", now, collectionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ResolveItems fills in the IDs and name of each item the score server has
+// a difficulty for, going by MD5.
+func (r *CollectionRepository) ResolveItems(ctx context.Context, items []models.BeatmapCollectionItem) error {
+	byMD5 := make(map[string][]int, len(items))
+	for i, item := range items {
+		byMD5[item.BeatmapMD5] = append(byMD5[item.BeatmapMD5], i)
+	}
+
+	for start := 0; start < len(items); start += collectionBatchSize {
+		batch := items[start:min(start+collectionBatchSize, len(items))]
+		args := make([]interface{}, 0, len(batch))
+		for _, item := range batch {
+			args = append(args, item.BeatmapMD5)
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(batch)), ", ")
+
+		var found []models.BeatmapCollectionItem
+		if err := r.db.SelectContext(ctx, &found, `
+			SELECT beatmap_md5, beatmap_id, beatmapset_id, song_name
+			FROM beatmaps
+			WHERE beatmap_md5 IN (`+placeholders+`)`, args...); err != nil {
+			return err
+		}
+		for _, f := range found {
+			for _, i := range byMD5[f.BeatmapMD5] {
+				items[i].BeatmapID = f.BeatmapID
+				items[i].BeatmapsetID = f.BeatmapsetID
+				items[i].SongName = f.SongName
+			}
+		}
+	}
+	return nil
+}
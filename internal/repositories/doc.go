@@ -0,0 +1,167 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/mysql"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+)
+
+type DocRepository struct {
+	db *mysql.DB
+}
+
+func NewDocRepository(db *mysql.DB) *DocRepository {
+	return &DocRepository{db: db}
+}
+
+// docPageSelect takes the title from the newest revision so drafts show
+// their working title in the admin list.
+const docPageSelect = `
+	SELECT p.id, p.slug, p.published_revision_id, p.created_at, p.updated_at,
+		COALESCE((SELECT MAX(r.id) FROM doc_revisions r WHERE r.page_id = p.id), 0) AS latest_revision_id,
+		COALESCE((SELECT r.title FROM doc_revisions r WHERE r.page_id = p.id ORDER BY r.id DESC LIMIT 1), '') AS title
+	FROM doc_pages p`
+
+const docRevisionSelect = `
+	SELECT r.id, r.page_id, r.title, r.body, r.format, r.summary, r.author_id,
+		COALESCE(u.username, '') AS author_name, r.created_at
+	FROM doc_revisions r
+	LEFT JOIN users u ON u.id = r.author_id`
+
+func (r *DocRepository) ListPages(ctx context.Context) ([]models.DocPage, error) {
+	var pages []models.DocPage
+	if err := r.db.SelectContext(ctx, &pages, docPageSelect+" ORDER BY p.slug ASC"); err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+func (r *DocRepository) FindPageByID(ctx context.Context, id int64) (*models.DocPage, error) {
+	return r.findPage(ctx, " WHERE p.id = ?", id)
+}
+
+func (r *DocRepository) FindPageBySlug(ctx context.Context, slug string) (*models.DocPage, error) {
+	return r.findPage(ctx, " WHERE p.slug = ?", slug)
+}
+
+func (r *DocRepository) findPage(ctx context.Context, where string, arg interface{}) (*models.DocPage, error) {
+	var page models.DocPage
+	err := r.db.GetContext(ctx, &page, docPageSelect+where, arg)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (r *DocRepository) CreatePage(ctx context.Context, slug string, now int64) (int64, error) {
+	result, err := r.db.ExecContext(ctx,
+		"INSERT INTO doc_pages(slug, created_at, updated_at) VALUES (?, ?, ?)",
+		slug, now, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (r *DocRepository) AddRevision(ctx context.Context, rev *models.DocRevision) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO doc_revisions(page_id, title, body, format, summary, author_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		rev.PageID, rev.Title, rev.Body, rev.Format, rev.Summary, rev.AuthorID, rev.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	_, err = r.db.ExecContext(ctx, "UPDATE doc_pages SET updated_at = ? WHERE id = ?", rev.CreatedAt, rev.PageID)
+	return id, err
+}
+
+func (r *DocRepository) GetRevision(ctx context.Context, id int64) (*models.DocRevision, error) {
+	var rev models.DocRevision
+	err := r.db.GetContext(ctx, &rev, docRevisionSelect+" WHERE r.id = ?", id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// PreviousRevision returns the revision saved before revID, or nil for the
+// first one.
+func (r *DocRepository) PreviousRevision(ctx context.Context, pageID, revID int64) (*models.DocRevision, error) {
+	var rev models.DocRevision
+	err := r.db.GetContext(ctx, &rev, docRevisionSelect+`
+		WHERE r.page_id = ? AND r.id < ?
+		ORDER BY r.id DESC LIMIT 1`, pageID, revID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// ListRevisions returns a page's history, newest first, without bodies.
+func (r *DocRepository) ListRevisions(ctx context.Context, pageID int64) ([]models.DocRevision, error) {
+	var revs []models.DocRevision
+	err := r.db.SelectContext(ctx, &revs, `
+		SELECT r.id, r.page_id, r.title, '' AS body, r.format, r.summary, r.author_id,
+			COALESCE(u.username, '') AS author_name, r.created_at
+		FROM doc_revisions r
+		LEFT JOIN users u ON u.id = r.author_id
+		WHERE r.page_id = ?
+		ORDER BY r.id DESC`, pageID)
+	if err != nil {
+		return nil, err
+	}
+	return revs, nil
+}
+
+// SetPublished points the page at revID; 0 unpublishes it.
+func (r *DocRepository) SetPublished(ctx context.Context, pageID, revID, now int64) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE doc_pages SET published_revision_id = ?, updated_at = ? WHERE id = ?",
+		revID, now, pageID)
+	return err
+}
+
+func (r *DocRepository) DeletePage(ctx context.Context, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM doc_revisions WHERE page_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM doc_pages WHERE id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *DocRepository) ListPublished(ctx context.Context) ([]models.PublishedDoc, error) {
+	var docs []models.PublishedDoc
+	err := r.db.SelectContext(ctx, &docs, `
+		SELECT p.slug, r.id, r.page_id, r.title, r.body, r.format, r.summary, r.author_id,
+			'' AS author_name, r.created_at
+		FROM doc_pages p
+		INNER JOIN doc_revisions r ON r.id = p.published_revision_id
+		ORDER BY r.title ASC`)
+	if err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/mysql"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+)
+
+const jobRunSelect = `
+	SELECT id, job, instance, manual, status, error, started_at, duration_ms
+	FROM job_runs`
+
+type JobRunRepository struct {
+	db *mysql.DB
+}
+
+func NewJobRunRepository(db *mysql.DB) *JobRunRepository {
+	return &JobRunRepository{db: db}
+}
+
+func (r *JobRunRepository) Start(ctx context.Context, run *models.JobRun) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO job_runs(job, instance, manual, status, error, started_at)
+		VALUES (?, ?, ?, ?, '', ?)`,
+		run.Job, run.Instance, run.Manual, models.JobRunRunning, run.StartedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (r *JobRunRepository) Finish(ctx context.Context, id int64, status models.JobRunStatus, errMsg string, durationMS int64) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE job_runs SET status = ?, error = ?, duration_ms = ? WHERE id = ?",
+		status, errMsg, durationMS, id)
+	return err
+}
+
+// Latest returns the most recent run of every job that has run, keyed by job.
+func (r *JobRunRepository) Latest(ctx context.Context) (map[string]models.JobRun, error) {
+	return r.latestWhere(ctx, "")
+}
+
+// LatestFailures returns the most recent failed run of every job that has
+// failed, keyed by job.
+func (r *JobRunRepository) LatestFailures(ctx context.Context) (map[string]models.JobRun, error) {
+	return r.latestWhere(ctx, "WHERE status = 'failed'")
+}
+
+func (r *JobRunRepository) latestWhere(ctx context.Context, where string) (map[string]models.JobRun, error) {
+	var runs []models.JobRun
+	err := r.db.SelectContext(ctx, &runs, jobRunSelect+`
+		WHERE id IN (SELECT MAX(id) FROM job_runs `+where+` GROUP BY job)`)
+	if err != nil {
+		return nil, err
+	}
+	byJob := make(map[string]models.JobRun, len(runs))
+	for _, run := range runs {
+		byJob[run.Job] = run
+	}
+	return byJob, nil
+}
+
+func (r *JobRunRepository) ListForJob(ctx context.Context, job string, limit int) ([]models.JobRun, error) {
+	var runs []models.JobRun
+	err := r.db.SelectContext(ctx, &runs, jobRunSelect+" WHERE job = ? ORDER BY id DESC LIMIT ?", job, limit)
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+func (r *JobRunRepository) ListFailures(ctx context.Context, limit int) ([]models.JobRun, error) {
+	var runs []models.JobRun
+	err := r.db.SelectContext(ctx, &runs, jobRunSelect+" WHERE status = 'failed' ORDER BY id DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// DeleteBefore removes runs started before cutoff, returning how many went.
+func (r *JobRunRepository) DeleteBefore(ctx context.Context, cutoff int64) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM job_runs WHERE started_at < ?", cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/mysql"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+)
+
+type RankRequestRepository struct {
+	db *mysql.DB
+}
+
+func NewRankRequestRepository(db *mysql.DB) *RankRequestRepository {
+	return &RankRequestRepository{db: db}
+}
+
+const rankRequestSelect = `
+	SELECT rr.id, rr.user_id, COALESCE(u.username, '') AS username,
+		rr.request_type, rr.beatmap_id, rr.beatmapset_id, rr.song_name, rr.beatmaps,
+		rr.status, rr.reason, rr.reviewer_id, COALESCE(rv.username, '') AS reviewer_name,
+		rr.requested_at, rr.reviewed_at
+	FROM beatmap_rank_requests rr
+	LEFT JOIN users u ON u.id = rr.user_id
+	LEFT JOIN users rv ON rv.id = rr.reviewer_id`
+
+func (r *RankRequestRepository) Create(ctx context.Context, req *models.RankRequest) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO beatmap_rank_requests(user_id, request_type, beatmap_id, beatmapset_id,
+			song_name, beatmaps, status, reason, requested_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, '', ?)`,
+		req.UserID, req.Type, req.BeatmapID, req.BeatmapsetID,
+		req.SongName, req.BeatmapsJSON, req.Status, req.RequestedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (r *RankRequestRepository) FindByID(ctx context.Context, id int64) (*models.RankRequest, error) {
+	var req models.RankRequest
+	err := r.db.GetContext(ctx, &req, rankRequestSelect+" WHERE rr.id = ?", id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// List returns requests newest first. An empty status matches every request.
+func (r *RankRequestRepository) List(ctx context.Context, status models.RankRequestStatus, limit, offset int) ([]models.RankRequest, error) {
+	query := rankRequestSelect
+	var args []interface{}
+	if status != "" {
+		query += " WHERE rr.status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY rr.requested_at DESC, rr.id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	var reqs []models.RankRequest
+	if err := r.db.SelectContext(ctx, &reqs, query, args...); err != nil {
+		return nil, err
+	}
+	return reqs, nil
+}
+
+func (r *RankRequestRepository) CountByStatus(ctx context.Context) (map[models.RankRequestStatus]int, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT status, COUNT(*) FROM beatmap_rank_requests GROUP BY status")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[models.RankRequestStatus]int)
+	for rows.Next() {
+		var status models.RankRequestStatus
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		counts[status] = count
+	}
+	return counts, rows.Err()
+}
+
+func (r *RankRequestRepository) CountSince(ctx context.Context, since int64) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM beatmap_rank_requests WHERE requested_at >= ?", since).Scan(&count)
+	return count, err
+}
+
+func (r *RankRequestRepository) CountByUserSince(ctx context.Context, userID int, since int64) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM beatmap_rank_requests WHERE user_id = ? AND requested_at >= ?",
+		userID, since).Scan(&count)
+	return count, err
+}
+
+// OldestByUserSince returns when the user's oldest request inside the window
+// was made, or 0 if there is none.
+func (r *RankRequestRepository) OldestByUserSince(ctx context.Context, userID int, since int64) (int64, error) {
+	var oldest sql.NullInt64
+	err := r.db.QueryRowContext(ctx,
+		"SELECT MIN(requested_at) FROM beatmap_rank_requests WHERE user_id = ? AND requested_at >= ?",
+		userID, since).Scan(&oldest)
+	return oldest.Int64, err
+}
+
+func (r *RankRequestRepository) PendingExistsForSet(ctx context.Context, setID int) (bool, error) {
+	var exists int
+	err := r.db.QueryRowContext(ctx,
+		"SELECT 1 FROM beatmap_rank_requests WHERE beatmapset_id = ? AND status = ? LIMIT 1",
+		setID, models.RankRequestPending).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *RankRequestRepository) Review(ctx context.Context, id int64, status models.RankRequestStatus, reason string, reviewerID int, reviewedAt int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE beatmap_rank_requests
+		SET status = ?, reason = ?, reviewer_id = ?, reviewed_at = ?
+		WHERE id = ?`,
+		status, reason, reviewerID, reviewedAt, id)
+	return err
+}
@@ -0,0 +1,200 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/mysql"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+)
+
+type RegistrationKeyRepository struct {
+	db *mysql.DB
+}
+
+func NewRegistrationKeyRepository(db *mysql.DB) *RegistrationKeyRepository {
+	return &RegistrationKeyRepository{db: db}
+}
+
+const registrationKeySelect = `
+	SELECT k.id, k.key, k.max_uses, k.uses, k.expires_at, k.note, k.created_by,
+		COALESCE(c.username, '') AS creator_name, k.owner_id,
+		COALESCE(o.username, '') AS owner_name, k.revoked, k.created_at
+	FROM registration_keys k
+	LEFT JOIN users c ON c.id = k.created_by
+	LEFT JOIN users o ON o.id = k.owner_id`
+
+const registrationKeyUseSelect = `
+	SELECT ku.user_id, COALESCE(u.username, '') AS username, ku.key_id, k.key, k.note,
+		k.owner_id, COALESCE(o.username, '') AS owner_name, ku.used_at, ku.ip
+	FROM registration_key_uses ku
+	INNER JOIN registration_keys k ON k.id = ku.key_id
+	LEFT JOIN users u ON u.id = ku.user_id
+	LEFT JOIN users o ON o.id = k.owner_id`
+
+func (r *RegistrationKeyRepository) Create(ctx context.Context, k *models.RegistrationKey) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO registration_keys(`+"`key`"+`, max_uses, expires_at, note, created_by, owner_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		k.Key, k.MaxUses, k.ExpiresAt, k.Note, k.CreatedBy, k.OwnerID, k.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// CreateOwned inserts a personal invite unless its owner already has allowance
+// or more, reporting whether it did. The owner's user row is locked while
+// counting so concurrent requests can't both take the last slot.
+func (r *RegistrationKeyRepository) CreateOwned(ctx context.Context, k *models.RegistrationKey, allowance int) (int64, bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	var locked int
+	if err := tx.QueryRowContext(ctx, "SELECT id FROM users WHERE id = ? FOR UPDATE", k.OwnerID).Scan(&locked); err != nil {
+		return 0, false, err
+	}
+	var count int
+	err = tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM registration_keys WHERE owner_id = ? AND revoked = 0", k.OwnerID).Scan(&count)
+	if err != nil {
+		return 0, false, err
+	}
+	if count >= allowance {
+		return 0, false, nil
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO registration_keys(`+"`key`"+`, max_uses, expires_at, note, created_by, owner_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		k.Key, k.MaxUses, k.ExpiresAt, k.Note, k.CreatedBy, k.OwnerID, k.CreatedAt)
+	if err != nil {
+		return 0, false, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, false, err
+	}
+	return id, true, tx.Commit()
+}
+
+func (r *RegistrationKeyRepository) FindByID(ctx context.Context, id int64) (*models.RegistrationKey, error) {
+	return r.find(ctx, registrationKeySelect+" WHERE k.id = ?", id)
+}
+
+func (r *RegistrationKeyRepository) FindByKey(ctx context.Context, key string) (*models.RegistrationKey, error) {
+	return r.find(ctx, registrationKeySelect+" WHERE k.key = ?", key)
+}
+
+// List returns keys newest first. Personal invites are included only when
+// withPersonal is set.
+func (r *RegistrationKeyRepository) List(ctx context.Context, withPersonal bool, limit, offset int) ([]models.RegistrationKey, error) {
+	query := registrationKeySelect
+	if !withPersonal {
+		query += " WHERE k.owner_id = 0"
+	}
+	var keys []models.RegistrationKey
+	err := r.db.SelectContext(ctx, &keys, query+" ORDER BY k.id DESC LIMIT ? OFFSET ?", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *RegistrationKeyRepository) ListByOwner(ctx context.Context, ownerID int) ([]models.RegistrationKey, error) {
+	var keys []models.RegistrationKey
+	err := r.db.SelectContext(ctx, &keys, registrationKeySelect+" WHERE k.owner_id = ? ORDER BY k.id DESC", ownerID)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// CountByOwner counts a user's personal invites that haven't been revoked.
+func (r *RegistrationKeyRepository) CountByOwner(ctx context.Context, ownerID int) (int, error) {
+	var count int
+	err := r.db.GetContext(ctx, &count,
+		"SELECT COUNT(*) FROM registration_keys WHERE owner_id = ? AND revoked = 0", ownerID)
+	return count, err
+}
+
+// Reserve takes one use of a key if it's still usable at now, returning false
+// when it isn't. The check and the increment are one statement so concurrent
+// registrations can't overuse a key.
+func (r *RegistrationKeyRepository) Reserve(ctx context.Context, id int64, now int64) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE registration_keys SET uses = uses + 1
+		WHERE id = ? AND revoked = 0 AND uses < max_uses AND (expires_at = 0 OR expires_at > ?)`, id, now)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+// Release gives back a use taken by Reserve when registration failed.
+func (r *RegistrationKeyRepository) Release(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE registration_keys SET uses = uses - 1 WHERE id = ? AND uses > 0", id)
+	return err
+}
+
+func (r *RegistrationKeyRepository) RecordUse(ctx context.Context, keyID int64, userID int, usedAt int64, ip string) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO registration_key_uses(user_id, key_id, used_at, ip)
+		VALUES (?, ?, ?, ?)`, userID, keyID, usedAt, ip)
+	return err
+}
+
+func (r *RegistrationKeyRepository) Revoke(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE registration_keys SET revoked = 1 WHERE id = ?", id)
+	return err
+}
+
+// RevokeExpiredInvites revokes personal invites that expired unused, which
+// frees the slots they held. It returns how many were revoked.
+func (r *RegistrationKeyRepository) RevokeExpiredInvites(ctx context.Context, now int64) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE registration_keys SET revoked = 1
+		WHERE owner_id <> 0 AND revoked = 0 AND uses = 0 AND expires_at > 0 AND expires_at <= ?`, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *RegistrationKeyRepository) ListUses(ctx context.Context, keyID int64) ([]models.RegistrationKeyUse, error) {
+	var uses []models.RegistrationKeyUse
+	err := r.db.SelectContext(ctx, &uses, registrationKeyUseSelect+" WHERE ku.key_id = ? ORDER BY ku.used_at DESC", keyID)
+	if err != nil {
+		return nil, err
+	}
+	return uses, nil
+}
+
+// UseForUser returns the key a user registered with, or nil.
+func (r *RegistrationKeyRepository) UseForUser(ctx context.Context, userID int) (*models.RegistrationKeyUse, error) {
+	var use models.RegistrationKeyUse
+	err := r.db.GetContext(ctx, &use, registrationKeyUseSelect+" WHERE ku.user_id = ?", userID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &use, nil
+}
+
+func (r *RegistrationKeyRepository) find(ctx context.Context, query string, args ...interface{}) (*models.RegistrationKey, error) {
+	var k models.RegistrationKey
+	err := r.db.GetContext(ctx, &k, query, args...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
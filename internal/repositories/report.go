@@ -0,0 +1,244 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/mysql"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+)
+
+type ReportRepository struct {
+	db *mysql.DB
+}
+
+func NewReportRepository(db *mysql.DB) *ReportRepository {
+	return &ReportRepository{db: db}
+}
+
+// reportSelect resolves the reporter, assignee and target names so list views
+// don't need a lookup per row.
+const reportSelect = `
+	SELECT r.id, r.reporter_id, COALESCE(ru.username, '') AS reporter_name,
+		r.target_type, r.target_id,
+		COALESCE(tu.username, tc.name, '') AS target_name,
+		r.category, r.reason, r.evidence, r.status,
+		r.assignee_id, COALESCE(au.username, '') AS assignee_name,
+		r.resolution, r.resolution_message,
+		r.created_at, r.updated_at, r.closed_at
+	FROM reports r
+	LEFT JOIN users ru ON ru.id = r.reporter_id
+	LEFT JOIN users au ON au.id = r.assignee_id
+	LEFT JOIN users tu ON r.target_type IN ('user', 'userpage') AND tu.id = r.target_id
+	LEFT JOIN clans tc ON r.target_type = 'clan' AND tc.id = r.target_id`
+
+type ReportFilter struct {
+	Status     models.ReportStatus
+	AssigneeID int
+	TargetType models.ReportTarget
+	TargetID   int64
+	Limit      int
+	Offset     int
+}
+
+func (r *ReportRepository) Create(ctx context.Context, report *models.Report) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO reports(reporter_id, target_type, target_id, category, reason, evidence,
+			status, resolution_message, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, '', ?, ?)`,
+		report.ReporterID, report.TargetType, report.TargetID, report.Category, report.Reason,
+		report.Evidence, report.Status, report.CreatedAt, report.UpdatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (r *ReportRepository) FindByID(ctx context.Context, id int64) (*models.Report, error) {
+	var report models.Report
+	err := r.db.GetContext(ctx, &report, reportSelect+" WHERE r.id = ?", id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (r *ReportRepository) List(ctx context.Context, filter ReportFilter) ([]models.Report, error) {
+	where, args := filter.where()
+	query := reportSelect + where + " ORDER BY r.created_at DESC, r.id DESC LIMIT ? OFFSET ?"
+	args = append(args, filter.Limit, filter.Offset)
+
+	var reports []models.Report
+	if err := r.db.SelectContext(ctx, &reports, query, args...); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+func (r *ReportRepository) Count(ctx context.Context, filter ReportFilter) (int, error) {
+	where, args := filter.where()
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM reports r"+where, args...).Scan(&count)
+	return count, err
+}
+
+func (r *ReportRepository) ListByReporter(ctx context.Context, reporterID, limit int) ([]models.Report, error) {
+	var reports []models.Report
+	err := r.db.SelectContext(ctx, &reports, reportSelect+`
+		WHERE r.reporter_id = ?
+		ORDER BY r.created_at DESC, r.id DESC LIMIT ?`, reporterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// ListAgainstUser returns the reports filed against a user or their userpage.
+func (r *ReportRepository) ListAgainstUser(ctx context.Context, userID, limit int) ([]models.Report, error) {
+	var reports []models.Report
+	err := r.db.SelectContext(ctx, &reports, reportSelect+`
+		WHERE r.target_type IN ('user', 'userpage') AND r.target_id = ?
+		ORDER BY r.created_at DESC, r.id DESC LIMIT ?`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// HasOpenReport reports whether the reporter already has an unresolved report
+// against the same target.
+func (r *ReportRepository) HasOpenReport(ctx context.Context, reporterID int, targetType models.ReportTarget, targetID int64) (bool, error) {
+	var exists int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT 1 FROM reports
+		WHERE reporter_id = ? AND target_type = ? AND target_id = ? AND status IN (?, ?)
+		LIMIT 1`,
+		reporterID, targetType, targetID, models.ReportStatusOpen, models.ReportStatusInProgress).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *ReportRepository) CountSince(ctx context.Context, reporterID int, since int64) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM reports WHERE reporter_id = ? AND created_at >= ?",
+		reporterID, since).Scan(&count)
+	return count, err
+}
+
+func (r *ReportRepository) CountByStatus(ctx context.Context) (map[models.ReportStatus]int, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT status, COUNT(*) FROM reports GROUP BY status")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[models.ReportStatus]int)
+	for rows.Next() {
+		var status models.ReportStatus
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		counts[status] = count
+	}
+	return counts, rows.Err()
+}
+
+func (r *ReportRepository) UpdateAssignee(ctx context.Context, id int64, assigneeID int, updatedAt int64) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE reports SET assignee_id = ?, updated_at = ? WHERE id = ?",
+		assigneeID, updatedAt, id)
+	return err
+}
+
+func (r *ReportRepository) UpdateStatus(ctx context.Context, id int64, status models.ReportStatus, updatedAt int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE reports SET status = ?, updated_at = ?, closed_at = 0,
+			resolution = '', resolution_message = ''
+		WHERE id = ?`,
+		status, updatedAt, id)
+	return err
+}
+
+func (r *ReportRepository) Close(ctx context.Context, id int64, status models.ReportStatus, resolution, message string, closedAt int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE reports SET status = ?, resolution = ?, resolution_message = ?,
+			updated_at = ?, closed_at = ?
+		WHERE id = ?`,
+		status, resolution, message, closedAt, closedAt, id)
+	return err
+}
+
+func (r *ReportRepository) AddNote(ctx context.Context, reportID int64, authorID int, content string, isEvent bool, createdAt int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO report_notes(report_id, author_id, content, is_event, created_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		reportID, authorID, content, isEvent, createdAt)
+	return err
+}
+
+func (r *ReportRepository) GetNotes(ctx context.Context, reportID int64) ([]models.ReportNote, error) {
+	var notes []models.ReportNote
+	err := r.db.SelectContext(ctx, &notes, `
+		SELECT n.id, n.report_id, n.author_id, COALESCE(u.username, '') AS author_name,
+			n.content, n.is_event, n.created_at
+		FROM report_notes n
+		LEFT JOIN users u ON u.id = n.author_id
+		WHERE n.report_id = ?
+		ORDER BY n.created_at ASC, n.id ASC`, reportID)
+	if err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+func (f ReportFilter) where() (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+
+	if f.Status != "" {
+		conds = append(conds, "r.status = ?")
+		args = append(args, f.Status)
+	}
+	if f.AssigneeID != 0 {
+		conds = append(conds, "r.assignee_id = ?")
+		args = append(args, f.AssigneeID)
+	}
+	if f.TargetType != "" {
+		conds = append(conds, "r.target_type = ?")
+		args = append(args, f.TargetType)
+	}
+	if f.TargetID != 0 {
+		conds = append(conds, "r.target_id = ?")
+		args = append(args, f.TargetID)
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// FindStaff lists users who can be assigned reports.
+func (r *ReportRepository) FindStaff(ctx context.Context) ([]models.User, error) {
+	var users []models.User
+	err := r.db.SelectContext(ctx, &users, `
+		SELECT id, username, privileges FROM users
+		WHERE privileges & ? > 0 AND privileges & ? > 0
+		ORDER BY username ASC`,
+		models.AdminPrivilegeManageReports, models.UserPrivilegeNormal)
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
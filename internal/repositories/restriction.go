@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/mysql"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+)
+
+// RestrictionRepository restricts users and keeps the end dates of timed
+// restrictions.
+type RestrictionRepository struct {
+	db *mysql.DB
+}
+
+func NewRestrictionRepository(db *mysql.DB) *RestrictionRepository {
+	return &RestrictionRepository{db: db}
+}
+
+// Restrict clears the user's public privilege. A restriction with an EndsAt
+// is remembered so it can be lifted; one without replaces any end date the
+// user had, making it permanent.
+func (r *RestrictionRepository) Restrict(ctx context.Context, rst *models.TimedRestriction) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "UPDATE users SET privileges = privileges & ~1, ban_datetime = ? WHERE id = ?",
+		strconv.FormatInt(rst.RestrictedAt, 10), rst.UserID)
+	if err != nil {
+		return err
+	}
+	if rst.EndsAt == 0 {
+		_, err = tx.ExecContext(ctx, "DELETE FROM user_restrictions WHERE user_id = ?", rst.UserID)
+	} else {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO user_restrictions(user_id, restricted_at, ends_at, reason, created_by)
+			VALUES (?, ?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE restricted_at = VALUES(restricted_at), ends_at = VALUES(ends_at),
+				reason = VALUES(reason), created_by = VALUES(created_by)`,
+			rst.UserID, rst.RestrictedAt, rst.EndsAt, rst.Reason, rst.CreatedBy)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *RestrictionRepository) FindByUser(ctx context.Context, userID int) (*models.TimedRestriction, error) {
+	var rst models.TimedRestriction
+	err := r.db.GetContext(ctx, &rst, `
+		SELECT user_id, restricted_at, ends_at, reason, created_by
+		FROM user_restrictions WHERE user_id = ?`, userID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rst, nil
+}
+
+// Expired returns up to limit timed restrictions that ended by now.
+func (r *RestrictionRepository) Expired(ctx context.Context, now int64, limit int) ([]models.TimedRestriction, error) {
+	var restrictions []models.TimedRestriction
+	err := r.db.SelectContext(ctx, &restrictions, `
+		SELECT user_id, restricted_at, ends_at, reason, created_by
+		FROM user_restrictions WHERE ends_at <= ?
+		ORDER BY ends_at LIMIT ?`, now, limit)
+	return restrictions, err
+}
+
+// Lift gives a restricted user their public privilege back and forgets the
+// restriction. The privilege is only restored while the user is still under
+// this restriction, and not banned or restricted again since; it reports
+// whether it was.
+func (r *RestrictionRepository) Lift(ctx context.Context, rst *models.TimedRestriction) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE users SET privileges = privileges | 1, ban_datetime = '0'
+		WHERE id = ? AND privileges & 3 = 2 AND CAST(ban_datetime AS UNSIGNED) = ?`,
+		rst.UserID, rst.RestrictedAt)
+	if err != nil {
+		return false, err
+	}
+	lifted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM user_restrictions WHERE user_id = ? AND restricted_at = ?",
+		rst.UserID, rst.RestrictedAt)
+	if err != nil {
+		return false, err
+	}
+	return lifted > 0, tx.Commit()
+}
@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/mysql"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+)
+
+type RulesRepository struct {
+	db *mysql.DB
+}
+
+func NewRulesRepository(db *mysql.DB) *RulesRepository {
+	return &RulesRepository{db: db}
+}
+
+const rulesVersionSelect = `
+	SELECT v.id, v.revision_id, v.summary, v.created_by,
+		COALESCE(u.username, '') AS creator_name, v.created_at,
+		(SELECT COUNT(*) FROM rules_acceptances a WHERE a.version_id = v.id) AS acceptances
+	FROM rules_versions v
+	LEFT JOIN users u ON u.id = v.created_by`
+
+func (r *RulesRepository) Latest(ctx context.Context) (*models.RulesVersion, error) {
+	var v models.RulesVersion
+	err := r.db.GetContext(ctx, &v, rulesVersionSelect+" ORDER BY v.id DESC LIMIT 1")
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (r *RulesRepository) List(ctx context.Context) ([]models.RulesVersion, error) {
+	var versions []models.RulesVersion
+	if err := r.db.SelectContext(ctx, &versions, rulesVersionSelect+" ORDER BY v.id DESC"); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (r *RulesRepository) Create(ctx context.Context, v *models.RulesVersion) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO rules_versions(revision_id, summary, created_by, created_at)
+		VALUES (?, ?, ?, ?)`, v.RevisionID, v.Summary, v.CreatedBy, v.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (r *RulesRepository) HasAccepted(ctx context.Context, userID int, versionID int64) (bool, error) {
+	var count int
+	err := r.db.GetContext(ctx, &count,
+		"SELECT COUNT(*) FROM rules_acceptances WHERE user_id = ? AND version_id = ?", userID, versionID)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Accept records the acceptance, keeping the first timestamp if the user
+// somehow submits twice.
+func (r *RulesRepository) Accept(ctx context.Context, userID int, versionID int64, ip string, acceptedAt int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT IGNORE INTO rules_acceptances(user_id, version_id, accepted_at, ip)
+		VALUES (?, ?, ?, ?)`, userID, versionID, acceptedAt, ip)
+	return err
+}
+
+// ForUser lists every version, newest first, with when userID accepted it.
+func (r *RulesRepository) ForUser(ctx context.Context, userID int) ([]models.RulesAcceptance, error) {
+	var acceptances []models.RulesAcceptance
+	err := r.db.SelectContext(ctx, &acceptances, `
+		SELECT v.id AS version_id, v.summary, v.created_at,
+			COALESCE(a.accepted_at, 0) AS accepted_at, COALESCE(a.ip, '') AS ip
+		FROM rules_versions v
+		LEFT JOIN rules_acceptances a ON a.version_id = v.id AND a.user_id = ?
+		ORDER BY v.id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return acceptances, nil
+}
@@ -0,0 +1,138 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/mysql"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+)
+
+type StaffNoteRepository struct {
+	db *mysql.DB
+}
+
+func NewStaffNoteRepository(db *mysql.DB) *StaffNoteRepository {
+	return &StaffNoteRepository{db: db}
+}
+
+const staffNoteSelect = `
+	SELECT n.id, n.target_type, n.target_id, n.body, n.pinned, n.author_id,
+		COALESCE(a.username, '') AS author_name, n.editor_id,
+		COALESCE(e.username, '') AS editor_name, n.created_at, n.updated_at
+	FROM staff_notes n
+	LEFT JOIN users a ON a.id = n.author_id
+	LEFT JOIN users e ON e.id = n.editor_id`
+
+// ListForTarget returns a target's notes, pinned ones first, then newest
+// first.
+func (r *StaffNoteRepository) ListForTarget(ctx context.Context, targetType models.AdminTarget, targetID int) ([]models.StaffNote, error) {
+	var notes []models.StaffNote
+	err := r.db.SelectContext(ctx, &notes, staffNoteSelect+`
+		WHERE n.target_type = ? AND n.target_id = ?
+		ORDER BY n.pinned DESC, n.created_at DESC, n.id DESC`, targetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+func (r *StaffNoteRepository) FindByID(ctx context.Context, id int64) (*models.StaffNote, error) {
+	var note models.StaffNote
+	err := r.db.GetContext(ctx, &note, staffNoteSelect+" WHERE n.id = ?", id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+// Create adds a note along with its first revision.
+func (r *StaffNoteRepository) Create(ctx context.Context, n *models.StaffNote) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO staff_notes(target_type, target_id, body, pinned, author_id, editor_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		n.TargetType, n.TargetID, n.Body, n.Pinned, n.AuthorID, n.AuthorID, n.CreatedAt, n.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := addStaffNoteRevision(ctx, tx, id, n.Body, n.AuthorID, n.CreatedAt); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// Update replaces a note's body and records the new version.
+func (r *StaffNoteRepository) Update(ctx context.Context, id int64, body string, editorID int, now int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE staff_notes SET body = ?, editor_id = ?, updated_at = ?
+		WHERE id = ?`, body, editorID, now, id); err != nil {
+		return err
+	}
+	if err := addStaffNoteRevision(ctx, tx, id, body, editorID, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *StaffNoteRepository) SetPinned(ctx context.Context, id int64, pinned bool) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE staff_notes SET pinned = ? WHERE id = ?", pinned, id)
+	return err
+}
+
+func (r *StaffNoteRepository) Delete(ctx context.Context, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM staff_note_revisions WHERE note_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM staff_notes WHERE id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListRevisions returns a note's versions, newest first.
+func (r *StaffNoteRepository) ListRevisions(ctx context.Context, noteID int64) ([]models.StaffNoteRevision, error) {
+	var revisions []models.StaffNoteRevision
+	err := r.db.SelectContext(ctx, &revisions, `
+		SELECT r.id, r.note_id, r.body, r.editor_id,
+			COALESCE(u.username, '') AS editor_name, r.created_at
+		FROM staff_note_revisions r
+		LEFT JOIN users u ON u.id = r.editor_id
+		WHERE r.note_id = ?
+		ORDER BY r.id DESC`, noteID)
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func addStaffNoteRevision(ctx context.Context, tx *mysql.Tx, noteID int64, body string, editorID int, now int64) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO staff_note_revisions(note_id, body, editor_id, created_at)
+		VALUES (?, ?, ?, ?)`, noteID, body, editorID, now)
+	return err
+}
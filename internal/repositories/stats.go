@@ -2,8 +2,11 @@ package repositories
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 
 	"github.com/RealistikOsu/soumetsu/internal/adapters/mysql"
+	"github.com/RealistikOsu/soumetsu/internal/models"
 )
 
 type StatsRepository struct {
@@ -47,6 +50,70 @@ func (r *StatsRepository) InitializeUserStats(ctx context.Context, userID int64,
 	return err
 }
 
+// statsTables and scoresTables are indexed by custom mode.
+var (
+	statsTables  = []string{"users_stats", "rx_stats", "ap_stats"}
+	scoresTables = []string{"scores", "scores_relax", "scores_ap"}
+)
+
+// WipePreview counts what Wipe would remove for one play mode.
+func (r *StatsRepository) WipePreview(ctx context.Context, userID int, m models.PlayMode) (*models.WipePreview, error) {
+	preview := models.WipePreview{PlayMode: m}
+
+	err := r.db.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT COUNT(*) FROM %s WHERE userid = ? AND play_mode = ?", scoresTables[m.CustomMode]),
+		userID, m.Mode).Scan(&preview.Scores)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.db.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT ranked_score_%[1]s, total_score_%[1]s, playcount_%[1]s, pp_%[1]s
+		FROM %[2]s WHERE id = ?`, m.Suffix(), statsTables[m.CustomMode]), userID).
+		Scan(&preview.RankedScore, &preview.TotalScore, &preview.Playcount, &preview.PP)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return &preview, nil
+}
+
+// Wipe deletes a user's scores in the given play modes and zeroes their
+// stats in one transaction, so either every mode is wiped or none is. It
+// returns how many scores were removed.
+func (r *StatsRepository) Wipe(ctx context.Context, userID int, modes []models.PlayMode) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var total int64
+	for _, m := range modes {
+		result, err := tx.ExecContext(ctx, fmt.Sprintf(
+			"DELETE FROM %s WHERE userid = ? AND play_mode = ?", scoresTables[m.CustomMode]),
+			userID, m.Mode)
+		if err != nil {
+			return 0, err
+		}
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		total += deleted
+
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(`
+			UPDATE %[2]s SET ranked_score_%[1]s = 0, total_score_%[1]s = 0, playcount_%[1]s = 0,
+				replays_watched_%[1]s = 0, total_hits_%[1]s = 0, level_%[1]s = 0,
+				avg_accuracy_%[1]s = 0, pp_%[1]s = 0, playtime_%[1]s = 0, max_combo_%[1]s = 0
+			WHERE id = ?`, m.Suffix(), statsTables[m.CustomMode]), userID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return total, tx.Commit()
+}
+
 type SystemRepository struct {
 	db *mysql.DB
 }
@@ -64,6 +131,29 @@ func (r *SystemRepository) RegistrationsEnabled(ctx context.Context) (bool, erro
 	return enabled, nil
 }
 
+func (r *SystemRepository) FindAll(ctx context.Context) ([]models.SystemSetting, error) {
+	var settings []models.SystemSetting
+	err := r.db.SelectContext(ctx, &settings, "SELECT name, value_int, value_string FROM system_settings")
+	if err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+func (r *SystemRepository) SetInt(ctx context.Context, name string, value int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO system_settings(name, value_int, value_string) VALUES (?, ?, '')
+		ON DUPLICATE KEY UPDATE value_int = VALUES(value_int)`, name, value)
+	return err
+}
+
+func (r *SystemRepository) SetString(ctx context.Context, name, value string) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO system_settings(name, value_int, value_string) VALUES (?, 0, ?)
+		ON DUPLICATE KEY UPDATE value_string = VALUES(value_string)`, name, value)
+	return err
+}
+
 type DiscordRepository struct {
 	db *mysql.DB
 }
@@ -93,6 +183,24 @@ func (r *DiscordRepository) Unlink(ctx context.Context, userID int) error {
 	return err
 }
 
+func (r *DiscordRepository) GetDiscordID(ctx context.Context, userID int) (string, error) {
+	var discordID string
+	err := r.db.QueryRowContext(ctx, "SELECT discord_id FROM discord_oauth WHERE user_id = ?", userID).Scan(&discordID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return discordID, err
+}
+
+func (r *DiscordRepository) FindUserIDByDiscordID(ctx context.Context, discordID string) (int, error) {
+	var userID int
+	err := r.db.QueryRowContext(ctx, "SELECT user_id FROM discord_oauth WHERE discord_id = ?", discordID).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return userID, err
+}
+
 type ProfileBackgroundRepository struct {
 	db *mysql.DB
 }
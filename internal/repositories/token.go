@@ -5,6 +5,7 @@ import (
 	"database/sql"
 
 	"github.com/RealistikOsu/soumetsu/internal/adapters/mysql"
+	"github.com/RealistikOsu/soumetsu/internal/models"
 )
 
 type TokenRepository struct {
@@ -103,6 +104,16 @@ func (r *TokenRepository) DeletePasswordResetKey(ctx context.Context, key string
 	return err
 }
 
+// DeleteExpiredPasswordResetKeys deletes reset keys created before the given
+// unix time.
+func (r *TokenRepository) DeleteExpiredPasswordResetKeys(ctx context.Context, before int64) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM password_recovery WHERE t < FROM_UNIXTIME(?)", before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 func (r *TokenRepository) LogIP(ctx context.Context, userID int, ip string) error {
 	_, err := r.db.ExecContext(ctx, `
 		INSERT INTO ip_user (userid, ip, occurencies) VALUES (?, ?, '1')
@@ -121,3 +132,56 @@ func (r *TokenRepository) GetUsernameByIP(ctx context.Context, ip string) (strin
 	}
 	return username, err
 }
+
+// GetIdentityTokens lists the identity tokens issued to a user, each with the
+// number of other accounts holding the same token.
+func (r *TokenRepository) GetIdentityTokens(ctx context.Context, userID int) ([]models.IdentityToken, error) {
+	var tokens []models.IdentityToken
+	err := r.db.SelectContext(ctx, &tokens, `
+		SELECT i.token,
+			(SELECT COUNT(DISTINCT o.userid) FROM identity_tokens o
+			 WHERE o.token = i.token AND o.userid <> i.userid) AS shared_with
+		FROM identity_tokens i WHERE i.userid = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// GetIPs lists the addresses a user has logged in from, most used first, each
+// with the number of other accounts seen on it.
+func (r *TokenRepository) GetIPs(ctx context.Context, userID int) ([]models.UserIP, error) {
+	var ips []models.UserIP
+	err := r.db.SelectContext(ctx, &ips, `
+		SELECT i.ip, i.occurencies,
+			(SELECT COUNT(DISTINCT o.userid) FROM ip_user o
+			 WHERE o.ip = i.ip AND o.userid <> i.userid) AS shared_with
+		FROM ip_user i WHERE i.userid = ?
+		ORDER BY i.occurencies DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return ips, nil
+}
+
+func (r *TokenRepository) FindUserIDsByIP(ctx context.Context, ip string, limit int) ([]int, error) {
+	var ids []int
+	err := r.db.SelectContext(ctx, &ids, `
+		SELECT DISTINCT userid FROM ip_user WHERE ip = ?
+		ORDER BY userid ASC LIMIT ?`, ip, limit)
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *TokenRepository) FindUserIDsByIdentityToken(ctx context.Context, token string, limit int) ([]int, error) {
+	var ids []int
+	err := r.db.SelectContext(ctx, &ids, `
+		SELECT DISTINCT userid FROM identity_tokens WHERE token = ?
+		ORDER BY userid ASC LIMIT ?`, token, limit)
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
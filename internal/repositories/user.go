@@ -144,6 +144,33 @@ func (r *UserRepository) UpdateUsername(ctx context.Context, id int, username st
 	return err
 }
 
+// Rename changes a user's name everywhere it is stored and records the old
+// one in their username history.
+func (r *UserRepository) Rename(ctx context.Context, id int, oldUsername, newUsername string, changedAt int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET username = ?, username_safe = ? WHERE id = ?",
+		newUsername, SafeUsername(newUsername), id); err != nil {
+		return err
+	}
+	for _, table := range []string{"users_stats", "rx_stats", "ap_stats"} {
+		if _, err := tx.ExecContext(ctx, "UPDATE "+table+" SET username = ? WHERE id = ?", newUsername, id); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO user_name_history(user_id, username, changed_datetime)
+		VALUES (?, ?, ?)`, id, oldUsername, changedAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 func (r *UserRepository) ClearFlags(ctx context.Context, id int, flags uint64) error {
 	_, err := r.db.ExecContext(ctx, "UPDATE users SET flags = flags & ~? WHERE id = ? LIMIT 1", flags, id)
 	return err
@@ -241,6 +268,86 @@ func (r *UserRepository) GetBadgeMembers(ctx context.Context, badgeID int) ([]mo
 	return users, nil
 }
 
+// FindIDsByOldUsername returns the users who have gone by username before.
+func (r *UserRepository) FindIDsByOldUsername(ctx context.Context, username string, limit int) ([]int, error) {
+	var ids []int
+	err := r.db.SelectContext(ctx, &ids, `
+		SELECT DISTINCT user_id FROM user_name_history
+		WHERE username = ? ORDER BY user_id ASC LIMIT ?`, strings.TrimSpace(username), limit)
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *UserRepository) GetUsernameHistory(ctx context.Context, userID int) ([]models.UsernameChange, error) {
+	var history []models.UsernameChange
+	err := r.db.SelectContext(ctx, &history, `
+		SELECT username, changed_datetime FROM user_name_history
+		WHERE user_id = ? ORDER BY changed_datetime DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func (r *UserRepository) GetBadges(ctx context.Context, userID int) ([]models.Badge, error) {
+	var badges []models.Badge
+	err := r.db.SelectContext(ctx, &badges, `
+		SELECT b.id, b.name, b.icon FROM badges b
+		JOIN user_badges ub ON ub.badge = b.id
+		WHERE ub.user = ? ORDER BY b.id ASC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return badges, nil
+}
+
+func (r *UserRepository) GetModerationState(ctx context.Context, userID int) (*models.ModerationState, error) {
+	var state models.ModerationState
+	err := r.db.GetContext(ctx, &state, `
+		SELECT CAST(ban_datetime AS UNSIGNED) AS ban_datetime, silence_end,
+			COALESCE(silence_reason, '') AS silence_reason, COALESCE(notes, '') AS notes
+		FROM users WHERE id = ?`, userID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// ExpiredSilences returns up to limit users whose silence ended by now but
+// is still on their row.
+func (r *UserRepository) ExpiredSilences(ctx context.Context, now int64, limit int) ([]models.Silence, error) {
+	var silences []models.Silence
+	err := r.db.SelectContext(ctx, &silences, `
+		SELECT id, silence_end, COALESCE(silence_reason, '') AS silence_reason
+		FROM users WHERE silence_end > 0 AND silence_end <= ?
+		LIMIT ?`, now, limit)
+	return silences, err
+}
+
+// ClearSilence removes a silence that ended at end. It reports false if the
+// user has been silenced again since.
+func (r *UserRepository) ClearSilence(ctx context.Context, userID int, end int64) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE users SET silence_end = 0, silence_reason = '' WHERE id = ? AND silence_end = ?", userID, end)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// CountPublic counts accounts that aren't restricted or banned.
+func (r *UserRepository) CountPublic(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.GetContext(ctx, &count, "SELECT COUNT(*) FROM users WHERE privileges & 1 = 1")
+	return count, err
+}
+
 func SafeUsername(username string) string {
 	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(username)), " ", "_")
 }
@@ -0,0 +1,281 @@
+package analytics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/repositories"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/stats"
+)
+
+const (
+	day  = 24 * 60 * 60
+	week = 7 * day
+
+	// SampleInterval is how often the online user count is sampled.
+	// SampleSchedule runs Sample on that interval.
+	SampleInterval = 5 * time.Minute
+	SampleSchedule = "*/5 * * * *"
+
+	metricOnlineUsers = "online_users"
+	metricActiveUsers = "active_users"
+	metricMaxClanID   = "clans_max_id"
+
+	topCountries = 25
+	cohortWeeks  = 12
+)
+
+// Ranges are the numbers of days the dashboard can show.
+var Ranges = []int{7, 30, 90, 365}
+
+// Series are the data sets that can be exported as CSV, in dashboard order.
+var Series = []string{"registrations", "active", "clans", "online", "countries", "cohorts"}
+
+// Dashboard is everything on the analytics page for the last Days days.
+type Dashboard struct {
+	Days int
+
+	Registrations      []models.DailyCount
+	TotalRegistrations int
+
+	// ActiveUsers and ClanCreations come from the sampler, so they only go
+	// back as far as it has been running.
+	ActiveUsers   []models.DailyCount
+	ClanCreations []models.DailyCount
+	ClansCreated  int
+
+	Active24h int
+	Active7d  int
+	Active30d int
+
+	// Online is bucketed hourly for short ranges and daily for long ones.
+	Online       []models.OnlinePoint
+	OnlineHourly bool
+
+	Countries   []models.CountryCount
+	PublicUsers int
+	Cohorts     []models.Cohort
+	Now         int64
+}
+
+// CountryShare is a country's percentage of public users.
+func (d *Dashboard) CountryShare(c models.CountryCount) float64 {
+	if d.PublicUsers == 0 {
+		return 0
+	}
+	return float64(c.Count) * 100 / float64(d.PublicUsers)
+}
+
+// CohortComplete reports whether everyone in the cohort registered at least
+// days days ago, so its retention figure for that many days is final.
+func (d *Dashboard) CohortComplete(c models.Cohort, days int) bool {
+	return d.Now-c.Start >= int64(days)*day+week
+}
+
+type Service struct {
+	repo  *repositories.AnalyticsRepository
+	stats *stats.Service
+}
+
+func NewService(repo *repositories.AnalyticsRepository, statsService *stats.Service) *Service {
+	return &Service{repo: repo, stats: statsService}
+}
+
+// ValidRange reports whether the dashboard can show the given number of days.
+func ValidRange(days int) bool {
+	for _, d := range Ranges {
+		if d == days {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) Dashboard(ctx context.Context, days int) (*Dashboard, error) {
+	if !ValidRange(days) {
+		return nil, services.NewBadRequest("Unknown range.")
+	}
+	now := time.Now().Unix()
+	today := now - now%day
+	since := today - int64(days-1)*day
+
+	d := &Dashboard{Days: days, Now: now}
+
+	registrations, err := s.repo.DailyRegistrations(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+	d.Registrations = fillDays(since, today, registrations)
+	for _, c := range d.Registrations {
+		d.TotalRegistrations += c.Count
+	}
+
+	if d.ActiveUsers, err = s.repo.DailySamples(ctx, metricActiveUsers, since); err != nil {
+		return nil, err
+	}
+	if d.ClanCreations, err = s.clanCreations(ctx, since); err != nil {
+		return nil, err
+	}
+	for _, c := range d.ClanCreations {
+		d.ClansCreated += c.Count
+	}
+
+	if d.Active24h, err = s.repo.CountActiveSince(ctx, now-day); err != nil {
+		return nil, err
+	}
+	if d.Active7d, err = s.repo.CountActiveSince(ctx, now-week); err != nil {
+		return nil, err
+	}
+	if d.Active30d, err = s.repo.CountActiveSince(ctx, now-30*day); err != nil {
+		return nil, err
+	}
+
+	bucket := int64(day)
+	if days <= 7 {
+		bucket = 60 * 60
+		d.OnlineHourly = true
+	}
+	if d.Online, err = s.repo.SampleBuckets(ctx, metricOnlineUsers, since, bucket); err != nil {
+		return nil, err
+	}
+
+	if d.Countries, err = s.repo.Countries(ctx, topCountries); err != nil {
+		return nil, err
+	}
+	if d.PublicUsers, err = s.repo.CountPublicUsers(ctx); err != nil {
+		return nil, err
+	}
+	if d.Cohorts, err = s.repo.WeeklyCohorts(ctx, today-(cohortWeeks-1)*week); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Export returns one of the dashboard's data sets as CSV rows, starting with
+// a header.
+func (s *Service) Export(ctx context.Context, series string, days int) ([][]string, error) {
+	d, err := s.Dashboard(ctx, days)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	switch series {
+	case "registrations":
+		rows = dailyRows("registrations", d.Registrations)
+	case "active":
+		rows = dailyRows("active_users", d.ActiveUsers)
+	case "clans":
+		rows = dailyRows("clans_created", d.ClanCreations)
+	case "online":
+		rows = append(rows, []string{"time", "peak", "average"})
+		for _, p := range d.Online {
+			rows = append(rows, []string{
+				formatTime(p.At),
+				strconv.Itoa(p.Peak),
+				strconv.FormatFloat(p.Average, 'f', 1, 64),
+			})
+		}
+	case "countries":
+		rows = append(rows, []string{"country", "users"})
+		for _, c := range d.Countries {
+			rows = append(rows, []string{c.Country, strconv.Itoa(c.Count)})
+		}
+	case "cohorts":
+		rows = append(rows, []string{"week", "registered", "day1", "day7", "day30"})
+		for _, c := range d.Cohorts {
+			rows = append(rows, []string{
+				formatDay(c.Start),
+				strconv.Itoa(c.Registered),
+				strconv.Itoa(c.Day1),
+				strconv.Itoa(c.Day7),
+				strconv.Itoa(c.Day30),
+			})
+		}
+	default:
+		return nil, services.NewNotFound("Unknown data set.")
+	}
+	return rows, nil
+}
+
+// Sample records the online user count, and updates today's active user count
+// and highest clan ID. Times are rounded down, so several instances sampling
+// at once just overwrite each other's rows.
+func (s *Service) Sample(ctx context.Context) error {
+	now := time.Now().Unix()
+	interval := int64(SampleInterval / time.Second)
+	today := now - now%day
+
+	online, err := s.stats.OnlineUsers(ctx)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.RecordSample(ctx, metricOnlineUsers, now-now%interval, online); err != nil {
+		return err
+	}
+
+	active, err := s.repo.CountActiveSince(ctx, today)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.RecordSample(ctx, metricActiveUsers, today, active); err != nil {
+		return err
+	}
+
+	maxClanID, err := s.repo.MaxClanID(ctx)
+	if err != nil {
+		return err
+	}
+	return s.repo.RecordSample(ctx, metricMaxClanID, today, maxClanID)
+}
+
+// clanCreations turns the daily highest clan IDs into clans created per day.
+// Days whose previous day wasn't sampled are left out.
+func (s *Service) clanCreations(ctx context.Context, since int64) ([]models.DailyCount, error) {
+	samples, err := s.repo.DailySamples(ctx, metricMaxClanID, since-day)
+	if err != nil {
+		return nil, err
+	}
+	var counts []models.DailyCount
+	for i := 1; i < len(samples); i++ {
+		prev, cur := samples[i-1], samples[i]
+		if cur.Day-prev.Day != day {
+			continue
+		}
+		counts = append(counts, models.DailyCount{Day: cur.Day, Count: max(cur.Count-prev.Count, 0)})
+	}
+	return counts, nil
+}
+
+// fillDays adds the days between since and until that counts has no entry
+// for, with a count of zero.
+func fillDays(since, until int64, counts []models.DailyCount) []models.DailyCount {
+	byDay := make(map[int64]int, len(counts))
+	for _, c := range counts {
+		byDay[c.Day] = c.Count
+	}
+	filled := make([]models.DailyCount, 0, (until-since)/day+1)
+	for d := since; d <= until; d += day {
+		filled = append(filled, models.DailyCount{Day: d, Count: byDay[d]})
+	}
+	return filled
+}
+
+func dailyRows(name string, counts []models.DailyCount) [][]string {
+	rows := [][]string{{"date", name}}
+	for _, c := range counts {
+		rows = append(rows, []string{formatDay(c.Day), strconv.Itoa(c.Count)})
+	}
+	return rows
+}
+
+func formatDay(t int64) string {
+	return time.Unix(t, 0).UTC().Format("2006-01-02")
+}
+
+func formatTime(t int64) string {
+	return time.Unix(t, 0).UTC().Format("2006-01-02 15:04")
+}
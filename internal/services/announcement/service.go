@@ -0,0 +1,245 @@
+package announcement
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/redis"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/repositories"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+)
+
+const (
+	maxTitleLength = 255
+	maxBodyLength  = 10000
+
+	PageSize = 50
+
+	// cacheTTL matches the settings cache: banners are rendered on every page
+	// so the live list shouldn't cost a query per request.
+	cacheTTL = 15 * time.Second
+
+	// broadcastChannel asks bancho to show an announcement to everyone online
+	// holding the given privileges.
+	broadcastChannel = "peppy:announcement"
+)
+
+type Input struct {
+	Title       string
+	Body        string
+	Audience    models.AnnouncementAudience
+	Privileges  models.UserPrivileges
+	StartsAt    time.Time
+	EndsAt      time.Time
+	Dismissible bool
+	PushIngame  bool
+}
+
+type Service struct {
+	repo  *repositories.AnnouncementRepository
+	redis *redis.Client
+
+	mu       sync.RWMutex
+	live     []models.Announcement
+	cachedAt time.Time
+}
+
+func NewService(repo *repositories.AnnouncementRepository, redis *redis.Client) *Service {
+	return &Service{repo: repo, redis: redis}
+}
+
+// ForUser returns the live announcements the user is in the audience of.
+// Errors are swallowed so a failing query doesn't take page rendering down.
+func (s *Service) ForUser(ctx context.Context, user models.SessionUser) []models.Announcement {
+	live, err := s.liveAnnouncements(ctx)
+	if err != nil {
+		slog.Error("failed to load announcements", "error", err)
+		return nil
+	}
+
+	var visible []models.Announcement
+	for _, a := range live {
+		if a.VisibleTo(user) {
+			visible = append(visible, a)
+		}
+	}
+	return visible
+}
+
+func (s *Service) List(ctx context.Context, page int) ([]models.Announcement, error) {
+	if page < 1 {
+		page = 1
+	}
+	return s.repo.List(ctx, PageSize+1, (page-1)*PageSize)
+}
+
+func (s *Service) Get(ctx context.Context, id int64) (*models.Announcement, error) {
+	a, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return nil, services.NewNotFound("Announcement not found.")
+	}
+	return a, nil
+}
+
+// Save creates an announcement when id is 0 and updates it otherwise.
+func (s *Service) Save(ctx context.Context, id int64, staffID int, input Input) (int64, error) {
+	a, err := validate(input)
+	if err != nil {
+		return 0, err
+	}
+	a.UpdatedAt = time.Now().Unix()
+
+	if id == 0 {
+		a.CreatedBy = staffID
+		a.CreatedAt = a.UpdatedAt
+		id, err = s.repo.Create(ctx, a)
+	} else {
+		if _, err = s.Get(ctx, id); err != nil {
+			return 0, err
+		}
+		a.ID = id
+		err = s.repo.Update(ctx, a)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	s.invalidate()
+	return id, nil
+}
+
+func (s *Service) Delete(ctx context.Context, id int64) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+// liveAnnouncements returns the live list, refreshing it when it's stale.
+// It's on the page render path, so it must stay free of side effects.
+func (s *Service) liveAnnouncements(ctx context.Context) ([]models.Announcement, error) {
+	s.mu.RLock()
+	if s.live != nil && time.Since(s.cachedAt) < cacheTTL {
+		live := s.live
+		s.mu.RUnlock()
+		return live, nil
+	}
+	s.mu.RUnlock()
+
+	live, err := s.repo.FindLive(ctx, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	if live == nil {
+		live = []models.Announcement{}
+	}
+
+	s.mu.Lock()
+	s.live = live
+	s.cachedAt = time.Now()
+	s.mu.Unlock()
+
+	return live, nil
+}
+
+// PushIngame pushes every live announcement that asked to be shown in-game
+// and hasn't been yet. It runs as a scheduled job.
+func (s *Service) PushIngame(ctx context.Context) error {
+	now := time.Now().Unix()
+	live, err := s.repo.FindLive(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range live {
+		if a.PushIngame && a.PushedAt == 0 {
+			s.push(ctx, a, now)
+		}
+	}
+	return nil
+}
+
+func (s *Service) push(ctx context.Context, a models.Announcement, now int64) {
+	claimed, err := s.repo.MarkPushed(ctx, a.ID, now)
+	if err != nil {
+		slog.Error("failed to claim announcement push", "error", err, "announcement_id", a.ID)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"message":    a.Title + "\n\n" + a.Body,
+		"privileges": a.IngamePrivileges(),
+	})
+	if err != nil {
+		return
+	}
+	if err := s.redis.Publish(ctx, broadcastChannel, string(payload)); err != nil {
+		slog.Error("failed to push announcement in-game", "error", err, "announcement_id", a.ID)
+	}
+}
+
+func (s *Service) invalidate() {
+	s.mu.Lock()
+	s.live = nil
+	s.mu.Unlock()
+}
+
+func validate(input Input) (*models.Announcement, error) {
+	title := strings.TrimSpace(input.Title)
+	if title == "" || utf8.RuneCountInString(title) > maxTitleLength {
+		return nil, services.NewBadRequest(fmt.Sprintf("The title must be between 1 and %d characters.", maxTitleLength))
+	}
+	body := strings.TrimSpace(input.Body)
+	if body == "" || utf8.RuneCountInString(body) > maxBodyLength {
+		return nil, services.NewBadRequest(fmt.Sprintf("The body must be between 1 and %d characters.", maxBodyLength))
+	}
+	if !input.Audience.Valid() {
+		return nil, services.NewBadRequest("Please pick an audience.")
+	}
+	if input.Audience == models.AudiencePrivileges && input.Privileges <= 0 {
+		return nil, services.NewBadRequest("Please give the privilege mask the audience must have.")
+	}
+	if input.Audience != models.AudiencePrivileges {
+		input.Privileges = 0
+	}
+
+	startsAt := input.StartsAt
+	if startsAt.IsZero() {
+		startsAt = time.Now()
+	}
+	var endsAt int64
+	if !input.EndsAt.IsZero() {
+		if !input.EndsAt.After(startsAt) {
+			return nil, services.NewBadRequest("The announcement must end after it starts.")
+		}
+		endsAt = input.EndsAt.Unix()
+	}
+
+	return &models.Announcement{
+		Title:       title,
+		Body:        body,
+		Audience:    input.Audience,
+		Privileges:  input.Privileges,
+		StartsAt:    startsAt.Unix(),
+		EndsAt:      endsAt,
+		Dismissible: input.Dismissible,
+		PushIngame:  input.PushIngame,
+	}, nil
+}
@@ -6,9 +6,11 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/RealistikOsu/soumetsu/internal/adapters/api"
 	"github.com/RealistikOsu/soumetsu/internal/adapters/redis"
@@ -17,6 +19,9 @@ import (
 	"github.com/RealistikOsu/soumetsu/internal/services"
 )
 
+// passwordResetKeyLifetime is how long a password reset link works for.
+const passwordResetKeyLifetime = 24 * time.Hour
+
 func generateRandomToken() (string, error) {
 	b := make([]byte, 32)
 	if _, err := rand.Read(b); err != nil {
@@ -201,6 +206,19 @@ func (s *Service) CheckMultiAccount(ctx context.Context, ip, identityToken strin
 	return "", "", nil
 }
 
+// ExpirePasswordResetKeys deletes password reset keys that are too old to
+// use.
+func (s *Service) ExpirePasswordResetKeys(ctx context.Context) error {
+	n, err := s.tokenRepo.DeleteExpiredPasswordResetKeys(ctx, time.Now().Add(-passwordResetKeyLifetime).Unix())
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		slog.Info("deleted expired password reset keys", "count", n)
+	}
+	return nil
+}
+
 func (s *Service) LogIP(ctx context.Context, userID int, ip string) error {
 	return s.tokenRepo.LogIP(ctx, userID, ip)
 }
@@ -2,62 +2,102 @@ package beatmap
 
 import (
 	"context"
-	"encoding/json"
-	"io"
-	"net/http"
+	"errors"
+	"strconv"
 
+	"github.com/RealistikOsu/soumetsu/internal/adapters/api"
+	"github.com/RealistikOsu/soumetsu/internal/adapters/beatmapcache"
+	"github.com/RealistikOsu/soumetsu/internal/adapters/mirror"
 	"github.com/RealistikOsu/soumetsu/internal/config"
 	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/services"
 )
 
 type Service struct {
 	config *config.Config
+	mirror *mirror.Client
+	api    *api.Client
+	cache  *beatmapcache.Cache
 }
 
-func NewService(cfg *config.Config) *Service {
-	return &Service{config: cfg}
+func NewService(cfg *config.Config, mirrorClient *mirror.Client, apiClient *api.Client, cache *beatmapcache.Cache) *Service {
+	return &Service{config: cfg, mirror: mirrorClient, api: apiClient, cache: cache}
 }
 
-func (s *Service) GetBeatmapSet(ctx context.Context, setID string) (*models.BeatmapSet, error) {
-	resp, err := http.Get(s.config.Beatmap.MirrorAPIURL + "/s/" + setID)
+func (s *Service) GetBeatmapSet(ctx context.Context, setID int) (*models.BeatmapSet, error) {
+	set, err := beatmapcache.Get(ctx, s.cache, beatmapcache.SetKey(setID), func(ctx context.Context) (*models.BeatmapSet, error) {
+		return s.mirror.GetBeatmapSet(ctx, setID)
+	}, setEntry)
 	if err != nil {
-		return nil, err
+		return nil, mirrorError(err, "Beatmap set not found.")
 	}
-	defer resp.Body.Close()
+	return set, nil
+}
+
+// CachedBeatmapSet returns the set if it's cached, and nil otherwise. It
+// never goes to the mirror, for callers that can do without the set.
+func (s *Service) CachedBeatmapSet(ctx context.Context, setID int) *models.BeatmapSet {
+	set, _ := beatmapcache.Peek[models.BeatmapSet](ctx, s.cache, beatmapcache.SetKey(setID))
+	return set
+}
 
-	body, err := io.ReadAll(resp.Body)
+func (s *Service) GetBeatmap(ctx context.Context, beatmapID int) (*models.Beatmap, error) {
+	bm, err := beatmapcache.Get(ctx, s.cache, beatmapcache.BeatmapKey(beatmapID), func(ctx context.Context) (*models.Beatmap, error) {
+		return s.mirror.GetBeatmap(ctx, beatmapID)
+	}, beatmapEntry)
 	if err != nil {
-		return nil, err
+		return nil, mirrorError(err, "Beatmap not found.")
 	}
+	return bm, nil
+}
 
-	var bset models.BeatmapSet
-	if err := json.Unmarshal(body, &bset); err != nil {
-		return nil, err
+func setEntry(set *models.BeatmapSet) beatmapcache.Entry {
+	entry := beatmapcache.Entry{Status: set.RankedStatus}
+	for _, bm := range set.ChildrenBeatmaps {
+		entry.MD5s = append(entry.MD5s, bm.FileMD5)
 	}
+	return entry
+}
 
-	return &bset, nil
+// beatmapEntry uses the default lifetime, as a mirror's difficulty doesn't
+// say what status its set has.
+func beatmapEntry(bm *models.Beatmap) beatmapcache.Entry {
+	return beatmapcache.Entry{Status: models.StatusPending, MD5s: []string{bm.FileMD5}, BeatmapID: bm.ID}
 }
 
-func (s *Service) GetBeatmap(ctx context.Context, beatmapID string) (*models.Beatmap, error) {
-	resp, err := http.Get(s.config.Beatmap.MirrorAPIURL + "/b/" + beatmapID)
+// GetBeatmapWithSet returns a difficulty along with the set it belongs to.
+func (s *Service) GetBeatmapWithSet(ctx context.Context, beatmapID int) (*models.Beatmap, *models.BeatmapSet, error) {
+	bm, err := s.GetBeatmap(ctx, beatmapID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	set, err := s.GetBeatmapSet(ctx, bm.ParentSetID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	return bm, set, nil
+}
 
-	var beatmap models.Beatmap
-	if err := json.Unmarshal(body, &beatmap); err != nil {
-		return nil, err
+// GetDownloadURL is where the set's .osz is downloaded from, optionally
+// without its video.
+func (s *Service) GetDownloadURL(setID int, noVideo bool) string {
+	u := s.config.Beatmap.DownloadMirrorURL + "/" + strconv.Itoa(setID)
+	if noVideo {
+		u += "?noVideo=1"
 	}
-
-	return &beatmap, nil
+	return u
 }
 
-func (s *Service) GetDownloadURL(setID string) string {
-	return s.config.Beatmap.DownloadMirrorURL + "/" + setID
+// mirrorError turns the mirror's typed errors into ones handlers show as a
+// 404 or 503.
+func mirrorError(err error, notFound string) error {
+	var notFoundErr *mirror.NotFoundError
+	if errors.As(err, &notFoundErr) {
+		return services.NewNotFound(notFound)
+	}
+	var unavailableErr *mirror.UnavailableError
+	if errors.As(err, &unavailableErr) {
+		return services.NewUnavailable("The beatmap mirror isn't responding right now. Please try again in a bit.")
+	}
+	return err
 }
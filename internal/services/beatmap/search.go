@@ -0,0 +1,592 @@
+package beatmap
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/api"
+	"github.com/RealistikOsu/soumetsu/internal/adapters/beatmapcache"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+)
+
+const (
+	SortRelevance  = ""
+	SortPlays      = "plays"
+	SortDate       = "date"
+	SortDifficulty = "difficulty"
+
+	// A search filters and sorts the API's best matches, at most
+	// searchPoolPages of searchPoolLimit beatmaps. The API can't filter by
+	// anything but mode and status, so matches past those are never seen.
+	searchPoolPages = 3
+	searchPoolLimit = 100
+	searchPageSize  = 20
+	// SearchPoolSize is the most beatmaps a search looks at.
+	SearchPoolSize = searchPoolPages * searchPoolLimit
+	// searchLoaders bounds the set lookups a search runs at once, and
+	// searchLookupsPerPage how many sets one page may look up in total.
+	searchLoaders        = 8
+	searchLookupsPerPage = 2 * searchPageSize
+	maxQueryLength       = 100
+
+	// searchPoolVersion is hashed into the cache key, so pools cached in an
+	// older shape are never read back.
+	searchPoolVersion = "2"
+
+	// searchTTL is how long a search's matches are kept. They aren't evicted
+	// when a beatmap is refreshed, so this is kept short.
+	searchTTL = 2 * time.Minute
+)
+
+// SearchStatuses are the statuses a search can be narrowed to, by the name
+// used in URLs. A search with no status in its URL looks for ranked maps.
+var SearchStatuses = map[string]int{
+	"ranked":    models.StatusRanked,
+	"approved":  models.StatusApproved,
+	"qualified": models.StatusQualified,
+	"loved":     models.StatusLoved,
+	"pending":   models.StatusPending,
+}
+
+// Range bounds a numeric filter. A zero bound is no bound.
+type Range struct {
+	Min float64
+	Max float64
+}
+
+func (r Range) contains(v float64) bool {
+	return (r.Min == 0 || v >= r.Min) && (r.Max == 0 || v <= r.Max)
+}
+
+// SearchQuery is a beatmap search as given in a listing URL.
+type SearchQuery struct {
+	Query string
+	// Mode is -1 for any mode.
+	Mode int
+	// Status is a key of SearchStatuses, or empty for any status.
+	Status  string
+	Creator string
+
+	Stars  Range
+	BPM    Range
+	Length Range // seconds
+	AR     Range
+	OD     Range
+	CS     Range
+	HP     Range
+
+	Sort string
+	Asc  bool
+	// Cursor is where the previous page ended, as given in SearchPage.Next.
+	Cursor string
+}
+
+// ParseSearchQuery reads a search from URL parameters.
+func ParseSearchQuery(v url.Values) (*SearchQuery, error) {
+	q := &SearchQuery{
+		Query:   strings.TrimSpace(v.Get("q")),
+		Mode:    -1,
+		Status:  "ranked",
+		Creator: strings.TrimSpace(v.Get("creator")),
+		Sort:    v.Get("sort"),
+		Asc:     v.Get("order") == "asc",
+		Cursor:  v.Get("cursor"),
+	}
+	if len(q.Query) > maxQueryLength || len(q.Creator) > maxQueryLength {
+		return nil, services.NewBadRequest("That search is too long.")
+	}
+
+	if mode := v.Get("mode"); mode != "" {
+		m, err := strconv.Atoi(mode)
+		if err != nil || m < 0 || m > 3 {
+			return nil, services.NewBadRequest("Unknown game mode.")
+		}
+		q.Mode = m
+	}
+
+	if status, ok := v["status"]; ok {
+		switch s := status[0]; {
+		case s == "" || s == "any":
+			q.Status = ""
+		default:
+			if _, ok := SearchStatuses[s]; !ok {
+				return nil, services.NewBadRequest("Unknown ranked status.")
+			}
+			q.Status = s
+		}
+	}
+
+	switch q.Sort {
+	case SortRelevance, SortPlays, SortDate, SortDifficulty:
+	default:
+		return nil, services.NewBadRequest("Unknown sort order.")
+	}
+
+	for name, r := range q.ranges() {
+		var err error
+		if r.Min, err = parseBound(v, name+"_min"); err != nil {
+			return nil, err
+		}
+		if r.Max, err = parseBound(v, name+"_max"); err != nil {
+			return nil, err
+		}
+		if r.Max != 0 && r.Min > r.Max {
+			return nil, services.NewBadRequest("The minimum " + name + " is above the maximum.")
+		}
+	}
+	return q, nil
+}
+
+func (q *SearchQuery) ranges() map[string]*Range {
+	return map[string]*Range{
+		"stars":  &q.Stars,
+		"bpm":    &q.BPM,
+		"length": &q.Length,
+		"ar":     &q.AR,
+		"od":     &q.OD,
+		"cs":     &q.CS,
+		"hp":     &q.HP,
+	}
+}
+
+func parseBound(v url.Values, name string) (float64, error) {
+	s := v.Get(name)
+	if s == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 || f > 1e6 {
+		return 0, services.NewBadRequest("Invalid value for " + name + ".")
+	}
+	return f, nil
+}
+
+// Values encodes the search, without its cursor, as URL parameters. Defaults
+// are left out so equal searches have equal URLs.
+func (q *SearchQuery) Values() url.Values {
+	v := url.Values{}
+	if q.Query != "" {
+		v.Set("q", q.Query)
+	}
+	if q.Mode >= 0 {
+		v.Set("mode", strconv.Itoa(q.Mode))
+	}
+	switch q.Status {
+	case "":
+		v.Set("status", "any")
+	case "ranked":
+	default:
+		v.Set("status", q.Status)
+	}
+	if q.Creator != "" {
+		v.Set("creator", q.Creator)
+	}
+	for name, r := range q.ranges() {
+		if r.Min != 0 {
+			v.Set(name+"_min", strconv.FormatFloat(r.Min, 'g', -1, 64))
+		}
+		if r.Max != 0 {
+			v.Set(name+"_max", strconv.FormatFloat(r.Max, 'g', -1, 64))
+		}
+	}
+	if q.Sort != SortRelevance {
+		v.Set("sort", q.Sort)
+		if q.Asc {
+			v.Set("order", "asc")
+		}
+	}
+	return v
+}
+
+// SearchResult is a set with at least one difficulty that matched.
+type SearchResult struct {
+	// Set has no difficulties; Beatmaps holds the ones that matched.
+	Set      models.BeatmapSet `json:"set"`
+	Beatmaps []models.Beatmap  `json:"beatmaps"`
+	// Status is the set's status on this server.
+	Status    int   `json:"status"`
+	Playcount int   `json:"playcount"`
+	Updated   int64 `json:"updated"`
+}
+
+// Stars is the highest star rating among the matching difficulties.
+func (r SearchResult) Stars() float64 {
+	stars := 0.0
+	for _, bm := range r.Beatmaps {
+		if bm.DifficultyRating > stars {
+			stars = bm.DifficultyRating
+		}
+	}
+	return stars
+}
+
+func (r SearchResult) StatusLabel() string {
+	return models.RankedStatusLabel(r.Status)
+}
+
+// SearchFacets count the matching sets by mode and by status.
+type SearchFacets struct {
+	Modes    map[int]int `json:"modes"`
+	Statuses map[int]int `json:"statuses"`
+}
+
+// searchHit is a difficulty the API matched, as the API describes it.
+type searchHit struct {
+	ID        int     `json:"id"`
+	Mode      int     `json:"mode"`
+	Status    int     `json:"status"`
+	Playcount int     `json:"playcount"`
+	Updated   int64   `json:"updated"`
+	Stars     float64 `json:"stars"`
+}
+
+// searchCandidate is a set with difficulties the API matched. Only the
+// candidates on the page being shown are looked up on the mirror.
+type searchCandidate struct {
+	SetID int         `json:"set_id"`
+	Hits  []searchHit `json:"hits"`
+	// Status, Playcount, Updated and Stars sum up the hits for sorting.
+	Status    int     `json:"status"`
+	Playcount int     `json:"playcount"`
+	Updated   int64   `json:"updated"`
+	Stars     float64 `json:"stars"`
+}
+
+// searchPool is the cached result of a search: the candidate sets in the
+// order results are shown in.
+type searchPool struct {
+	Candidates []searchCandidate `json:"candidates"`
+	Capped     bool              `json:"capped"`
+}
+
+type SearchPage struct {
+	Results []SearchResult `json:"results"`
+	// Total counts the matching sets. When Capped, the API had more beatmaps
+	// than a search looks at, so Total and Facets are only a lower bound.
+	// When Estimated, some filters can only be checked against the mirror,
+	// which is only asked about the sets on the page, so Total and Facets
+	// may count sets that turn out not to match.
+	Total     int          `json:"total"`
+	Capped    bool         `json:"capped"`
+	Estimated bool         `json:"estimated"`
+	Facets    SearchFacets `json:"facets"`
+	// Next is the cursor for the following page, empty on the last one.
+	Next string `json:"next,omitempty"`
+}
+
+// Search runs a search against the server's beatmaps. The API's matches are
+// narrowed down and sorted with what the API knows about them, and cached
+// for a couple of minutes so paging through them stays consistent. Only the sets
+// on the requested page are then looked up on the mirror, to fill in their
+// details and check the filters the API can't.
+func (s *Service) Search(ctx context.Context, q *SearchQuery) (*SearchPage, error) {
+	hash := sha1.Sum([]byte(searchPoolVersion + "?" + q.Values().Encode()))
+	pool, err := beatmapcache.Get(ctx, s.cache, beatmapcache.SearchKey(hex.EncodeToString(hash[:])), func(ctx context.Context) (*searchPool, error) {
+		return s.search(ctx, q)
+	}, func(*searchPool) beatmapcache.Entry {
+		return beatmapcache.Entry{TTL: searchTTL}
+	})
+	if err != nil {
+		return nil, err
+	}
+	candidates := pool.Candidates
+
+	page := &SearchPage{
+		Total:     len(candidates),
+		Capped:    pool.Capped,
+		Estimated: q.checkedOnMirror(),
+		Facets:    SearchFacets{Modes: map[int]int{}, Statuses: map[int]int{}},
+	}
+	for _, c := range candidates {
+		page.Facets.Statuses[c.Status]++
+		seen := map[int]bool{}
+		for _, hit := range c.Hits {
+			if !seen[hit.Mode] {
+				seen[hit.Mode] = true
+				page.Facets.Modes[hit.Mode]++
+			}
+		}
+	}
+
+	start := 0
+	if q.Cursor != "" {
+		key, setID, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		start = len(candidates)
+		for i, c := range candidates {
+			if q.after(sortKey(q.Sort, c, i), c.SetID, key, setID) {
+				start = i
+				break
+			}
+		}
+	}
+
+	results, end := s.fillPage(ctx, q, candidates[start:])
+	end += start
+	page.Results = results
+	if end > start && end < len(candidates) {
+		last := candidates[end-1]
+		page.Next = encodeCursor(sortKey(q.Sort, last, end-1), last.SetID)
+	}
+	return page, nil
+}
+
+// fillPage looks candidates up on the mirror, in order, until it has a page
+// of results or has looked up searchLookupsPerPage sets. It returns the
+// results and how many candidates it went through.
+func (s *Service) fillPage(ctx context.Context, q *SearchQuery, candidates []searchCandidate) ([]SearchResult, int) {
+	results := []SearchResult{}
+	examined := 0
+	for examined < len(candidates) && examined < searchLookupsPerPage && len(results) < searchPageSize {
+		if ctx.Err() != nil {
+			break
+		}
+		n := min(searchPageSize-len(results), len(candidates)-examined, searchLookupsPerPage-examined)
+		batch := candidates[examined : examined+n]
+		ids := make([]int, len(batch))
+		for i, c := range batch {
+			ids[i] = c.SetID
+		}
+
+		for i, set := range s.loadSets(ctx, ids) {
+			if set == nil {
+				continue
+			}
+			if result, ok := q.result(batch[i], set); ok {
+				results = append(results, result)
+			}
+		}
+		examined += n
+	}
+	return results, examined
+}
+
+// result checks a candidate against the mirror's data on its set.
+func (q *SearchQuery) result(c searchCandidate, set *models.BeatmapSet) (SearchResult, bool) {
+	if q.Creator != "" && !strings.EqualFold(set.Creator, q.Creator) {
+		return SearchResult{}, false
+	}
+	hits := make(map[int]searchHit, len(c.Hits))
+	for _, hit := range c.Hits {
+		hits[hit.ID] = hit
+	}
+
+	result := SearchResult{Set: *set}
+	result.Set.ChildrenBeatmaps = nil
+	for _, bm := range set.SortedDifficulties() {
+		hit, ok := hits[bm.ID]
+		if !ok || !q.matches(bm) {
+			continue
+		}
+		if len(result.Beatmaps) == 0 {
+			result.Status = hit.Status
+		}
+		result.Beatmaps = append(result.Beatmaps, bm)
+		result.Playcount += hit.Playcount
+		result.Updated = max(result.Updated, hit.Updated)
+	}
+	return result, len(result.Beatmaps) > 0
+}
+
+func (s *Service) search(ctx context.Context, q *SearchQuery) (*searchPool, error) {
+	ranked := -1
+	if q.Status != "" {
+		ranked = models.ServerStatus(SearchStatuses[q.Status])
+	}
+
+	var found []api.Beatmap
+	capped := false
+	for page := 1; page <= searchPoolPages; page++ {
+		beatmaps, err := s.api.SearchBeatmaps(ctx, q.Query, q.Mode, ranked, page, searchPoolLimit)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, beatmaps...)
+		if len(beatmaps) < searchPoolLimit {
+			break
+		}
+		// A full last page means the API may have more.
+		capped = page == searchPoolPages
+	}
+
+	candidates := []searchCandidate{}
+	index := make(map[int]int)
+	for _, bm := range found {
+		if !q.mayMatch(bm) {
+			continue
+		}
+		i, ok := index[bm.BeatmapsetID]
+		if !ok {
+			i = len(candidates)
+			index[bm.BeatmapsetID] = i
+			candidates = append(candidates, searchCandidate{SetID: bm.BeatmapsetID, Status: models.StatusFromServer(bm.Ranked)})
+		}
+		c := &candidates[i]
+		hit := searchHit{
+			ID:        bm.BeatmapID,
+			Mode:      bm.Mode,
+			Status:    models.StatusFromServer(bm.Ranked),
+			Playcount: bm.Playcount,
+			Updated:   parseUpdated(bm.LatestUpdate),
+			Stars:     bm.DifficultyStd,
+		}
+		c.Hits = append(c.Hits, hit)
+		c.Playcount += hit.Playcount
+		c.Updated = max(c.Updated, hit.Updated)
+		c.Stars = max(c.Stars, hit.Stars)
+	}
+
+	// Relevance is the API's own order, which candidates are already in.
+	if q.Sort != SortRelevance {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			a, b := candidates[i], candidates[j]
+			return q.after(sortKey(q.Sort, b, j), b.SetID, sortKey(q.Sort, a, i), a.SetID)
+		})
+	}
+	return &searchPool{Candidates: candidates, Capped: capped}, nil
+}
+
+// loadSets looks up the sets from the mirror, in order. A set the mirror
+// doesn't have or fails to send is left nil, so one bad set doesn't lose the
+// page. Nothing more is asked for once ctx is done.
+func (s *Service) loadSets(ctx context.Context, setIDs []int) []*models.BeatmapSet {
+	sets := make([]*models.BeatmapSet, len(setIDs))
+	sem := make(chan struct{}, searchLoaders)
+	var wg sync.WaitGroup
+	for i, id := range setIDs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i, id int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			set, err := s.GetBeatmapSet(ctx, id)
+			if err != nil {
+				if svcErr, ok := err.(*services.ServiceError); !ok || svcErr.Code != "not_found" {
+					slog.Warn("skipping beatmap set in search", "error", err, "set_id", id)
+				}
+				return
+			}
+			sets[i] = set
+		}(i, id)
+	}
+	wg.Wait()
+	return sets
+}
+
+// mayMatch narrows the pool with what the API knows about a difficulty. The
+// API only has an osu!standard star rating and whole BPMs, so those are
+// checked loosely, and everything is checked again against the mirror.
+func (q *SearchQuery) mayMatch(bm api.Beatmap) bool {
+	bpm := float64(bm.BPM)
+	if !q.BPM.contains(bpm) && !q.BPM.contains(bpm+1) && !q.BPM.contains(bpm-1) {
+		return false
+	}
+	if bm.Mode == 0 && !q.Stars.contains(bm.DifficultyStd) {
+		return false
+	}
+	return q.AR.contains(bm.AR) && q.OD.contains(bm.OD)
+}
+
+// checkedOnMirror reports whether the search has filters mayMatch can't
+// check exactly.
+func (q *SearchQuery) checkedOnMirror() bool {
+	if q.Creator != "" {
+		return true
+	}
+	for _, r := range q.ranges() {
+		if *r != (Range{}) {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *SearchQuery) matches(bm models.Beatmap) bool {
+	return q.Stars.contains(bm.DifficultyRating) &&
+		q.BPM.contains(bm.BPM) &&
+		q.Length.contains(float64(bm.TotalLength)) &&
+		q.AR.contains(float64(bm.AR)) &&
+		q.OD.contains(float64(bm.OD)) &&
+		q.CS.contains(float64(bm.CS)) &&
+		q.HP.contains(float64(bm.HP))
+}
+
+// sortKey is what candidates are ordered by. Relevance is the position in
+// the API's order. Difficulty uses the API's osu!standard star rating, the
+// only one it has.
+func sortKey(sortBy string, c searchCandidate, index int) float64 {
+	switch sortBy {
+	case SortPlays:
+		return float64(c.Playcount)
+	case SortDate:
+		return float64(c.Updated)
+	case SortDifficulty:
+		return c.Stars
+	}
+	return float64(index)
+}
+
+// after reports whether a result comes after another in the query's order.
+// Ties are broken by set ID so every result has a single place.
+func (q *SearchQuery) after(key float64, setID int, afterKey float64, afterSetID int) bool {
+	if key != afterKey {
+		if q.Asc || q.Sort == SortRelevance {
+			return key > afterKey
+		}
+		return key < afterKey
+	}
+	return setID > afterSetID
+}
+
+func encodeCursor(key float64, setID int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatFloat(key, 'g', -1, 64) + ":" + strconv.Itoa(setID)))
+}
+
+func decodeCursor(cursor string) (float64, int, error) {
+	invalid := services.NewBadRequest("That page of results doesn't exist. Try searching again.")
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, 0, invalid
+	}
+	keyPart, idPart, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return 0, 0, invalid
+	}
+	key, err := strconv.ParseFloat(keyPart, 64)
+	if err != nil {
+		return 0, 0, invalid
+	}
+	setID, err := strconv.Atoi(idPart)
+	if err != nil {
+		return 0, 0, invalid
+	}
+	return key, setID, nil
+}
+
+// parseUpdated reads the API's last update time, which is 0 if it can't.
+func parseUpdated(s string) int64 {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Unix()
+		}
+	}
+	return 0
+}
@@ -0,0 +1,278 @@
+package betakeys
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/RealistikOsu/soumetsu/internal/config"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/pkg/crypto"
+	"github.com/RealistikOsu/soumetsu/internal/repositories"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/settings"
+)
+
+const (
+	keyLength     = 16
+	maxNoteLength = 255
+	maxUses       = 10000
+
+	// inviteTTL is how long a personal invite stays valid.
+	inviteTTL = 30 * 24 * time.Hour
+
+	// InvitePrivilege is what a user needs to hand out personal invites.
+	InvitePrivilege = models.UserPrivilegeDonor
+
+	PageSize = 50
+)
+
+type CreateInput struct {
+	MaxUses int
+	// ExpiresAt is zero for a key that never expires.
+	ExpiresAt time.Time
+	Note      string
+}
+
+type Service struct {
+	config   *config.Config
+	repo     *repositories.RegistrationKeyRepository
+	settings *settings.Service
+}
+
+func NewService(cfg *config.Config, repo *repositories.RegistrationKeyRepository, settingsService *settings.Service) *Service {
+	return &Service{config: cfg, repo: repo, settings: settingsService}
+}
+
+// InviteOnly reports whether registering needs a key. Errors are logged and
+// treated as invite-only, so a settings outage doesn't open registration.
+func (s *Service) InviteOnly(ctx context.Context) bool {
+	v, err := s.settings.GetInt(ctx, settings.KeyInviteOnly)
+	if err != nil {
+		slog.Error("failed to load invite-only setting", "error", err)
+		return true
+	}
+	return v != 0
+}
+
+func (s *Service) SetInviteOnly(ctx context.Context, on bool) error {
+	var v int64
+	if on {
+		v = 1
+	}
+	return s.settings.SetInt(ctx, settings.KeyInviteOnly, v)
+}
+
+// Create adds a staff key.
+func (s *Service) Create(ctx context.Context, staffID int, input CreateInput) (*models.RegistrationKey, error) {
+	note := strings.TrimSpace(input.Note)
+	if utf8.RuneCountInString(note) > maxNoteLength {
+		return nil, services.NewBadRequest(fmt.Sprintf("The note can be at most %d characters.", maxNoteLength))
+	}
+	if input.MaxUses < 1 || input.MaxUses > maxUses {
+		return nil, services.NewBadRequest(fmt.Sprintf("A key can be used between 1 and %d times.", maxUses))
+	}
+	var expiresAt int64
+	if !input.ExpiresAt.IsZero() {
+		if !input.ExpiresAt.After(time.Now()) {
+			return nil, services.NewBadRequest("The expiry has to be in the future.")
+		}
+		expiresAt = input.ExpiresAt.Unix()
+	}
+
+	return s.create(ctx, &models.RegistrationKey{
+		MaxUses:   input.MaxUses,
+		ExpiresAt: expiresAt,
+		Note:      note,
+		CreatedBy: staffID,
+	})
+}
+
+// InviteAllowance is how many personal invites a user may have, which is zero
+// unless they have InvitePrivilege.
+func (s *Service) InviteAllowance(privileges models.UserPrivileges) int {
+	if privileges&InvitePrivilege == 0 {
+		return 0
+	}
+	return s.config.Security.InvitesPerUser
+}
+
+// Invites lists a user's personal invites and how many more they may create.
+func (s *Service) Invites(ctx context.Context, userID int, privileges models.UserPrivileges) ([]models.RegistrationKey, int, error) {
+	invites, err := s.repo.ListByOwner(ctx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	used, err := s.repo.CountByOwner(ctx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return invites, max(s.InviteAllowance(privileges)-used, 0), nil
+}
+
+// CreateInvite generates a single-use personal invite.
+func (s *Service) CreateInvite(ctx context.Context, userID int, privileges models.UserPrivileges) (*models.RegistrationKey, error) {
+	allowance := s.InviteAllowance(privileges)
+	if allowance == 0 {
+		return nil, services.NewForbidden("Only supporters can invite people.")
+	}
+	k := &models.RegistrationKey{
+		MaxUses:   1,
+		ExpiresAt: time.Now().Add(inviteTTL).Unix(),
+		CreatedBy: userID,
+		OwnerID:   userID,
+	}
+	if err := fillKey(k); err != nil {
+		return nil, err
+	}
+	id, ok, err := s.repo.CreateOwned(ctx, k, allowance)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, services.NewForbidden(fmt.Sprintf("You can have at most %d invites.", allowance))
+	}
+	k.ID = id
+	return k, nil
+}
+
+// ExpireInvites revokes personal invites that expired unused, so they stop
+// counting against their owner's allowance.
+func (s *Service) ExpireInvites(ctx context.Context) error {
+	n, err := s.repo.RevokeExpiredInvites(ctx, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		slog.Info("revoked expired invites", "count", n)
+	}
+	return nil
+}
+
+// RevokeInvite revokes one of a user's own unused invites, which frees the
+// slot.
+func (s *Service) RevokeInvite(ctx context.Context, userID int, id int64) error {
+	k, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if k == nil || k.OwnerID != userID {
+		return services.NewNotFound("Invite not found.")
+	}
+	if k.Uses > 0 {
+		return services.NewBadRequest("Someone has already registered with that invite.")
+	}
+	return s.repo.Revoke(ctx, id)
+}
+
+func (s *Service) List(ctx context.Context, withPersonal bool, page int) ([]models.RegistrationKey, bool, error) {
+	if page < 1 {
+		page = 1
+	}
+	keys, err := s.repo.List(ctx, withPersonal, PageSize+1, (page-1)*PageSize)
+	if err != nil {
+		return nil, false, err
+	}
+	hasNext := len(keys) > PageSize
+	if hasNext {
+		keys = keys[:PageSize]
+	}
+	return keys, hasNext, nil
+}
+
+// Key returns a key and the accounts registered with it.
+func (s *Service) Key(ctx context.Context, id int64) (*models.RegistrationKey, []models.RegistrationKeyUse, error) {
+	k, err := s.findKey(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	uses, err := s.repo.ListUses(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return k, uses, nil
+}
+
+func (s *Service) Revoke(ctx context.Context, id int64) error {
+	if _, err := s.findKey(ctx, id); err != nil {
+		return err
+	}
+	return s.repo.Revoke(ctx, id)
+}
+
+// UseForUser returns the key an account registered with, or nil.
+func (s *Service) UseForUser(ctx context.Context, userID int) (*models.RegistrationKeyUse, error) {
+	return s.repo.UseForUser(ctx, userID)
+}
+
+// Reserve takes a use of key for a registration in progress. Callers must
+// Release it if the registration fails, or Redeem it once it succeeds.
+func (s *Service) Reserve(ctx context.Context, key string) (*models.RegistrationKey, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, services.NewBadRequest("Registration is invite-only at the moment. Please enter your beta key.")
+	}
+	k, err := s.repo.FindByKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if k == nil {
+		return nil, services.NewBadRequest("That beta key doesn't exist.")
+	}
+
+	ok, err := s.repo.Reserve(ctx, k.ID, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, services.NewBadRequest("That beta key has expired or has already been used.")
+	}
+	return k, nil
+}
+
+func (s *Service) Release(ctx context.Context, k *models.RegistrationKey) {
+	if err := s.repo.Release(ctx, k.ID); err != nil {
+		slog.Error("failed to release beta key", "error", err, "key_id", k.ID)
+	}
+}
+
+// Redeem records that userID registered with k.
+func (s *Service) Redeem(ctx context.Context, k *models.RegistrationKey, userID int, ip string) error {
+	return s.repo.RecordUse(ctx, k.ID, userID, time.Now().Unix(), ip)
+}
+
+func (s *Service) create(ctx context.Context, k *models.RegistrationKey) (*models.RegistrationKey, error) {
+	err := fillKey(k)
+	if err != nil {
+		return nil, err
+	}
+	if k.ID, err = s.repo.Create(ctx, k); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// fillKey gives a new key its code and creation time.
+func fillKey(k *models.RegistrationKey) error {
+	key, err := crypto.GenerateRandomString(keyLength)
+	if err != nil {
+		return err
+	}
+	k.Key = key
+	k.CreatedAt = time.Now().Unix()
+	return nil
+}
+
+func (s *Service) findKey(ctx context.Context, id int64) (*models.RegistrationKey, error) {
+	k, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if k == nil {
+		return nil, services.NewNotFound("Key not found.")
+	}
+	return k, nil
+}
@@ -0,0 +1,316 @@
+package clanadmin
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/redis"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/pkg/validation"
+	"github.com/RealistikOsu/soumetsu/internal/repositories"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/webhooks"
+)
+
+const (
+	maxResults    = 50
+	recentActions = 50
+
+	maxReasonLength = 255
+
+	// clanUpdateChannel tells the server to refresh a user's clan, as
+	// auth.Service.PublishClanUpdate does.
+	clanUpdateChannel = "rosu:clan_update"
+)
+
+// Overview is everything staff see on the admin page for one clan.
+type Overview struct {
+	Clan    *models.Clan
+	Members []models.ClanMemberUser
+	Actions []models.AdminAction
+}
+
+type Service struct {
+	clans    *repositories.ClanRepository
+	adminLog *repositories.AdminLogRepository
+	redis    *redis.Client
+	webhooks *webhooks.Service
+}
+
+func NewService(
+	clans *repositories.ClanRepository,
+	adminLog *repositories.AdminLogRepository,
+	redisClient *redis.Client,
+	webhooksService *webhooks.Service,
+) *Service {
+	return &Service{
+		clans:    clans,
+		adminLog: adminLog,
+		redis:    redisClient,
+		webhooks: webhooksService,
+	}
+}
+
+// Search looks a query up as a clan ID, then as a name or tag prefix.
+func (s *Service) Search(ctx context.Context, query string) ([]models.Clan, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, services.NewBadRequest("Please enter something to search for.")
+	}
+
+	var clans []models.Clan
+	if id, err := strconv.Atoi(query); err == nil {
+		clan, err := s.clans.FindByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if clan != nil {
+			clans = append(clans, *clan)
+		}
+	}
+
+	matches, err := s.clans.Search(ctx, query, maxResults)
+	if err != nil {
+		return nil, err
+	}
+	for _, clan := range matches {
+		if len(clans) > 0 && clans[0].ID == clan.ID {
+			continue
+		}
+		clans = append(clans, clan)
+	}
+	return clans, nil
+}
+
+func (s *Service) Overview(ctx context.Context, id int) (*Overview, error) {
+	clan, err := s.findClan(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Overview{Clan: clan}
+	if o.Members, err = s.clans.ListMembers(ctx, id); err != nil {
+		return nil, err
+	}
+	if o.Actions, err = s.adminLog.ListForTarget(ctx, models.AdminTargetClan, id, recentActions); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Rename force-changes a clan's name and tag.
+func (s *Service) Rename(ctx context.Context, staffID, id int, name, tag string) error {
+	clan, err := s.findClan(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	name = strings.TrimSpace(name)
+	tag = strings.TrimSpace(tag)
+	if !validation.ValidateClanName(name) {
+		return services.NewBadRequest("Clan names are 2 to 15 characters of letters, numbers, spaces or '_[]-.")
+	}
+	if !validation.ValidateClanTag(tag) {
+		return services.NewBadRequest("Clan tags are 2 to 6 letters or numbers.")
+	}
+	if name == clan.Name && tag == clan.Tag {
+		return services.NewBadRequest("Nothing has changed.")
+	}
+
+	// Names compare case-insensitively, so a case-only change would find the
+	// clan itself.
+	if !strings.EqualFold(name, clan.Name) {
+		taken, err := s.clans.NameExists(ctx, name)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return services.NewConflict("Another clan already has that name.")
+		}
+	}
+	taken, err := s.clans.TagExists(ctx, tag, id)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return services.NewConflict("Another clan already has that tag.")
+	}
+
+	if err := s.clans.Update(ctx, id, name, clan.Description, tag); err != nil {
+		return err
+	}
+	s.publishMembers(ctx, id)
+
+	detail := fmt.Sprintf("[%s] %s → [%s] %s", clan.Tag, clan.Name, tag, name)
+	return s.record(ctx, staffID, id, "rename", detail)
+}
+
+func (s *Service) RemoveIcon(ctx context.Context, staffID, id int, reason string) error {
+	if _, err := s.findClan(ctx, id); err != nil {
+		return err
+	}
+	reason, err := validateReason(reason)
+	if err != nil {
+		return err
+	}
+
+	if err := s.clans.RemoveIcon(ctx, id); err != nil {
+		return err
+	}
+	return s.record(ctx, staffID, id, "remove_icon", reason)
+}
+
+func (s *Service) Kick(ctx context.Context, staffID, id, userID int, reason string) error {
+	if _, err := s.findClan(ctx, id); err != nil {
+		return err
+	}
+	member, err := s.findMember(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	if member.IsOwner() {
+		return services.NewBadRequest("Transfer the clan to someone else before kicking its owner.")
+	}
+	reason, err = validateReason(reason)
+	if err != nil {
+		return err
+	}
+
+	if err := s.clans.RemoveMember(ctx, userID); err != nil {
+		return err
+	}
+	s.publish(ctx, userID)
+
+	return s.record(ctx, staffID, id, "kick", fmt.Sprintf("%s (%d): %s", member.Username, userID, reason))
+}
+
+// Transfer makes another member the owner.
+func (s *Service) Transfer(ctx context.Context, staffID, id, userID int) error {
+	if _, err := s.findClan(ctx, id); err != nil {
+		return err
+	}
+	member, err := s.findMember(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	if member.IsOwner() {
+		return services.NewBadRequest("They already own the clan.")
+	}
+
+	if err := s.clans.TransferOwnership(ctx, id, userID); err != nil {
+		return err
+	}
+	s.publishMembers(ctx, id)
+
+	return s.record(ctx, staffID, id, "transfer", fmt.Sprintf("to %s (%d)", member.Username, userID))
+}
+
+// Disband deletes the clan. Its log entries are kept.
+func (s *Service) Disband(ctx context.Context, staffID, id int, reason string) error {
+	clan, err := s.findClan(ctx, id)
+	if err != nil {
+		return err
+	}
+	reason, err = validateReason(reason)
+	if err != nil {
+		return err
+	}
+
+	// Look the members up first; they're gone once the clan is.
+	memberIDs, err := s.clans.GetAllMemberUserIDs(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.clans.Disband(ctx, id); err != nil {
+		return err
+	}
+	for _, userID := range memberIDs {
+		s.publish(ctx, userID)
+	}
+
+	s.webhooks.Dispatch(ctx, webhooks.Event{
+		Type:        webhooks.EventClanDisbanded,
+		Title:       fmt.Sprintf("[%s] %s was disbanded", clan.Tag, clan.Name),
+		Description: reason,
+		URL:         "/admin/clans/" + strconv.Itoa(id),
+		Fields: []webhooks.Field{
+			{Name: "Clan", Value: strconv.Itoa(id)},
+			{Name: "Members", Value: strconv.Itoa(len(memberIDs))},
+		},
+		ActorID: staffID,
+		Data: map[string]any{
+			"clan_id":    id,
+			"clan_name":  clan.Name,
+			"clan_tag":   clan.Tag,
+			"member_ids": memberIDs,
+			"reason":     reason,
+		},
+	})
+
+	return s.record(ctx, staffID, id, "disband", fmt.Sprintf("[%s] %s, %d members: %s", clan.Tag, clan.Name, len(memberIDs), reason))
+}
+
+// publishMembers refreshes every member's clan on the server.
+func (s *Service) publishMembers(ctx context.Context, id int) {
+	memberIDs, err := s.clans.GetAllMemberUserIDs(ctx, id)
+	if err != nil {
+		slog.Error("failed to list clan members for update", "error", err, "clan_id", id)
+		return
+	}
+	for _, userID := range memberIDs {
+		s.publish(ctx, userID)
+	}
+}
+
+func (s *Service) publish(ctx context.Context, userID int) {
+	if err := s.redis.Publish(ctx, clanUpdateChannel, strconv.Itoa(userID)); err != nil {
+		slog.Error("failed to publish clan update", "error", err, "user_id", userID)
+	}
+}
+
+func (s *Service) record(ctx context.Context, staffID, id int, action, detail string) error {
+	return s.adminLog.Record(ctx, &models.AdminAction{
+		StaffID:    staffID,
+		TargetType: models.AdminTargetClan,
+		TargetID:   id,
+		Action:     action,
+		Detail:     detail,
+		CreatedAt:  time.Now().Unix(),
+	})
+}
+
+func (s *Service) findClan(ctx context.Context, id int) (*models.Clan, error) {
+	clan, err := s.clans.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if clan == nil {
+		return nil, services.NewNotFound("Clan not found.")
+	}
+	return clan, nil
+}
+
+func (s *Service) findMember(ctx context.Context, id, userID int) (*models.ClanMemberUser, error) {
+	members, err := s.clans.ListMembers(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for i := range members {
+		if members[i].UserID == userID {
+			return &members[i], nil
+		}
+	}
+	return nil, services.NewNotFound("They aren't in this clan.")
+}
+
+func validateReason(reason string) (string, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" || len(reason) > maxReasonLength {
+		return "", services.NewBadRequest(fmt.Sprintf("Please give a reason of at most %d characters.", maxReasonLength))
+	}
+	return reason, nil
+}
@@ -0,0 +1,404 @@
+package collections
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/pkg/collectiondb"
+	"github.com/RealistikOsu/soumetsu/internal/repositories"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/beatmap"
+)
+
+const (
+	maxCollections    = 100
+	maxItems          = 5000
+	maxNameLength     = 64
+	favouritesPerPage = 48
+
+	// MaxImportSize fits maxCollections full collections with room to spare.
+	MaxImportSize = 32 << 20
+)
+
+var md5Pattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+type Service struct {
+	collections *repositories.CollectionRepository
+	users       *repositories.UserRepository
+	beatmaps    *beatmap.Service
+}
+
+func NewService(collections *repositories.CollectionRepository, users *repositories.UserRepository, beatmaps *beatmap.Service) *Service {
+	return &Service{collections: collections, users: users, beatmaps: beatmaps}
+}
+
+// User resolves a profile URL parameter, either a user ID or a username.
+// Restricted users are only found by themselves.
+func (s *Service) User(ctx context.Context, param string, viewerID int) (*models.User, error) {
+	var user *models.User
+	var err error
+	if id, convErr := strconv.Atoi(param); convErr == nil {
+		user, err = s.users.FindByID(ctx, id)
+	} else {
+		user, err = s.users.FindByUsername(ctx, param)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || (user.Privileges&models.UserPrivilegePublic == 0 && user.ID != viewerID) {
+		return nil, services.NewNotFound("User not found.")
+	}
+	return user, nil
+}
+
+func (s *Service) Favourite(ctx context.Context, userID, setID int) error {
+	set, err := s.beatmaps.GetBeatmapSet(ctx, setID)
+	if err != nil {
+		return err
+	}
+	return s.collections.AddFavourite(ctx, &models.BeatmapFavourite{
+		UserID:       userID,
+		BeatmapsetID: set.ID,
+		SongName:     set.Artist + " - " + set.Title,
+		Creator:      set.Creator,
+		CreatedAt:    time.Now().Unix(),
+	})
+}
+
+func (s *Service) Unfavourite(ctx context.Context, userID, setID int) error {
+	return s.collections.RemoveFavourite(ctx, userID, setID)
+}
+
+// FavouriteState is how many users favourited the set, and whether userID
+// is one of them.
+func (s *Service) FavouriteState(ctx context.Context, userID, setID int) (int, bool, error) {
+	count, err := s.collections.CountFavourites(ctx, setID)
+	if err != nil {
+		return 0, false, err
+	}
+	if userID == 0 {
+		return count, false, nil
+	}
+	favourited, err := s.collections.IsFavourite(ctx, userID, setID)
+	return count, favourited, err
+}
+
+// Favourites returns a page of a user's favourites, newest first, and
+// whether there's a next page.
+func (s *Service) Favourites(ctx context.Context, userID, page int) ([]models.BeatmapFavourite, bool, error) {
+	favourites, err := s.collections.ListFavourites(ctx, userID, (page-1)*favouritesPerPage, favouritesPerPage+1)
+	if err != nil {
+		return nil, false, err
+	}
+	hasNext := len(favourites) > favouritesPerPage
+	if hasNext {
+		favourites = favourites[:favouritesPerPage]
+	}
+	return favourites, hasNext, nil
+}
+
+// List returns the user's collections that viewerID may see.
+func (s *Service) List(ctx context.Context, userID, viewerID int) ([]models.BeatmapCollection, error) {
+	return s.collections.ListForUser(ctx, userID, userID == viewerID)
+}
+
+// Get returns a collection and its difficulties. Private collections are
+// only found by their owner.
+func (s *Service) Get(ctx context.Context, id int64, viewerID int) (*models.BeatmapCollection, []models.BeatmapCollectionItem, error) {
+	c, err := s.visible(ctx, id, viewerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	items, err := s.collections.ListItems(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, items, nil
+}
+
+func (s *Service) Create(ctx context.Context, userID int, name string, public bool) (*models.BeatmapCollection, error) {
+	name, err := validateName(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkName(ctx, userID, 0, name); err != nil {
+		return nil, err
+	}
+	return s.create(ctx, userID, name, public)
+}
+
+func (s *Service) create(ctx context.Context, userID int, name string, public bool) (*models.BeatmapCollection, error) {
+	count, err := s.collections.CountForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxCollections {
+		return nil, services.NewBadRequest(fmt.Sprintf("You can have up to %d collections.", maxCollections))
+	}
+
+	c := &models.BeatmapCollection{
+		UserID:    userID,
+		Name:      name,
+		Public:    public,
+		CreatedAt: time.Now().Unix(),
+	}
+	c.UpdatedAt = c.CreatedAt
+	id, err := s.collections.Create(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	c.ID = id
+	return c, nil
+}
+
+func (s *Service) Update(ctx context.Context, userID int, id int64, name string, public bool) error {
+	if _, err := s.owned(ctx, id, userID); err != nil {
+		return err
+	}
+	name, err := validateName(name)
+	if err != nil {
+		return err
+	}
+	if err := s.checkName(ctx, userID, id, name); err != nil {
+		return err
+	}
+	return s.collections.Update(ctx, id, name, public, time.Now().Unix())
+}
+
+func (s *Service) Delete(ctx context.Context, userID int, id int64) error {
+	if _, err := s.owned(ctx, id, userID); err != nil {
+		return err
+	}
+	return s.collections.Delete(ctx, id)
+}
+
+// AddBeatmap adds a difficulty to one of the user's collections, returning
+// the collection and the difficulty so the caller can link back to them.
+func (s *Service) AddBeatmap(ctx context.Context, userID int, id int64, beatmapID int) (*models.BeatmapCollection, *models.Beatmap, error) {
+	c, err := s.owned(ctx, id, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.Items >= maxItems {
+		return nil, nil, services.NewBadRequest(fmt.Sprintf("A collection can hold up to %d beatmaps.", maxItems))
+	}
+	bm, set, err := s.beatmaps.GetBeatmapWithSet(ctx, beatmapID)
+	if err != nil {
+		return nil, nil, err
+	}
+	md5 := strings.ToLower(bm.FileMD5)
+	if !md5Pattern.MatchString(md5) {
+		return nil, nil, services.NewUnavailable("This beatmap can't be added to collections right now.")
+	}
+
+	_, err = s.collections.AddItems(ctx, id, []models.BeatmapCollectionItem{{
+		BeatmapMD5:   md5,
+		BeatmapID:    bm.ID,
+		BeatmapsetID: set.ID,
+		SongName:     fmt.Sprintf("%s - %s [%s]", set.Artist, set.Title, bm.DiffName),
+	}}, time.Now().Unix())
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, bm, nil
+}
+
+func (s *Service) RemoveBeatmap(ctx context.Context, userID int, id int64, md5 string) error {
+	if _, err := s.owned(ctx, id, userID); err != nil {
+		return err
+	}
+	return s.collections.RemoveItem(ctx, id, strings.ToLower(md5), time.Now().Unix())
+}
+
+// Export writes one collection as a collection.db.
+func (s *Service) Export(ctx context.Context, id int64, viewerID int) (*models.BeatmapCollection, []byte, error) {
+	c, items, err := s.Get(ctx, id, viewerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := encode([]collectiondb.Collection{toFile(c, items)})
+	return c, data, err
+}
+
+// ExportAll writes every collection of the user's that viewerID may see as
+// one collection.db.
+func (s *Service) ExportAll(ctx context.Context, userID, viewerID int) ([]byte, error) {
+	collections, err := s.List(ctx, userID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]collectiondb.Collection, 0, len(collections))
+	for i := range collections {
+		items, err := s.collections.ListItems(ctx, collections[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, toFile(&collections[i], items))
+	}
+	return encode(files)
+}
+
+// ImportResult counts what an import changed.
+type ImportResult struct {
+	Created  int
+	Merged   int
+	Beatmaps int
+	// Skipped counts collections left out for being over the limit.
+	Skipped int
+}
+
+// Import reads a collection.db into the user's collections. Ones named like
+// an existing collection are merged into it; new ones start out private.
+func (s *Service) Import(ctx context.Context, userID int, r io.Reader) (*ImportResult, error) {
+	files, err := collectiondb.Read(r)
+	if err != nil {
+		if errors.Is(err, collectiondb.ErrMalformed) {
+			return nil, services.NewBadRequest("That doesn't look like an osu! collection.db file.")
+		}
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, services.NewBadRequest("That collection.db has no collections in it.")
+	}
+
+	result := &ImportResult{}
+	for _, f := range files {
+		name := importName(f.Name)
+		c, err := s.collections.FindByName(ctx, userID, name)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			c, err = s.create(ctx, userID, name, false)
+			if err != nil {
+				var svcErr *services.ServiceError
+				if errors.As(err, &svcErr) {
+					result.Skipped++
+					continue
+				}
+				return nil, err
+			}
+			result.Created++
+		} else {
+			result.Merged++
+		}
+
+		items := importItems(f.MD5s, maxItems-c.Items)
+		if len(items) == 0 {
+			continue
+		}
+		if err := s.collections.ResolveItems(ctx, items); err != nil {
+			return nil, err
+		}
+		added, err := s.collections.AddItems(ctx, c.ID, items, time.Now().Unix())
+		if err != nil {
+			return nil, err
+		}
+		result.Beatmaps += added
+	}
+	return result, nil
+}
+
+// visible finds a collection viewerID may see.
+func (s *Service) visible(ctx context.Context, id int64, viewerID int) (*models.BeatmapCollection, error) {
+	c, err := s.collections.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil || (!c.Public && c.UserID != viewerID) {
+		return nil, services.NewNotFound("Collection not found.")
+	}
+	return c, nil
+}
+
+// owned finds one of userID's own collections.
+func (s *Service) owned(ctx context.Context, id int64, userID int) (*models.BeatmapCollection, error) {
+	c, err := s.collections.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil || c.UserID != userID {
+		return nil, services.NewNotFound("Collection not found.")
+	}
+	return c, nil
+}
+
+// checkName makes sure no other collection of the user's, than id, has the
+// name.
+func (s *Service) checkName(ctx context.Context, userID int, id int64, name string) error {
+	existing, err := s.collections.FindByName(ctx, userID, name)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != id {
+		return services.NewConflict("You already have a collection with that name.")
+	}
+	return nil
+}
+
+func validateName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", services.NewBadRequest("Please give the collection a name.")
+	}
+	if utf8.RuneCountInString(name) > maxNameLength {
+		return "", services.NewBadRequest(fmt.Sprintf("Collection names can be up to %d characters long.", maxNameLength))
+	}
+	return name, nil
+}
+
+// importName makes an imported collection's name fit, rather than refusing
+// the file over it.
+func importName(name string) string {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > maxNameLength {
+		name = strings.TrimSpace(string([]rune(name)[:maxNameLength]))
+	}
+	if name == "" {
+		name = "Imported"
+	}
+	return name
+}
+
+// importItems keeps up to limit distinct, well-formed MD5s.
+func importItems(md5s []string, limit int) []models.BeatmapCollectionItem {
+	seen := make(map[string]bool, len(md5s))
+	var items []models.BeatmapCollectionItem
+	for _, md5 := range md5s {
+		if len(items) >= limit {
+			break
+		}
+		md5 = strings.ToLower(md5)
+		if !md5Pattern.MatchString(md5) || seen[md5] {
+			continue
+		}
+		seen[md5] = true
+		items = append(items, models.BeatmapCollectionItem{BeatmapMD5: md5})
+	}
+	return items
+}
+
+func toFile(c *models.BeatmapCollection, items []models.BeatmapCollectionItem) collectiondb.Collection {
+	f := collectiondb.Collection{Name: c.Name, MD5s: make([]string, 0, len(items))}
+	for _, item := range items {
+		f.MD5s = append(f.MD5s, item.BeatmapMD5)
+	}
+	return f
+}
+
+func encode(files []collectiondb.Collection) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := collectiondb.Write(&buf, files); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,328 @@
+package docs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/pkg/textdiff"
+	"github.com/RealistikOsu/soumetsu/internal/repositories"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+)
+
+const (
+	maxTitleLength   = 255
+	maxSummaryLength = 255
+	maxBodyLength    = 200000
+
+	// cacheTTL bounds how long a publish takes to reach other replicas. The
+	// public routes and the 404 fallback read the cache on every hit.
+	cacheTTL = 15 * time.Second
+)
+
+var slugRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+type SaveInput struct {
+	Title   string
+	Body    string
+	Format  models.DocFormat
+	Summary string
+	Publish bool
+}
+
+type Diff struct {
+	Revision *models.DocRevision
+	// Previous is nil when Revision is the first one.
+	Previous *models.DocRevision
+	Lines    []textdiff.Line
+}
+
+type Service struct {
+	repo *repositories.DocRepository
+
+	mu        sync.RWMutex
+	published map[string]models.PublishedDoc
+	cachedAt  time.Time
+}
+
+func NewService(repo *repositories.DocRepository) *Service {
+	return &Service{repo: repo}
+}
+
+// Published returns the live page for slug. Errors are logged and treated as
+// a miss so a database hiccup falls through to the normal 404.
+func (s *Service) Published(ctx context.Context, slug string) (*models.PublishedDoc, bool) {
+	all, err := s.publishedDocs(ctx)
+	if err != nil {
+		slog.Error("failed to load docs", "error", err)
+		return nil, false
+	}
+	doc, ok := all[slug]
+	if !ok {
+		return nil, false
+	}
+	return &doc, true
+}
+
+func (s *Service) ListPublished(ctx context.Context) ([]models.PublishedDoc, error) {
+	return s.repo.ListPublished(ctx)
+}
+
+func (s *Service) Pages(ctx context.Context) ([]models.DocPage, error) {
+	return s.repo.ListPages(ctx)
+}
+
+// Page returns a page, its history and its newest revision.
+func (s *Service) Page(ctx context.Context, id int64) (*models.DocPage, []models.DocRevision, *models.DocRevision, error) {
+	page, err := s.findPage(ctx, id)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	revisions, err := s.repo.ListRevisions(ctx, id)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	latest, err := s.repo.GetRevision(ctx, page.LatestRevisionID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return page, revisions, latest, nil
+}
+
+// Revision returns a single revision, or nil if it doesn't exist.
+func (s *Service) Revision(ctx context.Context, revID int64) (*models.DocRevision, error) {
+	return s.repo.GetRevision(ctx, revID)
+}
+
+// Create adds an empty, unpublished page.
+func (s *Service) Create(ctx context.Context, staffID int, slug, title string) (int64, error) {
+	return s.create(ctx, staffID, slug, &models.DocRevision{
+		Title:   title,
+		Format:  models.DocFormatMarkdown,
+		Summary: "Created the page.",
+	})
+}
+
+// Import creates an unpublished page from the rendered HTML of one of the old
+// template pages, so staff can review it before it takes over the route.
+func (s *Service) Import(ctx context.Context, staffID int, slug, title, html string) (int64, error) {
+	return s.create(ctx, staffID, slug, &models.DocRevision{
+		Title:   title,
+		Body:    html,
+		Format:  models.DocFormatHTML,
+		Summary: "Imported from the template page.",
+	})
+}
+
+// Save records a new revision when anything changed, and publishes the
+// newest revision when asked to.
+func (s *Service) Save(ctx context.Context, id int64, staffID int, input SaveInput) error {
+	page, err := s.findPage(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	rev := &models.DocRevision{
+		PageID:   id,
+		Title:    strings.TrimSpace(input.Title),
+		Body:     strings.ReplaceAll(input.Body, "\r\n", "\n"),
+		Format:   input.Format,
+		Summary:  strings.TrimSpace(input.Summary),
+		AuthorID: staffID,
+	}
+	if err := validateRevision(rev); err != nil {
+		return err
+	}
+
+	latest, err := s.repo.GetRevision(ctx, page.LatestRevisionID)
+	if err != nil {
+		return err
+	}
+	revID := page.LatestRevisionID
+	if latest == nil || latest.Title != rev.Title || latest.Body != rev.Body || latest.Format != rev.Format {
+		rev.CreatedAt = time.Now().Unix()
+		revID, err = s.repo.AddRevision(ctx, rev)
+		if err != nil {
+			return err
+		}
+	} else if !input.Publish {
+		return services.NewBadRequest("Nothing has changed since the last revision.")
+	}
+
+	if input.Publish {
+		return s.publish(ctx, page, revID)
+	}
+	return nil
+}
+
+// Publish makes revID the live version, which also serves to roll back.
+func (s *Service) Publish(ctx context.Context, id, revID int64) error {
+	page, err := s.findPage(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.publish(ctx, page, revID)
+}
+
+func (s *Service) Unpublish(ctx context.Context, id int64) error {
+	if _, err := s.findPage(ctx, id); err != nil {
+		return err
+	}
+	if err := s.repo.SetPublished(ctx, id, 0, time.Now().Unix()); err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+func (s *Service) Delete(ctx context.Context, id int64) error {
+	if _, err := s.findPage(ctx, id); err != nil {
+		return err
+	}
+	if err := s.repo.DeletePage(ctx, id); err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+// Diff compares a revision with the one saved before it.
+func (s *Service) Diff(ctx context.Context, id, revID int64) (*Diff, error) {
+	rev, err := s.findRevision(ctx, id, revID)
+	if err != nil {
+		return nil, err
+	}
+	prev, err := s.repo.PreviousRevision(ctx, id, revID)
+	if err != nil {
+		return nil, err
+	}
+
+	var before string
+	if prev != nil {
+		before = prev.Body
+	}
+	return &Diff{Revision: rev, Previous: prev, Lines: textdiff.Lines(before, rev.Body)}, nil
+}
+
+func (s *Service) create(ctx context.Context, staffID int, slug string, rev *models.DocRevision) (int64, error) {
+	slug = strings.ToLower(strings.TrimSpace(slug))
+	if !slugRegex.MatchString(slug) {
+		return 0, services.NewBadRequest("Slugs may only contain lowercase letters, numbers and dashes, up to 64 characters.")
+	}
+	rev.Title = strings.TrimSpace(rev.Title)
+	rev.AuthorID = staffID
+	if err := validateRevision(rev); err != nil {
+		return 0, err
+	}
+
+	existing, err := s.repo.FindPageBySlug(ctx, slug)
+	if err != nil {
+		return 0, err
+	}
+	if existing != nil {
+		return 0, services.NewConflict("A page with that slug already exists.")
+	}
+
+	now := time.Now().Unix()
+	id, err := s.repo.CreatePage(ctx, slug, now)
+	if err != nil {
+		return 0, err
+	}
+	rev.PageID = id
+	rev.CreatedAt = now
+	if _, err := s.repo.AddRevision(ctx, rev); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (s *Service) publish(ctx context.Context, page *models.DocPage, revID int64) error {
+	rev, err := s.findRevision(ctx, page.ID, revID)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(rev.Body) == "" {
+		return services.NewBadRequest("An empty page can't be published.")
+	}
+	if err := s.repo.SetPublished(ctx, page.ID, revID, time.Now().Unix()); err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+func (s *Service) findPage(ctx context.Context, id int64) (*models.DocPage, error) {
+	page, err := s.repo.FindPageByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if page == nil {
+		return nil, services.NewNotFound("Page not found.")
+	}
+	return page, nil
+}
+
+func (s *Service) findRevision(ctx context.Context, pageID, revID int64) (*models.DocRevision, error) {
+	rev, err := s.repo.GetRevision(ctx, revID)
+	if err != nil {
+		return nil, err
+	}
+	if rev == nil || rev.PageID != pageID {
+		return nil, services.NewNotFound("Revision not found.")
+	}
+	return rev, nil
+}
+
+func (s *Service) publishedDocs(ctx context.Context) (map[string]models.PublishedDoc, error) {
+	s.mu.RLock()
+	if s.published != nil && time.Since(s.cachedAt) < cacheTTL {
+		published := s.published
+		s.mu.RUnlock()
+		return published, nil
+	}
+	s.mu.RUnlock()
+
+	docs, err := s.repo.ListPublished(ctx)
+	if err != nil {
+		return nil, err
+	}
+	published := make(map[string]models.PublishedDoc, len(docs))
+	for _, doc := range docs {
+		published[doc.Slug] = doc
+	}
+
+	s.mu.Lock()
+	s.published = published
+	s.cachedAt = time.Now()
+	s.mu.Unlock()
+
+	return published, nil
+}
+
+func (s *Service) invalidate() {
+	s.mu.Lock()
+	s.published = nil
+	s.mu.Unlock()
+}
+
+func validateRevision(rev *models.DocRevision) error {
+	if rev.Title == "" || utf8.RuneCountInString(rev.Title) > maxTitleLength {
+		return services.NewBadRequest(fmt.Sprintf("The title must be between 1 and %d characters.", maxTitleLength))
+	}
+	if len(rev.Body) > maxBodyLength {
+		return services.NewBadRequest("The page is too long.")
+	}
+	if utf8.RuneCountInString(rev.Summary) > maxSummaryLength {
+		return services.NewBadRequest(fmt.Sprintf("The edit summary can be at most %d characters.", maxSummaryLength))
+	}
+	if !rev.Format.Valid() {
+		return services.NewBadRequest("Please pick a format.")
+	}
+	return nil
+}
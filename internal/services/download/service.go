@@ -0,0 +1,310 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/mirror"
+	"github.com/RealistikOsu/soumetsu/internal/adapters/redis"
+	"github.com/RealistikOsu/soumetsu/internal/config"
+	"github.com/RealistikOsu/soumetsu/internal/pkg/diskcache"
+	"github.com/RealistikOsu/soumetsu/internal/pkg/flight"
+	"github.com/RealistikOsu/soumetsu/internal/repositories"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+)
+
+const limitWindow = time.Hour
+
+// maxCachedSize bounds a single cached .osz, however big the mirror says it
+// is. Sets with a video are rarely more than a few hundred megabytes.
+const maxCachedSize = 1 << 30
+
+// File is a download ready to send. A cached file has Content set and can be
+// served with any range; otherwise the mirror's response is passed on.
+type File struct {
+	Content *os.File
+	ModTime time.Time
+
+	Status int
+	Header http.Header
+	Body   io.ReadCloser
+}
+
+func (f *File) Close() error {
+	if f.Content != nil {
+		return f.Content.Close()
+	}
+	return f.Body.Close()
+}
+
+type Service struct {
+	config      *config.Config
+	downloader  *mirror.Downloader
+	cache       *diskcache.Cache
+	beatmapRepo *repositories.BeatmapRepository
+	redis       *redis.Client
+
+	// filling claims the cache keys being written, so concurrent misses on
+	// one set don't all write it.
+	filling flight.Group[struct{}]
+}
+
+// NewService opens the download cache when proxying is turned on.
+func NewService(
+	cfg *config.Config,
+	downloader *mirror.Downloader,
+	beatmapRepo *repositories.BeatmapRepository,
+	redis *redis.Client,
+) (*Service, error) {
+	s := &Service{
+		config:      cfg,
+		downloader:  downloader,
+		beatmapRepo: beatmapRepo,
+		redis:       redis,
+	}
+	if cfg.Beatmap.DownloadProxy {
+		cache, err := diskcache.New(cfg.Beatmap.DownloadCacheDir, int64(cfg.Beatmap.DownloadCacheMB)<<20)
+		if err != nil {
+			return nil, err
+		}
+		s.cache = cache
+	}
+	return s, nil
+}
+
+// Proxying reports whether downloads are streamed through this server rather
+// than redirected to the download mirror.
+func (s *Service) Proxying() bool {
+	return s.cache != nil
+}
+
+// Allow counts a download of the set against the hourly limits for the user,
+// if logged in, and the IP, and reports whether it's a new download. A resume
+// of a download the same user or IP started in the last hour is free and
+// isn't new; any other resume counts like a fresh download.
+func (s *Service) Allow(ctx context.Context, userID int, ip string, setID int, resume bool) (bool, error) {
+	who := "ip:" + ip
+	if userID != 0 {
+		who = "user:" + strconv.Itoa(userID)
+	}
+	startedKey := "soumetsu:downloads:started:" + who + ":" + strconv.Itoa(setID)
+	if resume {
+		started, err := s.redis.Exists(ctx, startedKey)
+		if err != nil {
+			return false, err
+		}
+		if started {
+			return false, nil
+		}
+	}
+
+	window := strconv.FormatInt(time.Now().Unix()/int64(limitWindow/time.Second), 10)
+	if userID != 0 {
+		if err := s.take(ctx, "soumetsu:downloads:user:"+strconv.Itoa(userID)+":"+window, s.config.Beatmap.DownloadsPerUser); err != nil {
+			return false, err
+		}
+	}
+	if err := s.take(ctx, "soumetsu:downloads:ip:"+ip+":"+window, s.config.Beatmap.DownloadsPerIP); err != nil {
+		return false, err
+	}
+	if err := s.redis.Set(ctx, startedKey, 1, limitWindow); err != nil {
+		slog.Error("failed to remember beatmap download", "error", err, "set_id", setID)
+	}
+	return true, nil
+}
+
+func (s *Service) take(ctx context.Context, key string, limit int) error {
+	if limit <= 0 {
+		return nil
+	}
+	n, err := s.redis.Incr(ctx, key)
+	if err != nil {
+		return err
+	}
+	if n == 1 {
+		if err := s.redis.Expire(ctx, key, limitWindow); err != nil {
+			return err
+		}
+	}
+	if n > int64(limit) {
+		return services.NewServiceError("You've downloaded a lot of beatmaps in the last hour. Please wait a while before downloading more.", "rate_limited", http.StatusTooManyRequests)
+	}
+	return nil
+}
+
+// Record adds one to the set's download count. Failures are only logged.
+func (s *Service) Record(ctx context.Context, setID int) {
+	if err := s.beatmapRepo.RecordDownload(ctx, setID, time.Now().Unix()); err != nil {
+		slog.Error("failed to record beatmap download", "error", err, "set_id", setID)
+	}
+}
+
+func (s *Service) Count(ctx context.Context, setID int) (int, error) {
+	return s.beatmapRepo.GetDownloadCount(ctx, setID)
+}
+
+// Open returns the set's .osz from the cache, or streams it from the first
+// mirror that has it. rangeHeader is passed on to the mirror. A whole file
+// from a mirror is cached as it's read.
+func (s *Service) Open(ctx context.Context, setID int, noVideo bool, rangeHeader string) (*File, error) {
+	key := cacheKey(setID, noVideo)
+	if file, err := s.Cached(setID, noVideo); file != nil || err != nil {
+		return file, err
+	}
+
+	dl, err := s.downloader.Download(ctx, setID, noVideo, rangeHeader)
+	if err != nil {
+		return nil, downloadError(err)
+	}
+
+	file := &File{Status: dl.Status, Header: dl.Header, Body: dl.Body}
+	if dl.Status != http.StatusOK {
+		return file, nil
+	}
+	size, err := strconv.ParseInt(dl.Header.Get("Content-Length"), 10, 64)
+	if err != nil || size <= 0 {
+		size = maxCachedSize
+	}
+	if size > maxCachedSize {
+		return file, nil
+	}
+	if release, ok := s.filling.Claim(key); ok {
+		w, err := s.cache.Create(key, size)
+		if err != nil {
+			release()
+			slog.Error("failed to start caching beatmap download", "error", err, "set_id", setID)
+		} else {
+			file.Body = &filler{
+				body:     dl.Body,
+				cache:    w,
+				expected: dl.Header.Get("Content-Length"),
+				done:     release,
+			}
+		}
+	}
+	return file, nil
+}
+
+// Head returns what a GET of the set's .osz would get, without the body: the
+// cached file, or the status and headers a mirror answers a one-byte range
+// request with. A ranged answer is turned into that of the whole file.
+func (s *Service) Head(ctx context.Context, setID int, noVideo bool) (*File, error) {
+	if file, err := s.Cached(setID, noVideo); file != nil || err != nil {
+		return file, err
+	}
+
+	dl, err := s.downloader.Download(ctx, setID, noVideo, "bytes=0-0")
+	if err != nil {
+		return nil, downloadError(err)
+	}
+	dl.Body.Close()
+
+	header := http.Header{}
+	for _, name := range []string{"Content-Length", "Accept-Ranges", "Last-Modified", "ETag"} {
+		if v := dl.Header.Get(name); v != "" {
+			header.Set(name, v)
+		}
+	}
+	status := dl.Status
+	switch status {
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Only an empty file has no first byte.
+		status = http.StatusOK
+		header.Set("Content-Length", "0")
+	case http.StatusPartialContent:
+		status = http.StatusOK
+		header.Set("Accept-Ranges", "bytes")
+		header.Del("Content-Length")
+		// Content-Range is "bytes 0-0/<size>", with * for an unknown size.
+		if _, size, ok := strings.Cut(dl.Header.Get("Content-Range"), "/"); ok && size != "*" {
+			header.Set("Content-Length", size)
+		}
+	}
+	return &File{Status: status, Header: header, Body: http.NoBody}, nil
+}
+
+func downloadError(err error) error {
+	var notFound *mirror.NotFoundError
+	if errors.As(err, &notFound) {
+		return services.NewNotFound("Beatmap set not found.")
+	}
+	var unavailable *mirror.UnavailableError
+	if errors.As(err, &unavailable) {
+		return services.NewUnavailable("None of the download mirrors are responding right now. Please try again in a bit.")
+	}
+	return err
+}
+
+// Cached returns the set's .osz if it's in the cache, and nil if it isn't.
+// It never goes to a mirror.
+func (s *Service) Cached(setID int, noVideo bool) (*File, error) {
+	f, err := s.cache.Open(cacheKey(setID, noVideo))
+	if err != nil {
+		return nil, nil
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &File{Content: f, ModTime: info.ModTime()}, nil
+}
+
+func cacheKey(setID int, noVideo bool) string {
+	if noVideo {
+		return strconv.Itoa(setID) + "-novideo.osz"
+	}
+	return strconv.Itoa(setID) + ".osz"
+}
+
+// filler copies a mirror's response into the cache as it's read, and keeps
+// it only if all of it was.
+type filler struct {
+	body     io.ReadCloser
+	cache    *diskcache.Writer
+	expected string
+	read     int64
+	complete bool
+	done     func()
+}
+
+func (f *filler) Read(p []byte) (int, error) {
+	n, err := f.body.Read(p)
+	if n > 0 && f.cache != nil {
+		if _, werr := f.cache.Write(p[:n]); werr != nil {
+			if errors.Is(werr, diskcache.ErrTooLarge) {
+				slog.Warn("beatmap download is too large to cache", "read", f.read+int64(n), "expected", f.expected)
+			} else {
+				slog.Error("failed to cache beatmap download", "error", werr)
+			}
+			f.cache.Abort()
+			f.cache = nil
+		}
+	}
+	f.read += int64(n)
+	if err == io.EOF {
+		f.complete = true
+	}
+	return n, err
+}
+
+func (f *filler) Close() error {
+	defer f.done()
+	if f.cache != nil {
+		if f.complete && (f.expected == "" || f.expected == strconv.FormatInt(f.read, 10)) {
+			if err := f.cache.Commit(); err != nil {
+				slog.Error("failed to cache beatmap download", "error", err)
+			}
+		} else {
+			f.cache.Abort()
+		}
+	}
+	return f.body.Close()
+}
@@ -43,6 +43,12 @@ var (
 		StatusCode: http.StatusInternalServerError,
 	}
 
+	ErrUnavailable = &ServiceError{
+		Message:    "Service unavailable",
+		Code:       "unavailable",
+		StatusCode: http.StatusServiceUnavailable,
+	}
+
 	ErrConflict = &ServiceError{
 		Message:    "Resource already exists",
 		Code:       "conflict",
@@ -89,3 +95,11 @@ func NewConflict(message string) *ServiceError {
 		StatusCode: http.StatusConflict,
 	}
 }
+
+func NewUnavailable(message string) *ServiceError {
+	return &ServiceError{
+		Message:    message,
+		Code:       "unavailable",
+		StatusCode: http.StatusServiceUnavailable,
+	}
+}
@@ -0,0 +1,354 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/mirror"
+	"github.com/RealistikOsu/soumetsu/internal/adapters/redis"
+	"github.com/RealistikOsu/soumetsu/internal/config"
+	"github.com/RealistikOsu/soumetsu/internal/pkg/diskcache"
+	"github.com/RealistikOsu/soumetsu/internal/pkg/flight"
+	"github.com/RealistikOsu/soumetsu/internal/pkg/resize"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/beatmap"
+)
+
+// placeholderPath is scaled to each size for sets without artwork, or when
+// the cover servers are down.
+const placeholderPath = "web/static/headers/default.jpg"
+
+const jpegQuality = 85
+
+// maxSourcePixels bounds the artwork decoded from the cover servers. Real
+// covers are 1800x500 at most; anything far bigger isn't decoded at all.
+const maxSourcePixels = 4096 * 4096
+
+// Artwork or a preview the asset servers don't have, or sent in a form that
+// can't be decoded, isn't asked for again until its miss expires. Outages
+// aren't remembered; the mirror breakers already deal with those.
+const (
+	missKeyPrefix = "soumetsu:media:miss:"
+	notFoundTTL   = 10 * time.Minute
+	badAssetTTL   = 2 * time.Minute
+)
+
+// badAssetError is a cover the asset servers sent that can't be used.
+type badAssetError struct {
+	err error
+}
+
+func (e *badAssetError) Error() string {
+	return e.err.Error()
+}
+
+func (e *badAssetError) Unwrap() error {
+	return e.err
+}
+
+// Image is a size set artwork is served at. Wide sizes are cut from the
+// set's cover and square ones from its list thumbnail.
+type Image struct {
+	Name   string
+	Width  int
+	Height int
+	source string
+}
+
+var (
+	Cover     = Image{"cover", 900, 250, "cover@2x.jpg"}
+	Card      = Image{"card", 400, 140, "cover@2x.jpg"}
+	Thumbnail = Image{"thumb", 150, 150, "list@2x.jpg"}
+)
+
+// CoverPath is where the set's Cover is served, relative to the site.
+func CoverPath(setID int) string {
+	return "/beatmapsets/" + strconv.Itoa(setID) + "/cover.jpg"
+}
+
+// File is artwork or a preview ready to send. It must be closed once sent.
+type File struct {
+	Content io.ReadSeeker
+	ModTime time.Time
+	ETag    string
+
+	close func() error
+}
+
+func (f *File) Close() error {
+	if f.close != nil {
+		return f.close()
+	}
+	return nil
+}
+
+// memoryFile serves content that isn't read from the cache.
+func memoryFile(key string, content []byte, modTime time.Time) *File {
+	return &File{
+		Content: bytes.NewReader(content),
+		ModTime: modTime,
+		ETag:    etag(key, int64(len(content)), modTime),
+	}
+}
+
+// etag tells versions of a file apart by where it's cached, its size and
+// when it was written, so it's known without reading the file.
+func etag(key string, size int64, modTime time.Time) string {
+	sum := sha256.Sum256([]byte(key + ":" + strconv.FormatInt(size, 10) + ":" + strconv.FormatInt(modTime.UnixNano(), 10)))
+	return fmt.Sprintf(`"%x"`, sum[:12])
+}
+
+// placeholder is a placeholder image, made once per size.
+type placeholder struct {
+	content []byte
+	madeAt  time.Time
+}
+
+type Service struct {
+	assets   *mirror.Assets
+	beatmaps *beatmap.Service
+	cache    *diskcache.Cache
+	redis    *redis.Client
+
+	// loading makes concurrent misses on one key wait for a single fetch
+	// rather than all making their own.
+	loading flight.Group[[]byte]
+
+	mu           sync.Mutex
+	placeholders map[string]placeholder
+}
+
+func NewService(cfg *config.Config, assets *mirror.Assets, beatmaps *beatmap.Service, redisClient *redis.Client) (*Service, error) {
+	cache, err := diskcache.New(cfg.Beatmap.AssetCacheDir, int64(cfg.Beatmap.AssetCacheMB)<<20)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{
+		assets:       assets,
+		beatmaps:     beatmaps,
+		cache:        cache,
+		redis:        redisClient,
+		placeholders: make(map[string]placeholder),
+	}, nil
+}
+
+// Image returns the set's artwork at the given size, scaling it down from
+// the cover server's copy the first time it's asked for.
+func (s *Service) Image(ctx context.Context, setID int, img Image) (*File, error) {
+	key := strconv.Itoa(setID) + "-" + img.Name + ".jpg"
+	return s.cached(ctx, key, func(ctx context.Context) ([]byte, error) {
+		raw, err := s.assets.Cover(ctx, setID, img.source)
+		if err != nil {
+			return nil, assetError(err, "This beatmap set has no artwork.")
+		}
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(raw))
+		if err != nil {
+			return nil, &badAssetError{fmt.Errorf("decoding %s of set %d: %w", img.source, setID, err)}
+		}
+		if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxSourcePixels {
+			return nil, &badAssetError{fmt.Errorf("%s of set %d is %dx%d, too big to decode", img.source, setID, cfg.Width, cfg.Height)}
+		}
+		src, _, err := image.Decode(bytes.NewReader(raw))
+		if err != nil {
+			return nil, &badAssetError{fmt.Errorf("decoding %s of set %d: %w", img.source, setID, err)}
+		}
+		return encode(resize.Fill(src, img.Width, img.Height))
+	})
+}
+
+// Preview returns the audio preview of the set a difficulty belongs to.
+func (s *Service) Preview(ctx context.Context, beatmapID int) (*File, error) {
+	bm, err := s.beatmaps.GetBeatmap(ctx, beatmapID)
+	if err != nil {
+		return nil, err
+	}
+	return s.SetPreview(ctx, bm.ParentSetID)
+}
+
+func (s *Service) SetPreview(ctx context.Context, setID int) (*File, error) {
+	key := strconv.Itoa(setID) + "-preview.mp3"
+	return s.cached(ctx, key, func(ctx context.Context) ([]byte, error) {
+		content, err := s.assets.Preview(ctx, setID)
+		if err != nil {
+			return nil, assetError(err, "This beatmap set has no preview.")
+		}
+		return content, nil
+	})
+}
+
+// Placeholder stands in for artwork that couldn't be served.
+func (s *Service) Placeholder(img Image) *File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := "placeholder-" + img.Name + ".jpg"
+	if p, ok := s.placeholders[img.Name]; ok {
+		return memoryFile(key, p.content, p.madeAt)
+	}
+	content, err := makePlaceholder(img)
+	if err != nil {
+		slog.Error("failed to make beatmap artwork placeholder", "error", err, "size", img.Name)
+		plain := image.NewRGBA(image.Rect(0, 0, img.Width, img.Height))
+		draw.Draw(plain, plain.Bounds(), image.NewUniform(color.RGBA{0x0f, 0x17, 0x2a, 0xff}), image.Point{}, draw.Src)
+		content, _ = encode(plain)
+	}
+	p := placeholder{content: content, madeAt: time.Now()}
+	s.placeholders[img.Name] = p
+	return memoryFile(key, p.content, p.madeAt)
+}
+
+func makePlaceholder(img Image) ([]byte, error) {
+	f, err := os.Open(placeholderPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	src, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	return encode(resize.Fill(src, img.Width, img.Height))
+}
+
+// cached serves key from the disk cache, or loads and caches it. The load
+// carries on if the visitor who started it leaves, as others may be waiting.
+// A recent miss is answered without loading.
+func (s *Service) cached(ctx context.Context, key string, fetch func(context.Context) ([]byte, error)) (*File, error) {
+	if file := s.open(key); file != nil {
+		return file, nil
+	}
+	if err := s.recentMiss(ctx, key); err != nil {
+		return nil, err
+	}
+
+	content, err := s.loading.Do(ctx, key, func(ctx context.Context) ([]byte, error) {
+		content, err := fetch(ctx)
+		if err != nil {
+			s.rememberMiss(ctx, key, err)
+			return nil, err
+		}
+		s.store(key, content)
+		return content, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Serve the cached copy when there is one, so its ETag is the one later
+	// requests get.
+	if file := s.open(key); file != nil {
+		return file, nil
+	}
+	return memoryFile(key, content, time.Now()), nil
+}
+
+// recentMiss returns the error key last failed with, if it's remembered.
+func (s *Service) recentMiss(ctx context.Context, key string) error {
+	data, err := s.redis.Get(ctx, missKeyPrefix+key)
+	if err != nil {
+		if err != redis.Nil {
+			slog.Warn("failed to read beatmap asset miss", "error", err, "key", key)
+		}
+		return nil
+	}
+	var miss services.ServiceError
+	if err := json.Unmarshal([]byte(data), &miss); err != nil {
+		slog.Warn("discarding unreadable beatmap asset miss", "error", err, "key", key)
+		return nil
+	}
+	return &miss
+}
+
+// rememberMiss stores what a not-found or unusable asset should be answered
+// with until its miss expires.
+func (s *Service) rememberMiss(ctx context.Context, key string, err error) {
+	var miss *services.ServiceError
+	ttl := notFoundTTL
+	var badAsset *badAssetError
+	switch {
+	case errors.As(err, &badAsset):
+		miss = services.NewServiceError("The beatmap artwork couldn't be read.", "bad_asset", http.StatusBadGateway)
+		ttl = badAssetTTL
+	case errors.As(err, &miss) && miss.StatusCode == http.StatusNotFound:
+	default:
+		return
+	}
+	data, err := json.Marshal(miss)
+	if err != nil {
+		return
+	}
+	if err := s.redis.Set(ctx, missKeyPrefix+key, data, ttl); err != nil {
+		slog.Warn("failed to remember beatmap asset miss", "error", err, "key", key)
+	}
+}
+
+// open returns key from the disk cache, or nil if it isn't there.
+func (s *Service) open(key string) *File {
+	f, err := s.cache.Open(key)
+	if err != nil {
+		return nil
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		slog.Warn("failed to read cached beatmap asset", "error", err, "key", key)
+		return nil
+	}
+	return &File{
+		Content: f,
+		ModTime: info.ModTime(),
+		ETag:    etag(key, info.Size(), info.ModTime()),
+		close:   f.Close,
+	}
+}
+
+func (s *Service) store(key string, content []byte) {
+	w, err := s.cache.Create(key, int64(len(content)))
+	if err != nil {
+		slog.Error("failed to cache beatmap asset", "error", err, "key", key)
+		return
+	}
+	if _, err := w.Write(content); err != nil {
+		w.Abort()
+		slog.Error("failed to cache beatmap asset", "error", err, "key", key)
+		return
+	}
+	if err := w.Commit(); err != nil {
+		slog.Error("failed to cache beatmap asset", "error", err, "key", key)
+	}
+}
+
+func encode(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func assetError(err error, notFound string) error {
+	var notFoundErr *mirror.NotFoundError
+	if errors.As(err, &notFoundErr) {
+		return services.NewNotFound(notFound)
+	}
+	var unavailable *mirror.UnavailableError
+	if errors.As(err, &unavailable) {
+		return services.NewUnavailable("The beatmap asset servers aren't responding right now.")
+	}
+	return err
+}
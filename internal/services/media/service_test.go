@@ -0,0 +1,131 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/mirror"
+	"github.com/RealistikOsu/soumetsu/internal/adapters/redis"
+	"github.com/RealistikOsu/soumetsu/internal/config"
+	"github.com/RealistikOsu/soumetsu/internal/pkg/diskcache"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+)
+
+// newTestService serves set 1 as missing, set 2 as a cover that isn't an
+// image and set 3 as a server error, counting requests for each path.
+func newTestService(t *testing.T) (*Service, *miniredis.Miniredis, func(path string) int) {
+	t.Helper()
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/2/covers/cover@2x.jpg":
+			w.Write([]byte("not an image"))
+		case "/3/covers/cover@2x.jpg":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	mr := miniredis.RunT(t)
+	port, err := strconv.Atoi(mr.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := redis.New(config.RedisConfig{Host: mr.Host(), Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	cache, err := diskcache.New(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Service{
+		assets:       mirror.NewAssets([]string{srv.URL}, []string{srv.URL}),
+		cache:        cache,
+		redis:        client,
+		placeholders: make(map[string]placeholder),
+	}
+	return s, mr, func(path string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return hits[path]
+	}
+}
+
+func TestMissesAreRemembered(t *testing.T) {
+	s, mr, hits := newTestService(t)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		setID  int
+		path   string
+		status int
+	}{
+		{1, "/1/covers/cover@2x.jpg", http.StatusNotFound},
+		{2, "/2/covers/cover@2x.jpg", http.StatusBadGateway},
+	} {
+		for i := 0; i < 3; i++ {
+			_, err := s.Image(ctx, tc.setID, Cover)
+			var svcErr *services.ServiceError
+			if i == 0 && tc.status == http.StatusBadGateway {
+				// The first failure is the decode error itself.
+				var badAsset *badAssetError
+				if !errors.As(err, &badAsset) {
+					t.Errorf("set %d: err = %v, want a bad asset", tc.setID, err)
+				}
+				continue
+			}
+			if !errors.As(err, &svcErr) || svcErr.StatusCode != tc.status {
+				t.Errorf("set %d, call %d: err = %v, want status %d", tc.setID, i, err, tc.status)
+			}
+		}
+		if n := hits(tc.path); n != 1 {
+			t.Errorf("set %d: asset server asked %d times, want once", tc.setID, n)
+		}
+	}
+
+	// Bad assets are forgotten first, then missing ones.
+	mr.FastForward(badAssetTTL)
+	s.Image(ctx, 1, Cover)
+	s.Image(ctx, 2, Cover)
+	if n := hits("/1/covers/cover@2x.jpg"); n != 1 {
+		t.Errorf("missing cover asked for %d times before expiring, want 1", n)
+	}
+	if n := hits("/2/covers/cover@2x.jpg"); n != 2 {
+		t.Errorf("bad cover asked for %d times after expiring, want 2", n)
+	}
+	mr.FastForward(notFoundTTL)
+	s.Image(ctx, 1, Cover)
+	if n := hits("/1/covers/cover@2x.jpg"); n != 2 {
+		t.Errorf("missing cover asked for %d times after expiring, want 2", n)
+	}
+}
+
+func TestOutagesAreNotRemembered(t *testing.T) {
+	s, _, hits := newTestService(t)
+	for i := 0; i < 2; i++ {
+		_, err := s.Image(context.Background(), 3, Cover)
+		var svcErr *services.ServiceError
+		if !errors.As(err, &svcErr) || svcErr.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("err = %v, want unavailable", err)
+		}
+	}
+	if n := hits("/3/covers/cover@2x.jpg"); n != 2 {
+		t.Errorf("asset server asked %d times, want 2", n)
+	}
+}
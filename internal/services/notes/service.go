@@ -0,0 +1,166 @@
+package notes
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/repositories"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+)
+
+const maxBodyLength = 5000
+
+// Panel is the list of notes shown alongside a user, clan or beatmap set.
+type Panel struct {
+	TargetType models.AdminTarget
+	TargetID   int
+	Notes      []models.StaffNote
+}
+
+func (p *Panel) AdminURL() string {
+	return p.TargetType.AdminURL(p.TargetID)
+}
+
+type Service struct {
+	notes *repositories.StaffNoteRepository
+	users *repositories.UserRepository
+}
+
+func NewService(notes *repositories.StaffNoteRepository, users *repositories.UserRepository) *Service {
+	return &Service{notes: notes, users: users}
+}
+
+func (s *Service) Panel(ctx context.Context, targetType models.AdminTarget, targetID int) (*Panel, error) {
+	notes, err := s.notes.ListForTarget(ctx, targetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+	return &Panel{TargetType: targetType, TargetID: targetID, Notes: notes}, nil
+}
+
+// UserPanel is Panel for a profile URL parameter, which is either a user ID
+// or a username. It returns nil when no such user exists.
+func (s *Service) UserPanel(ctx context.Context, param string) (*Panel, error) {
+	id, err := strconv.Atoi(param)
+	if err != nil {
+		user, err := s.users.FindByUsername(ctx, param)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, nil
+		}
+		id = user.ID
+	}
+	return s.Panel(ctx, models.AdminTargetUser, id)
+}
+
+func (s *Service) Add(ctx context.Context, staffID int, targetType models.AdminTarget, targetID int, body string, pinned bool) (*models.StaffNote, error) {
+	if !targetType.Valid() || targetID < 1 {
+		return nil, services.NewBadRequest("Notes can only be left on users, clans and beatmap sets.")
+	}
+	body, err := validateBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	note := &models.StaffNote{
+		TargetType: targetType,
+		TargetID:   targetID,
+		Body:       body,
+		Pinned:     pinned,
+		AuthorID:   staffID,
+		CreatedAt:  time.Now().Unix(),
+	}
+	if note.ID, err = s.notes.Create(ctx, note); err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// Edit replaces a note's text. Earlier versions stay in its history.
+func (s *Service) Edit(ctx context.Context, staffID int, id int64, body string) (*models.StaffNote, error) {
+	note, err := s.findNote(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	body, err = validateBody(body)
+	if err != nil {
+		return nil, err
+	}
+	if body == note.Body {
+		return note, nil
+	}
+
+	if err := s.notes.Update(ctx, id, body, staffID, time.Now().Unix()); err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+func (s *Service) Pin(ctx context.Context, id int64, pinned bool) (*models.StaffNote, error) {
+	note, err := s.findNote(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.notes.SetPinned(ctx, id, pinned); err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// Delete removes a note and its history. Only its author or someone who can
+// manage users may delete it.
+func (s *Service) Delete(ctx context.Context, staffID int, staffPrivileges models.UserPrivileges, id int64) (*models.StaffNote, error) {
+	note, err := s.findNote(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if note.AuthorID != staffID && staffPrivileges&models.AdminPrivilegeManageUsers == 0 {
+		return nil, services.NewForbidden("Only the note's author can delete it.")
+	}
+	if err := s.notes.Delete(ctx, id); err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// History returns a note and all its versions, newest first.
+func (s *Service) History(ctx context.Context, id int64) (*models.StaffNote, []models.StaffNoteRevision, error) {
+	note, err := s.findNote(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	revisions, err := s.notes.ListRevisions(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return note, revisions, nil
+}
+
+func (s *Service) findNote(ctx context.Context, id int64) (*models.StaffNote, error) {
+	note, err := s.notes.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if note == nil {
+		return nil, services.NewNotFound("Note not found.")
+	}
+	return note, nil
+}
+
+func validateBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", services.NewBadRequest("The note can't be empty.")
+	}
+	if utf8.RuneCountInString(body) > maxBodyLength {
+		return "", services.NewBadRequest(fmt.Sprintf("Notes can be at most %d characters.", maxBodyLength))
+	}
+	return body, nil
+}
@@ -0,0 +1,366 @@
+package performance
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/beatmapcache"
+	"github.com/RealistikOsu/soumetsu/internal/adapters/mirror"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/pkg/osufile"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/beatmap"
+)
+
+// Mods lists the mods the calculator takes, in the order they're written.
+// Relax and autopilot aren't among them: the server's pp for those is its
+// own, and isn't reproduced here.
+var Mods = []string{"NF", "EZ", "TD", "HD", "HR", "SD", "PF", "DT", "NC", "HT", "SO"}
+
+// attributesVersion is part of the cache key, so attributes cached in an
+// older shape are never read back.
+const attributesVersion = "3"
+
+// conflicts are mods that can't be played together.
+var conflicts = [][2]string{
+	{"EZ", "HR"},
+	{"DT", "HT"},
+	{"NC", "HT"},
+	{"DT", "NC"},
+	{"NF", "SD"},
+	{"NF", "PF"},
+	{"SD", "PF"},
+}
+
+type Query struct {
+	BeatmapID int
+	// Mods are acronyms from Mods, in that order.
+	Mods []string
+	// Accuracy is a percentage. It's only used when Count100 and Count50
+	// aren't given.
+	Accuracy float64
+	Counts   bool
+	Count100 int
+	Count50  int
+	Misses   int
+	// Combo is the play's max combo. 0 means a full combo.
+	Combo int
+}
+
+// ParseQuery reads a calculation from query parameters: beatmap, mods (as
+// in "HDDT"), acc, n100, n50, misses and combo. Everything but the beatmap
+// is optional.
+func ParseQuery(v url.Values) (*Query, error) {
+	q := &Query{Accuracy: 100}
+
+	id, err := strconv.Atoi(strings.TrimSpace(v.Get("beatmap")))
+	if err != nil || id <= 0 {
+		return nil, services.NewBadRequest("Enter a beatmap ID.")
+	}
+	q.BeatmapID = id
+
+	// Mods can come as one value or, from the form's checkboxes, several.
+	if q.Mods, err = ParseMods(strings.Join(v["mods"], "")); err != nil {
+		return nil, err
+	}
+
+	if s := strings.TrimSpace(v.Get("acc")); s != "" {
+		acc, err := strconv.ParseFloat(s, 64)
+		if err != nil || acc < 0 || acc > 100 || math.IsNaN(acc) {
+			return nil, services.NewBadRequest("Accuracy must be between 0 and 100.")
+		}
+		q.Accuracy = acc
+	}
+
+	fields := []struct {
+		name string
+		dest *int
+	}{
+		{"n100", &q.Count100},
+		{"n50", &q.Count50},
+		{"misses", &q.Misses},
+		{"combo", &q.Combo},
+	}
+	for _, f := range fields {
+		s := strings.TrimSpace(v.Get(f.name))
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return nil, services.NewBadRequest("Hit counts and combo must be whole numbers of 0 or more.")
+		}
+		*f.dest = n
+	}
+	q.Counts = v.Get("n100") != "" || v.Get("n50") != ""
+	return q, nil
+}
+
+// ParseMods reads mod acronyms, run together or separated, into their
+// usual order.
+func ParseMods(s string) ([]string, error) {
+	s = strings.ToUpper(strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return -1
+	}, s))
+	if len(s)%2 != 0 {
+		return nil, services.NewBadRequest("Mods must be two-letter acronyms, like HDDT.")
+	}
+
+	set := map[string]bool{}
+	for i := 0; i < len(s); i += 2 {
+		acronym := s[i : i+2]
+		if acronym == "NM" {
+			continue
+		}
+		if !isMod(acronym) {
+			return nil, services.NewBadRequest(acronym + " isn't a mod the calculator supports.")
+		}
+		set[acronym] = true
+	}
+	for _, c := range conflicts {
+		if set[c[0]] && set[c[1]] {
+			return nil, services.NewBadRequest(c[0] + " and " + c[1] + " can't be played together.")
+		}
+	}
+
+	var mods []string
+	for _, m := range Mods {
+		if set[m] {
+			mods = append(mods, m)
+		}
+	}
+	return mods, nil
+}
+
+func isMod(acronym string) bool {
+	for _, m := range Mods {
+		if m == acronym {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *Query) has(mod string) bool {
+	for _, m := range q.Mods {
+		if m == mod {
+			return true
+		}
+	}
+	return false
+}
+
+// ModString is the mods as the game writes them, or NM for none.
+func (q *Query) ModString() string {
+	if len(q.Mods) == 0 {
+		return "NM"
+	}
+	return strings.Join(q.Mods, "")
+}
+
+// difficultyMods is the part of ModString that changes difficulty, which is
+// what attributes are cached by.
+func (q *Query) difficultyMods() string {
+	var b strings.Builder
+	for _, m := range []string{"EZ", "HR", "DT", "HT"} {
+		if q.has(m) || m == "DT" && q.has("NC") {
+			b.WriteString(m)
+		}
+	}
+	if b.Len() == 0 {
+		return "NM"
+	}
+	return b.String()
+}
+
+func (q *Query) mods() osufile.Mods {
+	m := osufile.Mods{
+		Easy:     q.has("EZ"),
+		HardRock: q.has("HR"),
+		Hidden:   q.has("HD"),
+		NoFail:   q.has("NF"),
+		SpunOut:  q.has("SO"),
+	}
+	switch {
+	case q.has("DT"), q.has("NC"):
+		m.SpeedChange = osufile.DoubleTimeSpeed
+	case q.has("HT"):
+		m.SpeedChange = osufile.HalfTimeSpeed
+	}
+	return m
+}
+
+// Value is what a play is worth in vanilla pp.
+type Value struct {
+	Stars    float64 `json:"stars"`
+	PP       float64 `json:"pp"`
+	Aim      float64 `json:"aim"`
+	Speed    float64 `json:"speed"`
+	Accuracy float64 `json:"accuracy"`
+}
+
+type Result struct {
+	Beatmap models.Beatmap    `json:"beatmap"`
+	Set     models.BeatmapSet `json:"set"`
+	Mods    string            `json:"mods"`
+
+	// AR and OD are as they play with the mods' speed.
+	AR       float64 `json:"ar"`
+	OD       float64 `json:"od"`
+	HP       float64 `json:"hp"`
+	CS       float64 `json:"cs"`
+	MaxCombo int     `json:"max_combo"`
+
+	// The play calculated for, with hit counts filled in from the
+	// accuracy if they weren't given.
+	Accuracy float64 `json:"accuracy"`
+	Count300 int     `json:"count_300"`
+	Count100 int     `json:"count_100"`
+	Count50  int     `json:"count_50"`
+	Misses   int     `json:"count_misses"`
+	Combo    int     `json:"combo"`
+
+	Vanilla Value `json:"vanilla"`
+}
+
+type Service struct {
+	beatmaps *beatmap.Service
+	files    *mirror.OsuFiles
+	cache    *beatmapcache.Cache
+}
+
+func NewService(beatmaps *beatmap.Service, files *mirror.OsuFiles, cache *beatmapcache.Cache) *Service {
+	return &Service{beatmaps: beatmaps, files: files, cache: cache}
+}
+
+// Calculate works out what a play is worth on an osu!standard difficulty.
+// Difficulty is calculated once per beatmap and set of mods that change it.
+func (s *Service) Calculate(ctx context.Context, q *Query) (*Result, error) {
+	bm, set, err := s.beatmaps.GetBeatmapWithSet(ctx, q.BeatmapID)
+	if err != nil {
+		return nil, err
+	}
+	if bm.Mode != osufile.ModeStandard {
+		return nil, services.NewBadRequest("The calculator only works for osu!standard beatmaps for now.")
+	}
+
+	attrs, err := beatmapcache.Get(ctx, s.cache, beatmapcache.DifficultyKey(bm.ID, attributesVersion+":"+q.difficultyMods()), func(ctx context.Context) (*osufile.Attributes, error) {
+		return s.calculate(ctx, bm.ID, q.mods())
+	}, func(*osufile.Attributes) beatmapcache.Entry {
+		return beatmapcache.Entry{Status: set.RankedStatus, MD5s: []string{bm.FileMD5}, BeatmapID: bm.ID}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	score, err := q.score(attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	setCopy := *set
+	setCopy.ChildrenBeatmaps = nil
+	return &Result{
+		Beatmap:  *bm,
+		Set:      setCopy,
+		Mods:     q.ModString(),
+		AR:       attrs.AR,
+		OD:       attrs.OD,
+		HP:       attrs.HP,
+		CS:       attrs.CS,
+		MaxCombo: attrs.MaxCombo,
+		Accuracy: score.Accuracy() * 100,
+		Count300: score.Count300,
+		Count100: score.Count100,
+		Count50:  score.Count50,
+		Misses:   score.Misses,
+		Combo:    score.Combo,
+		Vanilla:  value(attrs, q.mods(), score),
+	}, nil
+}
+
+func (s *Service) calculate(ctx context.Context, beatmapID int, mods osufile.Mods) (*osufile.Attributes, error) {
+	data, err := s.files.Get(ctx, beatmapID)
+	if err != nil {
+		return nil, osuFileError(err)
+	}
+	b, err := osufile.Parse(bytes.NewReader(data))
+	if err != nil {
+		slog.Warn("failed to parse .osu file", "error", err, "beatmap_id", beatmapID)
+		return nil, services.NewServiceError("That beatmap's file couldn't be read.", "unreadable_beatmap", http.StatusBadGateway)
+	}
+
+	attrs, err := b.Difficulty(mods)
+	if err != nil {
+		return nil, services.NewBadRequest("The calculator only works for osu!standard beatmaps for now.")
+	}
+	return attrs, nil
+}
+
+// score is the play the query describes on a difficulty with the given
+// attributes.
+func (q *Query) score(a *osufile.Attributes) (osufile.Score, error) {
+	objects := a.Circles + a.Sliders + a.Spinners
+	if q.Misses > objects {
+		return osufile.Score{}, services.NewBadRequest("There are more misses than objects in the beatmap.")
+	}
+
+	var score osufile.Score
+	if q.Counts {
+		score = osufile.Score{
+			Count300: objects - q.Count100 - q.Count50 - q.Misses,
+			Count100: q.Count100,
+			Count50:  q.Count50,
+			Misses:   q.Misses,
+		}
+		if score.Count300 < 0 {
+			return osufile.Score{}, services.NewBadRequest("There are more hits than objects in the beatmap.")
+		}
+	} else {
+		score = osufile.EstimateScore(objects, q.Accuracy/100, q.Misses)
+	}
+
+	score.Combo = q.Combo
+	if score.Combo == 0 || score.Combo > a.MaxCombo {
+		score.Combo = a.MaxCombo
+	}
+	// A miss breaks combo, so a play with misses can't reach the full
+	// combo.
+	if q.Combo == 0 && score.Misses > 0 {
+		score.Combo = max(0, a.MaxCombo-score.Misses)
+	}
+	return score, nil
+}
+
+func value(a *osufile.Attributes, mods osufile.Mods, score osufile.Score) Value {
+	p := a.Performance(mods, score)
+	return Value{
+		Stars:    a.StarRating,
+		PP:       p.PP,
+		Aim:      p.Aim,
+		Speed:    p.Speed,
+		Accuracy: p.Accuracy,
+	}
+}
+
+func osuFileError(err error) error {
+	var notFoundErr *mirror.NotFoundError
+	if errors.As(err, &notFoundErr) {
+		return services.NewNotFound("That beatmap's file couldn't be found.")
+	}
+	var unavailableErr *mirror.UnavailableError
+	if errors.As(err, &unavailableErr) {
+		return services.NewUnavailable("Beatmap files can't be fetched right now. Please try again in a bit.")
+	}
+	return err
+}
@@ -0,0 +1,86 @@
+package performance
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/RealistikOsu/soumetsu/internal/pkg/osufile"
+)
+
+func TestParseMods(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+		ok   bool
+	}{
+		{"", nil, true},
+		{"NM", nil, true},
+		{"dthd", []string{"HD", "DT"}, true},
+		{"HD,HR", []string{"HD", "HR"}, true},
+		{"RX", nil, false},
+		{"AP", nil, false},
+		{"EZHR", nil, false},
+		{"HDD", nil, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseMods(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseMods(%q) error = %v, want ok %v", tt.in, err, tt.ok)
+			continue
+		}
+		if tt.ok && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseMods(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestQueryScore(t *testing.T) {
+	attrs := &osufile.Attributes{Circles: 90, Sliders: 9, Spinners: 1, MaxCombo: 130}
+	tests := []struct {
+		params string
+		want   osufile.Score
+		ok     bool
+	}{
+		{"beatmap=1", osufile.Score{Count300: 100, Combo: 130}, true},
+		{"beatmap=1&n100=4&misses=2", osufile.Score{Count300: 94, Count100: 4, Misses: 2, Combo: 128}, true},
+		{"beatmap=1&misses=1&combo=50", osufile.Score{Count300: 99, Misses: 1, Combo: 50}, true},
+		{"beatmap=1&combo=500", osufile.Score{Count300: 100, Combo: 130}, true},
+		{"beatmap=1&misses=101", osufile.Score{}, false},
+		{"beatmap=1&n100=60&n50=50", osufile.Score{}, false},
+	}
+	for _, tt := range tests {
+		v, _ := url.ParseQuery(tt.params)
+		q, err := ParseQuery(v)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", tt.params, err)
+		}
+		got, err := q.score(attrs)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: score error = %v, want ok %v", tt.params, err, tt.ok)
+			continue
+		}
+		if tt.ok && got != tt.want {
+			t.Errorf("%s: score = %+v, want %+v", tt.params, got, tt.want)
+		}
+	}
+}
+
+func TestDifficultyMods(t *testing.T) {
+	tests := map[string]string{
+		"":       "NM",
+		"HD":     "NM",
+		"HDHRNC": "HRDT",
+		"EZHT":   "EZHT",
+	}
+	for in, want := range tests {
+		mods, err := ParseMods(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := &Query{Mods: mods}
+		if got := q.difficultyMods(); got != want {
+			t.Errorf("difficultyMods for %q = %q, want %q", in, got, want)
+		}
+	}
+}
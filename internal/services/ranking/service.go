@@ -0,0 +1,259 @@
+package ranking
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/redis"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/repositories"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/beatmap"
+	"github.com/RealistikOsu/soumetsu/internal/services/webhooks"
+)
+
+const (
+	maxReasonLength = 1000
+	logLimit        = 50
+
+	// refreshChannel asks the score server to reload a beatmap by MD5 so its
+	// leaderboard picks up the new status.
+	refreshChannel = "ussr:refresh_bmap"
+)
+
+// Difficulty is one row of the ranking console.
+type Difficulty struct {
+	models.Beatmap
+	// Status is the status on this server, which may differ from the mirror.
+	Status int
+	// Known is false when the score server has never loaded the difficulty,
+	// so there's no row to change yet.
+	Known bool
+}
+
+func (d Difficulty) StatusLabel() string {
+	return models.RankedStatusLabel(d.Status)
+}
+
+type Console struct {
+	Set          *models.BeatmapSet
+	Difficulties []Difficulty
+	Log          []models.BeatmapRankingLog
+}
+
+// Change sets Status on the given difficulties of a set, or on the whole set
+// when BeatmapIDs is empty.
+type Change struct {
+	SetID      int
+	BeatmapIDs []int
+	Status     int
+	Reason     string
+	UserID     int
+}
+
+type Result struct {
+	Changed int
+	// Unknown counts difficulties skipped because the score server hasn't
+	// loaded them yet.
+	Unknown int
+}
+
+type Service struct {
+	beatmapRepo *repositories.BeatmapRepository
+	beatmaps    *beatmap.Service
+	redis       *redis.Client
+	webhooks    *webhooks.Service
+}
+
+func NewService(
+	beatmapRepo *repositories.BeatmapRepository,
+	beatmaps *beatmap.Service,
+	redis *redis.Client,
+	webhooksService *webhooks.Service,
+) *Service {
+	return &Service{
+		beatmapRepo: beatmapRepo,
+		beatmaps:    beatmaps,
+		redis:       redis,
+		webhooks:    webhooksService,
+	}
+}
+
+// Load fetches a set from the mirror along with its status on this server and
+// its recent ranking history.
+func (s *Service) Load(ctx context.Context, setID int) (*Console, error) {
+	set, difficulties, err := s.load(ctx, setID)
+	if err != nil {
+		return nil, err
+	}
+
+	log, err := s.beatmapRepo.GetRankingLog(ctx, setID, logLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Console{Set: set, Difficulties: difficulties, Log: log}, nil
+}
+
+// FindSetID resolves a difficulty to the set it belongs to.
+func (s *Service) FindSetID(ctx context.Context, beatmapID int) (int, error) {
+	bm, err := s.beatmaps.GetBeatmap(ctx, beatmapID)
+	if err != nil {
+		return 0, err
+	}
+	return bm.ParentSetID, nil
+}
+
+// RecentLog returns the latest status changes across every set.
+func (s *Service) RecentLog(ctx context.Context) ([]models.BeatmapRankingLog, error) {
+	return s.beatmapRepo.GetRankingLog(ctx, 0, logLimit)
+}
+
+func (s *Service) SetStatus(ctx context.Context, change Change) (*Result, error) {
+	if !models.IsSettableStatus(change.Status) {
+		return nil, services.NewBadRequest("Please pick a status.")
+	}
+	reason := strings.TrimSpace(change.Reason)
+	if reason == "" {
+		return nil, services.NewBadRequest("Please give a reason for the change.")
+	}
+	if utf8.RuneCountInString(reason) > maxReasonLength {
+		return nil, services.NewBadRequest(fmt.Sprintf("The reason can be at most %d characters.", maxReasonLength))
+	}
+
+	set, difficulties, err := s.load(ctx, change.SetID)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := difficulties
+	if len(change.BeatmapIDs) > 0 {
+		byID := make(map[int]Difficulty, len(difficulties))
+		for _, d := range difficulties {
+			byID[d.ID] = d
+		}
+		selected = selected[:0:0]
+		for _, id := range change.BeatmapIDs {
+			d, ok := byID[id]
+			if !ok {
+				return nil, services.NewBadRequest("Beatmap " + strconv.Itoa(id) + " isn't part of this set.")
+			}
+			selected = append(selected, d)
+		}
+	}
+
+	result := &Result{}
+	now := time.Now().Unix()
+	var changes []models.BeatmapRankingLog
+	var refresh []string
+	for _, d := range selected {
+		if !d.Known {
+			result.Unknown++
+			continue
+		}
+		if d.Status == change.Status {
+			continue
+		}
+		changes = append(changes, models.BeatmapRankingLog{
+			BeatmapID:    d.ID,
+			BeatmapsetID: set.ID,
+			SongName:     set.Artist + " - " + set.Title + " [" + d.DiffName + "]",
+			OldStatus:    d.Status,
+			NewStatus:    change.Status,
+			Reason:       reason,
+			UserID:       change.UserID,
+			CreatedAt:    now,
+		})
+		refresh = append(refresh, d.FileMD5)
+	}
+
+	if err := s.beatmapRepo.ApplyRankedStatus(ctx, change.Status, changes); err != nil {
+		return nil, err
+	}
+	result.Changed = len(changes)
+
+	for _, md5 := range refresh {
+		if err := s.redis.Publish(ctx, refreshChannel, md5); err != nil {
+			slog.Error("failed to publish beatmap refresh", "error", err, "beatmap_md5", md5)
+		}
+	}
+
+	if len(changes) > 0 {
+		s.webhooks.Dispatch(ctx, statusEvent(set, changes, change.UserID, reason))
+	}
+
+	return result, nil
+}
+
+func statusEvent(set *models.BeatmapSet, changes []models.BeatmapRankingLog, userID int, reason string) webhooks.Event {
+	names := make(map[int]string, len(set.ChildrenBeatmaps))
+	for _, bm := range set.ChildrenBeatmaps {
+		names[bm.ID] = bm.DiffName
+	}
+	diffs := make([]string, 0, len(changes))
+	ids := make([]int, 0, len(changes))
+	for _, c := range changes {
+		diffs = append(diffs, names[c.BeatmapID]+" ("+models.RankedStatusLabel(c.OldStatus)+")")
+		ids = append(ids, c.BeatmapID)
+	}
+
+	status := changes[0].NewStatus
+	label := models.RankedStatusLabel(status)
+	return webhooks.Event{
+		Type:        webhooks.EventBeatmapStatus,
+		Title:       fmt.Sprintf("%s - %s is now %s", set.Artist, set.Title, label),
+		Description: reason,
+		URL:         "/admin/beatmaps/" + strconv.Itoa(set.ID),
+		Fields: []webhooks.Field{
+			{Name: "Set", Value: strconv.Itoa(set.ID)},
+			{Name: "Difficulties", Value: strings.Join(diffs, "\n")},
+		},
+		ActorID: userID,
+		Data: map[string]any{
+			"set_id":      set.ID,
+			"beatmap_ids": ids,
+			"status":      status,
+			"status_name": label,
+			"reason":      reason,
+		},
+	}
+}
+
+func (s *Service) load(ctx context.Context, setID int) (*models.BeatmapSet, []Difficulty, error) {
+	if setID <= 0 {
+		return nil, nil, services.NewNotFound("Beatmap set not found.")
+	}
+
+	set, err := s.beatmaps.GetBeatmapSet(ctx, setID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(set.ChildrenBeatmaps) == 0 {
+		return nil, nil, services.NewNotFound("Beatmap set not found.")
+	}
+
+	statuses, err := s.beatmapRepo.GetRankedStatuses(ctx, set.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	difficulties := make([]Difficulty, 0, len(set.ChildrenBeatmaps))
+	for _, bm := range set.ChildrenBeatmaps {
+		status, known := statuses[bm.ID]
+		difficulties = append(difficulties, Difficulty{Beatmap: bm, Status: status, Known: known})
+	}
+	sort.Slice(difficulties, func(i, j int) bool {
+		if difficulties[i].Mode != difficulties[j].Mode {
+			return difficulties[i].Mode < difficulties[j].Mode
+		}
+		return difficulties[i].DifficultyRating < difficulties[j].DifficultyRating
+	})
+
+	return set, difficulties, nil
+}
@@ -0,0 +1,295 @@
+package rankrequest
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RealistikOsu/soumetsu/internal/config"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/repositories"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/beatmap"
+	"github.com/RealistikOsu/soumetsu/internal/services/ranking"
+)
+
+const (
+	window = 24 * time.Hour
+
+	maxReasonLength = 1000
+	PageSize        = 50
+)
+
+// Status is the queue summary shown on the rank request page.
+type Status struct {
+	Submitted       int    `json:"submitted"`
+	QueueSize       int    `json:"queue_size"`
+	SubmittedByUser int    `json:"submitted_by_user"`
+	MaxPerUser      int    `json:"max_per_user"`
+	CanSubmit       bool   `json:"can_submit"`
+	NextExpiration  string `json:"next_expiration,omitempty"`
+}
+
+type Service struct {
+	config   *config.Config
+	repo     *repositories.RankRequestRepository
+	beatmaps *beatmap.Service
+	ranking  *ranking.Service
+}
+
+func NewService(
+	cfg *config.Config,
+	repo *repositories.RankRequestRepository,
+	beatmaps *beatmap.Service,
+	ranking *ranking.Service,
+) *Service {
+	return &Service{
+		config:   cfg,
+		repo:     repo,
+		beatmaps: beatmaps,
+		ranking:  ranking,
+	}
+}
+
+func (s *Service) Status(ctx context.Context, userID int) (*Status, error) {
+	since := time.Now().Add(-window).Unix()
+
+	submitted, err := s.repo.CountSince(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+	byUser, err := s.repo.CountByUserSince(ctx, userID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{
+		Submitted:       submitted,
+		QueueSize:       s.config.Beatmap.RankRequestQueueSize,
+		SubmittedByUser: byUser,
+		MaxPerUser:      s.config.Beatmap.RankRequestsPerUser,
+	}
+	status.CanSubmit = byUser < status.MaxPerUser && submitted < status.QueueSize
+
+	if byUser >= status.MaxPerUser {
+		oldest, err := s.repo.OldestByUserSince(ctx, userID, since)
+		if err != nil {
+			return nil, err
+		}
+		if oldest != 0 {
+			status.NextExpiration = time.Unix(oldest, 0).Add(window).UTC().Format("2006-01-02 15:04 UTC")
+		}
+	}
+
+	return status, nil
+}
+
+// Submit validates a beatmap or set link against the mirror and queues it.
+func (s *Service) Submit(ctx context.Context, userID int, link string) (*models.RankRequest, error) {
+	requestType, id, ok := ParseLink(link)
+	if !ok {
+		return nil, services.NewBadRequest("Invalid beatmap URL. Please use a valid osu! or server beatmap link.")
+	}
+
+	beatmapID := 0
+	setID := id
+	if requestType == models.RankRequestTypeBeatmap {
+		bm, err := s.beatmaps.GetBeatmap(ctx, id)
+		if err != nil {
+			return nil, notFoundMessage(err)
+		}
+		beatmapID = bm.ID
+		setID = bm.ParentSetID
+	}
+
+	set, err := s.beatmaps.GetBeatmapSet(ctx, setID)
+	if err != nil {
+		return nil, notFoundMessage(err)
+	}
+	if len(set.ChildrenBeatmaps) == 0 {
+		return nil, services.NewNotFound("Beatmap not found. Make sure the beatmap exists.")
+	}
+	if set.IsRanked() {
+		return nil, services.NewBadRequest("This beatmap is already ranked.")
+	}
+
+	pending, err := s.repo.PendingExistsForSet(ctx, set.ID)
+	if err != nil {
+		return nil, err
+	}
+	if pending {
+		return nil, services.NewConflict("This beatmap has already been requested.")
+	}
+
+	status, err := s.Status(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if status.SubmittedByUser >= status.MaxPerUser {
+		return nil, services.NewBadRequest("You have reached your daily limit for requesting beatmaps.")
+	}
+	if status.Submitted >= status.QueueSize {
+		return nil, services.NewBadRequest("The rank request queue is full for today. Please try again later.")
+	}
+
+	snapshot, err := json.Marshal(snapshotBeatmaps(set))
+	if err != nil {
+		return nil, err
+	}
+
+	req := &models.RankRequest{
+		UserID:       userID,
+		Type:         requestType,
+		BeatmapID:    beatmapID,
+		BeatmapsetID: set.ID,
+		SongName:     set.Artist + " - " + set.Title,
+		BeatmapsJSON: string(snapshot),
+		Status:       models.RankRequestPending,
+		RequestedAt:  time.Now().Unix(),
+	}
+	req.ID, err = s.repo.Create(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// List returns one page of requests and whether there are more.
+func (s *Service) List(ctx context.Context, status models.RankRequestStatus, page, limit int) ([]models.RankRequest, bool, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > PageSize {
+		limit = PageSize
+	}
+
+	// Fetch one extra row to learn whether another page exists.
+	reqs, err := s.repo.List(ctx, status, limit+1, (page-1)*limit)
+	if err != nil {
+		return nil, false, err
+	}
+	hasMore := len(reqs) > limit
+	if hasMore {
+		reqs = reqs[:limit]
+	}
+	return reqs, hasMore, nil
+}
+
+func (s *Service) StatusCounts(ctx context.Context) (map[models.RankRequestStatus]int, error) {
+	return s.repo.CountByStatus(ctx)
+}
+
+// Review records a BAT decision. Ranking or loving applies the status to every
+// difficulty of the set through the ranking console, so it lands in the same
+// log; the reason is shown publicly on the request.
+func (s *Service) Review(ctx context.Context, id int64, reviewerID int, decision models.RankRequestStatus, reason string) (*models.RankRequest, error) {
+	if decision == models.RankRequestPending || !decision.Valid() {
+		return nil, services.NewBadRequest("Please pick a decision.")
+	}
+
+	reason = strings.TrimSpace(reason)
+	if decision == models.RankRequestDenied && reason == "" {
+		return nil, services.NewBadRequest("Please give the requester a reason for the denial.")
+	}
+	if len(reason) > maxReasonLength {
+		return nil, services.NewBadRequest("The reason is too long.")
+	}
+
+	req, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req == nil {
+		return nil, services.NewNotFound("Rank request not found.")
+	}
+	if req.Status != models.RankRequestPending {
+		return nil, services.NewConflict("This request has already been reviewed.")
+	}
+
+	if rankedStatus, ok := decision.RankedStatus(); ok {
+		logReason := "Rank request #" + strconv.FormatInt(req.ID, 10)
+		if reason != "" {
+			logReason += ": " + reason
+		}
+		if _, err := s.ranking.SetStatus(ctx, ranking.Change{
+			SetID:  req.BeatmapsetID,
+			Status: rankedStatus,
+			Reason: logReason,
+			UserID: reviewerID,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repo.Review(ctx, id, decision, reason, reviewerID, time.Now().Unix()); err != nil {
+		return nil, err
+	}
+	req.Status = decision
+	req.Reason = reason
+	return req, nil
+}
+
+// ParseLink extracts a beatmap or set ID from a link to this server, to
+// osu.ppy.sh, or from a bare beatmap ID.
+func ParseLink(link string) (string, int, bool) {
+	link = strings.TrimSpace(link)
+	if id, err := strconv.Atoi(link); err == nil && id > 0 {
+		return models.RankRequestTypeBeatmap, id, true
+	}
+
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", 0, false
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := 0; i+1 < len(parts); i++ {
+		id, err := strconv.Atoi(parts[i+1])
+		if err != nil || id <= 0 {
+			continue
+		}
+		switch parts[i] {
+		case "b", "beatmaps":
+			return models.RankRequestTypeBeatmap, id, true
+		case "s", "beatmapsets":
+			// osu.ppy.sh links a difficulty as /beatmapsets/1#osu/2.
+			if frag := strings.Split(u.Fragment, "/"); len(frag) == 2 {
+				if bid, err := strconv.Atoi(frag[1]); err == nil && bid > 0 {
+					return models.RankRequestTypeBeatmap, bid, true
+				}
+			}
+			return models.RankRequestTypeSet, id, true
+		}
+	}
+
+	return "", 0, false
+}
+
+func snapshotBeatmaps(set *models.BeatmapSet) []models.RankRequestBeatmap {
+	beatmaps := make([]models.RankRequestBeatmap, 0, len(set.ChildrenBeatmaps))
+	for _, bm := range set.ChildrenBeatmaps {
+		beatmaps = append(beatmaps, models.RankRequestBeatmap{
+			BeatmapID:     bm.ID,
+			BeatmapsetID:  set.ID,
+			SongName:      set.Artist + " - " + set.Title + " [" + bm.DiffName + "]",
+			Mode:          bm.Mode,
+			DifficultyStd: bm.DifficultyRating,
+		})
+	}
+	return beatmaps
+}
+
+// notFoundMessage swaps the mirror's not-found error for one that tells the
+// requester what to check.
+func notFoundMessage(err error) error {
+	if svcErr, ok := err.(*services.ServiceError); ok && svcErr.Code == "not_found" {
+		return services.NewNotFound("Beatmap not found. Make sure the beatmap exists.")
+	}
+	return err
+}
@@ -0,0 +1,405 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/api"
+	"github.com/RealistikOsu/soumetsu/internal/adapters/redis"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/repositories"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/webhooks"
+)
+
+const (
+	// dailyLimit caps how many reports one user can file per day, so the queue
+	// can't be flooded by a single grudge.
+	dailyLimit = 10
+
+	maxEvidenceLinks  = 5
+	maxEvidenceLength = 255
+	minReasonLength   = 10
+	maxReasonLength   = 2000
+	maxNoteLength     = 5000
+
+	PageSize = 50
+
+	// notificationChannel is bancho's in-game notification pub/sub channel.
+	notificationChannel = "peppy:notification"
+)
+
+// Target describes the reported entity as shown on the form and to staff.
+type Target struct {
+	Type    models.ReportTarget
+	ID      int64
+	Name    string
+	OwnerID int
+	URL     string
+}
+
+type SubmitInput struct {
+	ReporterID int
+	TargetType models.ReportTarget
+	TargetID   int64
+	Category   string
+	Reason     string
+	Evidence   string
+}
+
+type Service struct {
+	repo      *repositories.ReportRepository
+	userRepo  *repositories.UserRepository
+	clanRepo  *repositories.ClanRepository
+	apiClient *api.Client
+	redis     *redis.Client
+	webhooks  *webhooks.Service
+}
+
+func NewService(
+	repo *repositories.ReportRepository,
+	userRepo *repositories.UserRepository,
+	clanRepo *repositories.ClanRepository,
+	apiClient *api.Client,
+	redis *redis.Client,
+	webhooksService *webhooks.Service,
+) *Service {
+	return &Service{
+		repo:      repo,
+		userRepo:  userRepo,
+		clanRepo:  clanRepo,
+		apiClient: apiClient,
+		redis:     redis,
+		webhooks:  webhooksService,
+	}
+}
+
+// GetTarget resolves a report target, returning a not-found error when the
+// user, score or clan doesn't exist.
+func (s *Service) GetTarget(ctx context.Context, targetType models.ReportTarget, targetID int64) (*Target, error) {
+	if !targetType.Valid() || targetID <= 0 {
+		return nil, services.NewBadRequest("Invalid report target.")
+	}
+
+	target := &Target{Type: targetType, ID: targetID, URL: targetType.URL(targetID)}
+
+	switch targetType {
+	case models.ReportTargetUser, models.ReportTargetUserpage:
+		user, err := s.userRepo.FindByID(ctx, int(targetID))
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, services.NewNotFound("That user doesn't exist.")
+		}
+		target.Name = user.Username
+		target.OwnerID = user.ID
+
+	case models.ReportTargetClan:
+		clan, err := s.clanRepo.FindByID(ctx, int(targetID))
+		if err != nil {
+			return nil, err
+		}
+		if clan == nil {
+			return nil, services.NewNotFound("That clan doesn't exist.")
+		}
+		target.Name = clan.Name
+
+	case models.ReportTargetScore:
+		score, err := s.apiClient.GetScore(ctx, targetID)
+		if err != nil {
+			if apiErr, ok := err.(*api.APIError); ok && apiErr.StatusCode == http.StatusNotFound {
+				return nil, services.NewNotFound("That score doesn't exist.")
+			}
+			return nil, err
+		}
+		target.OwnerID = score.UserID
+		target.Name = score.SongName
+		if user, err := s.userRepo.FindByID(ctx, score.UserID); err == nil && user != nil {
+			target.Name = fmt.Sprintf("%s (played by %s)", score.SongName, user.Username)
+			target.URL = models.ReportTargetUser.URL(int64(user.ID))
+		}
+	}
+
+	return target, nil
+}
+
+func (s *Service) Submit(ctx context.Context, input SubmitInput) (int64, error) {
+	target, err := s.GetTarget(ctx, input.TargetType, input.TargetID)
+	if err != nil {
+		return 0, err
+	}
+	if target.OwnerID == input.ReporterID {
+		return 0, services.NewBadRequest("You can't report yourself.")
+	}
+
+	category, ok := models.FindReportCategory(input.Category)
+	if !ok || !category.AppliesTo(input.TargetType) {
+		return 0, services.NewBadRequest("Please pick a category for your report.")
+	}
+
+	reason := strings.TrimSpace(input.Reason)
+	if n := utf8.RuneCountInString(reason); n < minReasonLength || n > maxReasonLength {
+		return 0, services.NewBadRequest(fmt.Sprintf("Your description must be between %d and %d characters.", minReasonLength, maxReasonLength))
+	}
+
+	evidence, err := normaliseEvidence(input.Evidence)
+	if err != nil {
+		return 0, err
+	}
+
+	exists, err := s.repo.HasOpenReport(ctx, input.ReporterID, input.TargetType, input.TargetID)
+	if err != nil {
+		return 0, err
+	}
+	if exists {
+		return 0, services.NewConflict("You already have an open report about this. Staff will get to it soon.")
+	}
+
+	now := time.Now()
+	recent, err := s.repo.CountSince(ctx, input.ReporterID, now.Add(-24*time.Hour).Unix())
+	if err != nil {
+		return 0, err
+	}
+	if recent >= dailyLimit {
+		return 0, services.NewBadRequest("You have sent too many reports today. Please try again tomorrow.")
+	}
+
+	id, err := s.repo.Create(ctx, &models.Report{
+		ReporterID: input.ReporterID,
+		TargetType: input.TargetType,
+		TargetID:   input.TargetID,
+		Category:   category.Key,
+		Reason:     reason,
+		Evidence:   evidence,
+		Status:     models.ReportStatusOpen,
+		CreatedAt:  now.Unix(),
+		UpdatedAt:  now.Unix(),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	s.webhooks.Dispatch(ctx, webhooks.Event{
+		Type:        webhooks.EventReportCreated,
+		OccurredAt:  now,
+		Title:       fmt.Sprintf("New report #%d: %s", id, target.Name),
+		Description: reason,
+		URL:         fmt.Sprintf("/admin/reports/%d", id),
+		Fields: []webhooks.Field{
+			{Name: "Category", Value: category.Label},
+			{Name: "Target", Value: fmt.Sprintf("%s #%d", target.Type.Label(), target.ID)},
+		},
+		ActorID: input.ReporterID,
+		Data: map[string]any{
+			"report_id":   id,
+			"target_type": target.Type,
+			"target_id":   target.ID,
+			"target_name": target.Name,
+			"category":    category.Key,
+		},
+	})
+
+	return id, nil
+}
+
+func (s *Service) ListForReporter(ctx context.Context, reporterID int) ([]models.Report, error) {
+	return s.repo.ListByReporter(ctx, reporterID, PageSize)
+}
+
+// List returns one page of the triage queue along with the total match count.
+func (s *Service) List(ctx context.Context, filter repositories.ReportFilter, page int) ([]models.Report, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	filter.Limit = PageSize
+	filter.Offset = (page - 1) * PageSize
+
+	reports, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := s.repo.Count(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	return reports, total, nil
+}
+
+func (s *Service) StatusCounts(ctx context.Context) (map[models.ReportStatus]int, error) {
+	return s.repo.CountByStatus(ctx)
+}
+
+func (s *Service) Staff(ctx context.Context) ([]models.User, error) {
+	return s.repo.FindStaff(ctx)
+}
+
+func (s *Service) Get(ctx context.Context, id int64) (*models.Report, []models.ReportNote, error) {
+	report, err := s.find(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	notes, err := s.repo.GetNotes(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return report, notes, nil
+}
+
+// Assign hands the report to assigneeID, or unassigns it when assigneeID is 0.
+// Picking up an open report moves it to in progress.
+func (s *Service) Assign(ctx context.Context, id int64, staffID, assigneeID int) error {
+	report, err := s.find(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	event := "Unassigned the report."
+	if assigneeID != 0 {
+		assignee, err := s.userRepo.FindByID(ctx, assigneeID)
+		if err != nil {
+			return err
+		}
+		if assignee == nil || assignee.Privileges&models.AdminPrivilegeManageReports == 0 {
+			return services.NewBadRequest("Reports can only be assigned to staff who can manage reports.")
+		}
+		event = "Assigned the report to " + assignee.Username + "."
+	}
+
+	now := time.Now().Unix()
+	if err := s.repo.UpdateAssignee(ctx, id, assigneeID, now); err != nil {
+		return err
+	}
+	if assigneeID != 0 && report.Status == models.ReportStatusOpen {
+		if err := s.repo.UpdateStatus(ctx, id, models.ReportStatusInProgress, now); err != nil {
+			return err
+		}
+	}
+	return s.repo.AddNote(ctx, id, staffID, event, true, now)
+}
+
+// SetStatus moves a report between the open states. Closing goes through
+// Close so the reporter gets a resolution.
+func (s *Service) SetStatus(ctx context.Context, id int64, staffID int, status models.ReportStatus) error {
+	if status != models.ReportStatusOpen && status != models.ReportStatusInProgress {
+		return services.NewBadRequest("Use a resolution to close a report.")
+	}
+
+	report, err := s.find(ctx, id)
+	if err != nil {
+		return err
+	}
+	if report.Status == status {
+		return nil
+	}
+
+	now := time.Now().Unix()
+	if err := s.repo.UpdateStatus(ctx, id, status, now); err != nil {
+		return err
+	}
+
+	event := "Changed the status to " + status.Label() + "."
+	if report.Status.IsClosed() {
+		event = "Reopened the report."
+	}
+	return s.repo.AddNote(ctx, id, staffID, event, true, now)
+}
+
+func (s *Service) AddNote(ctx context.Context, id int64, staffID int, content string) error {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return services.NewBadRequest("The note can't be empty.")
+	}
+	if utf8.RuneCountInString(content) > maxNoteLength {
+		return services.NewBadRequest(fmt.Sprintf("Notes can be at most %d characters.", maxNoteLength))
+	}
+
+	if _, err := s.find(ctx, id); err != nil {
+		return err
+	}
+	return s.repo.AddNote(ctx, id, staffID, content, false, time.Now().Unix())
+}
+
+// Close resolves the report with a canned resolution and notifies the
+// reporter in-game. message overrides the canned reporter-facing text.
+func (s *Service) Close(ctx context.Context, id int64, staffID int, resolutionKey, message string) error {
+	resolution, ok := models.FindReportResolution(resolutionKey)
+	if !ok {
+		return services.NewBadRequest("Please pick a resolution.")
+	}
+
+	report, err := s.find(ctx, id)
+	if err != nil {
+		return err
+	}
+	if report.Status.IsClosed() {
+		return services.NewConflict("This report is already closed.")
+	}
+
+	message = strings.TrimSpace(message)
+	if message == "" {
+		message = resolution.Message
+	}
+
+	now := time.Now().Unix()
+	if err := s.repo.Close(ctx, id, resolution.Status, resolution.Key, message, now); err != nil {
+		return err
+	}
+	if err := s.repo.AddNote(ctx, id, staffID, "Closed the report: "+resolution.Label+".", true, now); err != nil {
+		return err
+	}
+
+	s.notifyReporter(ctx, report, message)
+	return nil
+}
+
+func (s *Service) find(ctx context.Context, id int64) (*models.Report, error) {
+	report, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if report == nil {
+		return nil, services.NewNotFound("Report not found.")
+	}
+	return report, nil
+}
+
+func (s *Service) notifyReporter(ctx context.Context, report *models.Report, message string) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"userID":  report.ReporterID,
+		"message": fmt.Sprintf("Your report #%d has been closed. %s", report.ID, message),
+	})
+	if err != nil {
+		return
+	}
+	if err := s.redis.Publish(ctx, notificationChannel, string(payload)); err != nil {
+		slog.Error("failed to notify reporter", "error", err, "report_id", report.ID)
+	}
+}
+
+// normaliseEvidence validates the submitted links and stores them one per line.
+func normaliseEvidence(raw string) (string, error) {
+	var links []string
+	for _, line := range strings.FieldsFunc(raw, func(r rune) bool { return r == '\n' || r == '\r' }) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		u, err := url.Parse(line)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(line) > maxEvidenceLength {
+			return "", services.NewBadRequest("Evidence must be a list of http(s) links, one per line.")
+		}
+		links = append(links, line)
+	}
+	if len(links) > maxEvidenceLinks {
+		return "", services.NewBadRequest(fmt.Sprintf("You can attach at most %d evidence links.", maxEvidenceLinks))
+	}
+	return strings.Join(links, "\n"), nil
+}
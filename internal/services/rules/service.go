@@ -0,0 +1,216 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/repositories"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/docs"
+)
+
+// Slug is the docs page that holds the rules.
+const Slug = "rules"
+
+const (
+	maxSummaryLength = 255
+
+	// cacheTTL bounds how long a new version takes to reach other replicas.
+	// The acceptance middleware reads the current version on every request.
+	cacheTTL = 15 * time.Second
+
+	// maxRemembered caps how many acceptances of the current version are
+	// kept in memory.
+	maxRemembered = 100000
+)
+
+type Service struct {
+	repo *repositories.RulesRepository
+	docs *docs.Service
+
+	mu       sync.RWMutex
+	current  *models.RulesVersion
+	cachedAt time.Time
+	// accepted remembers who has accepted the current version so the
+	// middleware only queries for users it hasn't seen accept yet.
+	accepted map[int]bool
+}
+
+func NewService(repo *repositories.RulesRepository, docsService *docs.Service) *Service {
+	return &Service{repo: repo, docs: docsService}
+}
+
+// Current returns the version users must have accepted, or nil if there has
+// never been one.
+func (s *Service) Current(ctx context.Context) (*models.RulesVersion, error) {
+	s.mu.RLock()
+	if !s.cachedAt.IsZero() && time.Since(s.cachedAt) < cacheTTL {
+		current := s.current
+		s.mu.RUnlock()
+		return current, nil
+	}
+	s.mu.RUnlock()
+
+	current, err := s.repo.Latest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.setCurrent(current)
+	s.mu.Unlock()
+
+	return current, nil
+}
+
+func (s *Service) NeedsAcceptance(ctx context.Context, userID int) (bool, error) {
+	current, err := s.Current(ctx)
+	if err != nil || current == nil {
+		return false, err
+	}
+
+	s.mu.RLock()
+	accepted := s.accepted[userID]
+	s.mu.RUnlock()
+	if accepted {
+		return false, nil
+	}
+
+	accepted, err = s.repo.HasAccepted(ctx, userID, current.ID)
+	if err != nil {
+		return false, err
+	}
+	if accepted {
+		s.remember(current.ID, userID)
+	}
+	return !accepted, nil
+}
+
+// Pending returns the version awaiting acceptance along with the rules text
+// it pinned.
+func (s *Service) Pending(ctx context.Context) (*models.RulesVersion, *models.DocRevision, error) {
+	current, err := s.Current(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if current == nil {
+		return nil, nil, services.NewNotFound("There are no rules to accept.")
+	}
+	rev, err := s.docs.Revision(ctx, current.RevisionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rev == nil {
+		return nil, nil, services.NewNotFound("The rules for this version are missing.")
+	}
+	return current, rev, nil
+}
+
+// Accept records that userID accepted versionID, which must still be the
+// current version.
+func (s *Service) Accept(ctx context.Context, userID int, versionID int64, ip string) error {
+	current, err := s.Current(ctx)
+	if err != nil {
+		return err
+	}
+	if current == nil || current.ID != versionID {
+		return services.NewConflict("The rules changed while you were reading them. Please read the new version.")
+	}
+
+	if err := s.repo.Accept(ctx, userID, versionID, ip, time.Now().Unix()); err != nil {
+		return err
+	}
+	s.remember(versionID, userID)
+	return nil
+}
+
+func (s *Service) Versions(ctx context.Context) ([]models.RulesVersion, error) {
+	return s.repo.List(ctx)
+}
+
+func (s *Service) ForUser(ctx context.Context, userID int) ([]models.RulesAcceptance, error) {
+	return s.repo.ForUser(ctx, userID)
+}
+
+// Live returns the published rules page, or nil while the rules are still the
+// template page.
+func (s *Service) Live(ctx context.Context) *models.PublishedDoc {
+	doc, _ := s.docs.Published(ctx, Slug)
+	return doc
+}
+
+// Require makes the live rules a new version that every user has to accept
+// before they can keep using the site.
+func (s *Service) Require(ctx context.Context, staffID int, summary string) error {
+	summary = strings.TrimSpace(summary)
+	if summary == "" || utf8.RuneCountInString(summary) > maxSummaryLength {
+		return services.NewBadRequest(fmt.Sprintf("Please describe what changed in at most %d characters.", maxSummaryLength))
+	}
+
+	live := s.Live(ctx)
+	if live == nil {
+		return services.NewBadRequest("The rules page has to be published in the docs first.")
+	}
+
+	latest, err := s.repo.Latest(ctx)
+	if err != nil {
+		return err
+	}
+	if latest != nil && latest.RevisionID == live.ID {
+		return services.NewConflict("Users already have to accept the live rules.")
+	}
+
+	_, err = s.repo.Create(ctx, &models.RulesVersion{
+		RevisionID: live.ID,
+		Summary:    summary,
+		CreatedBy:  staffID,
+		CreatedAt:  time.Now().Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+// setCurrent caches the current version, forgetting who accepted the old one
+// when it changes. s.mu must be held.
+func (s *Service) setCurrent(current *models.RulesVersion) {
+	if s.accepted == nil || !sameVersion(s.current, current) {
+		s.accepted = make(map[int]bool)
+	}
+	s.current = current
+	s.cachedAt = time.Now()
+}
+
+func (s *Service) remember(versionID int64, userID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil || s.current.ID != versionID {
+		return
+	}
+	// The set is only a shortcut past HasAccepted, so it's cleared rather
+	// than allowed to grow past maxRemembered.
+	if len(s.accepted) >= maxRemembered {
+		s.accepted = make(map[int]bool)
+	}
+	s.accepted[userID] = true
+}
+
+func sameVersion(a, b *models.RulesVersion) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.ID == b.ID
+}
+
+func (s *Service) invalidate() {
+	s.mu.Lock()
+	s.cachedAt = time.Time{}
+	s.mu.Unlock()
+}
@@ -0,0 +1,189 @@
+package settings
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/repositories"
+)
+
+const (
+	KeyGlobalAlert        = "website_global_alert"
+	KeyGameMaintenance    = "game_maintenance"
+	KeyWebsiteMaintenance = "website_maintenance"
+	KeyMaintenanceStart   = "website_maintenance_start"
+	KeyMaintenanceEnd     = "website_maintenance_end"
+	KeyMaintenanceMessage = "website_maintenance_message"
+	KeyInviteOnly         = "registrations_invite_only"
+)
+
+// cacheTTL bounds how stale a replica's view of system_settings may get. The
+// maintenance middleware reads settings on every request, so we don't want to
+// hit MySQL each time.
+const cacheTTL = 15 * time.Second
+
+// Service reads and writes the system_settings table.
+type Service struct {
+	repo *repositories.SystemRepository
+
+	mu       sync.RWMutex
+	cache    map[string]models.SystemSetting
+	cachedAt time.Time
+}
+
+func NewService(repo *repositories.SystemRepository) *Service {
+	return &Service{repo: repo}
+}
+
+func (s *Service) GetAll(ctx context.Context) (map[string]models.SystemSetting, error) {
+	s.mu.RLock()
+	if s.cache != nil && time.Since(s.cachedAt) < cacheTTL {
+		cached := s.cache
+		s.mu.RUnlock()
+		return cached, nil
+	}
+	s.mu.RUnlock()
+
+	rows, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return s.lastKnown(err)
+	}
+
+	loaded := make(map[string]models.SystemSetting, len(rows))
+	for _, row := range rows {
+		loaded[row.Name] = row
+	}
+
+	s.mu.Lock()
+	s.cache = loaded
+	s.cachedAt = time.Now()
+	s.mu.Unlock()
+
+	return loaded, nil
+}
+
+func (s *Service) GetInt(ctx context.Context, name string) (int64, error) {
+	all, err := s.GetAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return all[name].ValueInt, nil
+}
+
+func (s *Service) GetString(ctx context.Context, name string) (string, error) {
+	all, err := s.GetAll(ctx)
+	if err != nil {
+		return "", err
+	}
+	return all[name].ValueString, nil
+}
+
+func (s *Service) SetInt(ctx context.Context, name string, value int64) error {
+	defer s.invalidate()
+	return s.repo.SetInt(ctx, name, value)
+}
+
+func (s *Service) SetString(ctx context.Context, name, value string) error {
+	defer s.invalidate()
+	return s.repo.SetString(ctx, name, value)
+}
+
+// GetMaintenance assembles the website maintenance state from its settings.
+func (s *Service) GetMaintenance(ctx context.Context) (models.Maintenance, error) {
+	all, err := s.GetAll(ctx)
+	if err != nil {
+		return models.Maintenance{}, err
+	}
+	return maintenanceFromSettings(all), nil
+}
+
+func (s *Service) SetMaintenance(ctx context.Context, m models.Maintenance) error {
+	defer s.invalidate()
+
+	enabled := int64(0)
+	if m.Enabled {
+		enabled = 1
+	}
+	if err := s.repo.SetInt(ctx, KeyWebsiteMaintenance, enabled); err != nil {
+		return err
+	}
+	if err := s.repo.SetInt(ctx, KeyMaintenanceStart, unixOrZero(m.StartsAt)); err != nil {
+		return err
+	}
+	if err := s.repo.SetInt(ctx, KeyMaintenanceEnd, unixOrZero(m.EndsAt)); err != nil {
+		return err
+	}
+	return s.repo.SetString(ctx, KeyMaintenanceMessage, m.Message)
+}
+
+// TemplateSettings returns every setting in the {"String", "Int"} shape that
+// base.html reads, plus the derived maintenance state under "maintenance".
+// Errors are swallowed: a settings outage should not take rendering down.
+func (s *Service) TemplateSettings(ctx context.Context) map[string]interface{} {
+	result := make(map[string]interface{})
+
+	all, err := s.GetAll(ctx)
+	if err != nil {
+		return result
+	}
+
+	for name, setting := range all {
+		result[name] = map[string]interface{}{
+			"String": setting.ValueString,
+			"Int":    setting.ValueInt,
+		}
+	}
+	result["maintenance"] = maintenanceFromSettings(all)
+
+	return result
+}
+
+// lastKnown keeps serving the last snapshot that loaded when MySQL can't be
+// read, which is most likely mid-migration, exactly when maintenance is on.
+// It waits another cacheTTL before retrying so an outage isn't hammered. With
+// no snapshot at all the error is returned and callers decide what to do.
+func (s *Service) lastKnown(err error) (map[string]models.SystemSetting, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cache == nil {
+		return nil, err
+	}
+	slog.Warn("failed to reload system settings, serving last known state", "error", err)
+	s.cachedAt = time.Now()
+	return s.cache, nil
+}
+
+// invalidate forces the next read to reload, but keeps the current snapshot
+// for lastKnown.
+func (s *Service) invalidate() {
+	s.mu.Lock()
+	s.cachedAt = time.Time{}
+	s.mu.Unlock()
+}
+
+func maintenanceFromSettings(all map[string]models.SystemSetting) models.Maintenance {
+	return models.Maintenance{
+		Enabled:  all[KeyWebsiteMaintenance].ValueInt != 0,
+		StartsAt: timeOrZero(all[KeyMaintenanceStart].ValueInt),
+		EndsAt:   timeOrZero(all[KeyMaintenanceEnd].ValueInt),
+		Message:  all[KeyMaintenanceMessage].ValueString,
+	}
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func timeOrZero(unix int64) time.Time {
+	if unix == 0 {
+		return time.Time{}
+	}
+	return time.Unix(unix, 0)
+}
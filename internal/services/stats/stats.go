@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/RealistikOsu/soumetsu/internal/adapters/redis"
+	"github.com/RealistikOsu/soumetsu/internal/repositories"
 )
 
 const (
@@ -19,10 +20,11 @@ type ServerStats struct {
 
 type Service struct {
 	redis *redis.Client
+	users *repositories.UserRepository
 }
 
-func NewService(redis *redis.Client) *Service {
-	return &Service{redis: redis}
+func NewService(redis *redis.Client, users *repositories.UserRepository) *Service {
+	return &Service{redis: redis, users: users}
 }
 
 func (s *Service) GetServerStats(ctx context.Context) (*ServerStats, error) {
@@ -38,3 +40,26 @@ func (s *Service) GetServerStats(ctx context.Context) (*ServerStats, error) {
 
 	return stats, nil
 }
+
+// OnlineUsers reads the online user count, unlike GetServerStats returning
+// any error. A missing count means nobody is online.
+func (s *Service) OnlineUsers(ctx context.Context) (int, error) {
+	val, err := s.redis.Get(ctx, keyOnlineUsers)
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(val)
+}
+
+// RefreshRegisteredUsers recounts the registered user count shown around the
+// site, which otherwise drifts as accounts are restricted or unrestricted.
+func (s *Service) RefreshRegisteredUsers(ctx context.Context) error {
+	count, err := s.users.CountPublic(ctx)
+	if err != nil {
+		return err
+	}
+	return s.redis.Set(ctx, keyRegisteredUsers, count, 0)
+}
@@ -0,0 +1,116 @@
+package useradmin
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+)
+
+const (
+	// maxRestrictionDays bounds a timed restriction; anything longer should
+	// be permanent.
+	maxRestrictionDays = 365
+
+	// expireBatch is how many penalties one run of ExpirePenalties lifts of
+	// each kind. The rest wait for the next run.
+	expireBatch = 100
+
+	// banChannel and silenceChannel tell bancho a user's privileges or
+	// silence changed, and the moderation webhooks pick them up too.
+	banChannel     = "peppy:ban"
+	silenceChannel = "peppy:silence"
+)
+
+// Restrict restricts a user for days days, or for good when days is 0.
+func (s *Service) Restrict(ctx context.Context, staffID, id, days int, reason string) error {
+	user, err := s.findUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if user.Privileges&models.UserPrivilegeNormal == 0 {
+		return services.NewBadRequest("The user is banned.")
+	}
+	if days < 0 || days > maxRestrictionDays {
+		return services.NewBadRequest(fmt.Sprintf("A timed restriction lasts at most %d days.", maxRestrictionDays))
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" || len(reason) > maxReasonLength {
+		return services.NewBadRequest(fmt.Sprintf("Please give a reason of at most %d characters.", maxReasonLength))
+	}
+
+	now := time.Now()
+	rst := &models.TimedRestriction{
+		UserID:       id,
+		RestrictedAt: now.Unix(),
+		Reason:       reason,
+		CreatedBy:    staffID,
+	}
+	detail := "permanently: " + reason
+	if days > 0 {
+		rst.EndsAt = now.AddDate(0, 0, days).Unix()
+		detail = fmt.Sprintf("for %d days: %s", days, reason)
+	}
+	if err := s.restrictions.Restrict(ctx, rst); err != nil {
+		return err
+	}
+	s.publish(ctx, banChannel, id)
+	return s.record(ctx, staffID, id, "restrict", detail, rst.RestrictedAt)
+}
+
+// ExpirePenalties lifts timed restrictions that have run out and clears
+// silences that have ended, recording both in the user's staff actions. It
+// runs as a scheduled job.
+func (s *Service) ExpirePenalties(ctx context.Context) error {
+	now := time.Now().Unix()
+
+	restrictions, err := s.restrictions.Expired(ctx, now, expireBatch)
+	if err != nil {
+		return err
+	}
+	for i := range restrictions {
+		rst := &restrictions[i]
+		lifted, err := s.restrictions.Lift(ctx, rst)
+		if err != nil {
+			return err
+		}
+		if !lifted {
+			// Lifted, banned or restricted again some other way meanwhile.
+			continue
+		}
+		s.publish(ctx, banChannel, rst.UserID)
+		if err := s.record(ctx, 0, rst.UserID, "restriction_expired", rst.Reason, now); err != nil {
+			return err
+		}
+	}
+
+	silences, err := s.users.ExpiredSilences(ctx, now, expireBatch)
+	if err != nil {
+		return err
+	}
+	for _, silence := range silences {
+		cleared, err := s.users.ClearSilence(ctx, silence.UserID, silence.End)
+		if err != nil {
+			return err
+		}
+		if !cleared {
+			continue
+		}
+		s.publish(ctx, silenceChannel, silence.UserID)
+		if err := s.record(ctx, 0, silence.UserID, "silence_expired", silence.Reason, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) publish(ctx context.Context, channel string, userID int) {
+	if err := s.redis.Publish(ctx, channel, strconv.Itoa(userID)); err != nil {
+		slog.Error("failed to publish moderation change", "error", err, "channel", channel, "user_id", userID)
+	}
+}
@@ -0,0 +1,428 @@
+package useradmin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/api"
+	"github.com/RealistikOsu/soumetsu/internal/adapters/redis"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+	"github.com/RealistikOsu/soumetsu/internal/pkg/validation"
+	"github.com/RealistikOsu/soumetsu/internal/repositories"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/betakeys"
+	"github.com/RealistikOsu/soumetsu/internal/services/rules"
+)
+
+const (
+	// maxResults caps how many accounts a search lists; a busy shared IP can
+	// match a lot of them.
+	maxResults    = 50
+	recentScores  = 10
+	recentReports = 20
+	recentActions = 50
+
+	maxReasonLength = 255
+
+	renameChannel      = "peppy:change_username"
+	cachedStatsChannel = "peppy:update_cached_stats"
+)
+
+// leaderboardPrefixes name the global leaderboard sorted sets, indexed by
+// custom mode. Country boards append ":<country>".
+var leaderboardPrefixes = []string{"ripple:leaderboard", "ripple:leaderboard_relax", "ripple:leaderboard_ap"}
+
+// Overview is everything staff see on the admin page for one user.
+type Overview struct {
+	User       *models.User
+	Moderation *models.ModerationState
+	Names      []models.UsernameChange
+	IPs        []models.UserIP
+	Identities []models.IdentityToken
+	DiscordID  string
+	Clan       *models.Clan
+	ClanOwner  bool
+	Badges     []models.Badge
+	Reports    []models.Report
+	Actions    []models.AdminAction
+	// BetaKey is the key the account registered with, if any.
+	BetaKey *models.RegistrationKeyUse
+	// Restriction is set while the user is under a timed restriction.
+	Restriction *models.TimedRestriction
+
+	// Scores come from the API and are nil when it couldn't be reached.
+	Scores      []api.ScoreWithBeatmap
+	ScoresError bool
+	Mode        int
+	CustomMode  int
+
+	// Rules lists every rules version with when the user accepted it.
+	Rules        []models.RulesAcceptance
+	CurrentRules *models.RulesVersion
+}
+
+// Match is one search result and what it matched on.
+type Match struct {
+	User      *models.User
+	MatchedBy string
+}
+
+type Service struct {
+	users        *repositories.UserRepository
+	tokens       *repositories.TokenRepository
+	discord      *repositories.DiscordRepository
+	clans        *repositories.ClanRepository
+	reports      *repositories.ReportRepository
+	stats        *repositories.StatsRepository
+	adminLog     *repositories.AdminLogRepository
+	restrictions *repositories.RestrictionRepository
+	apiClient    *api.Client
+	redis        *redis.Client
+	rules        *rules.Service
+	betaKeys     *betakeys.Service
+}
+
+func NewService(
+	users *repositories.UserRepository,
+	tokens *repositories.TokenRepository,
+	discord *repositories.DiscordRepository,
+	clans *repositories.ClanRepository,
+	reports *repositories.ReportRepository,
+	stats *repositories.StatsRepository,
+	adminLog *repositories.AdminLogRepository,
+	restrictions *repositories.RestrictionRepository,
+	apiClient *api.Client,
+	redisClient *redis.Client,
+	rulesService *rules.Service,
+	betaKeyService *betakeys.Service,
+) *Service {
+	return &Service{
+		users:        users,
+		tokens:       tokens,
+		discord:      discord,
+		clans:        clans,
+		reports:      reports,
+		stats:        stats,
+		adminLog:     adminLog,
+		restrictions: restrictions,
+		apiClient:    apiClient,
+		redis:        redisClient,
+		rules:        rulesService,
+		betaKeys:     betaKeyService,
+	}
+}
+
+// Search looks a query up as a user ID, username, past username, email, IP,
+// Discord ID or identity token, in that order of preference.
+func (s *Service) Search(ctx context.Context, query string) ([]Match, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, services.NewBadRequest("Please enter something to search for.")
+	}
+
+	var matches []Match
+	seen := make(map[int]bool)
+	add := func(id int, matchedBy string) error {
+		if id == 0 || seen[id] || len(matches) >= maxResults {
+			return nil
+		}
+		user, err := s.users.FindByID(ctx, id)
+		if err != nil || user == nil {
+			return err
+		}
+		seen[id] = true
+		matches = append(matches, Match{User: user, MatchedBy: matchedBy})
+		return nil
+	}
+	addAll := func(ids []int, matchedBy string) error {
+		for _, id := range ids {
+			if err := add(id, matchedBy); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if id, err := strconv.Atoi(query); err == nil {
+		if err := add(id, "ID"); err != nil {
+			return nil, err
+		}
+	}
+
+	if net.ParseIP(query) != nil {
+		ids, err := s.tokens.FindUserIDsByIP(ctx, query, maxResults)
+		if err != nil {
+			return nil, err
+		}
+		if err := addAll(ids, "IP"); err != nil {
+			return nil, err
+		}
+		return matches, nil
+	}
+
+	if strings.Contains(query, "@") {
+		user, err := s.users.FindByEmail(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		if user != nil {
+			if err := add(user.ID, "Email"); err != nil {
+				return nil, err
+			}
+		}
+		return matches, nil
+	}
+
+	user, err := s.users.FindByUsername(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		if err := add(user.ID, "Username"); err != nil {
+			return nil, err
+		}
+	}
+
+	ids, err := s.users.FindIDsByOldUsername(ctx, query, maxResults)
+	if err != nil {
+		return nil, err
+	}
+	if err := addAll(ids, "Previous username"); err != nil {
+		return nil, err
+	}
+
+	discordUser, err := s.discord.FindUserIDByDiscordID(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if err := add(discordUser, "Discord ID"); err != nil {
+		return nil, err
+	}
+
+	ids, err = s.tokens.FindUserIDsByIdentityToken(ctx, query, maxResults)
+	if err != nil {
+		return nil, err
+	}
+	if err := addAll(ids, "Identity token"); err != nil {
+		return nil, err
+	}
+
+	return matches, nil
+}
+
+func (s *Service) User(ctx context.Context, id int) (*models.User, error) {
+	return s.findUser(ctx, id)
+}
+
+// Overview gathers the account overview. Scores are for mode and customMode.
+func (s *Service) Overview(ctx context.Context, id, mode, customMode int) (*Overview, error) {
+	user, err := s.findUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Overview{User: user, Mode: mode, CustomMode: customMode}
+
+	if o.Moderation, err = s.users.GetModerationState(ctx, id); err != nil {
+		return nil, err
+	}
+	if o.Names, err = s.users.GetUsernameHistory(ctx, id); err != nil {
+		return nil, err
+	}
+	if o.IPs, err = s.tokens.GetIPs(ctx, id); err != nil {
+		return nil, err
+	}
+	if o.Identities, err = s.tokens.GetIdentityTokens(ctx, id); err != nil {
+		return nil, err
+	}
+	if o.DiscordID, err = s.discord.GetDiscordID(ctx, id); err != nil {
+		return nil, err
+	}
+	if o.Badges, err = s.users.GetBadges(ctx, id); err != nil {
+		return nil, err
+	}
+	if o.Reports, err = s.reports.ListAgainstUser(ctx, id, recentReports); err != nil {
+		return nil, err
+	}
+	if o.Actions, err = s.adminLog.ListForTarget(ctx, models.AdminTargetUser, id, recentActions); err != nil {
+		return nil, err
+	}
+	if o.BetaKey, err = s.betaKeys.UseForUser(ctx, id); err != nil {
+		return nil, err
+	}
+	if o.Restriction, err = s.restrictions.FindByUser(ctx, id); err != nil {
+		return nil, err
+	}
+
+	membership, err := s.users.GetClanMembership(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if membership != nil {
+		if o.Clan, err = s.clans.FindByID(ctx, membership.ClanID); err != nil {
+			return nil, err
+		}
+		o.ClanOwner = membership.IsClanOwner()
+	}
+
+	if o.Rules, err = s.rules.ForUser(ctx, id); err != nil {
+		return nil, err
+	}
+	if o.CurrentRules, err = s.rules.Current(ctx); err != nil {
+		return nil, err
+	}
+
+	// The rest of the page is still useful to staff when the API is down.
+	o.Scores, err = s.apiClient.GetUserRecentScores(ctx, id, mode, customMode, 1, recentScores)
+	if err != nil {
+		slog.Warn("failed to load recent scores for admin overview", "error", err, "user_id", id)
+		o.ScoresError = true
+	}
+
+	return o, nil
+}
+
+// Rename force-changes a user's name, keeping the old one in their history.
+func (s *Service) Rename(ctx context.Context, staffID, id int, newUsername string) error {
+	user, err := s.findUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	newUsername = strings.TrimSpace(newUsername)
+	if !validation.ValidateUsername(newUsername) {
+		return services.NewBadRequest("Usernames are 2 to 15 characters of letters, numbers, spaces or _[]-.")
+	}
+	if newUsername == user.Username {
+		return services.NewBadRequest("That is already their username.")
+	}
+	// A case-only change keeps the same safe name, which is the user's own.
+	if repositories.SafeUsername(newUsername) != user.UsernameSafe {
+		taken, err := s.users.UsernameExists(ctx, newUsername)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return services.NewConflict("Someone else already has that username.")
+		}
+	}
+
+	now := time.Now().Unix()
+	if err := s.users.Rename(ctx, id, user.Username, newUsername, now); err != nil {
+		return err
+	}
+
+	payload, _ := json.Marshal(map[string]interface{}{"userID": id, "newUsername": newUsername})
+	if err := s.redis.Publish(ctx, renameChannel, string(payload)); err != nil {
+		slog.Error("failed to publish username change", "error", err, "user_id", id)
+	}
+
+	return s.record(ctx, staffID, id, "rename", fmt.Sprintf("%s → %s", user.Username, newUsername), now)
+}
+
+// WipePreview shows what Wipe would remove. mode and customMode may be -1
+// for all of them.
+func (s *Service) WipePreview(ctx context.Context, id, mode, customMode int) ([]models.WipePreview, error) {
+	if _, err := s.findUser(ctx, id); err != nil {
+		return nil, err
+	}
+	modes, err := playModes(mode, customMode)
+	if err != nil {
+		return nil, err
+	}
+
+	previews := make([]models.WipePreview, 0, len(modes))
+	for _, m := range modes {
+		preview, err := s.stats.WipePreview(ctx, id, m)
+		if err != nil {
+			return nil, err
+		}
+		previews = append(previews, *preview)
+	}
+	return previews, nil
+}
+
+// Wipe deletes a user's scores and resets their stats in the selected modes,
+// then drops them from the cached leaderboards. It returns the number of
+// scores removed.
+func (s *Service) Wipe(ctx context.Context, staffID, id, mode, customMode int, reason string) (int64, error) {
+	user, err := s.findUser(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	modes, err := playModes(mode, customMode)
+	if err != nil {
+		return 0, err
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" || len(reason) > maxReasonLength {
+		return 0, services.NewBadRequest(fmt.Sprintf("Please give a reason of at most %d characters.", maxReasonLength))
+	}
+
+	total, err := s.stats.Wipe(ctx, id, modes)
+	if err != nil {
+		return 0, err
+	}
+
+	labels := make([]string, 0, len(modes))
+	for _, m := range modes {
+		labels = append(labels, m.Label())
+		s.dropFromLeaderboards(ctx, user, m)
+	}
+
+	if err := s.redis.Publish(ctx, cachedStatsChannel, strconv.Itoa(id)); err != nil {
+		slog.Error("failed to publish stats refresh", "error", err, "user_id", id)
+	}
+
+	detail := fmt.Sprintf("%s, %d scores: %s", strings.Join(labels, ", "), total, reason)
+	return total, s.record(ctx, staffID, id, "wipe", detail, time.Now().Unix())
+}
+
+func (s *Service) dropFromLeaderboards(ctx context.Context, user *models.User, m models.PlayMode) {
+	key := leaderboardPrefixes[m.CustomMode] + ":" + m.Suffix()
+	keys := []string{key}
+	if user.Country != "" {
+		keys = append(keys, key+":"+strings.ToLower(user.Country))
+	}
+	for _, k := range keys {
+		if err := s.redis.ZRem(ctx, k, strconv.Itoa(user.ID)); err != nil {
+			slog.Error("failed to remove user from leaderboard", "error", err, "key", k, "user_id", user.ID)
+		}
+	}
+}
+
+func (s *Service) record(ctx context.Context, staffID, id int, action, detail string, now int64) error {
+	return s.adminLog.Record(ctx, &models.AdminAction{
+		StaffID:    staffID,
+		TargetType: models.AdminTargetUser,
+		TargetID:   id,
+		Action:     action,
+		Detail:     detail,
+		CreatedAt:  now,
+	})
+}
+
+func (s *Service) findUser(ctx context.Context, id int) (*models.User, error) {
+	user, err := s.users.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, services.NewNotFound("User not found.")
+	}
+	return user, nil
+}
+
+func playModes(mode, customMode int) ([]models.PlayMode, error) {
+	modes := models.PlayModes(mode, customMode)
+	if len(modes) == 0 {
+		return nil, services.NewBadRequest("That mode doesn't exist.")
+	}
+	return modes, nil
+}
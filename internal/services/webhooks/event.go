@@ -0,0 +1,81 @@
+package webhooks
+
+import "time"
+
+type EventType string
+
+const (
+	EventUserBanned    EventType = "user.banned"
+	EventUserSilenced  EventType = "user.silenced"
+	EventBeatmapStatus EventType = "beatmap.status_changed"
+	EventReportCreated EventType = "report.created"
+	EventClanDisbanded EventType = "clan.disbanded"
+	EventWebhookTest   EventType = "webhook.test"
+)
+
+// EventTypes are the events that can be routed, in the order the settings
+// page lists them.
+var EventTypes = []EventType{
+	EventUserBanned,
+	EventUserSilenced,
+	EventBeatmapStatus,
+	EventReportCreated,
+	EventClanDisbanded,
+}
+
+var eventLabels = map[EventType]string{
+	EventUserBanned:    "Bans and restrictions",
+	EventUserSilenced:  "Silences",
+	EventBeatmapStatus: "Beatmap status changes",
+	EventReportCreated: "New reports",
+	EventClanDisbanded: "Clan disbands",
+	EventWebhookTest:   "Test",
+}
+
+// eventColours are the Discord embed colours.
+var eventColours = map[EventType]int{
+	EventUserBanned:    0xdb2828,
+	EventUserSilenced:  0xf2711c,
+	EventBeatmapStatus: 0x21ba45,
+	EventReportCreated: 0xfbbd08,
+	EventClanDisbanded: 0xa333c8,
+	EventWebhookTest:   0x2185d0,
+}
+
+func (t EventType) Label() string {
+	if label, ok := eventLabels[t]; ok {
+		return label
+	}
+	return string(t)
+}
+
+func (t EventType) Valid() bool {
+	_, ok := eventLabels[t]
+	return ok
+}
+
+// Field is a name and value shown in the Discord embed.
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Event is something that happened that webhooks get told about. Title,
+// Description, URL and Fields are the human-readable summary; Data is the
+// machine-readable part of the JSON payload.
+type Event struct {
+	ID         string
+	Type       EventType
+	OccurredAt time.Time
+
+	Title       string
+	Description string
+	// URL may be a path, which is resolved against the site's base URL.
+	URL    string
+	Fields []Field
+	// ActorID is the user who caused the event, if known. Their name is
+	// added to the fields and data when the event is queued.
+	ActorID int
+
+	Data map[string]any
+}
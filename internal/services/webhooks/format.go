@@ -0,0 +1,136 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Headers sent with signed JSON deliveries.
+	HeaderEvent     = "X-Soumetsu-Event"
+	HeaderDelivery  = "X-Soumetsu-Delivery"
+	HeaderTimestamp = "X-Soumetsu-Timestamp"
+	HeaderSignature = "X-Soumetsu-Signature"
+
+	signaturePrefix = "sha256="
+
+	// Discord rejects embeds over these.
+	discordTitleLimit       = 256
+	discordDescriptionLimit = 4096
+	discordFieldValueLimit  = 1024
+)
+
+// Payload is the body of a JSON delivery.
+type Payload struct {
+	ID          string         `json:"id"`
+	Type        EventType      `json:"type"`
+	OccurredAt  int64          `json:"occurred_at"`
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	URL         string         `json:"url,omitempty"`
+	Data        map[string]any `json:"data,omitempty"`
+}
+
+func formatJSON(e Event) ([]byte, error) {
+	return json.Marshal(Payload{
+		ID:          e.ID,
+		Type:        e.Type,
+		OccurredAt:  e.OccurredAt.Unix(),
+		Title:       e.Title,
+		Description: e.Description,
+		URL:         e.URL,
+		Data:        e.Data,
+	})
+}
+
+type discordMessage struct {
+	Username string         `json:"username"`
+	Embeds   []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string              `json:"title"`
+	Description string              `json:"description,omitempty"`
+	URL         string              `json:"url,omitempty"`
+	Colour      int                 `json:"color"`
+	Fields      []discordEmbedField `json:"fields,omitempty"`
+	Footer      discordEmbedFooter  `json:"footer"`
+	Timestamp   string              `json:"timestamp"`
+}
+
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordEmbedFooter struct {
+	Text string `json:"text"`
+}
+
+// formatDiscord renders an event as a Discord webhook message with one embed.
+func formatDiscord(e Event) ([]byte, error) {
+	embed := discordEmbed{
+		Title:       truncate(e.Title, discordTitleLimit),
+		Description: truncate(e.Description, discordDescriptionLimit),
+		URL:         e.URL,
+		Colour:      eventColours[e.Type],
+		Footer:      discordEmbedFooter{Text: e.Type.Label()},
+		Timestamp:   e.OccurredAt.UTC().Format(time.RFC3339),
+	}
+	for _, f := range e.Fields {
+		if f.Value == "" {
+			continue
+		}
+		embed.Fields = append(embed.Fields, discordEmbedField{
+			Name:   f.Name,
+			Value:  truncate(f.Value, discordFieldValueLimit),
+			Inline: len(f.Value) <= 40,
+		})
+	}
+	return json.Marshal(discordMessage{Username: "Soumetsu", Embeds: []discordEmbed{embed}})
+}
+
+// isDiscord reports whether a URL is a Discord webhook, which gets an embed
+// instead of the signed JSON payload.
+func isDiscord(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	switch host {
+	case "discord.com", "discordapp.com", "ptb.discord.com", "canary.discord.com":
+		return strings.HasPrefix(u.Path, "/api/webhooks/")
+	}
+	return false
+}
+
+// Sign computes the signature header for a JSON delivery: an HMAC-SHA256 of
+// the timestamp, a dot and the body, keyed with the webhook secret.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a delivery's signature header, for receivers written in Go.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+func truncate(s string, limit int) string {
+	r := []rune(s)
+	if len(r) <= limit {
+		return s
+	}
+	return string(r[:limit-1]) + "…"
+}
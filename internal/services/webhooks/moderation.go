@@ -0,0 +1,119 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/redis"
+	"github.com/RealistikOsu/soumetsu/internal/models"
+)
+
+const (
+	// Bans, restrictions and silences are made through the API and admin
+	// panel, which tell the server by publishing the user's ID on these.
+	banChannel     = "peppy:ban"
+	silenceChannel = "peppy:silence"
+
+	// seenTTL is how long a published message is remembered, so only one
+	// instance dispatches it.
+	seenTTL = 30 * time.Second
+)
+
+// listen turns ban and silence announcements into events.
+func (s *Service) listen() {
+	defer s.wg.Done()
+	s.redis.Listen(s.done, "moderation event", s.receive, banChannel, silenceChannel)
+}
+
+func (s *Service) receive(msg *redis.Message) {
+	ctx := context.Background()
+	first, err := s.redis.SetNX(ctx, "soumetsu:webhooks:seen:"+msg.Channel+":"+msg.Payload, 1, seenTTL)
+	if err != nil {
+		slog.Error("failed to deduplicate moderation event", "error", err, "channel", msg.Channel)
+		return
+	}
+	if !first {
+		return
+	}
+
+	userID, err := strconv.Atoi(msg.Payload)
+	if err != nil {
+		return
+	}
+	switch msg.Channel {
+	case banChannel:
+		s.dispatchBan(ctx, userID)
+	case silenceChannel:
+		s.dispatchSilence(ctx, userID)
+	}
+}
+
+func (s *Service) dispatchBan(ctx context.Context, userID int) {
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		slog.Error("failed to look up banned user", "error", err, "user_id", userID)
+		return
+	}
+
+	action := "unbanned"
+	switch {
+	case user.Privileges&models.UserPrivilegeNormal == 0:
+		action = "banned"
+	case user.Privileges&models.UserPrivilegePublic == 0:
+		action = "restricted"
+	}
+
+	s.Dispatch(ctx, Event{
+		Type:  EventUserBanned,
+		Title: fmt.Sprintf("%s was %s", user.Username, action),
+		URL:   fmt.Sprintf("/admin/users/%d", user.ID),
+		Fields: []Field{
+			{Name: "User", Value: fmt.Sprintf("%s (%d)", user.Username, user.ID)},
+			{Name: "Country", Value: user.Country},
+		},
+		Data: map[string]any{
+			"user_id":    user.ID,
+			"username":   user.Username,
+			"action":     action,
+			"privileges": user.Privileges,
+		},
+	})
+}
+
+func (s *Service) dispatchSilence(ctx context.Context, userID int) {
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		slog.Error("failed to look up silenced user", "error", err, "user_id", userID)
+		return
+	}
+	state, err := s.users.GetModerationState(ctx, userID)
+	if err != nil || state == nil {
+		slog.Error("failed to look up silence", "error", err, "user_id", userID)
+		return
+	}
+
+	e := Event{
+		Type: EventUserSilenced,
+		URL:  fmt.Sprintf("/admin/users/%d", user.ID),
+		Fields: []Field{
+			{Name: "User", Value: fmt.Sprintf("%s (%d)", user.Username, user.ID)},
+		},
+		Data: map[string]any{
+			"user_id":     user.ID,
+			"username":    user.Username,
+			"silence_end": state.SilenceEnd,
+			"reason":      state.SilenceReason,
+		},
+	}
+	if state.SilenceEnd > time.Now().Unix() {
+		e.Title = user.Username + " was silenced"
+		e.Description = state.SilenceReason
+		e.Fields = append(e.Fields, Field{Name: "Until", Value: fmt.Sprintf("<t:%d:f>", state.SilenceEnd)})
+	} else {
+		e.Title = user.Username + "'s silence was lifted"
+	}
+	s.Dispatch(ctx, e)
+}
@@ -0,0 +1,453 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RealistikOsu/soumetsu/internal/adapters/redis"
+	"github.com/RealistikOsu/soumetsu/internal/config"
+	"github.com/RealistikOsu/soumetsu/internal/pkg/crypto"
+	"github.com/RealistikOsu/soumetsu/internal/repositories"
+	"github.com/RealistikOsu/soumetsu/internal/services"
+	"github.com/RealistikOsu/soumetsu/internal/services/settings"
+)
+
+const (
+	// queueKey is a sorted set of pending deliveries scored by when they're
+	// next due.
+	queueKey = "soumetsu:webhooks:queue"
+
+	pollInterval = 2 * time.Second
+	batchSize    = 50
+	sendTimeout  = 10 * time.Second
+
+	// maxInFlight bounds how many deliveries are being sent at once, and
+	// maxInFlightPerURL how many of those may go to one URL, so a slow
+	// endpoint can't hold up the others.
+	maxInFlight       = 20
+	maxInFlightPerURL = 2
+
+	maxAttempts = 8
+	baseBackoff = 15 * time.Second
+	maxBackoff  = time.Hour
+
+	// maxRoutesLength is what fits in a system_settings string.
+	maxRoutesLength = 512
+)
+
+// RouteSettingKey is the system setting holding the URLs an event type is
+// sent to, separated by whitespace.
+func RouteSettingKey(t EventType) string {
+	return "webhook_routes_" + strings.ReplaceAll(string(t), ".", "_")
+}
+
+// delivery is one event on its way to one URL.
+type delivery struct {
+	ID      string    `json:"id"`
+	Event   EventType `json:"event"`
+	URL     string    `json:"url"`
+	Discord bool      `json:"discord"`
+	Body    []byte    `json:"body"`
+	Attempt int       `json:"attempt"`
+}
+
+// Route is the URLs one event type goes to, for the settings page.
+type Route struct {
+	Type EventType
+	URLs []string
+}
+
+// Value is the URLs as they're edited, one per line.
+func (r Route) Value() string {
+	return strings.Join(r.URLs, "\n")
+}
+
+// Discord counts the URLs that get Discord embeds rather than signed JSON.
+func (r Route) Discord() int {
+	n := 0
+	for _, u := range r.URLs {
+		if isDiscord(u) {
+			n++
+		}
+	}
+	return n
+}
+
+type Service struct {
+	config   *config.Config
+	settings *settings.Service
+	users    *repositories.UserRepository
+	redis    *redis.Client
+	client   *http.Client
+
+	mu       sync.Mutex
+	inFlight map[string]int
+	sending  int
+
+	stopOnce sync.Once
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func NewService(
+	cfg *config.Config,
+	settingsService *settings.Service,
+	users *repositories.UserRepository,
+	redisClient *redis.Client,
+) *Service {
+	return &Service{
+		config:   cfg,
+		settings: settingsService,
+		users:    users,
+		redis:    redisClient,
+		client:   &http.Client{Timeout: sendTimeout},
+		inFlight: make(map[string]int),
+		done:     make(chan struct{}),
+	}
+}
+
+// Dispatch queues an event for every URL its type is routed to. Failures are
+// logged rather than returned, since the action that caused the event has
+// already happened.
+func (s *Service) Dispatch(ctx context.Context, e Event) {
+	urls, err := s.routeURLs(ctx, e.Type)
+	if err != nil {
+		slog.Error("failed to load webhook routes", "error", err, "event", e.Type)
+		return
+	}
+	if len(urls) == 0 {
+		return
+	}
+	if err := s.enqueue(ctx, e, urls); err != nil {
+		slog.Error("failed to queue webhook", "error", err, "event", e.Type)
+	}
+}
+
+// SendTest queues a test event to the URLs routed for t.
+func (s *Service) SendTest(ctx context.Context, t EventType, staffName string) error {
+	urls, err := s.routeURLs(ctx, t)
+	if err != nil {
+		return err
+	}
+	if len(urls) == 0 {
+		return services.NewBadRequest("Nothing is routed for " + t.Label() + " yet.")
+	}
+	return s.enqueue(ctx, Event{
+		Type:        EventWebhookTest,
+		Title:       "Webhook test",
+		Description: fmt.Sprintf("%s sent a test for %s.", staffName, t.Label()),
+		URL:         "/admin/webhooks",
+		Data:        map[string]any{"routed_event": t, "sent_by": staffName},
+	}, urls)
+}
+
+// Routes returns the URLs every event type is routed to.
+func (s *Service) Routes(ctx context.Context) ([]Route, error) {
+	routes := make([]Route, 0, len(EventTypes))
+	for _, t := range EventTypes {
+		urls, err := s.routeURLs(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, Route{Type: t, URLs: urls})
+	}
+	return routes, nil
+}
+
+// SetRoute replaces the URLs t is routed to.
+func (s *Service) SetRoute(ctx context.Context, t EventType, raw string) error {
+	if !t.Valid() || t == EventWebhookTest {
+		return services.NewBadRequest("Unknown event.")
+	}
+	urls := strings.Fields(raw)
+	for _, u := range urls {
+		parsed, err := url.Parse(u)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			return services.NewBadRequest(fmt.Sprintf("%q isn't an http or https URL.", u))
+		}
+	}
+	value := strings.Join(urls, "\n")
+	if len(value) > maxRoutesLength {
+		return services.NewBadRequest(fmt.Sprintf("The URLs for one event can be at most %d characters in total.", maxRoutesLength))
+	}
+	return s.settings.SetString(ctx, RouteSettingKey(t), value)
+}
+
+// Pending counts deliveries waiting to be sent or retried.
+func (s *Service) Pending(ctx context.Context) (int64, error) {
+	return s.redis.ZCard(ctx, queueKey)
+}
+
+// Signed reports whether JSON deliveries carry a signature.
+func (s *Service) Signed() bool {
+	return s.config.Security.WebhookSecret != ""
+}
+
+// StartWorker sends due deliveries, and dispatches bans and silences announced
+// on Redis, until Stop is called.
+func (s *Service) StartWorker() {
+	s.wg.Add(2)
+	go s.sendLoop()
+	go s.listen()
+}
+
+// Stop stops the worker, waiting for in-flight deliveries to finish.
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() { close(s.done) })
+	s.wg.Wait()
+}
+
+func (s *Service) routeURLs(ctx context.Context, t EventType) ([]string, error) {
+	raw, err := s.settings.GetString(ctx, RouteSettingKey(t))
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(raw), nil
+}
+
+func (s *Service) enqueue(ctx context.Context, e Event, urls []string) error {
+	if e.ActorID != 0 {
+		s.addActor(ctx, &e)
+	}
+	deliveries, err := s.deliveries(e, urls)
+	if err != nil {
+		return err
+	}
+
+	now := float64(time.Now().Unix())
+	for _, d := range deliveries {
+		member, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		if err := s.redis.ZAdd(ctx, queueKey, now, string(member)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliveries makes one delivery of e per URL, as a Discord embed for Discord
+// webhooks and as the JSON payload for everything else.
+func (s *Service) deliveries(e Event, urls []string) ([]delivery, error) {
+	if e.ID == "" {
+		id, err := crypto.GenerateRandomHex(16)
+		if err != nil {
+			return nil, err
+		}
+		e.ID = id
+	}
+	if e.OccurredAt.IsZero() {
+		e.OccurredAt = time.Now()
+	}
+	if strings.HasPrefix(e.URL, "/") {
+		e.URL = strings.TrimRight(s.config.App.BaseURL, "/") + e.URL
+	}
+
+	jsonBody, err := formatJSON(e)
+	if err != nil {
+		return nil, err
+	}
+	discordBody, err := formatDiscord(e)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]delivery, 0, len(urls))
+	for i, u := range urls {
+		d := delivery{ID: fmt.Sprintf("%s-%d", e.ID, i), Event: e.Type, URL: u, Body: jsonBody}
+		if isDiscord(u) {
+			d.Discord = true
+			d.Body = discordBody
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+func (s *Service) addActor(ctx context.Context, e *Event) {
+	name := fmt.Sprintf("#%d", e.ActorID)
+	if user, err := s.users.FindByID(ctx, e.ActorID); err != nil {
+		slog.Error("failed to look up webhook actor", "error", err, "user_id", e.ActorID)
+	} else if user != nil {
+		name = user.Username
+	}
+
+	e.Fields = append(e.Fields, Field{Name: "By", Value: name})
+	if e.Data == nil {
+		e.Data = make(map[string]any)
+	}
+	e.Data["actor_id"] = e.ActorID
+	e.Data["actor_name"] = name
+}
+
+func (s *Service) sendLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.sendDue()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// sendDue starts sending due deliveries in the background. Each is removed
+// from the queue before sending, so with several instances running only one
+// sends it. Deliveries to a URL that already has maxInFlightPerURL on the
+// way are left queued for a later poll.
+func (s *Service) sendDue() {
+	s.mu.Lock()
+	free := maxInFlight - s.sending
+	s.mu.Unlock()
+	if free <= 0 {
+		return
+	}
+
+	ctx := context.Background()
+	members, err := s.redis.ZRangeByScoreUpTo(ctx, queueKey, float64(time.Now().Unix()), batchSize)
+	if err != nil {
+		slog.Error("failed to read webhook queue", "error", err)
+		return
+	}
+
+	for _, member := range members {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+
+		var d delivery
+		if err := json.Unmarshal([]byte(member), &d); err != nil {
+			slog.Error("dropping malformed webhook delivery", "error", err)
+			s.redis.ZRemCount(ctx, queueKey, member)
+			continue
+		}
+		if !s.reserve(d.URL) {
+			continue
+		}
+
+		claimed, err := s.redis.ZRemCount(ctx, queueKey, member)
+		if err != nil || claimed == 0 {
+			s.release(d.URL)
+			if err != nil {
+				slog.Error("failed to claim webhook delivery", "error", err)
+				return
+			}
+			continue
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer s.release(d.URL)
+			s.attempt(ctx, d)
+		}()
+	}
+}
+
+// reserve takes a sending slot for a delivery to u, if one is free.
+func (s *Service) reserve(u string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sending >= maxInFlight || s.inFlight[u] >= maxInFlightPerURL {
+		return false
+	}
+	s.sending++
+	s.inFlight[u]++
+	return true
+}
+
+func (s *Service) release(u string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sending--
+	if s.inFlight[u]--; s.inFlight[u] <= 0 {
+		delete(s.inFlight, u)
+	}
+}
+
+func (s *Service) attempt(ctx context.Context, d delivery) {
+	retry, err := s.send(ctx, d)
+	if err == nil {
+		return
+	}
+
+	d.Attempt++
+	if !retry || d.Attempt >= maxAttempts {
+		slog.Error("giving up on webhook delivery", "error", err, "event", d.Event, "delivery", d.ID, "attempts", d.Attempt)
+		return
+	}
+
+	wait := backoff(d.Attempt)
+	slog.Warn("webhook delivery failed, retrying", "error", err, "event", d.Event, "delivery", d.ID, "attempt", d.Attempt, "retry_in", wait)
+	member, err := json.Marshal(d)
+	if err != nil {
+		slog.Error("failed to requeue webhook delivery", "error", err, "delivery", d.ID)
+		return
+	}
+	if err := s.redis.ZAdd(ctx, queueKey, float64(time.Now().Add(wait).Unix()), string(member)); err != nil {
+		slog.Error("failed to requeue webhook delivery", "error", err, "delivery", d.ID)
+	}
+}
+
+// send posts a delivery, reporting whether a failure is worth retrying.
+// Client errors other than rate limits and timeouts won't fix themselves.
+func (s *Service) send(ctx context.Context, d delivery) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Soumetsu-Webhooks")
+	if !d.Discord {
+		timestamp := time.Now().Unix()
+		req.Header.Set(HeaderEvent, string(d.Event))
+		req.Header.Set(HeaderDelivery, d.ID)
+		req.Header.Set(HeaderTimestamp, fmt.Sprint(timestamp))
+		if s.config.Security.WebhookSecret != "" {
+			req.Header.Set(HeaderSignature, Sign(s.config.Security.WebhookSecret, timestamp, d.Body))
+		}
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("webhook returned %s", resp.Status)
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusRequestTimeout:
+		return true, err
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return false, err
+	}
+	return true, err
+}
+
+// backoff is how long to wait before the given attempt, doubling each time.
+func backoff(attempt int) time.Duration {
+	wait := baseBackoff << (attempt - 1)
+	if wait > maxBackoff || wait <= 0 {
+		return maxBackoff
+	}
+	return wait
+}
@@ -0,0 +1,223 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/RealistikOsu/soumetsu/internal/config"
+)
+
+func newTestService(secret string) *Service {
+	cfg := config.NewTestConfig()
+	cfg.Security.WebhookSecret = secret
+	return &Service{
+		config:   cfg,
+		client:   &http.Client{Timeout: time.Second},
+		inFlight: make(map[string]int),
+		done:     make(chan struct{}),
+	}
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":"abc"}`)
+	sig := Sign("secret", 1700000000, body)
+
+	if sig != Sign("secret", 1700000000, body) {
+		t.Error("Sign isn't deterministic")
+	}
+	if len(sig) != len(signaturePrefix)+64 || sig[:len(signaturePrefix)] != signaturePrefix {
+		t.Errorf("Sign = %q, want %s and 64 hex digits", sig, signaturePrefix)
+	}
+	if !Verify("secret", 1700000000, body, sig) {
+		t.Error("Verify rejected a valid signature")
+	}
+
+	for name, other := range map[string]string{
+		"secret":    Sign("other", 1700000000, body),
+		"timestamp": Sign("secret", 1700000001, body),
+		"body":      Sign("secret", 1700000000, []byte(`{"id":"abd"}`)),
+	} {
+		if other == sig {
+			t.Errorf("changing the %s didn't change the signature", name)
+		}
+		if Verify("secret", 1700000000, body, other) {
+			t.Errorf("Verify accepted a signature with a different %s", name)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for _, tc := range []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 15 * time.Second},
+		{2, 30 * time.Second},
+		{3, time.Minute},
+		{7, 16 * time.Minute},
+		{9, time.Hour},
+		{64, time.Hour},
+	} {
+		if got := backoff(tc.attempt); got != tc.want {
+			t.Errorf("backoff(%d) = %s, want %s", tc.attempt, got, tc.want)
+		}
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	for _, tc := range []struct {
+		status int
+		retry  bool
+		fails  bool
+	}{
+		{http.StatusOK, false, false},
+		{http.StatusNoContent, false, false},
+		{http.StatusBadRequest, false, true},
+		{http.StatusNotFound, false, true},
+		{http.StatusRequestTimeout, true, true},
+		{http.StatusTooManyRequests, true, true},
+		{http.StatusInternalServerError, true, true},
+		{http.StatusBadGateway, true, true},
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.status)
+		}))
+		retry, err := newTestService("").send(context.Background(), delivery{ID: "d", URL: srv.URL, Body: []byte("{}")})
+		srv.Close()
+
+		if retry != tc.retry || (err != nil) != tc.fails {
+			t.Errorf("status %d: retry %v, err %v; want retry %v, failure %v", tc.status, retry, err, tc.retry, tc.fails)
+		}
+	}
+}
+
+func TestSendUnreachableRetries(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	retry, err := newTestService("").send(context.Background(), delivery{ID: "d", URL: srv.URL, Body: []byte("{}")})
+	if err == nil || !retry {
+		t.Errorf("retry %v, err %v; want a retryable error", retry, err)
+	}
+}
+
+func TestSendSignsJSON(t *testing.T) {
+	var got *http.Request
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	body := []byte(`{"id":"abc"}`)
+	d := delivery{ID: "abc-0", Event: EventUserBanned, URL: srv.URL, Body: body}
+	if _, err := newTestService("secret").send(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(gotBody) != string(body) {
+		t.Errorf("body = %s, want %s", gotBody, body)
+	}
+	if h := got.Header.Get(HeaderEvent); h != string(EventUserBanned) {
+		t.Errorf("%s = %q", HeaderEvent, h)
+	}
+	if h := got.Header.Get(HeaderDelivery); h != "abc-0" {
+		t.Errorf("%s = %q", HeaderDelivery, h)
+	}
+	timestamp, err := strconv.ParseInt(got.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		t.Fatalf("%s: %v", HeaderTimestamp, err)
+	}
+	if !Verify("secret", timestamp, gotBody, got.Header.Get(HeaderSignature)) {
+		t.Errorf("%s doesn't verify", HeaderSignature)
+	}
+}
+
+func TestSendLeavesDiscordUnsigned(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	d := delivery{ID: "abc-0", Event: EventUserBanned, URL: srv.URL, Discord: true, Body: []byte("{}")}
+	if _, err := newTestService("secret").send(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range []string{HeaderEvent, HeaderDelivery, HeaderTimestamp, HeaderSignature} {
+		if v := got.Get(h); v != "" {
+			t.Errorf("Discord delivery sent %s: %q", h, v)
+		}
+	}
+}
+
+func TestDeliveriesSplitDiscordAndJSON(t *testing.T) {
+	s := newTestService("")
+	e := Event{ID: "abc", Type: EventReportCreated, Title: "New report", URL: "/admin/reports/1"}
+	urls := []string{
+		"https://discord.com/api/webhooks/1/token",
+		"https://example.com/hook",
+		"https://discord.com/not-a-webhook",
+	}
+
+	deliveries, err := s.deliveries(e, urls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != len(urls) {
+		t.Fatalf("got %d deliveries, want %d", len(deliveries), len(urls))
+	}
+
+	for i, d := range deliveries {
+		if d.URL != urls[i] || d.ID != "abc-"+strconv.Itoa(i) || d.Event != EventReportCreated {
+			t.Errorf("delivery %d = %+v", i, d)
+		}
+		var body map[string]any
+		if err := json.Unmarshal(d.Body, &body); err != nil {
+			t.Fatalf("delivery %d: %v", i, err)
+		}
+		_, embed := body["embeds"]
+		if d.Discord != (i == 0) || embed != d.Discord {
+			t.Errorf("delivery %d: Discord %v, has embeds %v", i, d.Discord, embed)
+		}
+	}
+
+	var payload Payload
+	if err := json.Unmarshal(deliveries[1].Body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.URL != "http://localhost:2018/admin/reports/1" {
+		t.Errorf("payload URL = %q, want it resolved against the base URL", payload.URL)
+	}
+	if payload.OccurredAt == 0 {
+		t.Error("payload has no occurred_at")
+	}
+}
+
+func TestReserveLimitsPerURL(t *testing.T) {
+	s := newTestService("")
+	for i := 0; i < maxInFlightPerURL; i++ {
+		if !s.reserve("https://slow.example") {
+			t.Fatalf("reserve %d refused", i)
+		}
+	}
+	if s.reserve("https://slow.example") {
+		t.Error("reserve allowed more than maxInFlightPerURL to one URL")
+	}
+	if !s.reserve("https://fast.example") {
+		t.Error("a busy URL blocked another one")
+	}
+
+	s.release("https://slow.example")
+	if !s.reserve("https://slow.example") {
+		t.Error("release didn't free a slot")
+	}
+}
@@ -0,0 +1,35 @@
+-- Player, score, userpage and clan reports plus the staff triage trail.
+
+CREATE TABLE IF NOT EXISTS reports (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	reporter_id INT NOT NULL,
+	target_type VARCHAR(16) NOT NULL,
+	target_id BIGINT NOT NULL,
+	category VARCHAR(32) NOT NULL,
+	reason TEXT NOT NULL,
+	evidence TEXT NOT NULL,
+	status VARCHAR(16) NOT NULL DEFAULT 'open',
+	assignee_id INT NOT NULL DEFAULT 0,
+	resolution VARCHAR(32) NOT NULL DEFAULT '',
+	resolution_message TEXT NOT NULL,
+	created_at INT NOT NULL,
+	updated_at INT NOT NULL,
+	closed_at INT NOT NULL DEFAULT 0,
+	PRIMARY KEY (id),
+	KEY idx_reports_status (status, created_at),
+	KEY idx_reports_reporter (reporter_id, created_at),
+	KEY idx_reports_target (target_type, target_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Internal notes are only ever shown to staff. Events (assignment, status
+-- changes, closing) are stored alongside them so the report has a timeline.
+CREATE TABLE IF NOT EXISTS report_notes (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	report_id INT UNSIGNED NOT NULL,
+	author_id INT NOT NULL,
+	content TEXT NOT NULL,
+	is_event TINYINT(1) NOT NULL DEFAULT 0,
+	created_at INT NOT NULL,
+	PRIMARY KEY (id),
+	KEY idx_report_notes_report (report_id, created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,22 @@
+-- Rank requests submitted from /beatmaps/rank-request and reviewed by BATs.
+-- `beatmaps` is a JSON snapshot of the set's difficulties taken at submission
+-- so listing the queue doesn't need a mirror lookup per request.
+
+CREATE TABLE IF NOT EXISTS beatmap_rank_requests (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	user_id INT NOT NULL,
+	request_type CHAR(1) NOT NULL,
+	beatmap_id INT NOT NULL DEFAULT 0,
+	beatmapset_id INT NOT NULL,
+	song_name VARCHAR(255) NOT NULL,
+	beatmaps TEXT NOT NULL,
+	status VARCHAR(16) NOT NULL DEFAULT 'pending',
+	reason TEXT NOT NULL,
+	reviewer_id INT NOT NULL DEFAULT 0,
+	requested_at INT NOT NULL,
+	reviewed_at INT NOT NULL DEFAULT 0,
+	PRIMARY KEY (id),
+	KEY idx_rank_requests_status (status, requested_at),
+	KEY idx_rank_requests_user (user_id, requested_at),
+	KEY idx_rank_requests_set (beatmapset_id, status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,17 @@
+-- Audit trail for ranked status changes made from the admin beatmap console,
+-- one row per difficulty changed.
+
+CREATE TABLE IF NOT EXISTS beatmap_ranking_log (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	beatmap_id INT NOT NULL,
+	beatmapset_id INT NOT NULL,
+	song_name VARCHAR(255) NOT NULL,
+	old_status TINYINT NOT NULL,
+	new_status TINYINT NOT NULL,
+	reason TEXT NOT NULL,
+	user_id INT NOT NULL,
+	created_at INT NOT NULL,
+	PRIMARY KEY (id),
+	KEY idx_ranking_log_set (beatmapset_id, created_at),
+	KEY idx_ranking_log_created (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,21 @@
+-- Staff announcements shown as banners across the site. `audience` picks who
+-- sees them; `privileges` is the mask required for the 'privileges' audience.
+-- An ends_at of 0 keeps the announcement up until it is removed.
+
+CREATE TABLE IF NOT EXISTS announcements (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	title VARCHAR(255) NOT NULL,
+	body TEXT NOT NULL,
+	audience VARCHAR(16) NOT NULL DEFAULT 'everyone',
+	privileges BIGINT NOT NULL DEFAULT 0,
+	starts_at INT NOT NULL,
+	ends_at INT NOT NULL DEFAULT 0,
+	dismissible TINYINT(1) NOT NULL DEFAULT 1,
+	push_ingame TINYINT(1) NOT NULL DEFAULT 0,
+	pushed_at INT NOT NULL DEFAULT 0,
+	created_by INT NOT NULL,
+	created_at INT NOT NULL,
+	updated_at INT NOT NULL,
+	PRIMARY KEY (id),
+	KEY idx_announcements_window (starts_at, ends_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,28 @@
+-- Staff-editable documentation pages. Every save adds a revision; the page
+-- shows published_revision_id, so anything newer is a draft. A page with no
+-- published revision isn't visible to the public.
+
+CREATE TABLE IF NOT EXISTS doc_pages (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	slug VARCHAR(64) NOT NULL,
+	published_revision_id INT UNSIGNED NOT NULL DEFAULT 0,
+	created_at INT NOT NULL,
+	updated_at INT NOT NULL,
+	PRIMARY KEY (id),
+	UNIQUE KEY uniq_doc_pages_slug (slug)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- `format` is 'markdown' for pages written here and 'html' for pages imported
+-- from the old template files.
+CREATE TABLE IF NOT EXISTS doc_revisions (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	page_id INT UNSIGNED NOT NULL,
+	title VARCHAR(255) NOT NULL,
+	body MEDIUMTEXT NOT NULL,
+	format VARCHAR(16) NOT NULL DEFAULT 'markdown',
+	summary VARCHAR(255) NOT NULL DEFAULT '',
+	author_id INT NOT NULL,
+	created_at INT NOT NULL,
+	PRIMARY KEY (id),
+	KEY idx_doc_revisions_page (page_id, id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
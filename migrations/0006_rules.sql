@@ -0,0 +1,21 @@
+-- Versions of the rules that users have to accept. A version pins the docs
+-- revision of the rules page that was live when staff marked a change as
+-- material; minor edits to the page don't create one.
+
+CREATE TABLE IF NOT EXISTS rules_versions (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	revision_id INT UNSIGNED NOT NULL,
+	summary VARCHAR(255) NOT NULL DEFAULT '',
+	created_by INT NOT NULL,
+	created_at INT NOT NULL,
+	PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS rules_acceptances (
+	user_id INT NOT NULL,
+	version_id INT UNSIGNED NOT NULL,
+	accepted_at INT NOT NULL,
+	ip VARCHAR(45) NOT NULL DEFAULT '',
+	PRIMARY KEY (user_id, version_id),
+	KEY idx_rules_acceptances_version (version_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
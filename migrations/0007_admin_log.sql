@@ -0,0 +1,14 @@
+-- Actions staff take against users and clans from the admin panel, shown in
+-- the moderation history of whatever they targeted.
+
+CREATE TABLE IF NOT EXISTS admin_log (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	staff_id INT NOT NULL,
+	target_type VARCHAR(16) NOT NULL,
+	target_id INT NOT NULL,
+	action VARCHAR(32) NOT NULL,
+	detail TEXT NOT NULL,
+	created_at INT NOT NULL,
+	PRIMARY KEY (id),
+	KEY idx_admin_log_target (target_type, target_id, created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,28 @@
+-- Keys for invite-only registration. Staff keys may be shared and used several
+-- times; personal invites belong to the user who generated them and are used
+-- once. Ripple's old beta_keys table is left alone.
+
+CREATE TABLE IF NOT EXISTS registration_keys (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	`key` VARCHAR(32) NOT NULL,
+	max_uses INT NOT NULL DEFAULT 1,
+	uses INT NOT NULL DEFAULT 0,
+	expires_at INT NOT NULL DEFAULT 0,
+	note VARCHAR(255) NOT NULL DEFAULT '',
+	created_by INT NOT NULL,
+	owner_id INT NOT NULL DEFAULT 0,
+	revoked TINYINT(1) NOT NULL DEFAULT 0,
+	created_at INT NOT NULL,
+	PRIMARY KEY (id),
+	UNIQUE KEY idx_registration_keys_key (`key`),
+	KEY idx_registration_keys_owner (owner_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS registration_key_uses (
+	user_id INT NOT NULL,
+	key_id INT UNSIGNED NOT NULL,
+	used_at INT NOT NULL,
+	ip VARCHAR(45) NOT NULL DEFAULT '',
+	PRIMARY KEY (user_id),
+	KEY idx_registration_key_uses_key (key_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
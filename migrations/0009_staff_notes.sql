@@ -0,0 +1,26 @@
+-- Private notes staff keep on users, clans and beatmap sets. Every edit is
+-- kept in staff_note_revisions, starting with the note's first version.
+
+CREATE TABLE IF NOT EXISTS staff_notes (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	target_type VARCHAR(16) NOT NULL,
+	target_id INT NOT NULL,
+	body TEXT NOT NULL,
+	pinned TINYINT(1) NOT NULL DEFAULT 0,
+	author_id INT NOT NULL,
+	editor_id INT NOT NULL,
+	created_at INT NOT NULL,
+	updated_at INT NOT NULL,
+	PRIMARY KEY (id),
+	KEY idx_staff_notes_target (target_type, target_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS staff_note_revisions (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	note_id INT UNSIGNED NOT NULL,
+	body TEXT NOT NULL,
+	editor_id INT NOT NULL,
+	created_at INT NOT NULL,
+	PRIMARY KEY (id),
+	KEY idx_staff_note_revisions_note (note_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
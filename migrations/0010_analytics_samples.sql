@@ -0,0 +1,10 @@
+-- Time series the analytics sampler records from Redis and the database.
+-- online_users is sampled every few minutes; the daily metrics (active_users,
+-- clans_max_id) keep one row per UTC day, overwritten until the day ends.
+
+CREATE TABLE IF NOT EXISTS analytics_samples (
+	metric VARCHAR(32) NOT NULL,
+	sampled_at INT NOT NULL,
+	value INT NOT NULL,
+	PRIMARY KEY (metric, sampled_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,18 @@
+-- One row per run of a scheduled job, shown on /admin/jobs. Rows are pruned
+-- by the jobs.prune_history job. A run left as 'running' belongs to an
+-- instance that stopped without finishing it.
+
+CREATE TABLE IF NOT EXISTS job_runs (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	job VARCHAR(64) NOT NULL,
+	instance VARCHAR(64) NOT NULL,
+	manual TINYINT(1) NOT NULL DEFAULT 0,
+	status VARCHAR(16) NOT NULL,
+	error TEXT NOT NULL,
+	started_at INT NOT NULL,
+	duration_ms INT NOT NULL DEFAULT 0,
+	PRIMARY KEY (id),
+	KEY idx_job_runs_job (job, id),
+	KEY idx_job_runs_status (status, id),
+	KEY idx_job_runs_started (started_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
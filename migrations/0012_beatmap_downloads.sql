@@ -0,0 +1,9 @@
+-- How many times each beatmap set has been downloaded through the site. A
+-- resumed download isn't counted again.
+
+CREATE TABLE IF NOT EXISTS beatmap_downloads (
+	beatmapset_id INT NOT NULL,
+	downloads INT UNSIGNED NOT NULL DEFAULT 0,
+	last_downloaded_at INT NOT NULL,
+	PRIMARY KEY (beatmapset_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,37 @@
+-- Beatmap sets users have favourited. The set's name is kept so listings
+-- don't have to ask the mirror about every set.
+
+CREATE TABLE IF NOT EXISTS beatmap_favourites (
+	user_id INT NOT NULL,
+	beatmapset_id INT NOT NULL,
+	song_name VARCHAR(255) NOT NULL,
+	creator VARCHAR(64) NOT NULL,
+	created_at INT NOT NULL,
+	PRIMARY KEY (user_id, beatmapset_id),
+	KEY idx_beatmap_favourites_set (beatmapset_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Named lists of difficulties users keep, which can be exported to and
+-- imported from osu!'s collection.db. Difficulties are kept by MD5 as that's
+-- all collection.db has; the IDs and name are filled in when known.
+
+CREATE TABLE IF NOT EXISTS beatmap_collections (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	user_id INT NOT NULL,
+	name VARCHAR(64) NOT NULL,
+	public TINYINT(1) NOT NULL DEFAULT 0,
+	created_at INT NOT NULL,
+	updated_at INT NOT NULL,
+	PRIMARY KEY (id),
+	UNIQUE KEY idx_beatmap_collections_name (user_id, name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS beatmap_collection_items (
+	collection_id INT UNSIGNED NOT NULL,
+	beatmap_md5 CHAR(32) NOT NULL,
+	beatmap_id INT NOT NULL DEFAULT 0,
+	beatmapset_id INT NOT NULL DEFAULT 0,
+	song_name VARCHAR(255) NOT NULL DEFAULT '',
+	added_at INT NOT NULL,
+	PRIMARY KEY (collection_id, beatmap_md5)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
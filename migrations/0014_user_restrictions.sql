@@ -0,0 +1,12 @@
+-- Restrictions staff gave with an end date. The penalties.expire job lifts
+-- them once they run out; a restriction without a row here is permanent.
+
+CREATE TABLE IF NOT EXISTS user_restrictions (
+	user_id INT NOT NULL,
+	restricted_at INT NOT NULL,
+	ends_at INT NOT NULL,
+	reason VARCHAR(255) NOT NULL DEFAULT '',
+	created_by INT NOT NULL,
+	PRIMARY KEY (user_id),
+	KEY idx_user_restrictions_ends (ends_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,48 @@
+(function () {
+  // Plays a set's preview from the search results, one at a time.
+  let current = null;
+
+  function stop() {
+    if (!current) {
+      return;
+    }
+    current.audio.pause();
+    current.button.innerHTML = '<i class="fas fa-play text-white text-2xl"></i>';
+    current.card.classList.remove('musicPlaying');
+    current.card.style.removeProperty('--progress');
+    current = null;
+  }
+
+  document.addEventListener('click', (event) => {
+    const button = event.target.closest('[data-preview]');
+    if (!button) {
+      return;
+    }
+    event.preventDefault();
+
+    const playing = current && current.button === button;
+    stop();
+    if (playing) {
+      return;
+    }
+
+    const audio = new Audio('/beatmapsets/' + button.dataset.preview + '/preview.mp3');
+    audio.volume = 0.2;
+    const card = button.closest('.beatmap-card-compact');
+    current = { audio: audio, button: button, card: card };
+
+    audio.addEventListener('timeupdate', () => {
+      if (audio.duration) {
+        card.style.setProperty('--progress', (audio.currentTime / audio.duration) * 100 + '%');
+      }
+    });
+    audio.addEventListener('ended', stop);
+    audio.play().catch((error) => {
+      console.error('Error playing audio:', error);
+      stop();
+    });
+
+    button.innerHTML = '<i class="fas fa-stop text-white text-2xl"></i>';
+    card.classList.add('musicPlaying');
+  });
+})();
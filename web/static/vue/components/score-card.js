@@ -67,7 +67,7 @@ const ScoreCardComponent = {
       const beatmapsetId = this.score.beatmap?.beatmapset_id;
       if (!beatmapsetId) return {};
       return {
-        backgroundImage: `url(https://assets.ppy.sh/beatmaps/${beatmapsetId}/covers/cover.jpg)`,
+        backgroundImage: `url(/beatmapsets/${beatmapsetId}/cover.jpg)`,
       };
     },
     rankGrade() {
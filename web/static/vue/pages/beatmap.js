@@ -128,21 +128,21 @@ const beatmapApp = Soumetsu.createApp({
       if (!this.beatmapSet?.SetID) {
         return '';
       }
-      return `https://b.ppy.sh/preview/${this.beatmapSet.SetID}.mp3`;
+      return `/beatmapsets/${this.beatmapSet.SetID}/preview.mp3`;
     },
 
     coverUrl() {
       if (!this.beatmapSet?.SetID) {
         return '';
       }
-      return `https://assets.ppy.sh/beatmaps/${this.beatmapSet.SetID}/covers/cover.jpg`;
+      return `/beatmapsets/${this.beatmapSet.SetID}/cover.jpg`;
     },
 
     thumbUrl() {
       if (!this.beatmapSet?.SetID) {
         return '';
       }
-      return `https://b.ppy.sh/thumb/${this.beatmapSet.SetID}l.jpg`;
+      return `/beatmapsets/${this.beatmapSet.SetID}/thumb.jpg`;
     },
 
     // Check if mode menu should be shown (only for std mode maps)
@@ -15,26 +15,13 @@ const rankRequestApp = Soumetsu.createApp({
       requests: [],
       requestsLoading: false,
       requestsPage: 1,
+      requestsFilter: 'pending',
       hasMoreRequests: true,
       modeNames: ['osu', 'taiko', 'fruits', 'mania'],
     };
   },
 
   computed: {
-    apiBase() {
-      return window.soumetsuConf && window.soumetsuConf.baseAPI ? window.soumetsuConf.baseAPI : '';
-    },
-
-    apiToken() {
-      return window.soumetsuConf && window.soumetsuConf.apiToken
-        ? window.soumetsuConf.apiToken
-        : '';
-    },
-
-    apiConfigured() {
-      return this.apiBase !== '';
-    },
-
     percent() {
       if (!this.status) {
         return 0;
@@ -78,22 +65,9 @@ const rankRequestApp = Soumetsu.createApp({
       this.submitMessage = '';
       this.submitOk = false;
 
-      if (!this.apiConfigured) {
-        this.load = false;
-        this.error = true;
-        this.errorMessage = 'soumetsuConf.baseAPI is not set';
-        return;
-      }
-
-      const headers = {};
-      if (this.apiToken) {
-        headers['Authorization'] = 'Bearer ' + this.apiToken;
-      }
-
       try {
-        const response = await fetch(this.apiBase + '/api/v2/beatmaps/rank-requests/status', {
-          headers: headers,
-          credentials: 'include',
+        const response = await fetch('/beatmaps/rank-request/status', {
+          credentials: 'same-origin',
         });
 
         if (!response.ok) {
@@ -118,20 +92,6 @@ const rankRequestApp = Soumetsu.createApp({
       }
     },
 
-    getErrorMessage(errorCode) {
-      const errorMessages = {
-        'beatmaps.invalid_url':
-          'Invalid beatmap URL. Please use a valid osu! or server beatmap link.',
-        'beatmaps.beatmap_not_found': 'Beatmap not found. Make sure the beatmap exists.',
-        'beatmaps.already_requested': 'This beatmap has already been requested.',
-        'beatmaps.already_ranked': 'This beatmap is already ranked.',
-        'beatmaps.daily_limit_reached':
-          'You have reached your daily limit for requesting beatmaps.',
-        'auth.unauthenticated': 'You must be logged in to submit rank requests.',
-      };
-      return errorMessages[errorCode] || null;
-    },
-
     async submitBeatmap() {
       this.submitMessage = '';
       this.submitOk = false;
@@ -142,44 +102,33 @@ const rankRequestApp = Soumetsu.createApp({
         return;
       }
 
-      if (!this.apiConfigured) {
-        this.submitMessage = 'Submission is unavailable: API is not configured.';
-        return;
-      }
-
       if (this.status && this.status.can_submit === false) {
         this.submitMessage = 'You have reached your daily limit for requesting beatmaps.';
         return;
       }
 
-      const submitUrl = this.apiBase + '/api/v2/beatmaps/rank-requests';
-
       this.submitting = true;
 
       const headers = {
         'Content-Type': 'application/json',
       };
-      if (this.apiToken) {
-        headers['Authorization'] = 'Bearer ' + this.apiToken;
+      const csrfMeta = document.querySelector('meta[name="csrf-token"]');
+      if (csrfMeta) {
+        headers['X-CSRF-Token'] = csrfMeta.getAttribute('content');
       }
 
       try {
-        const response = await fetch(submitUrl, {
+        const response = await fetch('/beatmaps/rank-request', {
           method: 'POST',
           headers: headers,
-          credentials: 'include',
+          credentials: 'same-origin',
           body: JSON.stringify({ url: url }),
         });
 
         const data = await response.json();
 
         if (!response.ok) {
-          const errorCode = data.data || data.error || null;
-          const friendlyMessage = this.getErrorMessage(errorCode);
-          if (friendlyMessage) {
-            throw new Error(friendlyMessage);
-          }
-          throw new Error('Request failed (HTTP ' + response.status + ')');
+          throw new Error(data.message || 'Request failed (HTTP ' + response.status + ')');
         }
 
         this.submitOk = true;
@@ -198,10 +147,6 @@ const rankRequestApp = Soumetsu.createApp({
     },
 
     async fetchRequests(reset = true) {
-      if (!this.apiConfigured) {
-        return;
-      }
-
       if (reset) {
         this.requests = [];
         this.requestsPage = 1;
@@ -210,17 +155,11 @@ const rankRequestApp = Soumetsu.createApp({
 
       this.requestsLoading = true;
 
-      const headers = {};
-      if (this.apiToken) {
-        headers['Authorization'] = 'Bearer ' + this.apiToken;
-      }
-
       try {
         const response = await fetch(
-          this.apiBase + '/api/v2/beatmaps/rank-requests?page=' + this.requestsPage + '&limit=20',
+          '/beatmaps/rank-request/requests?status=' + this.requestsFilter + '&page=' + this.requestsPage + '&limit=20',
           {
-            headers: headers,
-            credentials: 'include',
+            credentials: 'same-origin',
           }
         );
 
@@ -244,6 +183,27 @@ const rankRequestApp = Soumetsu.createApp({
       }
     },
 
+    statusLabel(status) {
+      switch (status) {
+        case 'ranked':
+          return 'Ranked';
+        case 'loved':
+          return 'Loved';
+        case 'denied':
+          return 'Denied';
+        default:
+          return 'Pending';
+      }
+    },
+
+    setRequestsFilter(filter) {
+      if (this.requestsFilter === filter) {
+        return;
+      }
+      this.requestsFilter = filter;
+      this.fetchRequests(true);
+    },
+
     loadMoreRequests() {
       if (this.requestsLoading || !this.hasMoreRequests) {
         return;
@@ -479,6 +479,7 @@ func FuncMap(csrfService CSRFService, db *sqlx.DB) template.FuncMap {
 			return fmt.Sprintf("%02dh %02dm", int(math.Floor(seconds/3600)), int(math.Floor(seconds/60))%60)
 		},
 		"stringLower": strings.ToLower,
+		"hasPrefix":   strings.HasPrefix,
 		// domain extracts the host portion of a URL. Used for rendering
 		// `-devserver <domain>` instructions when only a full BaseURL is in config.
 		"domain": func(rawURL string) string {